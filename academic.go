@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// Categories of the events of the combined calendar
+const (
+	categoryLesson  = "Lezione"
+	categoryExams   = "Sessione d'esame"
+	categoryHoliday = "Festività"
+)
+
+// academicEntry is a period of the academic calendar: an exam session or a
+// holiday. Dates are in the YYYY-MM-DD format, End included.
+type academicEntry struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	// Campus limits the entry to the courses of a campus, empty for every
+	// course
+	Campus string `json:"campus,omitempty"`
+}
+
+// academicCalendar are the exam sessions and the closures of the university
+// published by Unibo, which are not available as open data, so they are
+// read from config.AcademicCalendarFile. It is replaced as a whole when
+// reloaded, while the requests read it, see academicEntries.
+var academicCalendar atomic.Pointer[[]academicEntry]
+
+// academicEntries returns the entries of the academic calendar, which must
+// not be modified.
+func academicEntries() []academicEntry {
+	if entries := academicCalendar.Load(); entries != nil {
+		return *entries
+	}
+	return nil
+}
+
+// loadAcademicCalendar reads the academic calendar from its file. A missing
+// file leaves only the national holidays. Entries without a category are
+// exam sessions.
+func loadAcademicCalendar() {
+	var entries []academicEntry
+	err := loadJsonFile(config.AcademicCalendarFile, &entries)
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to load academic calendar")
+		return
+	}
+	for i := range entries {
+		if entries[i].Category == "" {
+			entries[i].Category = categoryExams
+		}
+	}
+	academicCalendar.Store(&entries)
+}
+
+// academicCalendarVersion returns a hash of the academic calendar, which
+// changes when it is reloaded with different entries.
+func academicCalendarVersion() string {
+	data, _ := json.Marshal(academicEntries())
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
+// patronSaints are the holidays of the towns of the campuses.
+var patronSaints = map[string]struct {
+	Name  string
+	Month time.Month
+	Day   int
+}{
+	"Bologna": {"San Petronio", time.October, 4},
+	"Cesena":  {"San Giovanni Battista", time.June, 24},
+	"Forlì":   {"Madonna del Fuoco", time.February, 4},
+	"Ravenna": {"Sant'Apollinare", time.July, 23},
+	"Rimini":  {"San Gaudenzo", time.October, 14},
+}
+
+// easter returns the date of Easter of the year (anonymous Gregorian
+// algorithm).
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// nationalHolidays returns the Italian holidays of the year, and the one of
+// the patron saint of the campus.
+func nationalHolidays(year int, campus string) []academicEntry {
+	day := func(month time.Month, d int) string {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+	}
+	holiday := func(name string, date string) academicEntry {
+		return academicEntry{Name: name, Category: categoryHoliday, Start: date, End: date}
+	}
+
+	holidays := []academicEntry{
+		holiday("Capodanno", day(time.January, 1)),
+		holiday("Epifania", day(time.January, 6)),
+		holiday("Lunedì dell'Angelo", easter(year).AddDate(0, 0, 1).Format(time.DateOnly)),
+		holiday("Festa della Liberazione", day(time.April, 25)),
+		holiday("Festa dei Lavoratori", day(time.May, 1)),
+		holiday("Festa della Repubblica", day(time.June, 2)),
+		holiday("Ferragosto", day(time.August, 15)),
+		holiday("Ognissanti", day(time.November, 1)),
+		holiday("Immacolata Concezione", day(time.December, 8)),
+		holiday("Natale", day(time.December, 25)),
+		holiday("Santo Stefano", day(time.December, 26)),
+	}
+	if saint, found := patronSaints[campus]; found {
+		holidays = append(holidays, holiday(saint.Name, day(saint.Month, saint.Day)))
+	}
+	return holidays
+}
+
+// academicYear returns the first and last day of the academic year (from
+// September to September) of the first lesson of t.
+func academicYear(t timetable.Timetable) (time.Time, time.Time, bool) {
+	if len(t) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	first := slices.MinFunc(t, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	}).Start
+
+	year := first.Year()
+	if first.Month() < time.September {
+		year--
+	}
+	return time.Date(year, time.September, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(year+1, time.September, 30, 0, 0, 0, 0, time.UTC), true
+}
+
+// academicEvents returns the entries of the academic calendar of the course
+// in the academic year of the timetable, sorted by start.
+func academicEvents(course *unibo_integ.Course, t timetable.Timetable) []academicEntry {
+	from, to, ok := academicYear(t)
+	if !ok {
+		return nil
+	}
+
+	candidates := slices.Concat(academicEntries(), nationalHolidays(from.Year(), course.Campus), nationalHolidays(to.Year(), course.Campus))
+
+	var entries []academicEntry
+	for _, e := range candidates {
+		start, err := time.Parse(time.DateOnly, e.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.DateOnly, e.End)
+		if err != nil || end.Before(start) {
+			continue
+		}
+		if e.Category == categoryTeaching || (e.Campus != "" && e.Campus != course.Campus) || end.Before(from) || start.After(to) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	slices.SortStableFunc(entries, func(a, b academicEntry) int {
+		return strings.Compare(a.Start, b.Start)
+	})
+	return entries
+}
+
+// addAcademicEvents adds the entries to the calendar as all-day events,
+// which don't make the user busy.
+func addAcademicEvents(cal *ics.Calendar, entries []academicEntry) {
+	for _, entry := range entries {
+		start, _ := time.Parse(time.DateOnly, entry.Start)
+		end, _ := time.Parse(time.DateOnly, entry.End)
+
+		uid := fmt.Sprintf("%x", sha1.Sum([]byte(entry.Category+entry.Name+entry.Start+entry.Campus)))
+		e := cal.AddEvent(uid)
+		e.SetSummary(entry.Name)
+		e.SetAllDayStartAt(start)
+		// DTEND of all-day events is exclusive
+		e.SetAllDayEndAt(end.AddDate(0, 0, 1))
+		e.SetDtStampTime(time.Now())
+		e.SetTimeTransparency(ics.TransparencyTransparent)
+		e.AddCategory(entry.Category)
+	}
+}
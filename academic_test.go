@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_easter(t *testing.T) {
+	assert.Equal(t, time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), easter(2024))
+	assert.Equal(t, time.Date(2025, time.April, 20, 0, 0, 0, 0, time.UTC), easter(2025))
+}
+
+func Test_academicEvents(t *testing.T) {
+	academicCalendar.Store(&[]academicEntry{
+		{Name: "Sessione invernale", Category: categoryExams, Start: "2025-01-07", End: "2025-02-28"},
+		{Name: "Sessione di Cesena", Category: categoryExams, Start: "2025-01-08", End: "2025-01-09", Campus: "Cesena"},
+		{Name: "Anno passato", Category: categoryExams, Start: "2023-01-07", End: "2023-02-28"},
+	})
+	defer academicCalendar.Store(nil)
+
+	course := testCourse(8009)
+	entries := academicEvents(&course, testTimetable())
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	// The academic year of lessons in October 2024 goes from September 2024
+	// to September 2025
+	assert.Equal(t, []string{
+		"San Petronio", "Ognissanti", "Immacolata Concezione", "Natale", "Santo Stefano", "Capodanno", "Epifania",
+		"Sessione invernale", "Lunedì dell'Angelo", "Festa della Liberazione", "Festa dei Lavoratori",
+		"Festa della Repubblica", "Ferragosto",
+	}, names)
+
+	cal, err := createCal(testTimetable(), &course, 1, calOptions{Combined: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := cal.Serialize()
+	assert.Equal(t, true, strings.Contains(data, "CATEGORIES:Lezione\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "DTSTART;VALUE=DATE:20250107\r\nDTEND;VALUE=DATE:20250301\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "CATEGORIES:Festività\r\n"))
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+}
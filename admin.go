@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// Actions of the audit log
+const (
+	actionCachePurge = "cache.purge"
+	actionDataReload = "data.reload"
+	actionFlagSet    = "flag.set"
+)
+
+// adminPurgeCache empties the caches of the feeds and of the timetables, so
+// that they are fetched again from the upstream.
+func adminPurgeCache(c *gin.Context) {
+	feeds := calcache.Purge()
+	timetables := timetableCache.Purge()
+
+	detail := fmt.Sprintf("%d feeds, %d timetables", feeds, timetables)
+	audit(c, actionCachePurge, "", detail, nil)
+	c.JSON(http.StatusOK, gin.H{"feeds": feeds, "timetables": timetables})
+}
+
+// adminReloadData reads again the courses, the academic calendar and the
+// university events from their files. The courses are replaced only if the
+// file is valid, see validateCourses.
+func adminReloadData(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		err := reloadData(courses)
+		if err != nil {
+			audit(c, actionDataReload, "", "", err)
+			_ = c.Error(err)
+			writeProblem(c, http.StatusInternalServerError, codeInternal, fmt.Sprintf("Unable to reload data: %s", err))
+			return
+		}
+
+		n := courses.Load().Len()
+		audit(c, actionDataReload, "", fmt.Sprintf("%d courses", n), nil)
+		c.JSON(http.StatusOK, gin.H{"courses": n})
+	}
+}
+
+func reloadData(courses *unibo_integ.Courses) error {
+	next, err := openData()
+	if err != nil {
+		return fmt.Errorf("unable to read courses: %w", err)
+	}
+	err = validateCourses(next.ToList())
+	if err != nil {
+		return fmt.Errorf("invalid courses: %w", err)
+	}
+
+	err = uniboEvents.load(config.EventsFile)
+	if err != nil {
+		return err
+	}
+	loadAcademicCalendar()
+	courses.Swap(next)
+	return nil
+}
+
+// adminSetFlag changes a feature flag, given as JSON in the body (see Flag),
+// and saves the flags to [Config.FeatureFlagsFile], so that the change
+// survives restarts.
+func adminSetFlag(c *gin.Context) {
+	name := c.Param("name")
+
+	var flag Flag
+	err := c.ShouldBindJSON(&flag)
+	if err != nil || (flag.Rollout != nil && (*flag.Rollout < 0 || *flag.Rollout > 100)) {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "The body must be a flag, with a rollout between 0 and 100")
+		return
+	}
+
+	err = featureFlags.Update(config.FeatureFlagsFile, name, flag)
+	detail := fmt.Sprintf("enabled=%t", flag.Enabled)
+	if flag.Rollout != nil {
+		detail += fmt.Sprintf(" rollout=%d", *flag.Rollout)
+	}
+	audit(c, actionFlagSet, name, detail, err)
+	if err != nil {
+		_ = c.Error(err)
+		writeProblem(c, http.StatusInternalServerError, codeInternal, "Unable to save the feature flags")
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// setupAdmin registers the admin endpoints, only for the admins (see
+// requireAdmin). The actions are recorded in the audit log, see auditLog.
+func setupAdmin(r *gin.Engine, courses *unibo_integ.Courses) {
+	if adminOidcEnabled() {
+		r.GET("/admin/login", adminLogin)
+		r.GET("/admin/callback", adminCallback)
+	}
+	r.GET("/metrics", requireAdmin, metricsHandler)
+	setupPprof(r)
+
+	admin := r.Group("/admin", requireAdmin)
+	admin.GET("/opendata", adminOpenData)
+	admin.GET("/status", adminStatus)
+	admin.GET("/audit", adminAudit)
+	admin.POST("/cache/purge", adminPurgeCache)
+	admin.POST("/reload", adminReloadData(courses))
+	admin.PUT("/flags/:name", adminSetFlag)
+}
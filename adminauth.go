@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// The admin surface (/admin, /metrics and /debug/pprof) must not be public,
+// so every request must be authenticated by one of the configured methods:
+// basic auth, an OIDC session or a header set by a trusted reverse proxy.
+// Without any of them every request is refused, unless
+// [Config.AdminAllowLocalhost] allows the ones from the host itself.
+
+const (
+	adminSessionCookie = "admin_session"
+	adminSessionTTL    = time.Hour * 12
+	adminRealm         = `Basic realm="UniboCalendar admin", charset="UTF-8"`
+)
+
+// adminSessionKey signs the session cookies. A random one invalidates the
+// sessions at every restart.
+var adminSessionKey = func() []byte {
+	if config.AdminSessionSecret != "" {
+		return []byte(config.AdminSessionSecret)
+	}
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+func adminBasicEnabled() bool {
+	return len(config.AdminUsers) > 0
+}
+
+func adminOidcEnabled() bool {
+	return config.AdminOidcIssuer != "" && config.AdminOidcClientId != "" && config.AdminOidcRedirectUrl != ""
+}
+
+func adminProxyEnabled() bool {
+	return config.AdminProxyHeader != ""
+}
+
+// basicAdmin returns the user of the basic auth credentials of the request,
+// if they match one of [Config.AdminUsers] ("user:password").
+func basicAdmin(c *gin.Context) (string, bool) {
+	user, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	found := false
+	for _, entry := range config.AdminUsers {
+		u, p, _ := strings.Cut(entry, ":")
+		// Every entry is checked, so the time doesn't tell which user exists
+		if subtle.ConstantTimeCompare([]byte(u+":"+p), []byte(user+":"+password)) == 1 {
+			found = true
+		}
+	}
+	return user, found
+}
+
+// proxyAdmin returns the user set by the reverse proxy in
+// [Config.AdminProxyHeader], trusted only if the request comes from one of
+// [Config.AdminProxyIps].
+func proxyAdmin(c *gin.Context) (string, bool) {
+	user := c.GetHeader(config.AdminProxyHeader)
+	if user == "" {
+		return "", false
+	}
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	return user, slices.Contains(config.AdminProxyIps, host)
+}
+
+// localRequest reports whether the request comes from the host itself, and
+// not from a reverse proxy on the host (see trustedProxy): the proxied
+// requests come from loopback too, but on behalf of anyone.
+func localRequest(c *gin.Context) bool {
+	for _, header := range []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"} {
+		if c.GetHeader(header) != "" {
+			return false
+		}
+	}
+
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback() && !trustedProxy(c.Request.RemoteAddr)
+}
+
+// signAdminSession returns the value of the session cookie of the admin,
+// valid until the given time.
+func signAdminSession(admin string, until time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(admin + "|" + strconv.FormatInt(until.Unix(), 10)))
+	mac := hmac.New(sha256.New, adminSessionKey)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyAdminSession returns the admin of a session cookie, if it's valid
+// and not expired.
+func verifyAdminSession(value string, now time.Time) (string, bool) {
+	payload, sig, found := strings.Cut(value, ".")
+	if !found {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, adminSessionKey)
+	mac.Write([]byte(payload))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+	admin, rawUntil, _ := strings.Cut(string(data), "|")
+	until, err := strconv.ParseInt(rawUntil, 10, 64)
+	if err != nil || now.Unix() > until {
+		return "", false
+	}
+	return admin, true
+}
+
+// requireAdmin lets through only the authenticated admins, whose name is
+// saved for the audit log (see auditActor).
+func requireAdmin(c *gin.Context) {
+	if user, ok := basicAdmin(c); ok && adminBasicEnabled() {
+		c.Set(auditActorKey, "basic:"+user)
+		return
+	}
+	if cookie, err := c.Cookie(adminSessionCookie); err == nil && adminOidcEnabled() {
+		if email, ok := verifyAdminSession(cookie, time.Now()); ok {
+			c.Set(auditActorKey, "oidc:"+email)
+			return
+		}
+	}
+	if user, ok := proxyAdmin(c); ok && adminProxyEnabled() {
+		c.Set(auditActorKey, "proxy:"+user)
+		return
+	}
+	if config.AdminAllowLocalhost && !adminBasicEnabled() && !adminOidcEnabled() && !adminProxyEnabled() && localRequest(c) {
+		c.Set(auditActorKey, "local")
+		return
+	}
+
+	if adminOidcEnabled() && wantsHtml(c) {
+		c.Redirect(http.StatusFound, appUrl("/admin/login"))
+		c.Abort()
+		return
+	}
+	if adminBasicEnabled() {
+		c.Header("WWW-Authenticate", adminRealm)
+	}
+	writeProblem(c, http.StatusUnauthorized, codeUnauthorized, "Admin authentication required")
+	c.Abort()
+}
+
+// oidcProvider are the endpoints of the OIDC provider, from its discovery
+// document.
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+var (
+	adminOidcMu     sync.Mutex
+	adminOidcConfig *oauth2.Config
+	adminOidc       oidcProvider
+)
+
+// adminOAuth returns the OAuth client of the OIDC login, discovering the
+// endpoints of [Config.AdminOidcIssuer] the first time.
+func adminOAuth(ctx context.Context) (*oauth2.Config, oidcProvider, error) {
+	adminOidcMu.Lock()
+	defer adminOidcMu.Unlock()
+	if adminOidcConfig != nil {
+		return adminOidcConfig, adminOidc, nil
+	}
+
+	discovery := strings.TrimSuffix(config.AdminOidcIssuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery, nil)
+	if err != nil {
+		return nil, oidcProvider{}, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, oidcProvider{}, fmt.Errorf("unable to fetch the OIDC discovery document: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, oidcProvider{}, fmt.Errorf("unable to fetch the OIDC discovery document: status %d", res.StatusCode)
+	}
+
+	var provider oidcProvider
+	err = json.NewDecoder(res.Body).Decode(&provider)
+	if err != nil || provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.UserinfoEndpoint == "" {
+		return nil, oidcProvider{}, fmt.Errorf("invalid OIDC discovery document: %v", err)
+	}
+
+	adminOidc = provider
+	adminOidcConfig = &oauth2.Config{
+		ClientID:     config.AdminOidcClientId,
+		ClientSecret: config.AdminOidcClientSecret,
+		RedirectURL:  config.AdminOidcRedirectUrl,
+		Endpoint:     oauth2.Endpoint{AuthURL: provider.AuthorizationEndpoint, TokenURL: provider.TokenEndpoint},
+		Scopes:       []string{"openid", "email"},
+	}
+	return adminOidcConfig, adminOidc, nil
+}
+
+// adminOidcStates are the OAuth states of the logins in progress.
+var adminOidcStates = cache.New(time.Minute*10, time.Minute*20)
+
+// adminLogin redirects to the OIDC provider.
+func adminLogin(c *gin.Context) {
+	oauth, _, err := adminOAuth(c)
+	if err != nil {
+		_ = c.Error(err)
+		errorPage(c, http.StatusBadGateway, "Impossibile contattare il provider di autenticazione")
+		return
+	}
+
+	state := randomId()
+	adminOidcStates.Set(state, true, cache.DefaultExpiration)
+	c.Redirect(http.StatusFound, oauth.AuthCodeURL(state))
+}
+
+// adminCallback completes the OIDC login: the admin is identified by the
+// verified email of the userinfo endpoint, which must be one of
+// [Config.AdminOidcEmails].
+func adminCallback(c *gin.Context) {
+	state := c.Query("state")
+	if _, found := adminOidcStates.Get(state); !found {
+		errorPage(c, http.StatusBadRequest, "Autenticazione non valida o scaduta, riprova")
+		return
+	}
+	adminOidcStates.Delete(state)
+
+	oauth, provider, err := adminOAuth(c)
+	if err != nil {
+		_ = c.Error(err)
+		errorPage(c, http.StatusBadGateway, "Impossibile contattare il provider di autenticazione")
+		return
+	}
+	token, err := oauth.Exchange(c, c.Query("code"))
+	if err != nil {
+		_ = c.Error(err)
+		errorPage(c, http.StatusBadGateway, "Impossibile completare l'autenticazione")
+		return
+	}
+
+	res, err := oauth.Client(c, token).Get(provider.UserinfoEndpoint)
+	if err != nil {
+		_ = c.Error(err)
+		errorPage(c, http.StatusBadGateway, "Impossibile completare l'autenticazione")
+		return
+	}
+	defer res.Body.Close()
+
+	var info struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&info)
+	if err != nil || res.StatusCode != http.StatusOK {
+		_ = c.Error(fmt.Errorf("invalid userinfo response, status %d: %v", res.StatusCode, err))
+		errorPage(c, http.StatusBadGateway, "Impossibile completare l'autenticazione")
+		return
+	}
+	admin := slices.ContainsFunc(config.AdminOidcEmails, func(email string) bool {
+		return strings.EqualFold(email, info.Email)
+	})
+	if !info.EmailVerified || !admin {
+		log.Warn().Str("email", info.Email).Msg("admin login refused")
+		errorPage(c, http.StatusForbidden, "Questo account non è un amministratore")
+		return
+	}
+
+	secure := strings.HasPrefix(config.AdminOidcRedirectUrl, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(adminSessionCookie, signAdminSession(strings.ToLower(info.Email), time.Now().Add(adminSessionTTL)),
+		int(adminSessionTTL.Seconds()), appUrl("/"), "", secure, true)
+	c.Redirect(http.StatusFound, appUrl("/admin/audit"))
+}
+
+// setupPprof registers the profiling endpoints of net/http/pprof.
+func setupPprof(r *gin.Engine) {
+	debug := r.Group("/debug/pprof", requireAdmin)
+	debug.GET("/*profile", func(c *gin.Context) {
+		switch c.Param("profile") {
+		case "/cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "/profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "/symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "/trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			pprof.Index(c.Writer, c.Request)
+		}
+	})
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+}
+
+// logAdminAuth warns at startup if the admin surface is unreachable, or
+// reachable only from the host itself.
+func logAdminAuth() {
+	if adminBasicEnabled() || adminOidcEnabled() || adminProxyEnabled() {
+		return
+	}
+	if config.AdminAllowLocalhost {
+		log.Warn().Msg("no admin authentication configured, the admin endpoints are only reachable from localhost")
+	} else {
+		log.Warn().Msg("no admin authentication configured, the admin endpoints are disabled")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_adminSession(t *testing.T) {
+	now := time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+	cookie := signAdminSession("admin@unibo.it", now.Add(time.Hour))
+
+	admin, ok := verifyAdminSession(cookie, now)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "admin@unibo.it", admin)
+
+	_, ok = verifyAdminSession(cookie, now.Add(2*time.Hour))
+	assert.Equal(t, false, ok)
+
+	forged := signAdminSession("someone@unibo.it", now.Add(time.Hour))
+	_, ok = verifyAdminSession(forged[:len(forged)-2]+cookie[len(cookie)-2:], now)
+	assert.Equal(t, false, ok)
+	_, ok = verifyAdminSession("garbage", now)
+	assert.Equal(t, false, ok)
+}
+
+func Test_requireAdmin(t *testing.T) {
+	r := setupRouter(testCourses)
+	get := func(remote string, prepare func(req *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remote
+		if prepare != nil {
+			prepare(req)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without authentication, nobody
+	assert.Equal(t, http.StatusUnauthorized, get("127.0.0.1:1234", nil).Code)
+
+	// Unless localhost is allowed, and there is no proxy on localhost
+	config.AdminAllowLocalhost = true
+	assert.Equal(t, http.StatusUnauthorized, get("127.0.0.1:1234", nil).Code)
+	trustedProxies := config.TrustedProxies
+	config.TrustedProxies = nil
+	assert.Equal(t, http.StatusOK, get("127.0.0.1:1234", nil).Code)
+	assert.Equal(t, http.StatusOK, get("[::1]:1234", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("192.0.2.1:1234", nil).Code)
+
+	// A request forwarded by a proxy on the host is not local
+	for _, header := range []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"} {
+		w := get("127.0.0.1:1234", func(req *http.Request) { req.Header.Set(header, "192.0.2.1") })
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	config.TrustedProxies = []string{"127.0.0.0/8"}
+	assert.Equal(t, http.StatusUnauthorized, get("127.0.0.1:1234", nil).Code)
+	config.TrustedProxies = trustedProxies
+	config.AdminAllowLocalhost = true
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	config.AdminUsers = []string{"admin:secret"}
+	config.AdminProxyHeader = "X-Forwarded-User"
+	config.AdminProxyIps = []string{"10.0.0.1"}
+	defer func() {
+		config.AdminUsers = nil
+		config.AdminProxyHeader = ""
+		config.AdminProxyIps = []string{"127.0.0.1", "::1"}
+		config.AdminAllowLocalhost = false
+	}()
+
+	// Localhost is allowed only without other methods
+	w = get("127.0.0.1:1234", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, adminRealm, w.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusOK, get("192.0.2.1:1234", func(req *http.Request) { req.SetBasicAuth("admin", "secret") }).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("192.0.2.1:1234", func(req *http.Request) { req.SetBasicAuth("admin", "guess") }).Code)
+
+	proxied := func(req *http.Request) { req.Header.Set("X-Forwarded-User", "mario") }
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1234", proxied).Code)
+	// The header is trusted only from the proxy
+	assert.Equal(t, http.StatusUnauthorized, get("192.0.2.1:1234", proxied).Code)
+}
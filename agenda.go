@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// italianWeekdays are the names of the weekdays, by [time.Weekday].
+var italianWeekdays = [...]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"}
+
+// agendaDays groups the events of t by day, sorted by start.
+func agendaDays(t timetable.Timetable) [][]timetable.Event {
+	sorted := slices.Clone(t)
+	slices.SortStableFunc(sorted, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+
+	var days [][]timetable.Event
+	for _, e := range sorted {
+		last := len(days) - 1
+		if last >= 0 && sameDay(days[last][0].Start.Time, e.Start.Time) {
+			days[last] = append(days[last], e)
+		} else {
+			days = append(days, []timetable.Event{e})
+		}
+	}
+	return days
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// newOrgAgenda renders the timetable as an Org mode file, with a heading
+// for every day and an entry with an active timestamp for every lesson, so
+// the lessons appear in the Org agenda.
+func newOrgAgenda(t timetable.Timetable, course *unibo_integ.Course, year int, titles string) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("#+TITLE: %s - %d anno\n", course.Descrizione, year))
+
+	for _, day := range agendaDays(t) {
+		b.WriteString(fmt.Sprintf("\n* %s\n", day[0].Start.Format("2006-01-02 Mon")))
+		for _, e := range day {
+			summary, _ := eventSummary(e, titles)
+			b.WriteString(fmt.Sprintf("** %s\n", summary))
+			b.WriteString(fmt.Sprintf("   <%s-%s>\n", e.Start.Format("2006-01-02 Mon 15:04"), e.End.Format("15:04")))
+			b.WriteString("   :PROPERTIES:\n")
+			if room := eventRoom(e); room != "" {
+				b.WriteString(fmt.Sprintf("   :LOCATION: %s\n", room))
+			}
+			if e.Teacher != "" {
+				b.WriteString(fmt.Sprintf("   :TEACHER:  %s\n", e.Teacher))
+			}
+			b.WriteString(fmt.Sprintf("   :MODULE:   %s\n", e.CodModulo))
+			b.WriteString("   :END:\n")
+		}
+	}
+	return b.String()
+}
+
+// newMarkdownAgenda renders the timetable as a Markdown list of lessons,
+// under a heading for every day.
+func newMarkdownAgenda(t timetable.Timetable, course *unibo_integ.Course, year int, titles string) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("# %s - %d anno\n", course.Descrizione, year))
+
+	for _, day := range agendaDays(t) {
+		start := day[0].Start
+		b.WriteString(fmt.Sprintf("\n## %s %s\n\n", italianWeekdays[start.Weekday()], start.Format("02/01/2006")))
+		for _, e := range day {
+			summary, _ := eventSummary(e, titles)
+			b.WriteString(fmt.Sprintf("- **%s-%s** %s", e.Start.Format("15:04"), e.End.Format("15:04"), summary))
+			if room := eventRoom(e); room != "" {
+				b.WriteString(" - " + room)
+			}
+			if e.Teacher != "" {
+				b.WriteString(fmt.Sprintf(" (%s)", e.Teacher))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func buildOrgAgenda(ctx *gin.Context, t timetable.Timetable, course *unibo_integ.Course, year int, opts calOptions) ([]byte, bool) {
+	return []byte(newOrgAgenda(applyCalOptions(t, opts), course, year, opts.Titles)), true
+}
+
+func buildMarkdownAgenda(ctx *gin.Context, t timetable.Timetable, course *unibo_integ.Course, year int, opts calOptions) ([]byte, bool) {
+	return []byte(newMarkdownAgenda(applyCalOptions(t, opts), course, year, opts.Titles)), true
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_newOrgAgenda(t *testing.T) {
+	course := testCourse(8009)
+	tt := testTimetable()
+	// Out of order, on the next day
+	next := tt[0]
+	next.Start.Time, next.End.Time = tt[0].Start.AddDate(0, 0, 1), tt[0].End.AddDate(0, 0, 1)
+	tt = append(tt[:1], next, tt[1])
+
+	expected := "#+TITLE: INFORMATICA - 1 anno\n" +
+		"\n* 2024-10-01 Tue\n" +
+		"** ALGEBRA\n   <2024-10-01 Tue 09:00-11:00>\n   :PROPERTIES:\n   :LOCATION: AULA 1\n   :TEACHER:  Mario Rossi\n   :MODULE:   00001_1\n   :END:\n" +
+		"** ANALISI MATEMATICA\n   <2024-10-01 Tue 11:00-13:00>\n   :PROPERTIES:\n   :TEACHER:  Anna Bianchi\n   :MODULE:   00002_1\n   :END:\n" +
+		"\n* 2024-10-02 Wed\n" +
+		"** ALGEBRA\n   <2024-10-02 Wed 09:00-11:00>\n   :PROPERTIES:\n   :LOCATION: AULA 1\n   :TEACHER:  Mario Rossi\n   :MODULE:   00001_1\n   :END:\n"
+	assert.Equal(t, expected, newOrgAgenda(tt, &course, 1, titlesFull))
+}
+
+func Test_newMarkdownAgenda(t *testing.T) {
+	course := testCourse(8009)
+
+	expected := "# INFORMATICA - 1 anno\n" +
+		"\n## martedì 01/10/2024\n\n" +
+		"- **09:00-11:00** ALGEBRA - AULA 1 (Mario Rossi)\n" +
+		"- **11:00-13:00** ANALISI MATEMATICA (Anna Bianchi)\n"
+	assert.Equal(t, expected, newMarkdownAgenda(testTimetable(), &course, 1, titlesFull))
+}
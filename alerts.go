@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// operatorAlert is sent to the operator of the server when an upstream source
+// becomes degraded or recovers.
+type operatorAlert struct {
+	Source string `json:"source"`
+	// Degraded is false when the source recovered
+	Degraded            bool      `json:"degraded"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	Time                time.Time `json:"time"`
+}
+
+func (a operatorAlert) title() string {
+	if a.Degraded {
+		return fmt.Sprintf("Unibo Calendar: la sorgente %s non risponde", a.Source)
+	}
+	return fmt.Sprintf("Unibo Calendar: la sorgente %s è tornata disponibile", a.Source)
+}
+
+func (a operatorAlert) message() string {
+	if a.Degraded {
+		return fmt.Sprintf("%d richieste consecutive a %s sono fallite, l'ultima con: %s\n\n%s",
+			a.ConsecutiveFailures, a.Source, a.LastError, strings.TrimSuffix(config.PublicUrl, "/")+"/status")
+	}
+	return fmt.Sprintf("Le richieste a %s vanno di nuovo a buon fine.", a.Source)
+}
+
+// alertsEnabled reports whether at least one alert channel is configured.
+func alertsEnabled() bool {
+	return config.AlertThreshold > 0 &&
+		(config.AlertWebhookUrl != "" || config.AlertNtfyUrl != "" || config.AlertEmail != "")
+}
+
+// sendAlert delivers the alert to every configured channel, replaced by the
+// tests.
+var sendAlert = func(a operatorAlert) {
+	logger := log.With().Str("source", a.Source).Bool("degraded", a.Degraded).Logger()
+
+	if config.AlertWebhookUrl != "" {
+		if err := postAlertWebhook(a); err != nil {
+			logger.Warn().Err(err).Msg("unable to send alert webhook")
+		}
+	}
+	if config.AlertNtfyUrl != "" {
+		if err := postAlertNtfy(a); err != nil {
+			logger.Warn().Err(err).Msg("unable to send alert to ntfy")
+		}
+	}
+	if config.AlertEmail != "" {
+		if !digestEnabled() {
+			logger.Warn().Msg("alert email configured but emails are disabled")
+		} else if err := sendEmail(config.AlertEmail, a.title(), a.message()); err != nil {
+			logger.Warn().Err(err).Msg("unable to send alert email")
+		}
+	}
+}
+
+// alertClient delivers the alerts. They are sent when the network
+// misbehaves, so a request must not hang.
+var alertClient = &http.Client{Timeout: time.Second * 10}
+
+// postAlertWebhook posts the alert as JSON.
+func postAlertWebhook(a operatorAlert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	res, err := alertClient.Post(config.AlertWebhookUrl, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New(res.Status)
+	}
+	return nil
+}
+
+// postAlertNtfy publishes the alert to the ntfy topic URL
+// (e.g. "https://ntfy.sh/my-topic").
+func postAlertNtfy(a operatorAlert) error {
+	req, err := http.NewRequest(http.MethodPost, config.AlertNtfyUrl, strings.NewReader(a.message()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", a.title())
+	if a.Degraded {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	} else {
+		req.Header.Set("Tags", "white_check_mark")
+	}
+
+	res, err := alertClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New(res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+)
+
+func Test_upstreamDegraded(t *testing.T) {
+	config.AlertThreshold = 3
+	defer func() { config.AlertThreshold = 5 }()
+
+	u := &upstreamStatus{sources: make(map[string]*SourceStatus)}
+	var alerts []operatorAlert
+	for i := 0; i < 4; i++ {
+		if a, changed := u.update("test", errors.New("timeout")); changed {
+			alerts = append(alerts, a)
+		}
+	}
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, true, alerts[0].Degraded)
+	assert.Equal(t, 3, alerts[0].ConsecutiveFailures)
+	assert.Equal(t, "timeout", alerts[0].LastError)
+	assert.Equal(t, true, degraded(u.snapshot()))
+
+	a, changed := u.update("test", nil)
+	assert.Equal(t, true, changed)
+	assert.Equal(t, false, a.Degraded)
+	assert.Equal(t, false, degraded(u.snapshot()))
+}
+
+func Test_publicError(t *testing.T) {
+	u := &upstreamStatus{sources: make(map[string]*SourceStatus)}
+	u.update("test", fmt.Errorf("unable to get timetable: %w", &unibo.StatusError{Url: "http://10.0.0.1/orario", StatusCode: 503}))
+
+	status := u.snapshot()[0]
+	assert.Equal(t, "status 503", status.LastError)
+	assert.Equal(t, "unable to get timetable: unibo: status 503 for http://10.0.0.1/orario", status.lastErrorDetail)
+
+	assert.Equal(t, "timeout", publicError(context.DeadlineExceeded))
+	assert.Equal(t, "request failed", publicError(errors.New("dial tcp 10.0.0.1:443: connection refused")))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The types in this file are the JSON shapes of the /api/v1 endpoints.
+//
+// They are part of the stability contract of the API (see README.md): fields
+// can be added, but never renamed, removed or changed in meaning. Breaking
+// changes need a new /api/v2 group.
+
+type apiCourse struct {
+	Id            int    `json:"id"`
+	AcademicYear  string `json:"academic_year"`
+	Description   string `json:"description"`
+	Type          string `json:"type"`
+	Url           string `json:"url"`
+	Campus        string `json:"campus"`
+	School        string `json:"school"`
+	Years         int    `json:"years"`
+	International bool   `json:"international"`
+	Languages     string `json:"languages"`
+	Access        string `json:"access"`
+	// Class is the degree class (e.g. "LM-18"), if known
+	Class string `json:"class,omitempty"`
+	// DescriptionEn and UrlEn are the English name and page, if known.
+	// With ?lang=en, they are also used for Description and Url.
+	DescriptionEn string `json:"description_en,omitempty"`
+	UrlEn         string `json:"url_en,omitempty"`
+}
+
+type apiCurriculum struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+type apiCourseDetail struct {
+	apiCourse
+	// Curricula maps every year of the course to its curricula
+	Curricula map[int][]apiCurriculum `json:"curricula"`
+	// Calendars maps every year of the course to its calendar path
+	Calendars map[int]string `json:"calendars"`
+}
+
+type apiGroup struct {
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	Courses []int  `json:"courses"`
+}
+
+// newApiCourse returns the course with the name and URL in the given
+// language, see requestLang.
+func newApiCourse(c unibo_integ.Course, lang string) apiCourse {
+	return apiCourse{
+		Id:            c.Codice,
+		AcademicYear:  c.AnnoAccademico,
+		Description:   c.Name(lang),
+		Type:          c.Tipologia,
+		Url:           c.Website(lang),
+		Campus:        c.Campus,
+		School:        c.Ambiti,
+		Years:         c.DurataAnni,
+		International: c.Internazionale,
+		Languages:     c.Lingue,
+		Access:        c.Accesso,
+		Class:         c.Classe,
+		DescriptionEn: c.DescrizioneEn,
+		UrlEn:         c.UrlEn,
+	}
+}
+
+func newApiCurricula(curricula map[int]curriculum.Curricula) map[int][]apiCurriculum {
+	m := make(map[int][]apiCurriculum, len(curricula))
+	for year, cs := range curricula {
+		m[year] = make([]apiCurriculum, 0, len(cs))
+		for _, c := range cs {
+			m[year] = append(m[year], apiCurriculum{Code: c.Value, Label: c.Label})
+		}
+	}
+	return m
+}
+
+func newApiGroups(groups []CourseGroup) []apiGroup {
+	res := make([]apiGroup, 0, len(groups))
+	for _, g := range groups {
+		ids := make([]int, 0, len(g.Courses))
+		for _, c := range g.Courses {
+			ids = append(ids, c.Codice)
+		}
+		res = append(res, apiGroup{Name: g.Name, Slug: g.Slug, Courses: ids})
+	}
+	return res
+}
+
+// setupApiV1 registers the machine-readable endpoints under /api/v1.
+func setupApiV1(r *gin.Engine, courses *unibo_integ.Courses, middlewares ...gin.HandlerFunc) {
+	v1 := r.Group("/api/v1", middlewares...)
+	v1.OPTIONS("/*path", preflight)
+
+	v1.GET("/courses", func(c *gin.Context) {
+		filtered := courses.Load().ToList()
+		if class := c.Query("class"); class != "" {
+			filtered = filterByClass(filtered, class)
+		}
+
+		lang := requestLang(c)
+		res := make([]apiCourse, 0, len(filtered))
+		for _, course := range filtered {
+			res = append(res, newApiCourse(course, lang))
+		}
+		c.JSON(http.StatusOK, res)
+	})
+
+	v1.GET("/courses/:id", apiCoursePage(courses))
+	v1.GET("/courses/:id/:anno/teachings", apiTeachings(courses))
+
+	v1.GET("/schools", func(c *gin.Context) {
+		c.JSON(http.StatusOK, newApiGroups(groupBySchool(courses.Load().ToList())))
+	})
+	v1.GET("/campus", func(c *gin.Context) {
+		c.JSON(http.StatusOK, newApiGroups(groupByCampus(courses.Load().ToList())))
+	})
+
+	v1.GET("/cal/:id/:anno", getCoursesCal(courses))
+	v1.POST("/cal/batch", apiCalBatch(courses))
+	v1.GET("/teachers", apiTeachers(courses))
+	v1.GET("/conflicts", apiConflicts(courses))
+	v1.POST("/conflicts/:id/:anno", apiExternalConflictsHandler(courses))
+	v1.GET("/free-slots", apiFreeSlots(courses))
+	v1.GET("/rooms", apiBuildings)
+	v1.GET("/rooms/:building/occupancy", apiRoomOccupancy)
+
+	v1.GET("/usage", apiUsage)
+	v1.GET("/status", apiStatus)
+	v1.GET("/validate", validateCalendar(r))
+	v1.GET("/check/:id/:anno", apiCheck(r))
+	v1.POST("/webhooks", apiCreateWebhook(courses))
+	v1.DELETE("/webhooks/:id", apiDeleteWebhook)
+}
+
+func apiCoursePage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		course, perr := parseCourse(courses.Load(), "id", ctx.Param("id"))
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		curricula, err := getAllCurricula(course)
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("unable to retrieve curricula: %w", err))
+			writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable, "Unable to retrieve curricula")
+			return
+		}
+
+		calendars := make(map[int]string, course.DurataAnni)
+		for year := 1; year <= course.DurataAnni; year++ {
+			calendars[year] = appUrl(fmt.Sprintf("/api/v1/cal/%d/%d", course.Codice, year))
+		}
+
+		ctx.JSON(http.StatusOK, apiCourseDetail{
+			apiCourse: newApiCourse(*course, requestLang(ctx)),
+			Curricula: newApiCurricula(curricula),
+			Calendars: calendars,
+		})
+	}
+}
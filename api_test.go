@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// testCourses is a small set of courses that does not need the open data.
+var testCourses = unibo_integ.NewCourses(unibo_integ.NewCoursesMap([]unibo_integ.Course{
+	{Codice: 8009, Descrizione: "INFORMATICA", Campus: "Bologna", Ambiti: "Scienze", DurataAnni: 3, Tipologia: "Laurea"},
+	{Codice: 9254, Descrizione: "INGEGNERIA INFORMATICA", Campus: "Cesena", Ambiti: "Ingegneria e Architettura", DurataAnni: 2, Tipologia: "Laurea Magistrale"},
+}))
+
+// testCourse returns a copy of the test course with the given code.
+func testCourse(code int) unibo_integ.Course {
+	course, _ := testCourses.Load().FindById(code)
+	return *course
+}
+
+func Test_apiV1Courses(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/courses", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var courses []apiCourse
+	err := json.Unmarshal(w.Body.Bytes(), &courses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(courses))
+	assert.Equal(t, 9254, courses[0].Id)
+	assert.Equal(t, "Cesena", courses[0].Campus)
+}
+
+func Test_apiV1CourseNotFound(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/courses/1", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+
+	var p problem
+	err := json.Unmarshal(w.Body.Bytes(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, codeCourseNotFound, p.Code)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, "/api/v1/courses/1", p.Instance)
+}
+
+func Test_calInvalidYear(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/cal/8009/4", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var p problem
+	err := json.Unmarshal(w.Body.Bytes(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, codeInvalidYear, p.Code)
+}
+
+func Test_statusPage(t *testing.T) {
+	r := setupRouter(testCourses)
+	upstream.record(sourceTimetable, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/status", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Outcomes of the audited actions
+const (
+	auditSuccess = "success"
+	auditFailure = "failure"
+)
+
+// auditActorKey is the context key of the name of the admin performing the
+// request. Without it the actor is the client IP.
+const auditActorKey = "audit-actor"
+
+// defaultAuditLimit is the number of entries returned by /admin/audit when
+// ?limit= is missing.
+const defaultAuditLimit = 100
+
+// auditEntry is an admin action, as recorded in the audit log.
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target,omitempty"`
+	Outcome string    `json:"outcome"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// auditLog is an append-only log of the admin actions, one JSON entry per
+// line, so that it survives restarts and a crash loses at most a line.
+type auditLog struct {
+	mu   sync.Mutex
+	file string
+}
+
+var auditTrail = &auditLog{file: config.AuditLogFile}
+
+// record appends the entry to the log.
+func (l *auditLog) record(e auditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to encode audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err = os.MkdirAll(path.Dir(l.file), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("unable to create audit log folder: %w", err)
+	}
+	file, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	return file.Close()
+}
+
+// auditQuery are the filters of /admin/audit. Empty ones match every entry.
+type auditQuery struct {
+	Action string    `form:"action"`
+	Actor  string    `form:"actor"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit"`
+}
+
+func (q auditQuery) matches(e auditEntry) bool {
+	return (q.Action == "" || e.Action == q.Action) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since))
+}
+
+// query returns the most recent entries matching the query, newest first.
+// Malformed lines, e.g. one truncated by a crash, are skipped.
+func (l *auditLog) query(q auditQuery) ([]auditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := []auditEntry{}
+	file, err := os.Open(l.file)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e auditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit log: %w", err)
+	}
+
+	slices.Reverse(entries)
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
+
+// auditActor returns who is performing the admin request.
+func auditActor(c *gin.Context) string {
+	if actor := c.GetString(auditActorKey); actor != "" {
+		return actor
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// audit records the outcome of the admin action of the request. Failing to
+// record it is logged, but doesn't fail the action, which already happened.
+func audit(c *gin.Context, action string, target string, detail string, actionErr error) {
+	e := auditEntry{
+		Time:    time.Now().UTC(),
+		Actor:   auditActor(c),
+		Action:  action,
+		Target:  target,
+		Outcome: auditSuccess,
+		Detail:  detail,
+	}
+	if actionErr != nil {
+		e.Outcome = auditFailure
+		e.Detail = actionErr.Error()
+	}
+
+	err := auditTrail.record(e)
+	if err != nil {
+		log.Error().Err(err).Str("action", action).Str("actor", e.Actor).Msg("unable to record admin action")
+	}
+}
+
+// adminAudit returns the entries of the audit log, newest first, filtered by
+// ?action=, ?actor= and ?since= (RFC 3339), at most ?limit= of them.
+func adminAudit(c *gin.Context) {
+	var q auditQuery
+	err := c.ShouldBindQuery(&q)
+	if err != nil || q.Limit < 0 {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter,
+			"since must be an RFC 3339 time and limit a positive number")
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultAuditLimit
+	}
+
+	entries, err := auditTrail.query(q)
+	if err != nil {
+		_ = c.Error(err)
+		writeProblem(c, http.StatusInternalServerError, codeInternal, "Unable to read the audit log")
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_auditLog(t *testing.T) {
+	l := &auditLog{file: path.Join(t.TempDir(), "audit", "audit.jsonl")}
+
+	entries, err := l.query(auditQuery{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(entries))
+
+	start := time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i, action := range []string{actionCachePurge, actionFlagSet, actionCachePurge} {
+		err = l.record(auditEntry{Time: start.Add(time.Duration(i) * time.Hour), Actor: "ip:127.0.0.1", Action: action, Outcome: auditSuccess})
+		assert.Equal(t, nil, err)
+	}
+	// A line truncated by a crash is skipped
+	file, _ := os.OpenFile(l.file, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = file.WriteString(`{"time":"2024-10`)
+	_ = file.Close()
+
+	entries, err = l.query(auditQuery{Action: actionCachePurge})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(entries))
+	// Newest first
+	assert.Equal(t, start.Add(2*time.Hour), entries[0].Time)
+
+	entries, _ = l.query(auditQuery{Since: start.Add(time.Hour)})
+	assert.Equal(t, 2, len(entries))
+	entries, _ = l.query(auditQuery{Limit: 1})
+	assert.Equal(t, 1, len(entries))
+	entries, _ = l.query(auditQuery{Actor: "admin"})
+	assert.Equal(t, 0, len(entries))
+}
+
+func Test_adminActions(t *testing.T) {
+	dir := t.TempDir()
+	auditTrail = &auditLog{file: path.Join(dir, "audit.jsonl")}
+	flagsFile := config.FeatureFlagsFile
+	config.FeatureFlagsFile = path.Join(dir, "flags.json")
+	config.AdminUsers = []string{"admin:secret"}
+	defer func() {
+		auditTrail = &auditLog{file: config.AuditLogFile}
+		config.FeatureFlagsFile = flagsFile
+		config.AdminUsers = nil
+		featureFlags.Set(nil)
+	}()
+	r := setupRouter(testCourses)
+	request := func(method string, target string, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		return req
+	}
+
+	calcache.Set("test", newCachedFeed([]byte("BEGIN:VCALENDAR"), ""))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, request(http.MethodPost, "/admin/cache/purge", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, found := calcache.Get("test")
+	assert.Equal(t, false, found)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, request(http.MethodPut, "/admin/flags/rrule_compression", `{"enabled":true,"rollout":10}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, featureFlags.Enabled(flagRruleCompression))
+	saved, _ := os.ReadFile(config.FeatureFlagsFile)
+	assert.Equal(t, `{"rrule_compression":{"enabled":true,"rollout":10}}`, string(saved))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, request(http.MethodPut, "/admin/flags/strict_ics", `{"enabled":true,"rollout":200}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, request(http.MethodGet, "/admin/audit", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []auditEntry
+	_ = json.Unmarshal(w.Body.Bytes(), &entries)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, actionFlagSet, entries[0].Action)
+	assert.Equal(t, "rrule_compression", entries[0].Target)
+	assert.Equal(t, "enabled=true rollout=10", entries[0].Detail)
+	assert.Equal(t, auditSuccess, entries[0].Outcome)
+	assert.Equal(t, actionCachePurge, entries[1].Action)
+	assert.Equal(t, "basic:admin", entries[1].Actor)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, request(http.MethodGet, "/admin/audit?since=yesterday", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
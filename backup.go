@@ -0,0 +1,314 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A backup is a tar.gz archive with the JSON files of the stores (the
+// subscriptions, the webhooks, the API keys...) and the snapshots of the
+// timetables, so an instance can be recovered or moved to another server.
+// The stores are saved atomically (see saveJsonFile), so a backup can be
+// made while the server is running; a restore must be made with the server
+// stopped, since it would overwrite the files again.
+
+// backupSnapshots is the folder of the snapshots in the archive.
+const backupSnapshots = "snapshots"
+
+// backupTimeLayout is the time in the names of the backups.
+const backupTimeLayout = "20060102T150405"
+
+// backupFile is a store included in the backups.
+type backupFile struct {
+	// Name is the name of the file in the archive
+	Name string
+	Path string
+}
+
+// backupFiles returns the stores, where the server saves them.
+func backupFiles() []backupFile {
+	return []backupFile{
+		{"google.json", config.GoogleSubscriptionsFile},
+		{"digest.json", config.DigestSubscriptionsFile},
+		{"push.json", config.PushSubscriptionsFile},
+		{"matrix.json", config.MatrixRoomsFile},
+		{"webhooks.json", config.WebhooksFile},
+		{"api_keys.json", config.ApiKeysFile},
+		{"flags.json", config.FeatureFlagsFile},
+		{"stats.json", config.StatsFile},
+		{"academic_calendar.json", config.AcademicCalendarFile},
+		{"events.json", config.EventsFile},
+		{"audit.jsonl", config.AuditLogFile},
+	}
+}
+
+// writeBackup writes the archive of the stores and the snapshots to w. The
+// missing stores are skipped.
+func writeBackup(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range backupFiles() {
+		err := addBackupFile(tw, f.Name, f.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+
+	err := filepath.WalkDir(snapshotsDir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == snapshotsDir {
+			return fs.SkipDir
+		} else if err != nil || !d.Type().IsRegular() {
+			return err
+		} else if strings.HasSuffix(p, ".tmp") {
+			// A snapshot being saved, see saveSnapshot
+			return nil
+		}
+		rel, err := filepath.Rel(snapshotsDir, p)
+		if err != nil {
+			return err
+		}
+		return addBackupFile(tw, path.Join(backupSnapshots, filepath.ToSlash(rel)), p)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to add the snapshots: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addBackupFile adds the file at p to the archive with the given name.
+func addBackupFile(tw *tar.Writer, name string, p string) error {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	// The stores contain emails and tokens, see saveJsonFile
+	err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()})
+	if err == nil {
+		_, err = tw.Write(data)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add %s: %w", name, err)
+	}
+	return nil
+}
+
+// restoreBackup extracts the archive of writeBackup, replacing the stores
+// and the snapshots it contains. It returns the number of files restored.
+func restoreBackup(r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backup: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	restored := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return restored, nil
+		} else if err != nil {
+			return restored, fmt.Errorf("invalid backup: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dest, err := backupDestination(header.Name)
+		if err != nil {
+			return restored, err
+		}
+		err = restoreBackupFile(dest, tr)
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+}
+
+// backupDestination returns where the file of the archive with the given
+// name is restored.
+func backupDestination(name string) (string, error) {
+	files := backupFiles()
+	if i := slices.IndexFunc(files, func(f backupFile) bool { return f.Name == name }); i >= 0 {
+		return files[i].Path, nil
+	}
+
+	rel, found := strings.CutPrefix(name, backupSnapshots+"/")
+	if found && filepath.IsLocal(rel) {
+		return filepath.Join(snapshotsDir, filepath.FromSlash(rel)), nil
+	}
+	return "", fmt.Errorf("unexpected file %q in backup", name)
+}
+
+// restoreBackupFile writes the content of r to dest, replacing it
+// atomically.
+func restoreBackupFile(dest string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("unable to create folder of %s: %w", dest, err)
+	}
+
+	tmp := dest + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to restore %s: %w", dest, err)
+	}
+	_, err = io.Copy(file, r)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to restore %s: %w", dest, err)
+	}
+	return os.Rename(tmp, dest)
+}
+
+// saveBackup saves a backup in dir, named after now, and returns its path.
+func saveBackup(dir string, now time.Time) (string, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("unable to create backup folder: %w", err)
+	}
+
+	p := filepath.Join(dir, "backup-"+now.UTC().Format(backupTimeLayout)+".tar.gz")
+	file, err := os.OpenFile(p+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("unable to create backup: %w", err)
+	}
+	err = writeBackup(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(p + ".tmp")
+		return "", err
+	}
+	return p, os.Rename(p+".tmp", p)
+}
+
+// pruneBackups removes the backups in dir but the last keep.
+func pruneBackups(dir string, keep int) error {
+	// The names sort by time
+	backups, err := filepath.Glob(filepath.Join(dir, "backup-*.tar.gz"))
+	if err != nil || len(backups) <= keep {
+		return err
+	}
+	slices.Sort(backups)
+	for _, p := range backups[:len(backups)-keep] {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBackups saves a backup in config.BackupDir every interval.
+func runBackups(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		p, err := saveBackup(config.BackupDir, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("unable to save backup")
+			continue
+		}
+		err = pruneBackups(config.BackupDir, max(config.BackupKeep, 1))
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to remove old backups")
+		}
+		log.Info().Str("file", p).Msg("backup saved")
+	}
+}
+
+// runAdmin runs the admin commands:
+//
+//	admin backup [-o <file>]
+//	admin restore <file>
+func runAdmin(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: admin backup [-o <file>] | admin restore <file>")
+	}
+
+	switch args[0] {
+	case "backup":
+		flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+		output := flags.String("o", "", "file to write the backup to, by default a new one in the backup folder")
+		// Already read by loadConfig, see dataDirArg
+		flags.String("data-dir", config.DataDir, "folder of the data files")
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if *output == "" {
+			p, err := saveBackup(config.BackupDir, time.Now())
+			if err != nil {
+				return err
+			}
+			log.Info().Str("file", p).Msg("Backup saved")
+			return nil
+		}
+
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		err = writeBackup(file)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			log.Info().Str("file", *output).Msg("Backup saved")
+		}
+		return err
+
+	case "restore":
+		flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		flags.String("data-dir", config.DataDir, "folder of the data files")
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New("usage: admin restore <file>")
+		}
+
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		restored, err := restoreBackup(file)
+		if err != nil {
+			return err
+		}
+		log.Info().Int("files", restored).Msg("Backup restored")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown admin command %q", args[0])
+	}
+}
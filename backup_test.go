@@ -0,0 +1,102 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// useTestBackupDir points the stores and the snapshots to an empty temporary
+// folder.
+func useTestBackupDir(t *testing.T) string {
+	dir := t.TempDir()
+	oldConfig, oldSnapshots := config, snapshotsDir
+	t.Cleanup(func() { config, snapshotsDir = oldConfig, oldSnapshots })
+
+	config.WebhooksFile = filepath.Join(dir, "webhooks.json")
+	config.DigestSubscriptionsFile = filepath.Join(dir, "digest.json")
+	config.GoogleSubscriptionsFile = filepath.Join(dir, "missing.json")
+	snapshotsDir = filepath.Join(dir, "snapshots")
+	return dir
+}
+
+func Test_backupRestore(t *testing.T) {
+	dir := useTestBackupDir(t)
+	_ = os.WriteFile(config.WebhooksFile, []byte(`{"w1": {}}`), 0o600)
+	_ = os.WriteFile(config.DigestSubscriptionsFile, []byte(`{"d1": {}}`), 0o600)
+	snapshot := filepath.Join(snapshotsDir, "8009", "1-default", "2024-10-01.json")
+	_ = os.MkdirAll(filepath.Dir(snapshot), os.ModePerm)
+	_ = os.WriteFile(snapshot, []byte(`[]`), 0o600)
+
+	var buf bytes.Buffer
+	if err := writeBackup(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.Remove(config.WebhooksFile)
+	_ = os.WriteFile(config.DigestSubscriptionsFile, []byte(`{}`), 0o600)
+	_ = os.RemoveAll(snapshotsDir)
+
+	restored, err := restoreBackup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The missing google.json is not in the backup
+	assert.Equal(t, 3, restored)
+
+	data, _ := os.ReadFile(config.WebhooksFile)
+	assert.Equal(t, `{"w1": {}}`, string(data))
+	data, _ = os.ReadFile(config.DigestSubscriptionsFile)
+	assert.Equal(t, `{"d1": {}}`, string(data))
+	data, _ = os.ReadFile(snapshot)
+	assert.Equal(t, `[]`, string(data))
+	_, err = os.Stat(filepath.Join(dir, "missing.json"))
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func Test_restoreBackupUnexpectedFile(t *testing.T) {
+	dir := useTestBackupDir(t)
+
+	for _, name := range []string{"snapshots/../../evil.json", "/etc/passwd", "other.json"} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: 2, Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte("{}"))
+		_ = tw.Close()
+		_ = gz.Close()
+
+		_, err := restoreBackup(&buf)
+		assert.NotEqual(t, nil, err)
+	}
+	_, err := os.Stat(filepath.Join(dir, "evil.json"))
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func Test_saveBackupPrune(t *testing.T) {
+	useTestBackupDir(t)
+	dir := t.TempDir()
+
+	now := time.Date(2024, time.October, 1, 3, 0, 0, 0, time.UTC)
+	var saved []string
+	for i := range 4 {
+		p, err := saveBackup(dir, now.AddDate(0, 0, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, p)
+	}
+	assert.Equal(t, filepath.Join(dir, "backup-20241001T030000.tar.gz"), saved[0])
+
+	if err := pruneBackups(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "backup-*"))
+	assert.Equal(t, saved[2:], left)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// normalizeBasePath returns the prefix the app is mounted under, with a
+// leading slash and without a trailing one, empty for the root.
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// defaultBasePath returns the path of the public URL, so that mounting the
+// app under a prefix only needs PUBLIC_URL.
+func defaultBasePath(publicUrl string) string {
+	u, err := url.Parse(publicUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// appUrl returns the path p (absolute, e.g. "/courses") of the app, below
+// [Config.BasePath]. Every link, redirect and static reference goes through
+// it.
+func appUrl(p string) string {
+	return config.BasePath + p
+}
+
+// withBasePath serves the app under the base path. The prefix is removed
+// before routing, and requests without it are served too, for the reverse
+// proxies which already strip it.
+func withBasePath(h http.Handler, base string) http.Handler {
+	if base == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rest, found := strings.CutPrefix(req.URL.Path, base)
+		if !found || (rest != "" && rest[0] != '/') {
+			h.ServeHTTP(w, req)
+			return
+		}
+		if rest == "" {
+			rest = "/"
+		}
+
+		stripped := req.Clone(req.Context())
+		stripped.URL.Path = rest
+		stripped.URL.RawPath = ""
+		h.ServeHTTP(w, stripped)
+	})
+}
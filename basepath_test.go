@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_normalizeBasePath(t *testing.T) {
+	assert.Equal(t, "", normalizeBasePath(""))
+	assert.Equal(t, "", normalizeBasePath("/"))
+	assert.Equal(t, "/unibocal", normalizeBasePath("unibocal/"))
+	assert.Equal(t, "/apps/unibocal", normalizeBasePath("/apps/unibocal"))
+}
+
+func Test_defaultBasePath(t *testing.T) {
+	assert.Equal(t, "", defaultBasePath("https://unibocalendar.it"))
+	assert.Equal(t, "/unibocal/", defaultBasePath("https://example.org/unibocal/"))
+}
+
+func Test_withBasePath(t *testing.T) {
+	base := config.BasePath
+	config.BasePath = "/unibocal"
+	defer func() { config.BasePath = base }()
+
+	h := withBasePath(setupRouter(testCourses), config.BasePath)
+
+	// With and without the prefix, for the proxies that strip it
+	for _, p := range []string{"/unibocal/", "/"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", p, nil)
+		h.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), `href="/unibocal/courses"`))
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/unibocalendar/", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/unibocal/manifest.webmanifest", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"start_url":"/unibocal/"`))
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// POST /api/v1/cal/batch builds, in a single request, the calendar of more
+// course years, each with its own filters: merged in one calendar, or one
+// calendar per course year in a zip.
+
+const (
+	// maxBatchItems is the maximum number of course years of a batch, like
+	// the custom calendars
+	maxBatchItems = maxCustomFeeds
+	// maxBatchBody is the maximum size of the body of a batch
+	maxBatchBody   = 64 << 10
+	zipContentType = "application/zip"
+)
+
+// apiBatchItem is a course year of a batch, and the filters of its lessons.
+type apiBatchItem struct {
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum,omitempty"`
+	Filters    struct {
+		// Subjects are the module codes, as ?subjects=
+		Subjects []string `json:"subjects,omitempty"`
+		// Types are the lesson types, as ?types=
+		Types []string `json:"types,omitempty"`
+		// SkipDays are the excluded weekdays, as ?skip_days=
+		SkipDays []string `json:"skip_days,omitempty"`
+		// Period is the semester, as ?period=
+		Period string `json:"period,omitempty"`
+	} `json:"filters"`
+}
+
+// options returns the calendar options of the item: the ones of the request
+// with the filters of the item.
+func (item apiBatchItem) options(opts calOptions, now time.Time) calOptions {
+	opts.Subjects = nil
+	for _, s := range item.Filters.Subjects {
+		if s = strings.TrimSpace(s); s != "" && !slices.Contains(opts.Subjects, s) {
+			opts.Subjects = append(opts.Subjects, s)
+		}
+	}
+	slices.Sort(opts.Subjects)
+	opts.Types = parseLessonTypes(item.Filters.Types)
+	opts.SkipDays = parseSkipDays(item.Filters.SkipDays)
+	opts.Period = parsePeriod(item.Filters.Period, now)
+	opts.Curriculum = item.Curriculum
+	return opts
+}
+
+// batchFeed is a checked item of a batch.
+type batchFeed struct {
+	Course *unibo_integ.Course
+	Year   int
+	Opts   calOptions
+}
+
+// bindBatch reads the items of the batch from the body, and checks them.
+func bindBatch(ctx *gin.Context, courses *unibo_integ.CoursesMap, opts calOptions) ([]batchFeed, *paramError) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBatchBody)
+
+	var items []apiBatchItem
+	if err := ctx.ShouldBindJSON(&items); err != nil {
+		return nil, &paramError{"body", http.StatusBadRequest, codeInvalidParameter,
+			"body must be a JSON list of {course, year, curriculum, filters} items"}
+	}
+	if len(items) == 0 || len(items) > maxBatchItems {
+		return nil, &paramError{"body", http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("a batch must have between 1 and %d items", maxBatchItems)}
+	}
+
+	now := time.Now()
+	feeds := make([]batchFeed, len(items))
+	for i, item := range items {
+		feed, perr := checkBatchItem(courses, fmt.Sprintf("items[%d]", i), item, opts, now)
+		if perr != nil {
+			// The messages don't say which item is wrong
+			perr.Message = perr.Param + ": " + perr.Message
+			return nil, perr
+		}
+		feeds[i] = feed
+	}
+	return feeds, nil
+}
+
+// checkBatchItem checks the course, the year and the curriculum of the item.
+func checkBatchItem(courses *unibo_integ.CoursesMap, param string, item apiBatchItem, opts calOptions, now time.Time) (batchFeed, *paramError) {
+	course, perr := findCourse(courses, param+".course", item.Course)
+	if perr != nil {
+		return batchFeed{}, perr
+	}
+	if perr := checkYear(course, param+".year", item.Year); perr != nil {
+		return batchFeed{}, perr
+	}
+	if perr := checkCurriculum(course, param+".curriculum", item.Year, item.Curriculum); perr != nil {
+		return batchFeed{}, perr
+	}
+	return batchFeed{Course: course, Year: item.Year, Opts: item.options(opts, now)}, nil
+}
+
+// name returns the name of the calendar of the feed in a zip, e.g.
+// "8009-1.ics" or "8009-2-A58-000.ics".
+func (f batchFeed) name() string {
+	name := fmt.Sprintf("%d-%d", f.Course.Codice, f.Year)
+	if f.Opts.Curriculum != "" {
+		name += "-" + f.Opts.Curriculum
+	}
+	return name + ".ics"
+}
+
+// wantsZip reports whether the batch is requested as a zip, with ?zip=1 or
+// with the Accept header.
+func wantsZip(ctx *gin.Context) bool {
+	if zip, err := strconv.ParseBool(ctx.Query("zip")); err == nil {
+		return zip
+	}
+	return ctx.NegotiateFormat(feedFormats[defaultFormat].mediaType(), zipContentType) == zipContentType
+}
+
+// mergeBatch returns the lessons of the feeds included by their filters,
+// sorted by start. The lessons in more feeds are included once.
+func mergeBatch(feeds []batchFeed, timetables []timetable.Timetable) timetable.Timetable {
+	seen := make(map[string]bool)
+	var merged timetable.Timetable
+	for i, f := range feeds {
+		for _, e := range timetables[i] {
+			uid := eventUid(e)
+			if f.Opts.includes(e) && !seen[uid] {
+				seen[uid] = true
+				merged = append(merged, e)
+			}
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+	return merged
+}
+
+// apiCalBatch answers POST /api/v1/cal/batch. The options not related to
+// the filters (e.g. ?merge=, ?titles=, ?lang=) are query parameters, as for
+// the single calendars.
+func apiCalBatch(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		opts := parseCalOptions(ctx)
+		opts.Format = defaultFormat
+		feeds, perr := bindBatch(ctx, courses.Load(), opts)
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		timetables := make([]timetable.Timetable, len(feeds))
+		for i, f := range feeds {
+			t, err := getTimetable(f.Course, f.Year, curriculum.Curriculum{Value: f.Opts.Curriculum})
+			if errors.Is(err, errOverloaded) {
+				overloaded(ctx)
+				return
+			} else if err != nil {
+				_ = ctx.Error(err)
+				writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable,
+					fmt.Sprintf("Unable to retrieve the timetable of course %d", f.Course.Codice))
+				return
+			}
+			timetables[i] = t
+		}
+
+		if wantsZip(ctx) {
+			data, ok := zipBatch(ctx, feeds, timetables)
+			if ok {
+				ctx.Header("Content-Disposition", "attachment; filename=calendari.zip")
+				ctx.Data(http.StatusOK, zipContentType, data)
+			}
+			return
+		}
+
+		// The lessons are already filtered by the items
+		opts.Subjects, opts.Types, opts.SkipDays, opts.Period = nil, nil, nil, 0
+		merged := mergeBatch(feeds, timetables)
+		cal, err := createEventsCal(merged, opts)
+		if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create calendar")
+			return
+		}
+		cal.SetName("Calendario combinato")
+		cal.SetDescription("Orario delle lezioni dei corsi selezionati")
+
+		// The same items, in any order, are the same calendar
+		keys := make([]string, len(feeds))
+		for i, f := range feeds {
+			keys[i] = fmt.Sprintf("%d-%d-%s", f.Course.Codice, f.Year, f.Opts.identityKey())
+		}
+		slices.Sort(keys)
+		setCalendarIdentity(cal, calendarIdentity("batch-"+strings.Join(keys, ","), opts))
+
+		data, ok := serializeCalendar(ctx, cal, opts)
+		if ok {
+			successCalendar(ctx, data)
+		}
+	}
+}
+
+// zipBatch returns a zip with the calendar of every feed.
+func zipBatch(ctx *gin.Context, feeds []batchFeed, timetables []timetable.Timetable) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	names := make(map[string]bool)
+	for i, f := range feeds {
+		data, ok := buildCalendar(ctx, timetables[i], f.Course, f.Year, f.Opts)
+		if !ok {
+			return nil, false
+		}
+
+		// The same course year can be in more items, with other filters
+		name := f.name()
+		if names[name] {
+			name = strings.TrimSuffix(name, ".ics") + fmt.Sprintf("-%d.ics", i+1)
+		}
+		names[name] = true
+
+		file, err := w.Create(name)
+		if err == nil {
+			_, err = file.Write(data)
+		}
+		if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create the zip")
+			return nil, false
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create the zip")
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_apiCalBatchInvalid(t *testing.T) {
+	r := setupRouter(testCourses)
+	tests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"course": 8009}`, http.StatusBadRequest, "JSON list"},
+		{`[]`, http.StatusBadRequest, "between 1 and"},
+		{`[` + strings.Repeat(`{"course": 8009, "year": 1},`, maxBatchItems) + `{"course": 8009, "year": 2}]`, http.StatusBadRequest, "between 1 and"},
+		{`[{"course": 8009, "year": 1}, {"course": 1, "year": 1}]`, http.StatusNotFound, "items[1].course: no course with id 1"},
+		{`[{"course": 8009, "year": 4}]`, http.StatusBadRequest, "items[0].year: year must be between 1 and 3"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/cal/batch", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), tt.want))
+	}
+}
+
+func Test_apiBatchItemOptions(t *testing.T) {
+	var item apiBatchItem
+	item.Curriculum = "A58-000"
+	item.Filters.Subjects = []string{"00002", " 00001", "00002"}
+	item.Filters.Types = []string{"lab", "unknown"}
+	item.Filters.SkipDays = []string{"fri"}
+
+	opts := item.options(calOptions{Titles: "short", Subjects: []string{"00003"}}, time.Now())
+	assert.Equal(t, []string{"00001", "00002"}, opts.Subjects)
+	assert.Equal(t, []string{"lab"}, opts.Types)
+	assert.Equal(t, []time.Weekday{time.Friday}, opts.SkipDays)
+	assert.Equal(t, "A58-000", opts.Curriculum)
+	// The other options are the ones of the request
+	assert.Equal(t, "short", opts.Titles)
+}
+
+func Test_mergeBatch(t *testing.T) {
+	course := testCourse(8009)
+	feeds := []batchFeed{
+		{Course: &course, Year: 1, Opts: calOptions{Subjects: []string{"00002"}}},
+		{Course: &course, Year: 2},
+	}
+
+	// The lessons in both feeds are included once
+	merged := mergeBatch(feeds, []timetable.Timetable{testTimetable(), testTimetable()})
+	assert.Equal(t, 2, len(merged))
+	assert.Equal(t, "00001_1", merged[0].CodModulo)
+	assert.Equal(t, "00002_1", merged[1].CodModulo)
+}
+
+func Test_zipBatch(t *testing.T) {
+	course := testCourse(8009)
+	feeds := []batchFeed{
+		{Course: &course, Year: 1},
+		{Course: &course, Year: 1, Opts: calOptions{Subjects: []string{"00001"}}},
+	}
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/cal/batch?zip=1", nil)
+	data, ok := zipBatch(ctx, feeds, []timetable.Timetable{testTimetable(), testTimetable()})
+	assert.Equal(t, true, ok)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(zr.File))
+	assert.Equal(t, "8009-1.ics", zr.File[0].Name)
+	assert.Equal(t, "8009-1-2.ics", zr.File[1].Name)
+}
+
+func Test_wantsZip(t *testing.T) {
+	wants := func(url string, accept string) bool {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, url, nil)
+		ctx.Request.Header.Set("Accept", accept)
+		return wantsZip(ctx)
+	}
+
+	assert.Equal(t, false, wants("/api/v1/cal/batch", ""))
+	assert.Equal(t, false, wants("/api/v1/cal/batch", "text/calendar"))
+	assert.Equal(t, true, wants("/api/v1/cal/batch", zipContentType))
+	assert.Equal(t, true, wants("/api/v1/cal/batch?zip=1", ""))
+	assert.Equal(t, false, wants("/api/v1/cal/batch?zip=0", zipContentType))
+}
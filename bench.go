@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// benchResult is the outcome of a single request of the benchmark.
+type benchResult struct {
+	Kind     string
+	Duration time.Duration
+	Failed   bool
+	// RateLimited is set when the server answered 429, see rateLimit
+	RateLimited bool
+	// Cache is the X-Cache header of the response, see cacheHeader
+	Cache string
+}
+
+// benchTarget is the server being benchmarked.
+type benchTarget struct {
+	url string
+	// apiKey is sent with every request, so the bench uses its quota rather
+	// than the one of the anonymous clients
+	apiKey  string
+	client  *http.Client
+	courses []apiCourse
+}
+
+// runBench runs the bench subcommand, which replays a mix of /cal and
+// /courses requests against a running instance and reports the latency
+// percentiles and the cache hits of the calendars.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	target := fs.String("url", "http://localhost:8080", "base URL of the instance")
+	requests := fs.Int("requests", 1000, "total number of requests")
+	concurrency := fs.Int("concurrency", 8, "number of concurrent clients")
+	calRatio := fs.Float64("cal-ratio", 0.8, "fraction of the requests to /cal, the others go to /courses")
+	courses := fs.Int("courses", 20, "number of courses to request the calendars of, 0 for all")
+	query := fs.String("query", "", "query string added to the /cal requests (e.g. \"format=json\")")
+	apiKey := fs.String("api-key", "", "API key sent with the requests, to use its rate limit")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	t := &benchTarget{
+		url:    strings.TrimSuffix(*target, "/"),
+		apiKey: *apiKey,
+		client: &http.Client{Timeout: time.Minute},
+	}
+	err = t.loadCourses()
+	if err != nil {
+		return err
+	}
+	if *courses > 0 && *courses < len(t.courses) {
+		t.courses = t.courses[:*courses]
+	}
+
+	jobs := make(chan string)
+	results := make(chan benchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < max(*concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for kind := range jobs {
+				results <- t.request(kind, *query)
+			}
+		}()
+	}
+
+	go func() {
+		for i := 0; i < *requests; i++ {
+			if rand.Float64() < *calRatio {
+				jobs <- "/cal"
+			} else {
+				jobs <- "/courses"
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	start := time.Now()
+	var all []benchResult
+	for r := range results {
+		all = append(all, r)
+	}
+	elapsed := time.Since(start)
+
+	printBenchReport(os.Stdout, all, elapsed)
+	return nil
+}
+
+// loadCourses retrieves the courses of the target, to pick the calendars.
+func (t *benchTarget) loadCourses() error {
+	res, err := t.get(t.url + "/api/v1/courses")
+	if err != nil {
+		return fmt.Errorf("unable to retrieve courses: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to retrieve courses: %s", res.Status)
+	}
+	err = json.NewDecoder(res.Body).Decode(&t.courses)
+	if err != nil {
+		return fmt.Errorf("unable to decode courses: %w", err)
+	}
+	if len(t.courses) == 0 {
+		return fmt.Errorf("the instance has no courses")
+	}
+	return nil
+}
+
+// request makes a request of the given kind, to a random calendar for
+// "/cal".
+func (t *benchTarget) request(kind string, query string) benchResult {
+	u := t.url + "/courses"
+	if kind == "/cal" {
+		c := t.courses[rand.IntN(len(t.courses))]
+		u = fmt.Sprintf("%s/cal/%d/%d", t.url, c.Id, rand.IntN(max(c.Years, 1))+1)
+		if query != "" {
+			u += "?" + query
+		}
+	}
+
+	start := time.Now()
+	res, err := t.get(u)
+	if err != nil {
+		return benchResult{Kind: kind, Duration: time.Since(start), Failed: true}
+	}
+	_, err = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	return benchResult{
+		Kind:        kind,
+		Duration:    time.Since(start),
+		Failed:      err != nil || res.StatusCode != http.StatusOK,
+		RateLimited: res.StatusCode == http.StatusTooManyRequests,
+		Cache:       res.Header.Get(cacheHeader),
+	}
+}
+
+// get makes a GET request to the target, with the API key if any.
+func (t *benchTarget) get(u string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if t.apiKey != "" {
+		req.Header.Set("X-API-Key", t.apiKey)
+	}
+	return t.client.Do(req)
+}
+
+// printBenchReport writes the latency percentiles of every kind of request
+// and the cache hit ratio of the calendars.
+func printBenchReport(w io.Writer, results []benchResult, elapsed time.Duration) {
+	fmt.Fprintf(w, "%d requests in %s (%.1f req/s)\n\n",
+		len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds())
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\tfailed\tp50\tp90\tp99\tmax\t")
+	for _, kind := range []string{"/cal", "/courses"} {
+		var durations []time.Duration
+		failed := 0
+		for _, r := range results {
+			if r.Kind != kind {
+				continue
+			}
+			durations = append(durations, r.Duration)
+			if r.Failed {
+				failed++
+			}
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		slices.Sort(durations)
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", kind, len(durations), failed,
+			percentile(durations, 50), percentile(durations, 90), percentile(durations, 99),
+			durations[len(durations)-1].Round(time.Microsecond))
+	}
+	_ = tw.Flush()
+
+	// The latencies of the rejected requests would make the server look
+	// faster than it is
+	rateLimited := 0
+	for _, r := range results {
+		if r.RateLimited {
+			rateLimited++
+		}
+	}
+	if rateLimited > 0 {
+		fmt.Fprintf(w, "\n%d requests were rate limited: start the server with ANON_RATE_LIMIT=0 or use -api-key\n", rateLimited)
+	}
+
+	hits, revalidated, misses := 0, 0, 0
+	for _, r := range results {
+		switch r.Cache {
+		case "HIT":
+			hits++
+		case "REVALIDATED":
+			revalidated++
+		case "MISS":
+			misses++
+		}
+	}
+	if total := hits + revalidated + misses; total > 0 {
+		fmt.Fprintf(w, "\ncalendar cache: %d hits, %d revalidated, %d misses (%.1f%% hit ratio)\n",
+			hits, revalidated, misses, float64(hits+revalidated)/float64(total)*100)
+	}
+}
+
+// percentile returns the p-th percentile of the sorted durations, using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)].Round(time.Microsecond)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_percentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(durations, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(durations, 99))
+	assert.Equal(t, time.Millisecond, percentile(durations[:1], 90))
+}
+
+func Test_printBenchReport(t *testing.T) {
+	b := strings.Builder{}
+	printBenchReport(&b, []benchResult{
+		{Kind: "/cal", Duration: time.Millisecond, Cache: "MISS"},
+		{Kind: "/cal", Duration: time.Millisecond, Cache: "HIT"},
+		{Kind: "/cal", Duration: time.Millisecond, Cache: "HIT"},
+		{Kind: "/cal", Duration: time.Millisecond, Cache: "REVALIDATED"},
+		{Kind: "/courses", Duration: time.Millisecond, Failed: true},
+	}, time.Second)
+
+	assert.Equal(t, true, strings.Contains(b.String(), "5 requests"))
+	assert.Equal(t, true, strings.Contains(b.String(), "2 hits, 1 revalidated, 1 misses"))
+	assert.Equal(t, false, strings.Contains(b.String(), "rate limited"))
+
+	b.Reset()
+	printBenchReport(&b, []benchResult{
+		{Kind: "/cal", Duration: time.Millisecond, Cache: "MISS"},
+		{Kind: "/cal", Duration: time.Microsecond, Failed: true, RateLimited: true},
+	}, time.Second)
+	assert.Equal(t, true, strings.Contains(b.String(), "1 requests were rate limited"))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// paramError is a path or query parameter which is missing or invalid. The
+// message explains the accepted values, so it can be shown to the user.
+type paramError struct {
+	// Param is the name of the parameter, e.g. "anno"
+	Param   string
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *paramError) Error() string {
+	return e.Message
+}
+
+// writeParamError aborts the request with the problem details of the error.
+func writeParamError(ctx *gin.Context, err *paramError) {
+	writeProblem(ctx, err.Status, err.Code, err.Message)
+}
+
+// parseCourse parses the course id and finds the course.
+func parseCourse(courses *unibo_integ.CoursesMap, param string, raw string) (*unibo_integ.Course, *paramError) {
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, &paramError{param, http.StatusBadRequest, codeInvalidId,
+			fmt.Sprintf("course id must be a number, got %q", raw)}
+	}
+	return findCourse(courses, param, id)
+}
+
+// findCourse finds the course with the given id.
+func findCourse(courses *unibo_integ.CoursesMap, param string, id int) (*unibo_integ.Course, *paramError) {
+	course, found := courses.FindById(id)
+	if !found {
+		return nil, &paramError{param, http.StatusNotFound, codeCourseNotFound,
+			fmt.Sprintf("no course with id %d", id)}
+	}
+	return course, nil
+}
+
+// parseYear parses the year of the course.
+func parseYear(course *unibo_integ.Course, param string, raw string) (int, *paramError) {
+	year, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, &paramError{param, http.StatusBadRequest, codeInvalidYear,
+			fmt.Sprintf("year must be a number, got %q", raw)}
+	}
+	return year, checkYear(course, param, year)
+}
+
+// checkYear checks that the course has the year.
+func checkYear(course *unibo_integ.Course, param string, year int) *paramError {
+	if year >= 1 && year <= course.DurataAnni {
+		return nil
+	}
+
+	msg := fmt.Sprintf("year must be between 1 and %d for this course", course.DurataAnni)
+	if course.DurataAnni == 1 {
+		msg = "year must be 1 for this course"
+	}
+	return &paramError{param, http.StatusBadRequest, codeInvalidYear, msg}
+}
+
+// checkCurriculum checks that the year of the course has the curriculum.
+// An empty curriculum is the default one.
+//
+// If the curricula can't be retrieved the error has status 502, since the
+// curriculum can't be checked.
+func checkCurriculum(course *unibo_integ.Course, param string, year int, curr string) *paramError {
+	if curr == "" {
+		return nil
+	}
+
+	if !validCurriculum.MatchString(curr) {
+		return &paramError{param, http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("invalid curriculum %q", curr)}
+	}
+
+	curricula, err := getAllCurricula(course)
+	if err != nil {
+		return &paramError{param, http.StatusBadGateway, codeUpstreamUnavailable,
+			"unable to retrieve the curricula to check the curriculum"}
+	}
+
+	values := make([]string, 0, len(curricula[year]))
+	for _, c := range curricula[year] {
+		values = append(values, c.Value)
+	}
+	if slices.Contains(values, curr) {
+		return nil
+	}
+	return &paramError{param, http.StatusBadRequest, codeInvalidParameter,
+		fmt.Sprintf("unknown curriculum %q for year %d, expected one of: %s", curr, year, strings.Join(values, ", "))}
+}
+
+// validCurriculum matches the codes of the curricula, e.g. "A58-000". The
+// curriculum is part of the path of the snapshots, so its syntax is checked
+// even when the curricula can't be retrieved.
+var validCurriculum = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// curriculumErrorText returns the message shown in the pages for an error of
+// checkCurriculum.
+func curriculumErrorText(err *paramError) string {
+	if err.Status == http.StatusBadGateway {
+		return "Impossibile scaricare i curricula da Unibo, riprova più tardi"
+	}
+	return "Curriculum non valido"
+}
+
+// checkFormat checks that the format can be requested with ?format=.
+func checkFormat(format string) (feedFormat, *paramError) {
+	f, found := feedFormats[format]
+	if !found {
+		names := make([]string, 0, len(feedFormats))
+		for name := range feedFormats {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		return f, &paramError{"format", http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("format must be one of: %s", strings.Join(names, ", "))}
+	}
+	return f, nil
+}
+
+// feedParams identify the timetable of a course year.
+type feedParams struct {
+	Course *unibo_integ.Course
+	Year   int
+	// Curriculum is empty for the default one
+	Curriculum string
+}
+
+// bindFeed reads the :id and :anno path parameters and the ?curr= query
+// parameter.
+//
+// On error, the fields already read are set, e.g. the course when the year
+// is invalid.
+func bindFeed(ctx *gin.Context, courses *unibo_integ.CoursesMap) (feedParams, *paramError) {
+	var p feedParams
+	var err *paramError
+
+	p.Course, err = parseCourse(courses, "id", ctx.Param("id"))
+	if err != nil {
+		return p, err
+	}
+	p.Year, err = parseYear(p.Course, "anno", ctx.Param("anno"))
+	if err != nil {
+		return p, err
+	}
+	p.Curriculum = ctx.Query("curr")
+	return p, checkCurriculum(p.Course, "curr", p.Year, p.Curriculum)
+}
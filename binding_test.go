@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseYear(t *testing.T) {
+	course := testCourse(8009)
+
+	year, err := parseYear(&course, "anno", "2")
+	assert.Equal(t, 2, year)
+	assert.Equal(t, true, err == nil)
+
+	_, err = parseYear(&course, "anno", "4")
+	assert.Equal(t, "year must be between 1 and 3 for this course", err.Message)
+	assert.Equal(t, codeInvalidYear, err.Code)
+
+	_, err = parseYear(&course, "anno", "primo")
+	assert.Equal(t, `year must be a number, got "primo"`, err.Message)
+}
+
+func Test_parseCourse(t *testing.T) {
+	course, err := parseCourse(testCourses.Load(), "id", "9254")
+	assert.Equal(t, true, err == nil)
+	assert.Equal(t, "Cesena", course.Campus)
+
+	_, err = parseCourse(testCourses.Load(), "id", "1")
+	assert.Equal(t, http.StatusNotFound, err.Status)
+	assert.Equal(t, codeCourseNotFound, err.Code)
+
+	_, err = parseCourse(testCourses.Load(), "id", "abc")
+	assert.Equal(t, http.StatusBadRequest, err.Status)
+}
+
+func Test_checkFormat(t *testing.T) {
+	_, err := checkFormat("ics")
+	assert.Equal(t, true, err == nil)
+
+	_, err = checkFormat("pdf")
+	assert.Equal(t, "format must be one of: csv, ics, json, jsonfeed, md, org", err.Message)
+}
+
+func Test_checkCurriculum(t *testing.T) {
+	course := testCourse(8009)
+
+	assert.Equal(t, true, checkCurriculum(&course, "curr", 1, "") == nil)
+
+	for _, curr := range []string{"../../etc", "a/b", `a\b`, "..", "A58 000"} {
+		err := checkCurriculum(&course, "curr", 1, curr)
+		assert.Equal(t, http.StatusBadRequest, err.Status)
+		assert.Equal(t, codeInvalidParameter, err.Code)
+	}
+
+	curriculaCache.SetDefault("8009", map[int]curriculum.Curricula{1: {{Value: "000-000", Label: "Generale"}}})
+	assert.Equal(t, true, checkCurriculum(&course, "curr", 1, "000-000") == nil)
+	err := checkCurriculum(&course, "curr", 1, "A58-000")
+	assert.Equal(t, `unknown curriculum "A58-000" for year 1, expected one of: 000-000`, err.Message)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// builderGroup is a group of a module split in more groups (e.g. labs A-K and
+// L-Z).
+type builderGroup struct {
+	Code  string
+	Label string
+}
+
+// builderSubject is a subject that can be selected in the timetable builder.
+type builderSubject struct {
+	Code   string
+	Name   string
+	Groups []builderGroup
+}
+
+// builderSubjects lists the subjects of the timetable, with their groups if a
+// subject is split in more than one, sorted by name.
+func builderSubjects(t timetable.Timetable) []builderSubject {
+	indexes := make(map[string]int)
+	var subjects []builderSubject
+
+	for _, e := range t {
+		i, found := indexes[e.CodModulo]
+		if !found {
+			i = len(subjects)
+			indexes[e.CodModulo] = i
+			subjects = append(subjects, builderSubject{Code: e.CodModulo, Name: e.Title})
+		}
+
+		if e.CodSdoppiamento == "" || slices.ContainsFunc(subjects[i].Groups, func(g builderGroup) bool {
+			return g.Code == e.CodSdoppiamento
+		}) {
+			continue
+		}
+
+		// 28004_1--A-K -> A-K
+		_, label, found := strings.Cut(e.CodSdoppiamento, "--")
+		if !found {
+			label = e.CodSdoppiamento
+		}
+		subjects[i].Groups = append(subjects[i].Groups, builderGroup{Code: e.CodSdoppiamento, Label: label})
+	}
+
+	for i := range subjects {
+		if len(subjects[i].Groups) < 2 {
+			subjects[i].Groups = nil
+		}
+	}
+
+	slices.SortFunc(subjects, func(a, b builderSubject) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return subjects
+}
+
+// calendarPath returns the path of the calendar with the given selection.
+func calendarPath(courseId, year int, curr string, subjects []string) string {
+	p := fmt.Sprintf("/cal/%d/%d", courseId, year)
+
+	q := url.Values{}
+	if curr != "" {
+		q.Set("curr", curr)
+	}
+	if len(subjects) > 0 {
+		q.Set("subjects", strings.Join(subjects, ","))
+	}
+	if len(q) > 0 {
+		p += "?" + q.Encode()
+	}
+	return p
+}
+
+// timetableBuilder is a step by step form, working without JavaScript, to
+// pick a course, a year, a curriculum and the wanted subjects and groups,
+// producing the subscription URL for that exact selection.
+func timetableBuilder(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		snapshot := courses.Load()
+		data := gin.H{"courses": snapshot.ToList()}
+
+		course, found := snapshot.FindById(queryInt(ctx, "course"))
+		if !found {
+			renderHTML(ctx, http.StatusOK, "builder", data)
+			return
+		}
+		data["course"] = course
+
+		curricula, err := getAllCurricula(course)
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("unable to retrieve curricula: %w", err))
+		}
+
+		year := queryInt(ctx, "year")
+		if year <= 0 || year > course.DurataAnni {
+			data["curricula"] = curricula
+			renderHTML(ctx, http.StatusOK, "builder", data)
+			return
+		}
+		data["year"] = year
+
+		curr := ctx.Query("curr")
+		data["yearCurricula"] = curricula[year]
+		if perr := checkCurriculum(course, "curr", year, curr); perr != nil {
+			data["error"] = curriculumErrorText(perr) + "."
+			renderHTML(ctx, perr.Status, "builder", data)
+			return
+		}
+		data["curr"] = curr
+
+		if _, done := ctx.GetQuery("done"); done {
+			p := calendarPath(course.Codice, year, curr, ctx.QueryArray("subjects"))
+			data["path"] = appUrl(p)
+			data["webcal"] = template.URL(webcalUrl(ctx, p))
+			renderHTML(ctx, http.StatusOK, "builder", data)
+			return
+		}
+
+		t, err := getTimetable(course, year, curriculum.Curriculum{Value: curr})
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("unable to retrieve timetable: %w", err))
+			data["error"] = "Impossibile scaricare l'orario da Unibo, riprova più tardi."
+		}
+		data["subjects"] = builderSubjects(t)
+
+		renderHTML(ctx, http.StatusOK, "builder", data)
+	}
+}
+
+// queryInt returns the query parameter as an int, or 0 if missing or invalid.
+func queryInt(ctx *gin.Context, name string) int {
+	i, err := strconv.Atoi(ctx.Query(name))
+	if err != nil {
+		return 0
+	}
+	return i
+}
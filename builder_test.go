@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_builderSubjects(t *testing.T) {
+	subjects := builderSubjects(timetable.Timetable{
+		{CodModulo: "2_1", Title: "LAB", CodSdoppiamento: "2_1--A-K"},
+		{CodModulo: "2_1", Title: "LAB", CodSdoppiamento: "2_1--L-Z"},
+		{CodModulo: "1_1", Title: "ALGEBRA", CodSdoppiamento: "1_1"},
+		{CodModulo: "1_1", Title: "ALGEBRA", CodSdoppiamento: "1_1"},
+	})
+
+	assert.Equal(t, 2, len(subjects))
+	assert.Equal(t, "ALGEBRA", subjects[0].Name)
+	assert.Equal(t, 0, len(subjects[0].Groups))
+	assert.Equal(t, []builderGroup{{Code: "2_1--A-K", Label: "A-K"}, {Code: "2_1--L-Z", Label: "L-Z"}}, subjects[1].Groups)
+}
+
+func Test_calendarPath(t *testing.T) {
+	assert.Equal(t, "/cal/8009/1", calendarPath(8009, 1, "", nil))
+	assert.Equal(t, "/cal/8009/2?curr=A58-000&subjects=1_1%2C2_1--A-K", calendarPath(8009, 2, "A58-000", []string{"1_1", "2_1--A-K"}))
+}
+
+func Test_timetableBuilderStart(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/builder", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// buildCommit and buildDate are set when building, e.g.
+//
+//	go build -ldflags "-X main.buildCommit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Otherwise they're read from the VCS information embedded by go build.
+var (
+	buildCommit string
+	buildDate   string
+)
+
+// buildInfo identifies the running build, so that bug reports can be tied to
+// it.
+type buildInfo struct {
+	Commit string `json:"commit"`
+	Date   string `json:"date"`
+	// Modified is set when built from a tree with uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Features are the optional integrations that are configured and the
+	// enabled feature flags
+	Features []string `json:"features"`
+}
+
+func currentBuildInfo() buildInfo {
+	info := buildInfo{Commit: buildCommit, Date: buildDate, GoVersion: runtime.Version(), Features: enabledFeatures()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			case s.Key == "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
+
+// enabledFeatures returns the names of the configured integrations and of
+// the enabled feature flags (as "flag:<name>"), sorted.
+func enabledFeatures() []string {
+	features := []string{}
+	for name, enabled := range map[string]bool{
+		"mock":             config.Mock,
+		"tls":              config.TLSCertFile != "" && config.TLSKeyFile != "",
+		"http3":            config.HTTP3,
+		"google":           googleEnabled(),
+		"digest":           digestEnabled(),
+		"webpush":          webPushEnabled(),
+		"matrix":           matrixEnabled(),
+		"alerts":           alertsEnabled(),
+		"admin-basic":      adminBasicEnabled(),
+		"admin-oidc":       adminOidcEnabled(),
+		"admin-proxy":      adminProxyEnabled(),
+		"opendata-refresh": config.OpenDataRefreshInterval > 0,
+	} {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	slices.Sort(features)
+
+	for _, name := range featureFlags.EnabledNames() {
+		features = append(features, "flag:"+name)
+	}
+	return features
+}
+
+// versionHandler returns the build info.
+func versionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, currentBuildInfo())
+}
+
+// logBuildInfo logs the build info at startup.
+func logBuildInfo() {
+	info := currentBuildInfo()
+	log.Info().
+		Str("commit", info.Commit).
+		Str("build-date", info.Date).
+		Bool("modified", info.Modified).
+		Str("go-version", info.GoVersion).
+		Strs("features", info.Features).
+		Msg("Starting UniboCalendar")
+}
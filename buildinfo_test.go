@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_versionHandler(t *testing.T) {
+	buildCommit, buildDate = "0123abc", "2024-10-01T09:00:00Z"
+	featureFlags.Set(map[string]Flag{"strict_ics": {Enabled: true}, "event_dedup": {}})
+	defer func() {
+		buildCommit, buildDate = "", ""
+		featureFlags.Set(nil)
+	}()
+
+	w := httptest.NewRecorder()
+	setupRouter(testCourses).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var info buildInfo
+	_ = json.Unmarshal(w.Body.Bytes(), &info)
+	assert.Equal(t, "0123abc", info.Commit)
+	assert.Equal(t, "2024-10-01T09:00:00Z", info.Date)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, "flag:strict_ics", info.Features[len(info.Features)-1])
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+)
+
+// categoryTeaching is the category of the academic calendar entries that
+// are the teaching periods (semesters). They only tune the caches, see
+// adaptiveTTL, and are not added to the calendars.
+const categoryTeaching = "Periodo didattico"
+
+const (
+	// semesterStartWeeks are the first weeks of a semester, when the
+	// timetables still change daily.
+	semesterStartWeeks = 3
+	// ttlStartDivisor shortens the TTLs in the first weeks of a semester.
+	ttlStartDivisor = 4
+	// ttlBreakFactor lengthens the TTLs outside the teaching periods.
+	ttlBreakFactor = 6
+)
+
+// teachingPeriods returns the teaching periods of the academic calendar or,
+// if none is configured, the usual ones of Unibo around day: from mid
+// September to Christmas and from mid February to the beginning of June.
+func teachingPeriods(day time.Time) []academicEntry {
+	var periods []academicEntry
+	for _, e := range academicEntries() {
+		if e.Category == categoryTeaching {
+			periods = append(periods, e)
+		}
+	}
+	if periods != nil {
+		return periods
+	}
+
+	for _, year := range []int{day.Year() - 1, day.Year()} {
+		date := func(y int, month time.Month, d int) string {
+			return time.Date(y, month, d, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+		}
+		periods = append(periods,
+			academicEntry{Category: categoryTeaching, Start: date(year, time.September, 15), End: date(year, time.December, 22)},
+			academicEntry{Category: categoryTeaching, Start: date(year+1, time.February, 15), End: date(year+1, time.June, 7)},
+		)
+	}
+	return periods
+}
+
+// contains reports whether the entry includes the date of day.
+func (e academicEntry) contains(day string) bool {
+	return e.Start <= day && day <= e.End
+}
+
+// adaptiveTTL adapts a cache TTL to the academic calendar: in the first
+// weeks of a semester, when timetables churn daily, it's shorter, while
+// outside the teaching periods and during the holidays (see
+// academicCalendar), when they rarely change, it's longer.
+func adaptiveTTL(base time.Duration, now time.Time) time.Duration {
+	today := now.Format(time.DateOnly)
+
+	for _, e := range academicEntries() {
+		if e.Category == categoryHoliday && e.contains(today) {
+			return base * ttlBreakFactor
+		}
+	}
+
+	for _, period := range teachingPeriods(now) {
+		if !period.contains(today) {
+			continue
+		}
+		start, err := time.Parse(time.DateOnly, period.Start)
+		if err == nil && today < start.AddDate(0, 0, 7*semesterStartWeeks).Format(time.DateOnly) {
+			return base / ttlStartDivisor
+		}
+		return base
+	}
+	return base * ttlBreakFactor
+}
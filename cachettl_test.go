@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_adaptiveTTL(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 12, 0, 0, 0, time.UTC)
+	}
+	base := time.Minute * 10
+
+	// The usual teaching periods of Unibo
+	assert.Equal(t, base/ttlStartDivisor, adaptiveTTL(base, day(time.September, 20)))
+	assert.Equal(t, base, adaptiveTTL(base, day(time.November, 4)))
+	assert.Equal(t, base/ttlStartDivisor, adaptiveTTL(base, day(time.February, 20)))
+	assert.Equal(t, base*ttlBreakFactor, adaptiveTTL(base, day(time.August, 1)))
+	assert.Equal(t, base*ttlBreakFactor, adaptiveTTL(base, day(time.January, 15)))
+
+	defer academicCalendar.Store(academicCalendar.Load())
+	academicCalendar.Store(&[]academicEntry{
+		{Name: "Primo semestre", Category: categoryTeaching, Start: "2024-09-23", End: "2025-01-17"},
+		{Name: "Chiusura natalizia", Category: categoryHoliday, Start: "2024-12-23", End: "2025-01-06"},
+	})
+	assert.Equal(t, base*ttlBreakFactor, adaptiveTTL(base, day(time.September, 20)))
+	assert.Equal(t, base/ttlStartDivisor, adaptiveTTL(base, day(time.October, 1)))
+	assert.Equal(t, base*ttlBreakFactor, adaptiveTTL(base, day(time.December, 24)))
+	assert.Equal(t, base, adaptiveTTL(base, time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)))
+}
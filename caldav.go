@@ -0,0 +1,523 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+const (
+	davNs       = "DAV:"
+	calDavNs    = "urn:ietf:params:xml:ns:caldav"
+	calServerNs = "http://calendarserver.org/ns/"
+
+	// caldavRoot is both the principal and the calendar home of every client.
+	caldavRoot = "/caldav/"
+	// caldavDefaultCurriculum is the path segment used when the course has
+	// no curriculum.
+	caldavDefaultCurriculum = "default"
+
+	caldavAllow = "OPTIONS, GET, HEAD, PROPFIND, REPORT"
+)
+
+// davPrefixes are the prefixes used for the known namespaces in responses.
+var davPrefixes = map[string]string{
+	davNs:       "d",
+	calDavNs:    "c",
+	calServerNs: "cs",
+}
+
+// davResource is a collection or a single event exposed over CalDAV.
+type davResource struct {
+	Href     string
+	Name     string
+	Calendar bool
+	ETag     string
+	// Event is nil for collections
+	Event *timetable.Event
+	Opts  calOptions
+}
+
+func (r *davResource) collection() bool {
+	return r.Event == nil
+}
+
+// davProps are the properties we know how to answer. Each one returns
+// the inner XML of the property, and false if the resource does not have it.
+var davProps = map[xml.Name]func(r *davResource) (string, bool){
+	{Space: davNs, Local: "resourcetype"}: func(r *davResource) (string, bool) {
+		switch {
+		case r.Calendar:
+			return "<d:collection/><c:calendar/>", true
+		case r.collection():
+			return "<d:collection/>", true
+		}
+		return "", true
+	},
+	{Space: davNs, Local: "displayname"}: func(r *davResource) (string, bool) {
+		return escapeXml(r.Name), r.Name != ""
+	},
+	{Space: davNs, Local: "getetag"}: func(r *davResource) (string, bool) {
+		return escapeXml(r.ETag), r.ETag != ""
+	},
+	{Space: davNs, Local: "getcontenttype"}: func(r *davResource) (string, bool) {
+		return "text/calendar; charset=utf-8; component=vevent", !r.collection()
+	},
+	{Space: davNs, Local: "current-user-principal"}: func(r *davResource) (string, bool) {
+		return "<d:href>" + appUrl(caldavRoot) + "</d:href>", true
+	},
+	{Space: davNs, Local: "principal-URL"}: func(r *davResource) (string, bool) {
+		return "<d:href>" + appUrl(caldavRoot) + "</d:href>", true
+	},
+	{Space: davNs, Local: "current-user-privilege-set"}: func(r *davResource) (string, bool) {
+		return "<d:privilege><d:read/></d:privilege>", true
+	},
+	{Space: davNs, Local: "supported-report-set"}: func(r *davResource) (string, bool) {
+		return "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>", r.Calendar
+	},
+	{Space: calDavNs, Local: "calendar-home-set"}: func(r *davResource) (string, bool) {
+		return "<d:href>" + appUrl(caldavRoot) + "</d:href>", true
+	},
+	{Space: calDavNs, Local: "supported-calendar-component-set"}: func(r *davResource) (string, bool) {
+		return `<c:comp name="VEVENT"/>`, r.Calendar
+	},
+	{Space: calDavNs, Local: "calendar-data"}: func(r *davResource) (string, bool) {
+		if r.collection() {
+			return "", false
+		}
+		data, err := eventCalendarData(*r.Event, r.Opts)
+		if err != nil {
+			return "", false
+		}
+		return escapeXml(data), true
+	},
+	{Space: calServerNs, Local: "getctag"}: func(r *davResource) (string, bool) {
+		return escapeXml(r.ETag), r.Calendar
+	},
+}
+
+// davRequest is the body of a PROPFIND or REPORT request.
+type davRequest struct {
+	// Root is the name of the root element, which selects the REPORT type
+	Root    xml.Name
+	AllProp bool
+	Props   []xml.Name
+	// Hrefs are the resources listed in a calendar-multiget REPORT
+	Hrefs []string
+	// Start and End are the bounds of the time-range filter of a
+	// calendar-query REPORT, zero if missing
+	Start, End time.Time
+}
+
+// parseDavRequest parses the XML body of a PROPFIND or REPORT request.
+// An empty body is a request for every property.
+func parseDavRequest(body io.Reader) (davRequest, error) {
+	req := davRequest{}
+	decoder := xml.NewDecoder(body)
+
+	var stack []xml.Name
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return req, fmt.Errorf("invalid DAV request body: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				req.Root = t.Name
+			}
+			parent := xml.Name{}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			switch {
+			case t.Name.Space == davNs && t.Name.Local == "allprop":
+				req.AllProp = true
+			case parent.Space == davNs && parent.Local == "prop":
+				req.Props = append(req.Props, t.Name)
+			case t.Name.Space == calDavNs && t.Name.Local == "time-range":
+				for _, attr := range t.Attr {
+					value, _ := time.Parse("20060102T150405Z", attr.Value)
+					switch attr.Name.Local {
+					case "start":
+						req.Start = value
+					case "end":
+						req.End = value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1].Space == davNs && stack[len(stack)-1].Local == "href" {
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(string(t)))
+			}
+		}
+	}
+
+	if req.Root.Local == "" || (req.Root.Local == "propfind" && len(req.Props) == 0) {
+		req.AllProp = true
+	}
+	return req, nil
+}
+
+// names returns the properties the client asked for.
+func (r davRequest) names() []xml.Name {
+	if !r.AllProp {
+		return r.Props
+	}
+
+	names := make([]xml.Name, 0, len(davProps))
+	for name := range davProps {
+		// calendar-data is only returned when asked for
+		if name.Local != "calendar-data" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeMultistatus writes the properties of the resources, and a 404 status
+// for each of the missing hrefs.
+func writeMultistatus(ctx *gin.Context, resources []*davResource, names []xml.Name, missing []string) {
+	b := strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+
+	for _, res := range resources {
+		found := strings.Builder{}
+		notFound := strings.Builder{}
+		for _, name := range names {
+			prop, known := davProps[name]
+			value, ok := "", false
+			if known {
+				value, ok = prop(res)
+			}
+
+			if ok {
+				writeDavElement(&found, name, value)
+			} else {
+				writeDavElement(&notFound, name, "")
+			}
+		}
+
+		b.WriteString("<d:response><d:href>" + escapeXml(res.Href) + "</d:href>")
+		if found.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + found.String() + "</d:prop>")
+			b.WriteString("<d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if notFound.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + notFound.String() + "</d:prop>")
+			b.WriteString("<d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+
+	for _, href := range missing {
+		b.WriteString("<d:response><d:href>" + escapeXml(href) + "</d:href>")
+		b.WriteString("<d:status>HTTP/1.1 404 Not Found</d:status></d:response>")
+	}
+
+	b.WriteString("</d:multistatus>")
+	ctx.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+// writeDavElement writes an element, declaring its namespace if it is not
+// one of davPrefixes.
+func writeDavElement(b *strings.Builder, name xml.Name, value string) {
+	prefix, known := davPrefixes[name.Space]
+	tag := prefix + ":" + name.Local
+	attrs := ""
+	if !known {
+		tag = "x:" + name.Local
+		attrs = ` xmlns:x="` + escapeXml(name.Space) + `"`
+	}
+
+	if value == "" {
+		b.WriteString("<" + tag + attrs + "/>")
+		return
+	}
+	b.WriteString("<" + tag + attrs + ">" + value + "</" + tag + ">")
+}
+
+func escapeXml(s string) string {
+	b := strings.Builder{}
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// davEventName returns the name of the resource of the event.
+func davEventName(event timetable.Event) string {
+	return eventUid(event) + ".ics"
+}
+
+// davEventETag returns a tag which changes only when the event changes.
+func davEventETag(event timetable.Event) string {
+	return `"` + eventHash(event) + `"`
+}
+
+// eventCalendarData returns the calendar containing only the event.
+func eventCalendarData(event timetable.Event, opts calOptions) (string, error) {
+	cal, err := createEventsCal(timetable.Timetable{event}, opts)
+	if err != nil {
+		return "", err
+	}
+	return cal.Serialize(), nil
+}
+
+// davCalendarResources returns the calendar collection at href and the
+// events it contains.
+func davCalendarResources(t timetable.Timetable, href string, name string, opts calOptions) (*davResource, []*davResource) {
+	if opts.filters() {
+		t = filterTimetable(nil, t, opts)
+	}
+
+	events := make([]*davResource, 0, len(t))
+	ctag := sha1.New()
+	for i := range t {
+		etag := davEventETag(t[i])
+		ctag.Write([]byte(etag))
+		events = append(events, &davResource{
+			Href:  href + davEventName(t[i]),
+			ETag:  etag,
+			Event: &t[i],
+			Opts:  opts,
+		})
+	}
+
+	collection := &davResource{
+		Href:     href,
+		Name:     name,
+		Calendar: true,
+		ETag:     fmt.Sprintf(`"%x"`, ctag.Sum(nil)),
+	}
+	return collection, events
+}
+
+// davCalendar validates the path parameters and returns the resources of
+// the requested calendar.
+//
+// If something goes wrong, the error response is already written and the
+// boolean is false.
+func davCalendar(ctx *gin.Context, courses *unibo_integ.Courses) (*davResource, []*davResource, bool) {
+	// Every invalid path is a missing resource for DAV clients
+	course, perr := parseCourse(courses.Load(), "id", ctx.Param("id"))
+	if perr != nil {
+		ctx.String(http.StatusNotFound, perr.Message)
+		return nil, nil, false
+	}
+	year, perr := parseYear(course, "anno", ctx.Param("anno"))
+	if perr != nil {
+		ctx.String(http.StatusNotFound, perr.Message)
+		return nil, nil, false
+	}
+
+	curr := curriculum.Curriculum{}
+	if ctx.Param("curr") != caldavDefaultCurriculum {
+		curr.Value = ctx.Param("curr")
+	}
+	if perr := checkCurriculum(course, "curr", year, curr.Value); perr != nil {
+		status := http.StatusNotFound
+		if perr.Status == http.StatusBadGateway {
+			status = perr.Status
+		}
+		ctx.String(status, perr.Message)
+		return nil, nil, false
+	}
+
+	t, err := getTimetable(course, year, curr)
+	if errors.Is(err, errOverloaded) {
+		overloaded(ctx)
+		return nil, nil, false
+	} else if err != nil {
+		_ = ctx.Error(err)
+		ctx.String(http.StatusInternalServerError, "Unable to retrieve timetable")
+		return nil, nil, false
+	}
+
+	href := fmt.Sprintf("%s%d/%d/%s/", appUrl(caldavRoot), course.Codice, year, ctx.Param("curr"))
+	name := fmt.Sprintf("%s - %d year", course.Descrizione, year)
+	collection, events := davCalendarResources(t, href, name, parseCalOptions(ctx))
+	return collection, events, true
+}
+
+// davOptions advertises the CalDAV support.
+func davOptions(ctx *gin.Context) {
+	ctx.Header("DAV", "1, calendar-access")
+	ctx.Header("Allow", caldavAllow)
+	ctx.Status(http.StatusOK)
+}
+
+// davReadOnly refuses every request modifying the calendars.
+func davReadOnly(ctx *gin.Context) {
+	ctx.Header("Allow", caldavAllow)
+	ctx.String(http.StatusMethodNotAllowed, "Calendars are read-only")
+}
+
+// davRoot answers to the PROPFIND on the principal and calendar home.
+func davRoot(ctx *gin.Context) {
+	req, err := parseDavRequest(ctx.Request.Body)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	root := &davResource{Href: appUrl(caldavRoot), Name: "Unibo Calendar"}
+	writeMultistatus(ctx, []*davResource{root}, req.names(), nil)
+}
+
+// davCollection answers to PROPFIND, REPORT and GET on a calendar.
+func davCollection(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		collection, events, ok := davCalendar(ctx, courses)
+		if !ok {
+			return
+		}
+
+		req, err := parseDavRequest(ctx.Request.Body)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch ctx.Request.Method {
+		case "PROPFIND":
+			resources := []*davResource{collection}
+			if ctx.GetHeader("Depth") != "0" {
+				resources = append(resources, events...)
+			}
+			writeMultistatus(ctx, resources, req.names(), nil)
+
+		case "REPORT":
+			switch {
+			case req.Root.Space == calDavNs && req.Root.Local == "calendar-query":
+				writeMultistatus(ctx, filterDavEvents(events, req.Start, req.End), req.names(), nil)
+			case req.Root.Space == calDavNs && req.Root.Local == "calendar-multiget":
+				found, missing := multigetDavEvents(events, req.Hrefs)
+				writeMultistatus(ctx, found, req.names(), missing)
+			default:
+				ctx.Data(http.StatusForbidden, "application/xml; charset=utf-8",
+					[]byte(`<?xml version="1.0" encoding="utf-8"?><d:error xmlns:d="DAV:"><d:supported-report/></d:error>`))
+			}
+
+		default:
+			// The whole calendar is the same as /cal
+			target := fmt.Sprintf("/cal/%s/%s", ctx.Param("id"), ctx.Param("anno"))
+			if curr := ctx.Param("curr"); curr != caldavDefaultCurriculum {
+				target += "?curr=" + curr
+			}
+			ctx.Redirect(http.StatusFound, appUrl(target))
+		}
+	}
+}
+
+// davEvent answers to PROPFIND and GET on a single event.
+func davEvent(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		_, events, ok := davCalendar(ctx, courses)
+		if !ok {
+			return
+		}
+
+		found, _ := multigetDavEvents(events, []string{ctx.Param("event")})
+		if len(found) == 0 {
+			ctx.String(http.StatusNotFound, "Event not found")
+			return
+		}
+		event := found[0]
+
+		if ctx.Request.Method == "PROPFIND" {
+			req, err := parseDavRequest(ctx.Request.Body)
+			if err != nil {
+				ctx.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			writeMultistatus(ctx, found, req.names(), nil)
+			return
+		}
+
+		data, err := eventCalendarData(*event.Event, event.Opts)
+		if err != nil {
+			_ = ctx.Error(err)
+			ctx.String(http.StatusInternalServerError, "Unable to create calendar")
+			return
+		}
+		ctx.Header("ETag", event.ETag)
+		ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(data))
+	}
+}
+
+// filterDavEvents returns the events overlapping the given time range.
+// Zero bounds are open.
+func filterDavEvents(events []*davResource, start, end time.Time) []*davResource {
+	filtered := make([]*davResource, 0, len(events))
+	for _, e := range events {
+		if !start.IsZero() && !e.Event.End.After(start) {
+			continue
+		}
+		if !end.IsZero() && !e.Event.Start.Before(end) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// multigetDavEvents returns the events with the given hrefs, and the hrefs
+// which do not match any event.
+func multigetDavEvents(events []*davResource, hrefs []string) (found []*davResource, missing []string) {
+	byName := make(map[string]*davResource, len(events))
+	for _, e := range events {
+		byName[path.Base(e.Href)] = e
+	}
+
+	for _, href := range hrefs {
+		if e, ok := byName[path.Base(href)]; ok {
+			found = append(found, e)
+		} else {
+			missing = append(missing, href)
+		}
+	}
+	return found, missing
+}
+
+// setupCalDav registers the read-only CalDAV endpoints under caldavRoot.
+func setupCalDav(r *gin.Engine, courses *unibo_integ.Courses, middlewares ...gin.HandlerFunc) {
+	dav := r.Group(caldavRoot, middlewares...)
+
+	collection := davCollection(courses)
+	event := davEvent(courses)
+
+	for _, method := range []string{"PROPFIND", "REPORT", "GET", "HEAD"} {
+		dav.Handle(method, "/:id/:anno/:curr/", collection)
+	}
+	for _, method := range []string{"PROPFIND", "GET", "HEAD"} {
+		dav.Handle(method, "/:id/:anno/:curr/:event", event)
+	}
+	dav.Handle("PROPFIND", "/", davRoot)
+
+	dav.OPTIONS("/*path", davOptions)
+	for _, method := range []string{"PUT", "DELETE", "PROPPATCH", "MKCOL", "MKCALENDAR", "MOVE", "COPY", "LOCK"} {
+		dav.Handle(method, "/*path", davReadOnly)
+	}
+}
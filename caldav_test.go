@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseDavRequest(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+    <c:time-range start="20241001T100000Z" end="20241002T000000Z"/>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+
+	req, err := parseDavRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "calendar-query", req.Root.Local)
+	assert.Equal(t, false, req.AllProp)
+	assert.Equal(t, 2, len(req.Props))
+	assert.Equal(t, time.Date(2024, time.October, 1, 10, 0, 0, 0, time.UTC), req.Start)
+
+	req, err = parseDavRequest(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, req.AllProp)
+}
+
+func Test_davCalendarResources(t *testing.T) {
+	tt := testTimetable()
+	collection, events := davCalendarResources(tt, "/caldav/8009/1/default/", "INFORMATICA - 1 year", calOptions{})
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "/caldav/8009/1/default/"+eventUid(tt[0])+".ics", events[0].Href)
+
+	// ALGEBRA ends at 11:00 in Rome, which is 09:00 UTC
+	start := time.Date(2024, time.October, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, len(filterDavEvents(events, start, time.Time{})))
+
+	found, missing := multigetDavEvents(events, []string{events[1].Href, "/caldav/8009/1/default/nope.ics"})
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, 1, len(missing))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+
+	req, _ := parseDavRequest(strings.NewReader(
+		`<d:propfind xmlns:d="DAV:" xmlns:x="urn:example"><d:prop><d:resourcetype/><x:unknown/></d:prop></d:propfind>`))
+	writeMultistatus(ctx, []*davResource{collection}, req.names(), nil)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	body := w.Body.String()
+	assert.Equal(t, true, strings.Contains(body, "<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>"))
+	assert.Equal(t, true, strings.Contains(body, `<x:unknown xmlns:x="urn:example"/>`))
+	assert.Equal(t, true, strings.Contains(body, "HTTP/1.1 404 Not Found"))
+}
+
+func Test_davReadOnly(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/caldav/8009/1/default/event.ics", http.NoBody)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/caldav/8009/1/default/", http.NoBody)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "1, calendar-access", w.Header().Get("DAV"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PROPFIND", "/caldav/", http.NoBody)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+}
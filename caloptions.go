@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// calOptions are the query parameters changing how a calendar is generated.
+type calOptions struct {
+	// Subjects are the module codes to include. Nil means every subject.
+	Subjects []string
+	// Types are the lesson types to include, see lessonType. Nil means
+	// every type.
+	Types []string
+	// SkipDays are the weekdays whose lessons are excluded.
+	SkipDays []time.Weekday
+	// Period is the semester whose lessons are included, see lessonSemester.
+	// Zero means both.
+	Period int
+	// Merge joins the consecutive lessons of the same module in a single
+	// event, see mergeAdjacentEvents.
+	Merge bool
+	// Titles is the ?titles= mode of the event names, see eventSummary.
+	Titles string
+	// TitleCase converts the uppercase names of the teachings to title
+	// case, see titleCase.
+	TitleCase bool
+	// Combined adds the exam sessions and the holidays to the lessons, see
+	// academicEvents.
+	Combined bool
+	// Strict enables the strict RFC 5545 compliance mode, see sanitizeEvent.
+	Strict bool
+	// Recurring emits the weekly lessons as recurring events, see
+	// addRecurringLessons.
+	Recurring bool
+	// Format is the name of the wanted representation, see feedFormats.
+	Format string
+	// Lang is the language of the names of the teachings, empty for the
+	// upstream ones. See localizeEvent.
+	Lang string
+	// Stale adds a warning that the timetable may be outdated, see
+	// addStaleWarning. It's not a query parameter: it's set when the
+	// upstream is failing.
+	Stale bool
+	// Curriculum is the curriculum of the course feed, part of the identity
+	// of the calendar (see calendarIdentity). It's not a query parameter:
+	// it's set from the bound feed, and it's already part of the cache keys.
+	Curriculum string
+}
+
+// parseCalOptions reads the calendar options from the query parameters.
+func parseCalOptions(ctx *gin.Context) calOptions {
+	opts := calOptions{}
+
+	subjectIds := ctx.Query("subjects")
+	if subjectIds != "" {
+		tmp := strings.Split(subjectIds, ",")
+		for i := range tmp {
+			if len(tmp[i]) != 0 {
+				opts.Subjects = append(opts.Subjects, tmp[i])
+			}
+		}
+		log.Debug().Strs("subjects", opts.Subjects).Msg("queried subjects")
+	}
+	slices.Sort(opts.Subjects)
+
+	opts.Types = parseLessonTypes(strings.Split(ctx.Query("types"), ","))
+	opts.SkipDays = parseSkipDays(strings.Split(ctx.Query("skip_days"), ","))
+
+	opts.Period = parsePeriod(ctx.Query("period"), time.Now())
+
+	// ?strict=1 forces the strict mode, otherwise the feature flag decides
+	strict, err := strconv.ParseBool(ctx.Query("strict"))
+	if err == nil {
+		opts.Strict = strict
+	} else {
+		opts.Strict = featureFlags.EnabledFor(flagStrictIcs, ctx.Request.URL.Path)
+	}
+
+	// ?rrule=1 forces the recurring events, otherwise the feature flag decides
+	recurring, err := strconv.ParseBool(ctx.Query("rrule"))
+	if err == nil {
+		opts.Recurring = recurring
+	} else {
+		opts.Recurring = featureFlags.EnabledFor(flagRruleCompression, ctx.Request.URL.Path)
+	}
+
+	opts.Merge, _ = strconv.ParseBool(ctx.Query("merge"))
+	opts.Combined, _ = strconv.ParseBool(ctx.Query("combined"))
+	opts.TitleCase, _ = strconv.ParseBool(ctx.Query("titlecase"))
+
+	switch titles := ctx.Query("titles"); titles {
+	case titlesShort, titlesCode:
+		opts.Titles = titles
+	default:
+		opts.Titles = titlesFull
+	}
+
+	opts.Format = requestFormat(ctx)
+
+	opts.Lang = requestLang(ctx)
+
+	return opts
+}
+
+// parseLessonTypes returns the sorted lesson types of the names, see
+// lessonTypeAliases. The unknown names are skipped.
+func parseLessonTypes(names []string) []string {
+	var types []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if t, found := lessonTypeAliases[name]; found && !slices.Contains(types, t) {
+			types = append(types, t)
+		} else if name != "" && !found {
+			log.Debug().Str("type", name).Msg("unknown lesson type")
+		}
+	}
+	slices.Sort(types)
+	return types
+}
+
+// parseSkipDays returns the sorted weekdays of the names, see parseWeekday.
+// The unknown names are skipped.
+func parseSkipDays(names []string) []time.Weekday {
+	var days []time.Weekday
+	for _, name := range names {
+		day, found := parseWeekday(name)
+		if found && !slices.Contains(days, day) {
+			days = append(days, day)
+		}
+	}
+	slices.Sort(days)
+	return days
+}
+
+// key returns a string identifying the options, to be used in cache keys.
+func (o calOptions) key() string {
+	return fmt.Sprintf("%s-%s-%v-%d-%t-%s-%t-%t-%t-%t-%s-%s", o.Subjects, o.Types, o.SkipDays, o.Period, o.Merge, o.Titles, o.TitleCase, o.Combined, o.Strict, o.Recurring, o.Format, o.Lang)
+}
+
+// filters reports whether the options exclude some events.
+func (o calOptions) filters() bool {
+	return o.Subjects != nil || o.Types != nil || o.SkipDays != nil || o.Period != 0
+}
+
+// includes reports whether the event passes the filters of the options.
+func (o calOptions) includes(e timetable.Event) bool {
+	if o.Subjects != nil && !slices.Contains(o.Subjects, e.CodModulo) &&
+		(e.CodSdoppiamento == "" || !slices.Contains(o.Subjects, e.CodSdoppiamento)) {
+		return false
+	}
+	if o.Types != nil && !slices.Contains(o.Types, lessonType(e)) {
+		return false
+	}
+	if o.SkipDays != nil && slices.Contains(o.SkipDays, e.Start.Weekday()) {
+		return false
+	}
+	if o.Period != 0 && lessonSemester(e) != o.Period {
+		return false
+	}
+	return true
+}
+
+// filterTimetable appends to dst the events of t included by the options,
+// and returns the extended slice.
+func filterTimetable(dst, t timetable.Timetable, opts calOptions) timetable.Timetable {
+	for _, e := range t {
+		if opts.includes(e) {
+			dst = append(dst, e)
+		}
+	}
+	return dst
+}
+
+// weekdayPrefixes are the first three letters of the weekdays, in English
+// and Italian.
+var weekdayPrefixes = map[string]time.Weekday{
+	"sun": time.Sunday, "dom": time.Sunday,
+	"mon": time.Monday, "lun": time.Monday,
+	"tue": time.Tuesday, "mar": time.Tuesday,
+	"wed": time.Wednesday, "mer": time.Wednesday,
+	"thu": time.Thursday, "gio": time.Thursday,
+	"fri": time.Friday, "ven": time.Friday,
+	"sat": time.Saturday, "sab": time.Saturday,
+}
+
+// parseWeekday parses a weekday from its English or Italian name, also
+// abbreviated (e.g. "sat", "Saturday", "sab", "sabato").
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 {
+		return 0, false
+	}
+	day, found := weekdayPrefixes[name[:3]]
+	return day, found
+}
+
+// applyCalOptions returns the events of t as requested by the options:
+// filtered, merged, localized and with the titles cased. t is not modified.
+func applyCalOptions(t timetable.Timetable, opts calOptions) timetable.Timetable {
+	if opts.filters() {
+		t = filterTimetable(nil, t, opts)
+	}
+	if opts.Merge {
+		t = mergeAdjacentEvents(t)
+	}
+	t = localizeTimetable(t, opts.Lang)
+	if !opts.TitleCase {
+		return t
+	}
+
+	res := make(timetable.Timetable, len(t))
+	for i, e := range t {
+		e.Title = titleCase(e.Title)
+		res[i] = e
+	}
+	return res
+}
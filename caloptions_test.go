@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseCalOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/cal/8009/1?skip_days=sabato,Mon,xyz,sat&types=lab,unknown", nil)
+
+	opts := parseCalOptions(ctx)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Saturday}, opts.SkipDays)
+	assert.Equal(t, []string{lessonLab}, opts.Types)
+	assert.Equal(t, true, opts.filters())
+}
+
+func Test_filterTimetableBySkipDays(t *testing.T) {
+	tt := testTimetable()
+	// 2024-10-01 is a tuesday
+	assert.Equal(t, 0, len(filterTimetable(nil, tt, calOptions{SkipDays: []time.Weekday{time.Tuesday}})))
+	assert.Equal(t, 2, len(filterTimetable(nil, tt, calOptions{SkipDays: []time.Weekday{time.Saturday}})))
+}
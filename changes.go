@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// Kinds of schedule changes
+const (
+	changeAdded     = "added"
+	changeCancelled = "cancelled"
+	changeRoom      = "room"
+)
+
+// scheduleChange is a change of an upcoming lesson between two fetches of
+// the same timetable.
+type scheduleChange struct {
+	Kind  string
+	Event timetable.Event
+	// OldRoom is the previous room of a changeRoom
+	OldRoom string
+}
+
+// changeDetector compares every timetable fetched with the previous one of
+// the same feed, and notifies the listeners of the changes.
+type changeDetector struct {
+	mu        sync.Mutex
+	last      map[feedRef]map[string]timetable.Event
+	listeners []func(feed feedRef, changes []scheduleChange)
+}
+
+var scheduleChanges = &changeDetector{last: map[feedRef]map[string]timetable.Event{}}
+
+// onChange registers a function called, in its own goroutine, with the
+// changes of every feed.
+func (cd *changeDetector) onChange(fn func(feed feedRef, changes []scheduleChange)) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.listeners = append(cd.listeners, fn)
+}
+
+// observe saves the timetable of the feed and notifies the changes since the
+// previous one. The first timetable of a feed has no changes.
+func (cd *changeDetector) observe(feed feedRef, t timetable.Timetable, now time.Time) {
+	current := make(map[string]timetable.Event, len(t))
+	for _, e := range t {
+		current[eventUid(e)] = e
+	}
+
+	cd.mu.Lock()
+	previous, found := cd.last[feed]
+	cd.last[feed] = current
+	listeners := slices.Clone(cd.listeners)
+	cd.mu.Unlock()
+
+	if !found {
+		return
+	}
+	changes := diffSchedule(previous, current, now)
+	if len(changes) == 0 {
+		return
+	}
+	for _, fn := range listeners {
+		go fn(feed, changes)
+	}
+}
+
+// forget removes the last timetable of the feed: its next one will have no
+// changes.
+func (cd *changeDetector) forget(feed feedRef) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	delete(cd.last, feed)
+}
+
+// diffSchedule returns the changes of the lessons not yet ended at now,
+// sorted by start. A lesson moved to another time is a cancellation and an
+// addition, because the UID of the events depends on their time.
+func diffSchedule(previous, current map[string]timetable.Event, now time.Time) []scheduleChange {
+	var changes []scheduleChange
+	for uid, e := range current {
+		if !e.End.After(now) {
+			continue
+		}
+		old, found := previous[uid]
+		if !found {
+			changes = append(changes, scheduleChange{Kind: changeAdded, Event: e})
+		} else if eventRoom(old) != eventRoom(e) {
+			changes = append(changes, scheduleChange{Kind: changeRoom, Event: e, OldRoom: eventRoom(old)})
+		}
+	}
+	for uid, e := range previous {
+		if _, found := current[uid]; !found && e.End.After(now) {
+			changes = append(changes, scheduleChange{Kind: changeCancelled, Event: e})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b scheduleChange) int {
+		return a.Event.Start.Compare(b.Event.Start.Time)
+	})
+	return changes
+}
+
+// String describes the change in a short sentence, for the notifications.
+func (c scheduleChange) String() string {
+	when := c.Event.Start.Format("02/01 15:04")
+	switch c.Kind {
+	case changeAdded:
+		return fmt.Sprintf("Nuova lezione: %s, %s", c.Event.Title, when)
+	case changeCancelled:
+		return fmt.Sprintf("Lezione annullata: %s, %s", c.Event.Title, when)
+	case changeRoom:
+		return fmt.Sprintf("Cambio aula: %s, %s, ora in %s", c.Event.Title, when, eventRoom(c.Event))
+	}
+	return c.Event.Title
+}
+
+// filterChanges returns the changes of the lessons with the module codes,
+// or every change if codes is nil.
+func filterChanges(changes []scheduleChange, codes []string) []scheduleChange {
+	if codes == nil {
+		return changes
+	}
+
+	var filtered []scheduleChange
+	for _, c := range changes {
+		if len(filterTimetableBySubjects(nil, timetable.Timetable{c.Event}, codes)) > 0 {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_diffSchedule(t *testing.T) {
+	tt := testTimetable()
+	now := tt[0].Start.AddDate(0, 0, -1)
+	index := func(t timetable.Timetable) map[string]timetable.Event {
+		m := map[string]timetable.Event{}
+		for _, e := range t {
+			m[eventUid(e)] = e
+		}
+		return m
+	}
+
+	changed := testTimetable()
+	changed[0].Classrooms = []timetable.Classroom{{ResourceDesc: "AULA 2"}}
+	changed = changed[:1]
+
+	changes := diffSchedule(index(tt), index(changed), now)
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, changeRoom, changes[0].Kind)
+	assert.Equal(t, "AULA 1", changes[0].OldRoom)
+	assert.Equal(t, "Cambio aula: ALGEBRA, 01/10 09:00, ora in AULA 2", changes[0].String())
+	assert.Equal(t, changeCancelled, changes[1].Kind)
+	assert.Equal(t, "ANALISI MATEMATICA", changes[1].Event.Title)
+
+	// Past lessons are not changes
+	assert.Equal(t, 0, len(diffSchedule(index(tt), index(changed), now.AddDate(1, 0, 0))))
+
+	assert.Equal(t, 1, len(filterChanges(changes, []string{"00002_1"})))
+}
+
+func Test_changeDetector(t *testing.T) {
+	cd := &changeDetector{last: map[feedRef]map[string]timetable.Event{}}
+	notified := make(chan []scheduleChange, 1)
+	cd.onChange(func(feed feedRef, changes []scheduleChange) {
+		notified <- changes
+	})
+
+	feed := feedRef{Course: 8009, Year: 1}
+	now := testTimetable()[0].Start.AddDate(0, 0, -1)
+	cd.observe(feed, testTimetable(), now)
+	cd.observe(feed, testTimetable()[:1], now)
+
+	select {
+	case changes := <-notified:
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, changeCancelled, changes[0].Kind)
+	case <-time.After(time.Second):
+		t.Fatal("changes not notified")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSubscriptionSize is the size after which some calendar clients refuse
+// or silently truncate a subscription.
+const maxSubscriptionSize = 1 << 20
+
+// feedCheck is the result of checking a calendar the way a client would
+// when subscribing to it.
+type feedCheck struct {
+	Path        string       `json:"path"`
+	Status      int          `json:"status"`
+	ContentType string       `json:"content_type"`
+	Size        int          `json:"size"`
+	Events      int          `json:"events"`
+	LatencyMs   int64        `json:"latency_ms"`
+	Valid       bool         `json:"valid"`
+	Problems    []icsProblem `json:"problems"`
+	Warnings    []string     `json:"warnings"`
+}
+
+// checkFeed requests the calendar at the given path and reports everything
+// that could make a subscription to it fail.
+func checkFeed(r *gin.Engine, p string) (feedCheck, error) {
+	start := time.Now()
+	w, err := serveSelf(r, p)
+	if err != nil {
+		return feedCheck{}, err
+	}
+
+	check := feedCheck{
+		Path:        p,
+		Status:      w.Code,
+		ContentType: w.Header().Get("Content-Type"),
+		Size:        w.Body.Len(),
+		LatencyMs:   time.Since(start).Milliseconds(),
+		Problems:    []icsProblem{},
+		Warnings:    []string{},
+	}
+
+	if w.Code != http.StatusOK {
+		check.Warnings = append(check.Warnings,
+			fmt.Sprintf("the calendar returned status %d: %s", w.Code, strings.TrimSpace(w.Body.String())))
+		return check, nil
+	}
+
+	data := w.Body.Bytes()
+	check.Events = bytes.Count(data, []byte("BEGIN:VEVENT"))
+	problems := validateICS(data)
+	if problems != nil {
+		check.Problems = problems
+	}
+	check.Valid = len(check.Problems) == 0
+
+	if !strings.HasPrefix(check.ContentType, "text/calendar") {
+		check.Warnings = append(check.Warnings, "the content type is not text/calendar")
+	}
+	if check.Events == 0 {
+		check.Warnings = append(check.Warnings, "the calendar does not contain any lesson")
+	}
+	if check.Size > maxSubscriptionSize {
+		check.Warnings = append(check.Warnings, "the calendar is larger than 1 MB, some clients may refuse it")
+	}
+	return check, nil
+}
+
+// apiCheck checks the calendar of the course year, with the same query
+// parameters of /cal.
+func apiCheck(r *gin.Engine) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		id, anno := ctx.Param("id"), ctx.Param("anno")
+		if _, err := strconv.Atoi(id); err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidId, "Invalid course id")
+			return
+		}
+		if _, err := strconv.Atoi(anno); err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidYear, "Invalid year")
+			return
+		}
+
+		p := fmt.Sprintf("/cal/%s/%s", url.PathEscape(id), url.PathEscape(anno))
+		if ctx.Request.URL.RawQuery != "" {
+			p += "?" + ctx.Request.URL.RawQuery
+		}
+
+		check, err := checkFeed(r, p)
+		if err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusOK, check)
+	}
+}
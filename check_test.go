@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_checkFeed(t *testing.T) {
+	course := testCourse(8009)
+	cal, err := createCal(testTimetable(), &course, 1, calOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/cal/8009/1", func(c *gin.Context) {
+		successCalendar(c, []byte(cal.Serialize()))
+	})
+
+	check, err := checkFeed(r, "/cal/8009/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, check.Status)
+	assert.Equal(t, 2, check.Events)
+	assert.Equal(t, true, check.Valid)
+	assert.Equal(t, 0, len(check.Warnings))
+
+	check, err = checkFeed(r, "/cal/8009/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, check.Status)
+	assert.Equal(t, false, check.Valid)
+	assert.Equal(t, 1, len(check.Warnings))
+
+	_, err = checkFeed(r, "/status")
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// parseFeedSpec parses a course year written as "course-year" or
+// "course-year-curriculum", e.g. "8010-2" or "9254-1-A58-000".
+func parseFeedSpec(courses *unibo_integ.CoursesMap, param string, raw string) (feedParams, *paramError) {
+	var p feedParams
+
+	parts := strings.SplitN(raw, "-", 3)
+	if len(parts) < 2 {
+		return p, &paramError{param, http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("%s must be in the form course-year or course-year-curriculum, got %q", param, raw)}
+	}
+
+	var err *paramError
+	p.Course, err = parseCourse(courses, param, parts[0])
+	if err != nil {
+		return p, err
+	}
+	p.Year, err = parseYear(p.Course, param, parts[1])
+	if err != nil {
+		return p, err
+	}
+	if len(parts) == 3 {
+		p.Curriculum = parts[2]
+	}
+	return p, checkCurriculum(p.Course, param, p.Year, p.Curriculum)
+}
+
+// lessonsOverlap reports whether the lessons of the same day overlap.
+func lessonsOverlap(a, b weekLesson) bool {
+	// The times are in the HH:MM format, so they compare as strings
+	return a.Start < b.End && b.Start < a.End
+}
+
+// markOverlaps sets Overlap on the lessons of a and b, the grids of the same
+// week, that overlap a lesson of the other grid.
+func markOverlaps(a, b []weekDay) {
+	for i := range a {
+		for j := range a[i].Lessons {
+			for k := range b[i].Lessons {
+				if lessonsOverlap(a[i].Lessons[j], b[i].Lessons[k]) {
+					a[i].Lessons[j].Overlap = true
+					b[i].Lessons[k].Overlap = true
+				}
+			}
+		}
+	}
+}
+
+// compareColumn is a timetable of the comparison page.
+type compareColumn struct {
+	Course     *unibo_integ.Course
+	Year       int
+	Curriculum string
+	Days       []weekDay
+	// Error is set when the timetable can't be retrieved
+	Error string
+}
+
+// newCompareColumn retrieves the timetable of the week starting on monday.
+func newCompareColumn(feed feedParams, monday time.Time) compareColumn {
+	col := compareColumn{Course: feed.Course, Year: feed.Year, Curriculum: feed.Curriculum}
+
+	t, err := getTimetable(feed.Course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+	if err != nil {
+		col.Error = "Impossibile scaricare l'orario da Unibo, riprova più tardi."
+	}
+	col.Days = newWeekGrid(t, monday)
+	return col
+}
+
+// comparePage shows two timetables side by side, a week at a time,
+// highlighting the overlapping lessons, for students choosing between two
+// courses or curricula. The timetables are given as ?a= and ?b= in the form
+// accepted by parseFeedSpec, and the week as ?week=.
+func comparePage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		data := gin.H{"a": c.Query("a"), "b": c.Query("b")}
+		if c.Query("a") == "" || c.Query("b") == "" {
+			renderHTML(c, http.StatusOK, "compare", data)
+			return
+		}
+
+		snapshot := courses.Load()
+		a, perr := parseFeedSpec(snapshot, "a", c.Query("a"))
+		var b feedParams
+		if perr == nil {
+			b, perr = parseFeedSpec(snapshot, "b", c.Query("b"))
+		}
+		var monday time.Time
+		if perr == nil {
+			monday, perr = parseWeek("week", c.Query("week"))
+		}
+		if perr != nil {
+			data["error"] = perr.Message
+			renderHTML(c, perr.Status, "compare", data)
+			return
+		}
+
+		renderComparison(c, data, a, b, monday)
+	}
+}
+
+// renderComparison renders the timetables of a and b in the week starting
+// on monday.
+func renderComparison(c *gin.Context, data gin.H, a, b feedParams, monday time.Time) {
+	colA := newCompareColumn(a, monday)
+	colB := newCompareColumn(b, monday)
+	markOverlaps(colA.Days, colB.Days)
+
+	rows := make([][2]weekDay, len(colA.Days))
+	overlaps := 0
+	for i := range rows {
+		rows[i] = [2]weekDay{colA.Days[i], colB.Days[i]}
+		for _, l := range colA.Days[i].Lessons {
+			if l.Overlap {
+				overlaps++
+			}
+		}
+	}
+
+	weekPath := func(week time.Time) string {
+		q := url.Values{}
+		q.Set("a", data["a"].(string))
+		q.Set("b", data["b"].(string))
+		q.Set("week", week.Format(time.DateOnly))
+		return appUrl("/compare?" + q.Encode())
+	}
+
+	data["columns"] = [2]compareColumn{colA, colB}
+	data["rows"] = rows
+	data["overlaps"] = overlaps
+	data["monday"] = monday.Format("02/01/2006")
+	data["previous"] = weekPath(monday.AddDate(0, 0, -7))
+	data["next"] = weekPath(monday.AddDate(0, 0, 7))
+	renderHTML(c, http.StatusOK, "compare", data)
+}
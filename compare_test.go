@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseFeedSpec(t *testing.T) {
+	courses := testCourses.Load()
+
+	p, err := parseFeedSpec(courses, "a", "8009-1")
+	assert.Equal(t, (*paramError)(nil), err)
+	assert.Equal(t, 8009, p.Course.Codice)
+	assert.Equal(t, 1, p.Year)
+	assert.Equal(t, "", p.Curriculum)
+
+	_, err = parseFeedSpec(courses, "a", "8009")
+	assert.Equal(t, http.StatusBadRequest, err.Status)
+	_, err = parseFeedSpec(courses, "a", "1-1")
+	assert.Equal(t, http.StatusNotFound, err.Status)
+	_, err = parseFeedSpec(courses, "b", "8009-9")
+	assert.Equal(t, "b", err.Param)
+	assert.Equal(t, codeInvalidYear, err.Code)
+}
+
+func Test_markOverlaps(t *testing.T) {
+	a := []weekDay{{Lessons: []weekLesson{{Start: "09:00", End: "11:00"}, {Start: "14:00", End: "16:00"}}}, {}}
+	b := []weekDay{{Lessons: []weekLesson{{Start: "11:00", End: "13:00"}, {Start: "15:00", End: "17:00"}}}, {}}
+
+	markOverlaps(a, b)
+	// Lessons ending when the other starts don't overlap
+	assert.Equal(t, false, a[0].Lessons[0].Overlap)
+	assert.Equal(t, false, b[0].Lessons[0].Overlap)
+	assert.Equal(t, true, a[0].Lessons[1].Overlap)
+	assert.Equal(t, true, b[0].Lessons[1].Overlap)
+}
+
+func Test_comparePage(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/compare", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `name="a"`))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/compare?a=8009-1&b=nope", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "course-year"))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// errOverloaded is returned when a request waited too long for a free slot.
+var errOverloaded = errors.New("too many concurrent requests")
+
+// semaphore limits the number of goroutines doing something at the same time.
+type semaphore chan struct{}
+
+// acquire waits for a free slot, until the context is done.
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errOverloaded
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// keyedSemaphore is a set of semaphores, one for every key, created when
+// needed and removed when unused.
+type keyedSemaphore struct {
+	size  int
+	mutex sync.Mutex
+	sems  map[string]semaphore
+	users map[string]int
+}
+
+func newKeyedSemaphore(size int) *keyedSemaphore {
+	return &keyedSemaphore{
+		size:  max(size, 1),
+		sems:  make(map[string]semaphore),
+		users: make(map[string]int),
+	}
+}
+
+// acquire waits for a free slot of the key. On success, release must be
+// called when done.
+func (k *keyedSemaphore) acquire(ctx context.Context, key string) error {
+	k.mutex.Lock()
+	sem, found := k.sems[key]
+	if !found {
+		sem = make(semaphore, k.size)
+		k.sems[key] = sem
+	}
+	k.users[key]++
+	k.mutex.Unlock()
+
+	err := sem.acquire(ctx)
+	if err != nil {
+		k.done(key)
+	}
+	return err
+}
+
+func (k *keyedSemaphore) release(key string) {
+	k.mutex.Lock()
+	sem := k.sems[key]
+	k.mutex.Unlock()
+
+	sem.release()
+	k.done(key)
+}
+
+func (k *keyedSemaphore) done(key string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.users[key]--
+	if k.users[key] == 0 {
+		delete(k.users, key)
+		delete(k.sems, key)
+	}
+}
+
+var (
+	// feedGenerations limits the concurrent generations of the same feed
+	feedGenerations = newKeyedSemaphore(config.FeedConcurrency)
+	// upstreamFetches limits the concurrent requests to the Unibo website
+	upstreamFetches = make(semaphore, max(config.UpstreamConcurrency, 1))
+)
+
+// withUpstreamSlot runs fetch once a slot for an upstream request is free,
+// waiting at most [Config.QueueTimeout]. The context of fetch, derived from
+// parent, expires after [Config.UpstreamTimeout], so the slot is always
+// released.
+func withUpstreamSlot[T any](parent context.Context, fetch func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(parent, config.QueueTimeout)
+	defer cancel()
+
+	err := upstreamFetches.acquire(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer upstreamFetches.release()
+
+	ctx, cancel = context.WithTimeout(parent, config.UpstreamTimeout)
+	defer cancel()
+	return fetch(ctx)
+}
+
+// fetchTimetable retrieves the timetable of the course, respecting the limit
+// of concurrent upstream requests.
+func fetchTimetable(course *unibo_integ.Course, year int, curr curriculum.Curriculum) (timetable.Timetable, error) {
+	if config.Mock {
+		return mockTimetable(course, year)
+	}
+
+	// Not bound to the request that needed it: the timetable is cached for
+	// the other requests too
+	t, err := withUpstreamSlot(context.Background(), func(ctx context.Context) (timetable.Timetable, error) {
+		return unibo_integ.Unibo.Timetable(ctx, *course, year, curr, nil)
+	})
+	if !errors.Is(err, errOverloaded) {
+		upstream.record(sourceTimetable, err)
+	}
+	return t, err
+}
+
+// fetchAllCurricula retrieves the curricula of the course, respecting the
+// limit of concurrent upstream requests.
+func fetchAllCurricula(course *unibo_integ.Course) (map[int]curriculum.Curricula, error) {
+	if config.Mock {
+		return mockAllCurricula(course)
+	}
+
+	curricula, err := withUpstreamSlot(context.Background(), func(ctx context.Context) (map[int]curriculum.Curricula, error) {
+		return unibo_integ.Unibo.AllCurricula(ctx, *course)
+	})
+	if !errors.Is(err, errOverloaded) {
+		upstream.record(sourceCurricula, err)
+	}
+	return curricula, err
+}
+
+// acquireFeed waits for a free generation slot for the feed, at most
+// [Config.QueueTimeout]. On success, the returned function must be called
+// when done.
+func acquireFeed(key string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.QueueTimeout)
+	defer cancel()
+
+	err := feedGenerations.acquire(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return func() { feedGenerations.release(key) }, nil
+}
+
+// retryAfterOverload is the suggested wait before retrying a request
+// rejected with errOverloaded.
+const retryAfterOverload = 30 * time.Second
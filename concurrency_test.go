@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_withUpstreamSlotTimeout(t *testing.T) {
+	timeout := config.UpstreamTimeout
+	t.Cleanup(func() { config.UpstreamTimeout = timeout })
+	config.UpstreamTimeout = time.Millisecond * 10
+
+	// A stalled request gives its slot back
+	_, err := withUpstreamSlot(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, len(upstreamFetches))
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Config contains the settings of the server, read from environment
+// variables.
+type Config struct {
+	// DevMode reads the templates from disk on every request, instead of
+	// using the ones embedded in the binary.
+	DevMode bool
+	// Mock serves the courses, curricula and timetables bundled in the
+	// binary instead of the Unibo ones, so the server works offline. It can
+	// also be enabled with the --mock flag.
+	Mock bool
+	// DataDir is the folder of the data files: the open data, the snapshots
+	// and the JSON files of the stores, unless their path is configured. It
+	// can also be set with the --data-dir flag.
+	DataDir string
+
+	// PrefetchWorkers is the number of concurrent upstream fetches made by the
+	// background prefetch jobs.
+	PrefetchWorkers int
+	// PrefetchDelay is the pause every prefetch worker takes between two jobs.
+	PrefetchDelay time.Duration
+
+	// Port is the port the server listens on.
+	Port string
+	// TLSCertFile and TLSKeyFile enable serving HTTPS (and HTTP/2) directly.
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTP3 enables the HTTP/3 listener, when serving HTTPS.
+	HTTP3 bool
+
+	// FeatureFlagsFile is the JSON file containing the feature flags.
+	FeatureFlagsFile string
+
+	// ApiKeysFile is the JSON file containing the API keys.
+	ApiKeysFile string
+	// AnonRateLimit is the number of requests allowed to every anonymous
+	// client (by IP address) every RateLimitWindow. Zero disables the limit.
+	AnonRateLimit   int
+	RateLimitWindow time.Duration
+
+	// FeedConcurrency is the number of concurrent generations of the same
+	// calendar (course and year).
+	FeedConcurrency int
+	// UpstreamConcurrency is the number of concurrent requests to Unibo.
+	UpstreamConcurrency int
+	// QueueTimeout is how long a request waits for a free slot before being
+	// rejected.
+	QueueTimeout time.Duration
+	// UpstreamTimeout is how long a request to Unibo can take, so a stalled
+	// response doesn't hold its slot forever.
+	UpstreamTimeout time.Duration
+
+	// CorsOrigins, CorsMethods and CorsHeaders configure the CORS policy of
+	// the API endpoints. "*" in CorsOrigins allows every origin.
+	CorsOrigins []string
+	CorsMethods []string
+	CorsHeaders []string
+
+	// IcsProdId is the PRODID of the generated calendars, identifying the
+	// deployment that produced them.
+	IcsProdId string
+	// IcsMethod is the METHOD of the generated calendars. Empty omits it.
+	IcsMethod string
+	// IcsCalscale is the CALSCALE of the generated calendars. Empty omits it.
+	IcsCalscale string
+
+	// GoogleClientId, GoogleClientSecret and GoogleRedirectUrl are the OAuth
+	// client of the Google Calendar integration, which is disabled if they
+	// are empty.
+	GoogleClientId     string
+	GoogleClientSecret string
+	GoogleRedirectUrl  string
+	// GoogleSubscriptionsFile is the JSON file containing the calendars
+	// kept in sync on Google Calendar.
+	GoogleSubscriptionsFile string
+	// GoogleSyncInterval is how often the timetables of the synced calendars
+	// are checked for changes.
+	GoogleSyncInterval time.Duration
+
+	// PublicUrl is the URL the server is reachable at, used in the links of
+	// the emails. By default it's BaseUrl.
+	PublicUrl string
+	// BaseUrl is the scheme and host (e.g. "https://unibocalendar.it") of the
+	// absolute links of the pages, like the webcal ones. If empty, they are
+	// taken from the request, see requestOrigin.
+	BaseUrl string
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies whose
+	// X-Forwarded-* headers are trusted.
+	TrustedProxies []string
+	// BasePath is the prefix the app is mounted under (e.g. "/unibocal"),
+	// when the reverse proxy serves it in a subpath. By default it's the path
+	// of PublicUrl.
+	BasePath string
+	// SmtpAddr (host:port), SmtpUser and SmtpPassword configure the server
+	// sending the emails. MailFrom is the sender address. Emails are
+	// disabled if SmtpAddr or MailFrom are empty.
+	SmtpAddr     string
+	SmtpUser     string
+	SmtpPassword string
+	MailFrom     string
+	// DigestSubscriptionsFile is the JSON file containing the subscriptions
+	// to the daily digest.
+	DigestSubscriptionsFile string
+	// DigestTime is the time of the day (e.g. "19:00", Europe/Rome) the
+	// digests with the lessons of the next day are sent.
+	DigestTime string
+
+	// VapidPublicKey and VapidPrivateKey are the keys (base64url, as
+	// generated by `npx web-push generate-vapid-keys`) of the Web Push
+	// notifications, which are disabled if they are empty. VapidSubject is
+	// the contact of the operator given to the push services.
+	VapidPublicKey  string
+	VapidPrivateKey string
+	VapidSubject    string
+	// PushSubscriptionsFile is the JSON file containing the browsers
+	// subscribed to the notifications.
+	PushSubscriptionsFile string
+
+	// MatrixHomeserver (e.g. "https://matrix.org") and MatrixAccessToken are
+	// the account of the Matrix bot, which is disabled if they are empty.
+	MatrixHomeserver  string
+	MatrixAccessToken string
+	// MatrixRoomsFile is the JSON file containing the courses followed by
+	// every room.
+	MatrixRoomsFile string
+
+	// WebhooksFile is the JSON file containing the registered chat webhooks.
+	WebhooksFile string
+
+	// AcademicCalendarFile is the JSON file containing the exam sessions and
+	// the closures of the university, see academicCalendar.
+	AcademicCalendarFile string
+
+	// AlertThreshold is the number of consecutive failed requests to an
+	// upstream source after which it is marked as degraded and the operator
+	// is alerted. Zero disables the alerts.
+	AlertThreshold int
+	// AlertWebhookUrl receives the alerts as JSON, AlertNtfyUrl is a ntfy
+	// topic (e.g. "https://ntfy.sh/my-topic") and AlertEmail an address
+	// the alerts are emailed to. Empty ones are disabled.
+	AlertWebhookUrl string
+	AlertNtfyUrl    string
+	AlertEmail      string
+
+	// OpenDataRefreshInterval is how often a new open data file is looked
+	// for. Zero only downloads it at startup.
+	OpenDataRefreshInterval time.Duration
+
+	// StatsFile is the JSON file containing the number of requests of every
+	// calendar.
+	StatsFile string
+	// WarmupCalendars is the number of most requested calendars generated at
+	// startup, before accepting requests. Zero disables the warm-up, which
+	// gives up after WarmupTimeout.
+	WarmupCalendars int
+	WarmupTimeout   time.Duration
+
+	// CalCacheMaxEntries and CalCacheMaxBytes limit the number and the total
+	// size of the cached calendars, TimetableCacheMaxEntries the number of
+	// cached timetables. The least recently used are evicted first.
+	CalCacheMaxEntries       int
+	CalCacheMaxBytes         int
+	TimetableCacheMaxEntries int
+
+	// EventsFile is the JSON file containing the events of the whole
+	// university, see uniboEvent.
+	EventsFile string
+
+	// CurriculaCacheTTL is how long the curricula of a course are cached.
+	CurriculaCacheTTL time.Duration
+
+	// AuditLogFile is the file recording the admin actions, see auditLog.
+	AuditLogFile string
+
+	// BackupDir is where the backups of the stores are saved, every
+	// BackupInterval (zero disables them). Only the last BackupKeep are
+	// kept.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// AdminUsers are the "user:password" credentials of the admins, for basic
+	// auth. See requireAdmin for the other methods.
+	AdminUsers []string
+	// AdminOidcIssuer, AdminOidcClientId, AdminOidcClientSecret and
+	// AdminOidcRedirectUrl enable the OIDC login of the admins, whose emails
+	// are AdminOidcEmails. AdminSessionSecret signs their sessions.
+	AdminOidcIssuer       string
+	AdminOidcClientId     string
+	AdminOidcClientSecret string
+	AdminOidcRedirectUrl  string
+	AdminOidcEmails       []string
+	AdminSessionSecret    string
+	// AdminProxyHeader is the header where a reverse proxy, at one of
+	// AdminProxyIps, puts the name of the authenticated admin.
+	AdminProxyHeader string
+	AdminProxyIps    []string
+	// AdminAllowLocalhost allows the requests from the host itself when no
+	// other method is configured.
+	AdminAllowLocalhost bool
+}
+
+var config = loadConfig()
+
+func loadConfig() Config {
+	dataDir := dataDirArg(os.Args[1:], envString("DATA_DIR", "data"))
+	baseUrl := envString("BASE_URL", "")
+	publicUrl := envString("PUBLIC_URL", cmp.Or(baseUrl, "http://localhost:8080"))
+	inData := func(name string) string {
+		return path.Join(dataDir, name)
+	}
+
+	return Config{
+		DevMode: envBool("DEV_MODE", false),
+		DataDir: dataDir,
+		Mock:    envBool("MOCK", false),
+
+		PrefetchWorkers: envInt("PREFETCH_WORKERS", 2),
+		PrefetchDelay:   envDuration("PREFETCH_DELAY", time.Second*30),
+		Port:            envString("PORT", "8080"),
+		TLSCertFile:     envString("TLS_CERT_FILE", ""),
+		TLSKeyFile:      envString("TLS_KEY_FILE", ""),
+		HTTP3:           envBool("HTTP3", false),
+
+		FeatureFlagsFile: envString("FEATURE_FLAGS_FILE", inData("flags.json")),
+
+		ApiKeysFile:     envString("API_KEYS_FILE", inData("api_keys.json")),
+		AnonRateLimit:   envInt("ANON_RATE_LIMIT", 120),
+		RateLimitWindow: envDuration("RATE_LIMIT_WINDOW", time.Minute),
+
+		FeedConcurrency:     envInt("FEED_CONCURRENCY", 1),
+		UpstreamConcurrency: envInt("UPSTREAM_CONCURRENCY", 16),
+		QueueTimeout:        envDuration("QUEUE_TIMEOUT", time.Second*10),
+		UpstreamTimeout:     envDuration("UPSTREAM_TIMEOUT", time.Second*30),
+
+		CorsOrigins: envList("CORS_ORIGINS", []string{"*"}),
+		CorsMethods: envList("CORS_METHODS", []string{"GET", "HEAD", "OPTIONS"}),
+		CorsHeaders: envList("CORS_HEADERS", []string{"Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "X-API-Key"}),
+
+		IcsProdId:   envString("ICS_PRODID", "-//VaiTon//UniboCalendar//IT"),
+		IcsMethod:   envString("ICS_METHOD", "PUBLISH"),
+		IcsCalscale: envString("ICS_CALSCALE", "GREGORIAN"),
+
+		GoogleClientId:          envString("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:      envString("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectUrl:       envString("GOOGLE_REDIRECT_URL", ""),
+		GoogleSubscriptionsFile: envString("GOOGLE_SUBSCRIPTIONS_FILE", inData("google.json")),
+		GoogleSyncInterval:      envDuration("GOOGLE_SYNC_INTERVAL", time.Hour),
+
+		PublicUrl:               publicUrl,
+		BasePath:                normalizeBasePath(envString("BASE_PATH", defaultBasePath(publicUrl))),
+		BaseUrl:                 strings.TrimSuffix(baseUrl, "/"),
+		TrustedProxies:          envList("TRUSTED_PROXIES", []string{"127.0.0.1", "::1"}),
+		SmtpAddr:                envString("SMTP_ADDR", ""),
+		SmtpUser:                envString("SMTP_USER", ""),
+		SmtpPassword:            envString("SMTP_PASSWORD", ""),
+		MailFrom:                envString("MAIL_FROM", ""),
+		DigestSubscriptionsFile: envString("DIGEST_SUBSCRIPTIONS_FILE", inData("digest.json")),
+		DigestTime:              envString("DIGEST_TIME", "19:00"),
+
+		VapidPublicKey:        envString("VAPID_PUBLIC_KEY", ""),
+		VapidPrivateKey:       envString("VAPID_PRIVATE_KEY", ""),
+		VapidSubject:          envString("VAPID_SUBJECT", "mailto:admin@localhost"),
+		PushSubscriptionsFile: envString("PUSH_SUBSCRIPTIONS_FILE", inData("push.json")),
+
+		MatrixHomeserver:  envString("MATRIX_HOMESERVER", ""),
+		MatrixAccessToken: envString("MATRIX_ACCESS_TOKEN", ""),
+		MatrixRoomsFile:   envString("MATRIX_ROOMS_FILE", inData("matrix.json")),
+
+		WebhooksFile: envString("WEBHOOKS_FILE", inData("webhooks.json")),
+
+		AcademicCalendarFile: envString("ACADEMIC_CALENDAR_FILE", inData("academic_calendar.json")),
+		EventsFile:           envString("EVENTS_FILE", inData("events.json")),
+
+		AlertThreshold:  envInt("ALERT_THRESHOLD", 5),
+		AlertWebhookUrl: envString("ALERT_WEBHOOK_URL", ""),
+		AlertNtfyUrl:    envString("ALERT_NTFY_URL", ""),
+		AlertEmail:      envString("ALERT_EMAIL", ""),
+
+		OpenDataRefreshInterval: envDuration("OPENDATA_REFRESH_INTERVAL", time.Hour*24),
+
+		StatsFile:       envString("STATS_FILE", inData("stats.json")),
+		WarmupCalendars: envInt("WARMUP_CALENDARS", 0),
+		WarmupTimeout:   envDuration("WARMUP_TIMEOUT", time.Minute*2),
+
+		CalCacheMaxEntries:       envInt("CAL_CACHE_MAX_ENTRIES", 5000),
+		CalCacheMaxBytes:         envInt("CAL_CACHE_MAX_BYTES", 256<<20),
+		TimetableCacheMaxEntries: envInt("TIMETABLE_CACHE_MAX_ENTRIES", 2000),
+
+		CurriculaCacheTTL: envDuration("CURRICULA_CACHE_TTL", time.Hour*24*7),
+
+		AuditLogFile: envString("AUDIT_LOG_FILE", inData("audit.jsonl")),
+
+		BackupDir:      envString("BACKUP_DIR", inData("backups")),
+		BackupInterval: envDuration("BACKUP_INTERVAL", time.Hour*24),
+		BackupKeep:     envInt("BACKUP_KEEP", 7),
+
+		AdminUsers:            envList("ADMIN_USERS", nil),
+		AdminOidcIssuer:       envString("ADMIN_OIDC_ISSUER", ""),
+		AdminOidcClientId:     envString("ADMIN_OIDC_CLIENT_ID", ""),
+		AdminOidcClientSecret: envString("ADMIN_OIDC_CLIENT_SECRET", ""),
+		AdminOidcRedirectUrl:  envString("ADMIN_OIDC_REDIRECT_URL", ""),
+		AdminOidcEmails:       envList("ADMIN_OIDC_EMAILS", nil),
+		AdminSessionSecret:    envString("ADMIN_SESSION_SECRET", ""),
+		AdminProxyHeader:      envString("ADMIN_PROXY_HEADER", ""),
+		AdminProxyIps:         envList("ADMIN_PROXY_IPS", []string{"127.0.0.1", "::1"}),
+		AdminAllowLocalhost:   envBool("ADMIN_ALLOW_LOCALHOST", false),
+	}
+}
+
+// dataDirArg returns the value of the --data-dir flag, or def if it's not
+// given. It's needed before the flags are parsed, as the default paths of
+// the files depend on it.
+func dataDirArg(args []string, def string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "data-dir" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return def
+}
+
+// envString returns the value of the environment variable, or def if it is
+// unset.
+func envString(name string, def string) string {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return def
+	}
+	return value
+}
+
+// envList returns the value of the environment variable as a comma separated
+// list, or def if it is unset.
+func envList(name string, def []string) []string {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return def
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envBool returns the value of the environment variable as a bool, or def if
+// it is unset or invalid.
+func envBool(name string, def bool) bool {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("invalid boolean environment variable, using default")
+		return def
+	}
+	return b
+}
+
+// envInt returns the value of the environment variable as an int, or def if
+// it is unset or invalid.
+func envInt(name string, def int) int {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return def
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("invalid integer environment variable, using default")
+		return def
+	}
+	return i
+}
+
+// envDuration returns the value of the environment variable as a
+// [time.Duration] (e.g. "30s"), or def if it is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("invalid duration environment variable, using default")
+		return def
+	}
+	return d
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_dataDirArg(t *testing.T) {
+	assert.Equal(t, "data", dataDirArg(nil, "data"))
+	assert.Equal(t, "/var/lib/unibocalendar", dataDirArg([]string{"--data-dir", "/var/lib/unibocalendar"}, "data"))
+	assert.Equal(t, "/srv", dataDirArg([]string{"--mock", "-data-dir=/srv"}, "data"))
+	assert.Equal(t, "data", dataDirArg([]string{"data-dir=/srv", "--data-directory=/srv"}, "data"))
+	assert.Equal(t, "data", dataDirArg([]string{"--data-dir"}, "data"))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// lessonConflict are two lessons of different teachings overlapping in time.
+type lessonConflict struct {
+	A timetable.Event
+	B timetable.Event
+}
+
+// findConflicts returns the pairs of overlapping lessons of different
+// teachings, ordered by the start of the first lesson. t must be sorted by
+// start. The groups of the same teaching (e.g. A-K and L-Z) are alternatives,
+// so they don't conflict with each other.
+func findConflicts(t timetable.Timetable) []lessonConflict {
+	var conflicts []lessonConflict
+	for i, a := range t {
+		for _, b := range t[i+1:] {
+			if !b.Start.Before(a.End.Time) {
+				break
+			}
+			if a.CodModulo != b.CodModulo {
+				conflicts = append(conflicts, lessonConflict{a, b})
+			}
+		}
+	}
+	return conflicts
+}
+
+type apiConflictLesson struct {
+	Teaching string    `json:"teaching"`
+	Title    string    `json:"title"`
+	Teacher  string    `json:"teacher,omitempty"`
+	Room     string    `json:"room,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+type apiConflict struct {
+	// Start and End delimit the time when both lessons take place
+	Start   time.Time            `json:"start"`
+	End     time.Time            `json:"end"`
+	Lessons [2]apiConflictLesson `json:"lessons"`
+}
+
+func newApiConflictLesson(e timetable.Event) apiConflictLesson {
+	return apiConflictLesson{
+		Teaching: e.CodModulo,
+		Title:    e.Title,
+		Teacher:  e.Teacher,
+		Room:     eventRoom(e),
+		Start:    e.Start.Time,
+		End:      e.End.Time,
+	}
+}
+
+func newApiConflict(c lessonConflict) apiConflict {
+	end := c.A.End.Time
+	if c.B.End.Before(end) {
+		end = c.B.End.Time
+	}
+	return apiConflict{
+		Start:   c.B.Start.Time,
+		End:     end,
+		Lessons: [2]apiConflictLesson{newApiConflictLesson(c.A), newApiConflictLesson(c.B)},
+	}
+}
+
+// apiConflicts returns the overlapping lessons of the teachings given in the
+// "teachings" query parameter (see parseTeachingRefs), which can belong to
+// different courses, so students can spot the clashes of a study plan.
+func apiConflicts(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		refs, err := parseTeachingRefs(ctx.Query("teachings"))
+		if err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, fmt.Sprintf("Invalid teachings: %s", err))
+			return
+		}
+
+		t, status, err := customTimetable(courses, refs)
+		if status == http.StatusServiceUnavailable {
+			overloaded(ctx)
+			return
+		} else if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, status, customProblemCodes[status], fmt.Sprintf("Unable to retrieve timetables: %s", err))
+			return
+		}
+
+		conflicts := findConflicts(t)
+		res := make([]apiConflict, 0, len(conflicts))
+		for _, c := range conflicts {
+			res = append(res, newApiConflict(c))
+		}
+		ctx.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_findConflicts(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	at := func(hour, min int) timetable.CalendarTime {
+		return timetable.CalendarTime{Time: time.Date(2024, time.October, 1, hour, min, 0, 0, rome)}
+	}
+
+	tt := timetable.Timetable{
+		{CodModulo: "1", CodSdoppiamento: "1--A-K", Start: at(9, 0), End: at(11, 0)},
+		{CodModulo: "1", CodSdoppiamento: "1--L-Z", Start: at(9, 0), End: at(11, 0)},
+		{CodModulo: "2", Start: at(10, 30), End: at(12, 0)},
+		// Starts when the previous ends
+		{CodModulo: "3", Start: at(12, 0), End: at(13, 0)},
+	}
+
+	conflicts := findConflicts(tt)
+	assert.Equal(t, 2, len(conflicts))
+	assert.Equal(t, "1--A-K", conflicts[0].A.CodSdoppiamento)
+	assert.Equal(t, "2", conflicts[0].B.CodModulo)
+	assert.Equal(t, "1--L-Z", conflicts[1].A.CodSdoppiamento)
+
+	c := newApiConflict(conflicts[0])
+	assert.Equal(t, at(10, 30).Time, c.Start)
+	assert.Equal(t, at(11, 0).Time, c.End)
+}
+
+func Test_apiConflictsInvalid(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/conflicts?teachings=8009", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/conflicts?teachings=1:1:28004_1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
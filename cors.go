@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cors adds the CORS headers configured by [Config.CorsOrigins],
+// [Config.CorsMethods] and [Config.CorsHeaders], and answers preflight
+// requests.
+//
+// Calendars are often fetched by web based viewers, so by default every
+// origin is allowed.
+func cors() gin.HandlerFunc {
+	allowAny := slices.Contains(config.CorsOrigins, "*")
+	methods := strings.Join(config.CorsMethods, ", ")
+	headers := strings.Join(config.CorsHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		if allowAny {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else if slices.Contains(config.CorsOrigins, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		} else if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		} else {
+			// Let the browser block the response
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", "86400")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// preflight answers the OPTIONS requests not handled by cors, e.g. when the
+// Origin header is missing.
+func preflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// courseFilter are the filters of the course list, read from the query
+// parameters so that they work with plain GET forms, without JavaScript.
+type courseFilter struct {
+	// Query are words that must all appear in the type or name of the course
+	Query  string `form:"q"`
+	Campus string `form:"campus"`
+	Type   string `form:"type"`
+	// Class is the degree class, see filterByClass
+	Class string `form:"class"`
+}
+
+// empty reports whether the filter keeps every course.
+func (f courseFilter) empty() bool {
+	return f == courseFilter{}
+}
+
+// matches reports whether the course satisfies every filter.
+func (f courseFilter) matches(c unibo_integ.Course) bool {
+	if f.Campus != "" && !strings.EqualFold(c.Campus, f.Campus) {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(c.Tipologia, f.Type) {
+		return false
+	}
+	if f.Class != "" && normalizeClass(c.Classe) != normalizeClass(f.Class) {
+		return false
+	}
+
+	text := strings.ToLower(c.Tipologia + " in " + c.Descrizione + " " + c.DescrizioneEn)
+	for _, word := range strings.Fields(strings.ToLower(f.Query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterCourses returns the courses satisfying the filter.
+func filterCourses(courses []unibo_integ.Course, f courseFilter) []unibo_integ.Course {
+	if f.empty() {
+		return courses
+	}
+
+	var res []unibo_integ.Course
+	for _, c := range courses {
+		if f.matches(c) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// distinctValues returns the sorted, non-empty values of key among the
+// courses, the options of a filter.
+func distinctValues(courses []unibo_integ.Course, key func(c unibo_integ.Course) string) []string {
+	var values []string
+	for _, c := range courses {
+		v := strings.TrimSpace(key(c))
+		if v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	slices.Sort(values)
+	return values
+}
+
+// coursesPage lists the courses, filtered server side by the query
+// parameters (see courseFilter).
+func coursesPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var filter courseFilter
+		_ = c.ShouldBindQuery(&filter)
+
+		list := courses.Load().ToList()
+		heading := ""
+		if filter.Class != "" {
+			heading = "Corsi della classe " + normalizeClass(filter.Class)
+		}
+
+		renderHTML(c, http.StatusOK, "courses", gin.H{
+			"courses":  filterCourses(list, filter),
+			"total":    len(list),
+			"heading":  heading,
+			"filter":   filter,
+			"filtered": !filter.empty(),
+			"campuses": distinctValues(list, func(c unibo_integ.Course) string { return c.Campus }),
+			"types":    distinctValues(list, func(c unibo_integ.Course) string { return c.Tipologia }),
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_filterCourses(t *testing.T) {
+	list := testCourses.Load().ToList()
+
+	assert.Equal(t, 2, len(filterCourses(list, courseFilter{})))
+	assert.Equal(t, 2, len(filterCourses(list, courseFilter{Query: "informatica"})))
+	assert.Equal(t, 1, len(filterCourses(list, courseFilter{Query: "laurea INGEGNERIA"})))
+	assert.Equal(t, 1, len(filterCourses(list, courseFilter{Campus: "cesena"})))
+	assert.Equal(t, 1, len(filterCourses(list, courseFilter{Type: "Laurea"})))
+	assert.Equal(t, 0, len(filterCourses(list, courseFilter{Campus: "Bologna", Type: "Laurea Magistrale"})))
+}
+
+func Test_coursesPageFilter(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/courses?campus=Cesena&q=", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "/courses/9254"))
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "/courses/8009"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "1 corsi su 2"))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// curriculaCache contains the curricula of the courses, by course code.
+// Curricula rarely change within a semester, so they are kept for
+// config.CurriculaCacheTTL, unless the open data changes the course.
+var curriculaCache = cache.New(config.CurriculaCacheTTL, time.Hour)
+
+// getAllCurricula returns the curricula of every year of the course, using
+// the cache when possible.
+func getAllCurricula(course *unibo_integ.Course) (map[int]curriculum.Curricula, error) {
+	key := strconv.Itoa(course.Codice)
+	if c, found := curriculaCache.Get(key); found {
+		return c.(map[int]curriculum.Curricula), nil
+	}
+
+	curricula, err := fetchAllCurricula(course)
+	if err != nil {
+		return nil, err
+	}
+
+	curriculaCache.Set(key, curricula, cache.DefaultExpiration)
+	return curricula, nil
+}
+
+// invalidateCurricula removes from the cache the curricula of the courses
+// removed or changed by a new version of the open data. If the website of
+// a course changed, its website id is scraped again too.
+func invalidateCurricula(delta courseDelta) {
+	for _, code := range delta.Removed {
+		curriculaCache.Delete(strconv.Itoa(code))
+	}
+	for _, change := range delta.Changed {
+		curriculaCache.Delete(strconv.Itoa(change.Code))
+		if slices.Contains(change.Fields, "Url") {
+			unibo_integ.Unibo.ForgetWebsiteId(change.Code)
+		}
+	}
+}
+
+// fillCurriculaCache fetches the curricula of every course, so the course
+// pages do not need to wait for the upstream on their first visit.
+func fillCurriculaCache(courses *unibo_integ.Courses) {
+	// This is to make sure everything is started
+	time.Sleep(time.Second * 5)
+
+	// Curricula are cheap to fetch, so a short pause is gentle enough
+	prefetchCourses(courses, time.Second, func(course *unibo_integ.Course) {
+		_, err := getAllCurricula(course)
+		if err != nil {
+			log.Err(err).Int("course-code", course.Codice).Str("course-name", course.Descrizione).Msg("Can't warm curricula cache")
+		}
+	})
+
+	log.Info().Int("courses", courses.Load().Len()).Msg("curricula cache warmed")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_invalidateCurricula(t *testing.T) {
+	curricula := map[int]curriculum.Curricula{1: {{Value: "000-000", Label: "Generale"}}}
+	for _, key := range []string{"8009", "9254", "1234"} {
+		curriculaCache.SetDefault(key, curricula)
+	}
+
+	invalidateCurricula(courseDelta{
+		Removed: []int{1234},
+		Changed: []courseChange{{Code: 9254, Fields: []string{"Url"}}},
+	})
+
+	_, found := curriculaCache.Get("8009")
+	assert.Equal(t, true, found)
+	_, found = curriculaCache.Get("9254")
+	assert.Equal(t, false, found)
+	_, found = curriculaCache.Get("1234")
+	assert.Equal(t, false, found)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// maxCustomFeeds is the maximum number of timetables (course, year and
+// curriculum) a custom calendar can be made of.
+const maxCustomFeeds = 10
+
+// teachingRef identifies a teaching in the timetable of a course year.
+type teachingRef struct {
+	Course     int
+	Year       int
+	Curriculum string
+	Code       string
+}
+
+// feedRef identifies the timetable of a course year.
+type feedRef struct {
+	Course     int
+	Year       int
+	Curriculum string
+}
+
+// parseTeachingRefs parses a comma separated list of teachings, each in the
+// form "course:year:code" or "course:year:curriculum:code", e.g.
+// "8009:1:28004_1,9254:2:A58-000:30001_1".
+func parseTeachingRefs(s string) ([]teachingRef, error) {
+	var refs []teachingRef
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("invalid teaching %q", item)
+		}
+
+		courseId, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid course in teaching %q", item)
+		}
+		year, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid year in teaching %q", item)
+		}
+
+		ref := teachingRef{Course: courseId, Year: year, Code: parts[len(parts)-1]}
+		if len(parts) == 4 {
+			ref.Curriculum = parts[2]
+		}
+		refs = append(refs, ref)
+	}
+
+	if len(refs) == 0 {
+		return nil, errors.New("no teachings given")
+	}
+	return refs, nil
+}
+
+// groupTeachingRefs groups the teachings by the timetable they belong to.
+func groupTeachingRefs(refs []teachingRef) map[feedRef][]string {
+	m := make(map[feedRef][]string)
+	for _, r := range refs {
+		f := feedRef{Course: r.Course, Year: r.Year, Curriculum: r.Curriculum}
+		if !slices.Contains(m[f], r.Code) {
+			m[f] = append(m[f], r.Code)
+		}
+	}
+	return m
+}
+
+// customProblemCodes are the problem codes of the statuses returned by
+// customTimetable.
+var customProblemCodes = map[int]string{
+	http.StatusBadRequest:          codeInvalidParameter,
+	http.StatusNotFound:            codeCourseNotFound,
+	http.StatusBadGateway:          codeUpstreamUnavailable,
+	http.StatusInternalServerError: codeInternal,
+}
+
+// customTimetable fetches the timetables of the teachings and merges the
+// events of the wanted ones.
+func customTimetable(courses *unibo_integ.Courses, refs []teachingRef) (timetable.Timetable, int, error) {
+	feeds := groupTeachingRefs(refs)
+	if len(feeds) > maxCustomFeeds {
+		return nil, http.StatusBadRequest, fmt.Errorf("at most %d different course years can be merged", maxCustomFeeds)
+	}
+
+	var merged timetable.Timetable
+	for feed, codes := range feeds {
+		course, found := courses.Load().FindById(feed.Course)
+		if !found {
+			return nil, http.StatusNotFound, fmt.Errorf("course %d not found", feed.Course)
+		}
+		if feed.Year <= 0 || feed.Year > course.DurataAnni {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid year %d for course %d", feed.Year, feed.Course)
+		}
+		if perr := checkCurriculum(course, "teachings", feed.Year, feed.Curriculum); perr != nil {
+			return nil, perr.Status, fmt.Errorf("course %d: %w", feed.Course, perr)
+		}
+
+		t, err := getTimetable(course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+		if errors.Is(err, errOverloaded) {
+			return nil, http.StatusServiceUnavailable, err
+		} else if err != nil {
+			return nil, http.StatusBadGateway, fmt.Errorf("unable to retrieve timetable of course %d: %w", feed.Course, err)
+		}
+
+		merged = filterTimetableBySubjects(merged, t, codes)
+	}
+
+	slices.SortFunc(merged, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+	return merged, http.StatusOK, nil
+}
+
+// getCustomCal returns a single calendar merging teachings of different
+// courses, given in the "teachings" query parameter (see parseTeachingRefs),
+// and the external calendars given in the "external" ones (see
+// addExternalCalendars).
+func getCustomCal(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		refs, err := parseTeachingRefs(ctx.Query("teachings"))
+		if err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, fmt.Sprintf("Invalid teachings: %s", err))
+			return
+		}
+		externals, err := parseExternalUrls(ctx.QueryArray("external"))
+		if err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, fmt.Sprintf("Invalid external calendars: %s", err))
+			return
+		}
+
+		// Teachings are already filtered, so the subjects option is ignored
+		opts := parseCalOptions(ctx)
+		opts.Subjects = nil
+
+		sorted := slices.Clone(refs)
+		slices.SortFunc(sorted, func(a, b teachingRef) int {
+			return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+		feed := fmt.Sprintf("custom-%v", sorted)
+		if len(externals) > 0 {
+			feed += fmt.Sprintf("-%v", externals)
+		}
+		cacheKey := feed + "-" + opts.key()
+		if cal, found := calcache.Get(cacheKey); found && cal.fresh() {
+			successCalendar(ctx, cal.data)
+			return
+		}
+
+		t, status, err := customTimetable(courses, refs)
+		if status == http.StatusServiceUnavailable {
+			overloaded(ctx)
+			return
+		} else if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, status, customProblemCodes[status], fmt.Sprintf("Unable to create calendar: %s", err))
+			return
+		}
+
+		cal, err := createEventsCal(t, opts)
+		if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create calendar")
+			return
+		}
+		cal.SetName("Calendario personalizzato")
+		cal.SetDescription("Orario delle lezioni degli insegnamenti selezionati")
+		setCalendarIdentity(cal, calendarIdentity(feed, opts))
+		complete := addExternalCalendars(ctx, cal, externals)
+
+		data, ok := serializeCalendar(ctx, cal, opts)
+		if !ok {
+			return
+		}
+
+		// A skipped external calendar is fetched again at the next request
+		if complete {
+			calcache.Set(cacheKey, newCachedFeed(data, ""))
+		}
+		successCalendar(ctx, data)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseTeachingRefs(t *testing.T) {
+	refs, err := parseTeachingRefs("8009:1:28004_1, 9254:2:A58-000:30001_1,8009:1:28005_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, len(refs))
+	assert.Equal(t, teachingRef{Course: 9254, Year: 2, Curriculum: "A58-000", Code: "30001_1"}, refs[1])
+
+	feeds := groupTeachingRefs(refs)
+	assert.Equal(t, 2, len(feeds))
+	assert.Equal(t, []string{"28004_1", "28005_1"}, feeds[feedRef{Course: 8009, Year: 1}])
+
+	_, err = parseTeachingRefs("8009:28004_1")
+	assert.NotEqual(t, nil, err)
+
+	_, err = parseTeachingRefs("")
+	assert.NotEqual(t, nil, err)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path"
 	"strconv"
@@ -16,103 +18,215 @@ import (
 	"github.com/VaiTon/unibocalendar/unibo_integ"
 )
 
+// coursesPathJson is the open data file, as last downloaded.
+var coursesPathJson = path.Join(config.DataDir, "courses.json")
+
 const (
-	coursesPathJson = "data/courses.json"
-	packageId       = "degree-programmes"
-	resourceAlias   = "corsi_latest_it"
+	packageId     = "degree-programmes"
+	resourceAlias = "corsi_latest_it"
+	// resourceAliasEn is the same file with the English names and URLs
+	resourceAliasEn = "corsi_latest_en"
 )
 
-func downloadOpenDataIfNewer() {
+// downloadOpenDataIfNewer downloads the open data if there is a newer
+// version, returning the new courses once they are validated and saved. It
+// returns nil if the open data did not change or the new file is not usable,
+// in which case the previous courses are kept, and an error if there are no
+// previous courses to keep.
+func downloadOpenDataIfNewer() (*unibo_integ.CoursesMap, error) {
 
 	// Get package
 	pack, err := opendata.FetchPackage(packageId)
 	if err != nil {
+		upstream.record(sourceOpenData, err)
 		log.Warn().Err(err).Msg("unable to get package")
-		return
+		return nil, nil
 	}
 
 	// If no resources, return nil
 	if len(pack.Result.Resources) == 0 {
+		upstream.record(sourceOpenData, errors.New("no resources found"))
 		log.Warn().Msg("no resources found while downloading open data")
-		return
+		return nil, nil
 	}
 
 	// Get wanted resource
 	resource, found := pack.Result.Resources.GetByAlias(resourceAlias)
 	if !found {
+		upstream.record(sourceOpenData, fmt.Errorf("unable to find resource '%s'", resourceAlias))
 		log.Warn().Msgf("unable to find resource '%s'", resourceAlias)
-		return
+		return nil, nil
 	}
 
 	// Get last modified resource
 	lastMod := resource.LastMod
 
-	// Parse last modified time
-	lastModTime, err := time.Parse("2006-01-02T15:04:05.999999999", lastMod)
-	if err != nil {
-		log.Panic().Err(err).Msg("Unable to parse last modified time")
-	}
-
 	old := false
 	// Get file last modified time, if file does not exist return lastMod.Url
 	stat, err := os.Stat(coursesPathJson)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			log.Panic().Err(err).Msg("Unable to get file stat")
+			return nil, fmt.Errorf("unable to get file stat: %w", err)
 		} else {
 			old = true
 		}
 	}
 
+	// Parse last modified time
+	lastModTime, err := time.Parse("2006-01-02T15:04:05.999999999", lastMod)
+	if err != nil {
+		if !old {
+			log.Error().Err(err).Msg("Unable to parse last modified time, keeping the previous courses")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to parse last modified time: %w", err)
+	}
+
 	if !old && stat.ModTime().After(lastModTime) {
+		upstream.record(sourceOpenData, nil)
 		log.Info().Msg("Opendata file is up to date")
-		return
+		return nil, nil
 	}
 
-	courses, err := unibo_integ.DownloadResource(resource)
+	download, err := unibo_integ.DownloadResource(resource)
+	upstream.record(sourceOpenData, err)
 	if err != nil {
-		log.Panic().Err(err).Msg("Unable to download courses")
+		if !old {
+			// The previous file is still usable
+			log.Error().Err(err).Msg("Unable to download courses, keeping the previous ones")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to download courses: %w", err)
 	}
 
 	actualYear := time.Now().Year()
 
 	// Filter courses by actual year
-	courses = lo.Filter(courses, func(c unibo_integ.Course, _ int) bool {
+	courses := lo.Filter(download.Courses, func(c unibo_integ.Course, _ int) bool {
 		return strings.Contains(c.AnnoAccademico, strconv.Itoa(actualYear))
 	})
 
-	err = saveData(courses)
+	err = validateCourses(courses)
 	if err != nil {
-		log.Panic().Err(err).Msg("Unable to save courses")
+		upstream.record(sourceOpenData, err)
+		if !old {
+			log.Error().Err(err).Msg("Invalid open data file, keeping the previous courses")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid open data file: %w", err)
 	}
+	addEnglishNames(pack.Result.Resources, courses)
+	next := unibo_integ.NewCoursesMap(courses)
 
-	log.Info().Msg("Opendata file downloaded")
-}
+	previous, err := openData()
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("Unable to open previous open data file")
+	}
+	delta := diffCourses(previous, courses)
+	invalidateCurricula(delta)
 
-func saveData(courses []unibo_integ.Course) error {
-	err := createDataFolder()
+	err = saveData(courses)
 	if err != nil {
-		return err
+		if !old {
+			log.Error().Err(err).Msg("Unable to save courses, keeping the previous ones")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to save courses: %w", err)
 	}
 
-	jsonFile, err := os.Create(coursesPathJson)
+	err = saveOpenDataInfo(openDataInfo{
+		DownloadedAt: time.Now(),
+		Size:         download.Size,
+		Checksum:     download.Checksum,
+		Delta:        delta,
+	})
 	if err != nil {
-		return err
+		log.Warn().Err(err).Msg("Unable to save open data info")
+	}
+
+	log.Info().
+		Int("size", download.Size).
+		Str("checksum", download.Checksum).
+		Ints("added", delta.Added).
+		Ints("removed", delta.Removed).
+		Int("modified", len(delta.Changed)).
+		Bool("unchanged", delta.Empty()).
+		Msg("Opendata file downloaded")
+	return next, nil
+}
+
+// addEnglishNames adds the English names and URLs of the courses, from the
+// English version of the open data. Without it, only the Italian ones are
+// shown.
+func addEnglishNames(resources opendata.Resources, courses []unibo_integ.Course) {
+	resource, found := resources.GetByAlias(resourceAliasEn)
+	if !found {
+		log.Warn().Msgf("unable to find resource '%s', courses have no English names", resourceAliasEn)
+		return
 	}
 
-	err = json.NewEncoder(jsonFile).Encode(courses)
+	download, err := unibo_integ.DownloadResource(resource)
 	if err != nil {
-		return err
+		log.Warn().Err(err).Msg("Unable to download English courses, courses have no English names")
+		return
+	}
+	unibo_integ.AddEnglishNames(courses, download.Courses)
+}
+
+// validateCourses checks that the courses of a new open data file can
+// replace the current ones: a truncated or malformed file must not empty the
+// course list.
+func validateCourses(courses []unibo_integ.Course) error {
+	if len(courses) == 0 {
+		return errors.New("no courses of the current academic year")
 	}
 
+	seen := make(map[int]bool, len(courses))
+	for _, c := range courses {
+		if c.Codice <= 0 {
+			return fmt.Errorf("invalid course code %d", c.Codice)
+		}
+		if seen[c.Codice] {
+			return fmt.Errorf("duplicated course %d", c.Codice)
+		}
+		seen[c.Codice] = true
+
+		if c.Descrizione == "" {
+			return fmt.Errorf("course %d has no name", c.Codice)
+		}
+		if c.DurataAnni <= 0 {
+			return fmt.Errorf("course %d has invalid duration %d", c.Codice, c.DurataAnni)
+		}
+	}
 	return nil
 }
 
-func createDataFolder() error {
-	return os.MkdirAll(path.Dir(coursesPathJson), os.ModePerm)
+// refreshOpenData checks for a new open data file every interval. The new
+// courses are parsed and validated in the background and only then replace
+// the active ones, so requests never see a partial course list.
+func refreshOpenData(courses *unibo_integ.Courses, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		next, err := downloadOpenDataIfNewer()
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to refresh the courses")
+			continue
+		} else if next == nil {
+			continue
+		}
+		previous := courses.Swap(next)
+		log.Info().Int("previous", previous.Len()).Int("courses", next.Len()).Msg("Courses replaced")
+	}
+}
+
+// saveData saves the courses, replacing the file atomically.
+func saveData(courses []unibo_integ.Course) error {
+	return saveJsonFile(coursesPathJson, courses, 0o644)
 }
 
-func openData() (unibo_integ.CoursesMap, error) {
+func openData() (*unibo_integ.CoursesMap, error) {
 	// Open file
 	file, err := os.Open(coursesPathJson)
 	if err != nil {
@@ -132,11 +246,5 @@ func openData() (unibo_integ.CoursesMap, error) {
 		return nil, err
 	}
 
-	// Create the map
-	courseMap := make(unibo_integ.CoursesMap, len(courses))
-	for _, course := range courses {
-		courseMap[course.Codice] = course
-	}
-
-	return courseMap, nil
+	return unibo_integ.NewCoursesMap(courses), nil
 }
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// digestEnabled reports whether the emails are configured.
+func digestEnabled() bool {
+	return config.SmtpAddr != "" && config.MailFrom != ""
+}
+
+// digestSubscription is an email address receiving, every evening, the
+// lessons of the next day of a course year.
+type digestSubscription struct {
+	// Id is secret, it's used in the confirmation and unsubscribe links
+	Id    string `json:"id"`
+	Email string `json:"email"`
+	// Confirmed is set when the user opens the link in the confirmation
+	// email, no digest is sent before
+	Confirmed bool `json:"confirmed"`
+
+	Course     int      `json:"course"`
+	Year       int      `json:"year"`
+	Curriculum string   `json:"curriculum,omitempty"`
+	Subjects   []string `json:"subjects,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	SentAt    time.Time `json:"sent_at,omitempty"`
+}
+
+// digestSubscriptions are the digest subscriptions, saved as JSON.
+type digestSubscriptions struct {
+	mu   sync.Mutex
+	file string
+	subs map[string]*digestSubscription
+}
+
+var digestSubs = &digestSubscriptions{file: config.DigestSubscriptionsFile}
+
+// load reads the subscriptions from their file. A missing file is an empty
+// list of subscriptions.
+func (d *digestSubscriptions) load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.read()
+}
+
+// read reads the subscriptions from their file, leaving them unloaded if it
+// fails. The mutex must be held.
+func (d *digestSubscriptions) read() error {
+	subs := map[string]*digestSubscription{}
+	data, err := os.ReadFile(d.file)
+	if os.IsNotExist(err) {
+		d.subs = subs
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read digest subscriptions: %w", err)
+	}
+
+	err = json.Unmarshal(data, &subs)
+	if err != nil {
+		return fmt.Errorf("unable to decode digest subscriptions: %w", err)
+	}
+	d.subs = subs
+	return nil
+}
+
+// update calls fn with the subscriptions and saves them if fn doesn't fail.
+func (d *digestSubscriptions) update(fn func(subs map[string]*digestSubscription) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The API can be called before Run loads the file, and the file must not
+	// be overwritten if it couldn't be read
+	if d.subs == nil {
+		err := d.read()
+		if err != nil {
+			return err
+		}
+	}
+	err := fn(d.subs)
+	if err != nil {
+		return err
+	}
+	// The file contains the email addresses of the users
+	return saveJsonFile(d.file, d.subs, 0o600)
+}
+
+// confirmed returns the subscriptions whose address has been confirmed.
+func (d *digestSubscriptions) confirmed() []digestSubscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subs := make([]digestSubscription, 0, len(d.subs))
+	for _, sub := range d.subs {
+		if sub.Confirmed {
+			subs = append(subs, *sub)
+		}
+	}
+	return subs
+}
+
+// owned returns the subscriptions of the address of the subscription with
+// the given id, none if it doesn't exist.
+func (d *digestSubscriptions) owned(id string) []digestSubscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, found := d.subs[id]
+	if !found {
+		return nil
+	}
+	var subs []digestSubscription
+	for _, s := range d.subs {
+		if s.Email == sub.Email {
+			subs = append(subs, *s)
+		}
+	}
+	return subs
+}
+
+// nextDigest returns the first time after now at the clock time at (e.g.
+// "19:00"), in the location of now.
+func nextDigest(now time.Time, at time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Run sends the digests every day at config.DigestTime.
+func (d *digestSubscriptions) Run(courses *unibo_integ.Courses) {
+	err := d.load()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load digest subscriptions")
+		return
+	}
+
+	runDaily(func(tomorrow time.Time) {
+		for _, sub := range d.confirmed() {
+			d.send(courses, sub, tomorrow)
+		}
+	})
+}
+
+// runDaily calls fn every day at config.DigestTime (Europe/Rome), with the
+// date of the next day.
+func runDaily(fn func(tomorrow time.Time)) {
+	at, err := time.Parse("15:04", config.DigestTime)
+	if err != nil {
+		log.Error().Err(err).Str("time", config.DigestTime).Msg("invalid digest time")
+		return
+	}
+	for {
+		next := nextDigest(time.Now().In(romeLocation), at)
+		time.Sleep(time.Until(next))
+		fn(next.AddDate(0, 0, 1))
+	}
+}
+
+// send emails the lessons of the day to the subscriber. Nothing is sent if
+// there are no lessons.
+func (d *digestSubscriptions) send(courses *unibo_integ.Courses, sub digestSubscription, day time.Time) {
+	logger := log.With().Str("subscription", secretLogId(sub.Id)).Int("course-code", sub.Course).Logger()
+
+	course, found := courses.Load().FindById(sub.Course)
+	if !found {
+		logger.Warn().Msg("course of digest subscription not found")
+		return
+	}
+
+	// The timetables are usually already cached, because the same course
+	// years are also requested by the calendar clients
+	t, err := getTimetable(course, sub.Year, curriculum.Curriculum{Value: sub.Curriculum})
+	if err != nil {
+		logger.Warn().Err(err).Msg("unable to retrieve timetable for digest")
+		return
+	}
+	if sub.Subjects != nil {
+		t = filterTimetableBySubjects(nil, t, sub.Subjects)
+	}
+
+	lessons := lessonsOn(t, day)
+	if len(lessons) == 0 {
+		return
+	}
+
+	subject := fmt.Sprintf("Lezioni di domani - %s", course.Descrizione)
+	err = sendEmail(sub.Email, subject, digestBody(course, sub, day, lessons))
+	if err != nil {
+		logger.Warn().Err(err).Msg("unable to send digest")
+		return
+	}
+
+	err = d.update(func(subs map[string]*digestSubscription) error {
+		if s, found := subs[sub.Id]; found {
+			s.SentAt = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to save digest subscriptions")
+	}
+}
+
+// lessonsOn returns the lessons of t starting on the day, sorted by start.
+func lessonsOn(t timetable.Timetable, day time.Time) timetable.Timetable {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	var lessons timetable.Timetable
+	for _, e := range t {
+		if !e.Start.Before(start) && e.Start.Before(end) {
+			lessons = append(lessons, e)
+		}
+	}
+	slices.SortFunc(lessons, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+	return lessons
+}
+
+// digestBody is the plain text of a digest email.
+func digestBody(course *unibo_integ.Course, sub digestSubscription, day time.Time, lessons timetable.Timetable) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("Lezioni del %s del %d anno di %s:\n\n", day.Format("02/01/2006"), sub.Year, course.Descrizione))
+	b.WriteString(formatLessons(lessons))
+	b.WriteString(fmt.Sprintf("Per non ricevere più queste email: %s\n", digestLink("unsubscribe", sub.Id)))
+	b.WriteString(fmt.Sprintf("Per eliminare tutti i tuoi dati: %s\n", meLink(sub.Id)))
+	return b.String()
+}
+
+// formatLessons lists the lessons as plain text, with their time, room and
+// teacher.
+func formatLessons(lessons timetable.Timetable) string {
+	b := strings.Builder{}
+	for _, e := range lessons {
+		b.WriteString(fmt.Sprintf("%s-%s %s\n", e.Start.Format("15:04"), e.End.Format("15:04"), e.Title))
+		if room := eventRoom(e); room != "" {
+			b.WriteString(fmt.Sprintf("  Aula: %s\n", room))
+		}
+		if e.Teacher != "" {
+			b.WriteString(fmt.Sprintf("  Docente: %s\n", e.Teacher))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// digestLink returns the absolute URL of a digest endpoint for the
+// subscription.
+func digestLink(action string, id string) string {
+	return strings.TrimSuffix(config.PublicUrl, "/") + "/digest/" + action + "?id=" + id
+}
+
+// smtpSendMail sends the emails, replaced by the tests.
+var smtpSendMail = smtp.SendMail
+
+// sendEmail sends a plain text email from config.MailFrom.
+func sendEmail(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if config.SmtpUser != "" {
+		host, _, _ := strings.Cut(config.SmtpAddr, ":")
+		auth = smtp.PlainAuth("", config.SmtpUser, config.SmtpPassword, host)
+	}
+
+	msg := bytes.Buffer{}
+	msg.WriteString("From: " + config.MailFrom + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	from, err := mail.ParseAddress(config.MailFrom)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	return smtpSendMail(config.SmtpAddr, auth, from.Address, []string{to}, msg.Bytes())
+}
+
+// digestSubscribe saves a new subscription and sends the email to confirm
+// the address.
+func digestSubscribe(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		address, err := mail.ParseAddress(ctx.PostForm("email"))
+		if err != nil {
+			errorPage(ctx, http.StatusBadRequest, "Indirizzo email non valido")
+			return
+		}
+		id, err := strconv.Atoi(ctx.PostForm("course"))
+		if err != nil {
+			errorPage(ctx, http.StatusBadRequest, "Codice del corso non valido")
+			return
+		}
+		course, found := courses.Load().FindById(id)
+		if !found {
+			errorPage(ctx, http.StatusNotFound, "Corso non trovato")
+			return
+		}
+		year, err := strconv.Atoi(ctx.PostForm("anno"))
+		if err != nil || year <= 0 || year > course.DurataAnni {
+			errorPage(ctx, http.StatusBadRequest, "Anno non valido")
+			return
+		}
+		if perr := checkCurriculum(course, "curr", year, ctx.PostForm("curr")); perr != nil {
+			errorPage(ctx, perr.Status, curriculumErrorText(perr))
+			return
+		}
+
+		var subjects []string
+		for _, s := range strings.Split(ctx.PostForm("subjects"), ",") {
+			if s != "" {
+				subjects = append(subjects, s)
+			}
+		}
+
+		sub := &digestSubscription{
+			Id:         randomId(),
+			Email:      address.Address,
+			Course:     course.Codice,
+			Year:       year,
+			Curriculum: ctx.PostForm("curr"),
+			Subjects:   subjects,
+			CreatedAt:  time.Now(),
+		}
+
+		body := fmt.Sprintf("Per ricevere ogni sera le lezioni del giorno dopo del %d anno di %s apri il link:\n\n%s\n\n"+
+			"Se non hai richiesto l'iscrizione ignora questa email.\n", year, course.Descrizione, digestLink("confirm", sub.Id))
+		err = sendEmail(sub.Email, "Conferma l'iscrizione al riepilogo delle lezioni", body)
+		if err != nil {
+			_ = ctx.Error(err)
+			errorPage(ctx, http.StatusBadGateway, "Impossibile inviare l'email di conferma")
+			return
+		}
+
+		err = digestSubs.update(func(subs map[string]*digestSubscription) error {
+			subs[sub.Id] = sub
+			return nil
+		})
+		if err != nil {
+			_ = ctx.Error(err)
+			errorPage(ctx, http.StatusInternalServerError, "Impossibile salvare l'iscrizione")
+			return
+		}
+
+		ctx.String(http.StatusOK, "Ti abbiamo inviato un'email per confermare l'iscrizione.")
+	}
+}
+
+// errDigestNotFound is returned when the subscription of a link doesn't
+// exist anymore.
+var errDigestNotFound = fmt.Errorf("digest subscription not found")
+
+func digestConfirm(ctx *gin.Context) {
+	err := digestSubs.update(func(subs map[string]*digestSubscription) error {
+		sub, found := subs[ctx.Query("id")]
+		if !found {
+			return errDigestNotFound
+		}
+		sub.Confirmed = true
+		return nil
+	})
+	if err == errDigestNotFound {
+		errorPage(ctx, http.StatusNotFound, "Iscrizione non trovata")
+		return
+	} else if err != nil {
+		_ = ctx.Error(err)
+		errorPage(ctx, http.StatusInternalServerError, "Impossibile salvare l'iscrizione")
+		return
+	}
+
+	ctx.String(http.StatusOK, "Iscrizione confermata, riceverai le lezioni del giorno dopo ogni sera alle %s.", config.DigestTime)
+}
+
+func digestUnsubscribe(ctx *gin.Context) {
+	err := digestSubs.update(func(subs map[string]*digestSubscription) error {
+		if _, found := subs[ctx.Query("id")]; !found {
+			return errDigestNotFound
+		}
+		delete(subs, ctx.Query("id"))
+		return nil
+	})
+	if err == errDigestNotFound {
+		errorPage(ctx, http.StatusNotFound, "Iscrizione non trovata")
+		return
+	} else if err != nil {
+		_ = ctx.Error(err)
+		errorPage(ctx, http.StatusInternalServerError, "Impossibile salvare l'iscrizione")
+		return
+	}
+
+	ctx.String(http.StatusOK, "Non riceverai più il riepilogo delle lezioni.")
+}
+
+// setupDigest registers the endpoints of the digest emails. The
+// subscriptions send emails, so they are rate limited.
+func setupDigest(r *gin.Engine, courses *unibo_integ.Courses, limiter gin.HandlerFunc) {
+	r.POST("/digest/subscribe", limiter, digestSubscribe(courses))
+	r.GET("/digest/confirm", digestConfirm)
+	r.GET("/digest/unsubscribe", digestUnsubscribe)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/smtp"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_nextDigest(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	at, _ := time.Parse("15:04", "19:00")
+
+	now := time.Date(2024, time.October, 1, 12, 0, 0, 0, rome)
+	assert.Equal(t, time.Date(2024, time.October, 1, 19, 0, 0, 0, rome), nextDigest(now, at))
+
+	now = time.Date(2024, time.October, 1, 19, 0, 0, 0, rome)
+	assert.Equal(t, time.Date(2024, time.October, 2, 19, 0, 0, 0, rome), nextDigest(now, at))
+}
+
+func Test_digestSend(t *testing.T) {
+	tt := testTimetable()
+	day := tt[0].Start.Time
+	assert.Equal(t, 2, len(lessonsOn(tt, day)))
+	assert.Equal(t, 0, len(lessonsOn(tt, day.AddDate(0, 0, 1))))
+
+	course := testCourse(8009)
+	sub := digestSubscription{Id: "abc", Email: "student@example.com", Course: 8009, Year: 1}
+	body := digestBody(&course, sub, day, lessonsOn(tt, day))
+	assert.Equal(t, true, strings.Contains(body, "09:00-11:00 ALGEBRA\n  Aula: AULA 1\n  Docente: Mario Rossi\n"))
+	assert.Equal(t, true, strings.Contains(body, "/digest/unsubscribe?id=abc"))
+
+	var sent []byte
+	smtpSendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, []string{"student@example.com"}, to)
+		sent = msg
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+	mailFrom := config.MailFrom
+	t.Cleanup(func() { config.MailFrom = mailFrom })
+	config.MailFrom = "Calendario <noreply@example.com>"
+
+	err := sendEmail(sub.Email, "Lezioni di domani - Informatica", body)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(string(sent), "Subject: Lezioni di domani - Informatica\r\n"))
+	assert.Equal(t, true, strings.Contains(string(sent), "  Aula: AULA 1\r\n"))
+}
+
+func Test_digestSubscriptions(t *testing.T) {
+	subs := &digestSubscriptions{file: path.Join(t.TempDir(), "digest.json")}
+	err := subs.update(func(s map[string]*digestSubscription) error {
+		s["a"] = &digestSubscription{Id: "a"}
+		s["b"] = &digestSubscription{Id: "b", Confirmed: true}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	loaded := &digestSubscriptions{file: subs.file}
+	assert.Equal(t, nil, loaded.load())
+	confirmed := loaded.confirmed()
+	assert.Equal(t, 1, len(confirmed))
+	assert.Equal(t, "b", confirmed[0].Id)
+}
+
+func Test_digestSubscriptionsNotLoaded(t *testing.T) {
+	file := path.Join(t.TempDir(), "digest.json")
+	err := os.WriteFile(file, []byte(`{"a": {"id": "a", "email": "a@example.com"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A subscription before Run loaded the file keeps the others
+	subs := &digestSubscriptions{file: file}
+	err = subs.update(func(s map[string]*digestSubscription) error {
+		s["b"] = &digestSubscription{Id: "b"}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	loaded := &digestSubscriptions{file: file}
+	assert.Equal(t, nil, loaded.load())
+	assert.Equal(t, 2, len(loaded.subs))
+
+	// An unreadable file is not overwritten
+	err = os.WriteFile(file, []byte(`{"a": `), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := &digestSubscriptions{file: file}
+	assert.NotEqual(t, nil, broken.load())
+	err = broken.update(func(s map[string]*digestSubscription) error {
+		s["c"] = &digestSubscription{Id: "c"}
+		return nil
+	})
+	assert.NotEqual(t, nil, err)
+	data, _ := os.ReadFile(file)
+	assert.Equal(t, `{"a": `, string(data))
+}
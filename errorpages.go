@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// wantsHtml reports whether the client is a browser, which prefers an error
+// page, rather than a calendar client or an API consumer.
+func wantsHtml(c *gin.Context) bool {
+	return c.NegotiateFormat(problemContentType, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// isApiPath reports whether the path is of an endpoint consumed by programs,
+// which always answer errors with problem details.
+func isApiPath(p string) bool {
+	return strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/cal/") || strings.HasPrefix(p, "/caldav/")
+}
+
+// errorPage aborts the request rendering the error page, with a search box
+// and a link back to the course list.
+func errorPage(c *gin.Context, status int, message string) {
+	c.Abort()
+	renderHTML(c, status, "error", gin.H{
+		"status":  status,
+		"message": message,
+	})
+}
+
+// yearNotFoundPage aborts the request rendering the error page of a year
+// the course does not have, linking the calendars of the existing ones.
+func yearNotFoundPage(c *gin.Context, course *unibo_integ.Course, year string) {
+	c.Abort()
+	renderHTML(c, http.StatusNotFound, "error", gin.H{
+		"status":  http.StatusNotFound,
+		"message": "Anno non valido",
+		"course":  course,
+		"year":    year,
+	})
+}
+
+// notFound answers the requests to unknown paths.
+func notFound(c *gin.Context) {
+	if isApiPath(c.Request.URL.Path) || !wantsHtml(c) {
+		writeProblem(c, http.StatusNotFound, codeNotFound, "Not found")
+		return
+	}
+	errorPage(c, http.StatusNotFound, "Pagina non trovata")
+}
+
+// recovered answers the requests whose handler panicked.
+func recovered(c *gin.Context, _ any) {
+	if isApiPath(c.Request.URL.Path) || !wantsHtml(c) {
+		writeProblem(c, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+	errorPage(c, http.StatusInternalServerError, "Si è verificato un errore, riprova più tardi")
+}
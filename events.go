@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Categories of the university events
+const (
+	categoryInauguration = "Inaugurazione"
+	categoryOpenDay      = "Open day"
+	categoryStrike       = "Sciopero"
+	categoryClosure      = "Chiusura"
+	categoryEvent        = "Evento"
+)
+
+// uniboEvent is an event of the whole university, not tied to a course: an
+// inauguration, an open day, a strike or a closure.
+//
+// Start and End are either dates (YYYY-MM-DD, End included) for all-day
+// events, or RFC 3339 times.
+type uniboEvent struct {
+	Name        string `json:"name"`
+	Category    string `json:"category,omitempty"`
+	Start       string `json:"start"`
+	End         string `json:"end,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Url         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Campus limits the event to a campus, empty for the whole university
+	Campus string `json:"campus,omitempty"`
+}
+
+// times returns the start and the exclusive end of the event, and whether
+// it lasts whole days.
+func (e uniboEvent) times() (time.Time, time.Time, bool, error) {
+	end := e.End
+	if end == "" {
+		end = e.Start
+	}
+
+	if start, err := time.Parse(time.DateOnly, e.Start); err == nil {
+		last, err := time.Parse(time.DateOnly, end)
+		if err != nil || last.Before(start) {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("invalid end %q of event %q", e.End, e.Name)
+		}
+		return start, last.AddDate(0, 0, 1), true, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, e.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("invalid start %q of event %q", e.Start, e.Name)
+	}
+	if e.End == "" {
+		return start, start.Add(time.Hour), false, nil
+	}
+	last, err := time.Parse(time.RFC3339, e.End)
+	if err != nil || last.Before(start) {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("invalid end %q of event %q", e.End, e.Name)
+	}
+	return start, last, false, nil
+}
+
+// uniboEventList contains the university events, which Unibo doesn't
+// publish as open data, so they are read from config.EventsFile.
+type uniboEventList struct {
+	mu     sync.RWMutex
+	events []uniboEvent
+}
+
+var uniboEvents = &uniboEventList{}
+
+// load reads the events from the file, skipping the invalid ones. Events
+// without a category are generic events.
+func (l *uniboEventList) load(file string) error {
+	var events []uniboEvent
+	err := loadJsonFile(file, &events)
+	if err != nil {
+		return err
+	}
+
+	valid := make([]uniboEvent, 0, len(events))
+	for _, e := range events {
+		if _, _, _, err := e.times(); err != nil {
+			log.Warn().Err(err).Msg("skipping invalid university event")
+			continue
+		}
+		if e.Category == "" {
+			e.Category = categoryEvent
+		}
+		valid = append(valid, e)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = valid
+	return nil
+}
+
+// Watch reloads the events when the file changes, checking every interval.
+func (l *uniboEventList) Watch(file string, interval time.Duration) {
+	var lastMod time.Time
+	for {
+		stat, err := os.Stat(file)
+		if err == nil && stat.ModTime() != lastMod {
+			err = l.load(file)
+			if err != nil {
+				log.Warn().Err(err).Msg("unable to reload university events, keeping the previous ones")
+			} else {
+				lastMod = stat.ModTime()
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// list returns the events of the whole university and the ones of the
+// campus, if not empty, sorted by start.
+func (l *uniboEventList) list(campus string) []uniboEvent {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var events []uniboEvent
+	for _, e := range l.events {
+		if e.Campus == "" || (campus != "" && strings.EqualFold(e.Campus, campus)) {
+			events = append(events, e)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b uniboEvent) int {
+		return strings.Compare(a.Start, b.Start)
+	})
+	return events
+}
+
+// createUniboEventsCal creates the calendar of the events. Closures and
+// strikes make the user busy, the other events don't.
+func createUniboEventsCal(events []uniboEvent) *ics.Calendar {
+	cal := ics.NewCalendar()
+	cal.SetProductId(config.IcsProdId)
+	if config.IcsCalscale != "" {
+		cal.SetCalscale(config.IcsCalscale)
+	}
+	if config.IcsMethod != "" {
+		cal.SetMethod(ics.Method(config.IcsMethod))
+	}
+	cal.SetName("Eventi di Ateneo")
+	cal.SetDescription("Inaugurazioni, open day, scioperi e chiusure dell'Università di Bologna")
+
+	for _, event := range events {
+		start, end, allDay, err := event.times()
+		if err != nil {
+			continue
+		}
+
+		uid := fmt.Sprintf("%x", sha1.Sum([]byte(event.Category+event.Name+event.Start+event.Campus)))
+		e := cal.AddEvent(uid)
+		e.SetSummary(event.Name)
+		if allDay {
+			e.SetAllDayStartAt(start)
+			e.SetAllDayEndAt(end)
+		} else {
+			e.SetStartAt(start)
+			e.SetEndAt(end)
+		}
+		e.SetDtStampTime(time.Now())
+		e.AddCategory(event.Category)
+		if event.Category != categoryClosure && event.Category != categoryStrike {
+			e.SetTimeTransparency(ics.TransparencyTransparent)
+		}
+		if event.Location != "" {
+			e.SetLocation(event.Location)
+		}
+		if event.Description != "" {
+			e.SetDescription(event.Description)
+		}
+		if event.Url != "" {
+			e.SetURL(event.Url)
+		}
+	}
+	return cal
+}
+
+// getUniboEventsCal returns the calendar of the university events, with
+// ?campus= to also include the ones of a campus only.
+func getUniboEventsCal(ctx *gin.Context) {
+	campus := ctx.Query("campus")
+	cal := createUniboEventsCal(uniboEvents.list(campus))
+	setCalendarIdentity(cal, calendarIdentity("events-"+strings.ToLower(campus), calOptions{}))
+	data, ok := serializeCalendar(ctx, cal, calOptions{})
+	if !ok {
+		return
+	}
+	successCalendar(ctx, data)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_uniboEventTimes(t *testing.T) {
+	start, end, allDay, err := uniboEvent{Name: "Chiusura", Start: "2025-08-11", End: "2025-08-15"}.times()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, allDay)
+	assert.Equal(t, "2025-08-11", start.Format("2006-01-02"))
+	assert.Equal(t, "2025-08-16", end.Format("2006-01-02"))
+
+	_, end, allDay, err = uniboEvent{Name: "Inaugurazione", Start: "2024-11-25T11:00:00+01:00"}.times()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, allDay)
+	assert.Equal(t, "12:00", end.Format("15:04"))
+
+	_, _, _, err = uniboEvent{Name: "Open day", Start: "2025-03-15", End: "2025-03-14"}.times()
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_uniboEventsCal(t *testing.T) {
+	l := &uniboEventList{events: []uniboEvent{
+		{Name: "Chiusura estiva", Category: categoryClosure, Start: "2025-08-11", End: "2025-08-15"},
+		{Name: "Open day", Category: categoryOpenDay, Start: "2025-03-15", Campus: "Cesena"},
+	}}
+	assert.Equal(t, 1, len(l.list("")))
+	assert.Equal(t, 2, len(l.list("cesena")))
+
+	ics := createUniboEventsCal(l.list("Cesena")).Serialize()
+	assert.Equal(t, 2, strings.Count(ics, "BEGIN:VEVENT"))
+	assert.Equal(t, true, strings.Contains(ics, "DTSTART;VALUE=DATE:20250315"))
+	// Only the open day doesn't make the user busy
+	assert.Equal(t, 1, strings.Count(ics, "TRANSP:TRANSPARENT"))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+)
+
+// A custom calendar can include external calendars, e.g. the ones of the gym
+// or of an association, linked with ?external=<url>. They are fetched when
+// the calendar is built, and their events are rebuilt with only the
+// properties needed to show them, so an external calendar can't add alarms,
+// attendees or anything else to the subscription of the user.
+
+// maxExternalFeeds is the maximum number of external calendars of a custom
+// calendar.
+const maxExternalFeeds = 3
+
+// maxExternalText is the maximum length, in bytes, of the text of an external
+// event.
+const maxExternalText = 2000
+
+// externalPast and externalFuture delimit the occurrences of the recurring
+// external events: the calendar is rebuilt when its cache expires, so the
+// window moves with it.
+const (
+	externalPast   = time.Hour * 24 * 30
+	externalFuture = time.Hour * 24 * 365
+)
+
+// externalStatuses are the values of STATUS of the events kept.
+var externalStatuses = []string{"TENTATIVE", "CONFIRMED", "CANCELLED"}
+
+// parseExternalUrls parses the urls of the external calendars, given with
+// ?external=, and returns them sorted.
+func parseExternalUrls(raw []string) ([]string, error) {
+	var urls []string
+	for _, r := range raw {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		u, err := url.Parse(r)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "webcal") || u.Host == "" {
+			return nil, fmt.Errorf("invalid external calendar url %q", r)
+		}
+		if !slices.Contains(urls, u.String()) {
+			urls = append(urls, u.String())
+		}
+	}
+
+	if len(urls) > maxExternalFeeds {
+		return nil, fmt.Errorf("at most %d external calendars can be included", maxExternalFeeds)
+	}
+	slices.Sort(urls)
+	return urls, nil
+}
+
+// addExternalCalendars fetches the external calendars and adds their events
+// to cal. A calendar that can't be fetched or read is skipped, so the
+// lessons are still served: the error is logged with the request, and false
+// is returned so the incomplete calendar isn't cached.
+func addExternalCalendars(ctx *gin.Context, cal *ics.Calendar, urls []string) bool {
+	now := time.Now()
+	ok := true
+	for _, u := range urls {
+		data, err := fetchCalendar(u)
+		if err == nil {
+			err = addExternalEvents(cal, u, data, now.Add(-externalPast), now.Add(externalFuture))
+		}
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("external calendar %s: %w", u, err))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// addExternalEvents adds to cal the events of the external calendar at the
+// given url, see sanitizeExternalEvent. The recurring events are expanded
+// from the given time and before the limit.
+func addExternalEvents(cal *ics.Calendar, source string, data []byte, from time.Time, limit time.Time) error {
+	external, err := ics.ParseCalendar(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	events := external.Events()
+	if len(events) > maxExternalOccurrences {
+		return fmt.Errorf("more than %d events", maxExternalOccurrences)
+	}
+
+	// The occurrences moved by an event with RECURRENCE-ID, by UID
+	overridden := map[string][]time.Time{}
+	for _, e := range events {
+		recurrenceId := e.GetProperty(ics.ComponentProperty(ics.PropertyRecurrenceId))
+		if recurrenceId == nil {
+			continue
+		}
+		if t, err := parseIcsTime(recurrenceId.Value, eventLocation(recurrenceId, romeLocation)); err == nil {
+			overridden[externalUid(e)] = append(overridden[externalUid(e)], t)
+		}
+	}
+
+	added := 0
+	for _, e := range events {
+		sanitized, err := sanitizeExternalEvent(e, source, from, limit, overridden[externalUid(e)])
+		if err != nil {
+			continue
+		}
+		for _, s := range sanitized {
+			if added >= maxExternalOccurrences {
+				return nil
+			}
+			cal.AddVEvent(s)
+			added++
+		}
+	}
+	return nil
+}
+
+// externalUid returns the UID of an external event.
+func externalUid(e *ics.VEvent) string {
+	if p := e.GetProperty(ics.ComponentPropertyUniqueId); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// sanitizeExternalEvent returns a copy of the event with only its time, its
+// text (summary, location and description, cleaned as the lessons, see
+// cleanText) and its status.
+//
+// The recurring events are expanded between from and limit (see
+// recurrence), skipping the excluded and the overridden occurrences, and
+// every occurrence is converted to Europe/Rome: this way the VTIMEZONEs of
+// the external calendar aren't needed, and the occurrences keep their hour
+// in the time zone of the event when only one of the two changes DST.
+func sanitizeExternalEvent(src *ics.VEvent, source string, from time.Time, limit time.Time, overridden []time.Time) ([]*ics.VEvent, error) {
+	start, end, err := eventTimes(src, romeLocation)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.New("event ends before it starts")
+	}
+	allDay := len(src.GetProperty(ics.ComponentPropertyDtStart).Value) == len("20060102")
+
+	// The UID is bound to the source, so it can't replace a lesson or an
+	// event of another external calendar
+	uid := externalUid(src)
+	if uid == "" {
+		uid = start.String()
+	}
+	if recurrenceId := src.GetProperty(ics.ComponentProperty(ics.PropertyRecurrenceId)); recurrenceId != nil {
+		uid += "|" + recurrenceId.Value
+	}
+
+	rule := src.GetProperty(ics.ComponentPropertyRrule)
+	if rule == nil {
+		return []*ics.VEvent{newExternalEvent(src, externalEventId(source, uid), start, end, allDay)}, nil
+	}
+
+	r, err := parseRecurrence(rule.Value, start.Location())
+	if err != nil {
+		return nil, err
+	}
+	excluded := slices.Clone(overridden)
+	for i := range src.Properties {
+		prop := &src.Properties[i]
+		if prop.IANAToken != string(ics.ComponentPropertyExdate) {
+			continue
+		}
+		for _, value := range strings.Split(prop.Value, ",") {
+			if t, err := parseIcsTime(value, eventLocation(prop, start.Location())); err == nil {
+				excluded = append(excluded, t)
+			}
+		}
+	}
+
+	var occurrences []*ics.VEvent
+	for _, s := range r.occurrences(start, from.Add(-end.Sub(start)), limit, excluded) {
+		id := externalEventId(source, uid+"|"+s.UTC().Format(icsUtcLayout))
+		occurrences = append(occurrences, newExternalEvent(src, id, s, s.Add(end.Sub(start)), allDay))
+	}
+	return occurrences, nil
+}
+
+// externalEventId returns the UID of an event of the external calendar at
+// source.
+func externalEventId(source string, uid string) string {
+	return fmt.Sprintf("%x-external", sha1.Sum([]byte(source+"|"+uid)))
+}
+
+// newExternalEvent returns an event with the given UID and times, and the
+// text and status of src.
+func newExternalEvent(src *ics.VEvent, id string, start time.Time, end time.Time, allDay bool) *ics.VEvent {
+	e := ics.NewEvent(id)
+	e.SetDtStampTime(time.Now())
+	e.SetProperty(ics.ComponentPropertyDtStart, externalTimeValue(start, allDay), externalTimeParams(allDay)...)
+	e.SetProperty(ics.ComponentPropertyDtEnd, externalTimeValue(end, allDay), externalTimeParams(allDay)...)
+
+	if p := src.GetProperty(ics.ComponentPropertySummary); p != nil {
+		e.SetSummary(externalText(p.Value))
+	}
+	if p := src.GetProperty(ics.ComponentPropertyLocation); p != nil {
+		e.SetLocation(externalText(p.Value))
+	}
+	if p := src.GetProperty(ics.ComponentPropertyDescription); p != nil {
+		e.SetDescription(externalText(p.Value))
+	}
+	if p := src.GetProperty(ics.ComponentPropertyStatus); p != nil && slices.Contains(externalStatuses, strings.ToUpper(p.Value)) {
+		e.SetProperty(ics.ComponentPropertyStatus, strings.ToUpper(p.Value))
+	}
+	if p := src.GetProperty(ics.ComponentPropertyTransp); p != nil &&
+		strings.EqualFold(p.Value, string(ics.TransparencyTransparent)) {
+		e.SetTimeTransparency(ics.TransparencyTransparent)
+	}
+	return e
+}
+
+// externalTimeValue returns t as a DATE for the all-day events, otherwise
+// as a local date-time of Europe/Rome.
+func externalTimeValue(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format("20060102")
+	}
+	return romeTimeValue(t)
+}
+
+// externalTimeParams returns the parameters of the values of
+// externalTimeValue.
+func externalTimeParams(allDay bool) []ics.PropertyParameter {
+	if allDay {
+		return []ics.PropertyParameter{ics.WithValue(string(ics.ValueDataTypeDate))}
+	}
+	return []ics.PropertyParameter{withRomeTzid()}
+}
+
+// externalText cleans the text of an external event line by line, keeping
+// the line breaks of the descriptions, and truncates it to maxExternalText.
+func externalText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = cleanText(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	s = strings.Join(lines, "\n")
+
+	if len(s) > maxExternalText {
+		s = strings.ToValidUTF8(s[:maxExternalText], "") + "…"
+	}
+	return s
+}
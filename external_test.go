@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseExternalUrls(t *testing.T) {
+	urls, err := parseExternalUrls([]string{"webcal://gym.example.com/cal.ics", " ", "https://example.org/a.ics", "webcal://gym.example.com/cal.ics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"https://example.org/a.ics", "webcal://gym.example.com/cal.ics"}, urls)
+
+	_, err = parseExternalUrls([]string{"file:///etc/passwd"})
+	assert.NotEqual(t, nil, err)
+	_, err = parseExternalUrls([]string{"https://a.example/1", "https://a.example/2", "https://a.example/3", "https://a.example/4"})
+	assert.NotEqual(t, nil, err)
+}
+
+const testGymCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Gym//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:spinning@gym\r\n" +
+	"DTSTAMP:20241001T000000Z\r\n" +
+	"DTSTART;TZID=America/New_York:20241001T120000\r\n" +
+	"DTEND;TZID=America/New_York:20241001T130000\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=TU;COUNT=10\r\n" +
+	"EXDATE;TZID=America/New_York:20241008T120000\r\n" +
+	"SUMMARY:Spinning &amp; <b>pesi</b>\r\n" +
+	"DESCRIPTION:Porta l'asciugamano\\n\\nIstruttore: Luca\r\n" +
+	"ATTENDEE:mailto:someone@example.com\r\n" +
+	"X-CUSTOM:value\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:party@gym\r\n" +
+	"DTSTAMP:20241001T000000Z\r\n" +
+	"DTSTART;VALUE=DATE:20241031\r\n" +
+	"SUMMARY:Festa\r\n" +
+	"STATUS:X-UNKNOWN\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:broken@gym\r\n" +
+	"SUMMARY:Senza orario\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func Test_addExternalEvents(t *testing.T) {
+	cal, err := createEventsCal(testTimetable(), calOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC)
+	limit := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	err = addExternalEvents(cal, "https://gym.example.com/cal.ics", []byte(testGymCalendar), from, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 10 Tuesdays but the excluded one, the party and the lessons; the
+	// event without a start is skipped
+	events := cal.Events()
+	assert.Equal(t, 2+9+1, len(events))
+
+	data := cal.Serialize()
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+	for _, e := range events[2:] {
+		assert.Equal(t, false, strings.Contains(e.Serialize(), "RRULE"))
+		assert.Equal(t, false, strings.Contains(e.Serialize(), "20241008T"))
+	}
+
+	spinning := events[2].Serialize()
+	// 12:00 in New York is 18:00 in Rome
+	assert.Equal(t, true, strings.Contains(spinning, "DTSTART;TZID=Europe/Rome:20241001T180000\r\n"))
+	assert.Equal(t, true, strings.Contains(spinning, "SUMMARY:Spinning & pesi\r\n"))
+	assert.Equal(t, true, strings.Contains(spinning, `DESCRIPTION:Porta l'asciugamano\nIstruttore: Luca`))
+	assert.Equal(t, false, strings.Contains(spinning, "spinning@gym"))
+	assert.Equal(t, false, strings.Contains(spinning, "ATTENDEE"))
+	assert.Equal(t, false, strings.Contains(spinning, "X-CUSTOM"))
+	assert.Equal(t, false, strings.Contains(spinning, "VALARM"))
+
+	// Between 27 October and 3 November only Rome is out of DST, so the
+	// lesson in New York is at 17:00 in Rome
+	assert.Equal(t, true, strings.Contains(events[5].Serialize(), "DTSTART;TZID=Europe/Rome:20241029T170000\r\n"))
+	assert.Equal(t, true, strings.Contains(events[6].Serialize(), "DTSTART;TZID=Europe/Rome:20241105T180000\r\n"))
+	assert.NotEqual(t, events[2].Id(), events[3].Id())
+
+	party := events[11].Serialize()
+	assert.Equal(t, true, strings.Contains(party, "DTSTART;VALUE=DATE:20241031\r\n"))
+	assert.Equal(t, true, strings.Contains(party, "DTEND;VALUE=DATE:20241101\r\n"))
+	assert.Equal(t, false, strings.Contains(party, "STATUS"))
+
+	// The events of other calendars with the same UID stay distinct
+	gym, _ := ics.ParseCalendar(strings.NewReader(testGymCalendar))
+	other, _ := sanitizeExternalEvent(gym.Events()[0], "https://other.example.com/cal.ics", from, limit, nil)
+	assert.NotEqual(t, events[2].Id(), other[0].Id())
+}
+
+func Test_addExternalEventsOverridden(t *testing.T) {
+	calendar := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:yoga@gym\r\n" +
+		"DTSTART;TZID=Europe/Rome:20241001T180000\r\n" +
+		"DTEND;TZID=Europe/Rome:20241001T190000\r\n" +
+		"RRULE:FREQ=DAILY;COUNT=3\r\n" +
+		"SUMMARY:Yoga\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:yoga@gym\r\n" +
+		"RECURRENCE-ID;TZID=Europe/Rome:20241002T180000\r\n" +
+		"DTSTART;TZID=Europe/Rome:20241002T200000\r\n" +
+		"DTEND;TZID=Europe/Rome:20241002T210000\r\n" +
+		"SUMMARY:Yoga serale\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	cal := ics.NewCalendar()
+	err := addExternalEvents(cal, "https://gym.example.com/cal.ics", []byte(calendar),
+		time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The moved occurrence replaces the one of the series
+	data := cal.Serialize()
+	assert.Equal(t, 3, len(cal.Events()))
+	assert.Equal(t, false, strings.Contains(data, "DTSTART;TZID=Europe/Rome:20241002T180000"))
+	assert.Equal(t, true, strings.Contains(data, "DTSTART;TZID=Europe/Rome:20241002T200000"))
+}
+
+func Test_addExternalCalendarsUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testGymCalendar))
+	}))
+	defer srv.Close()
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	cal := ics.NewCalendar()
+	// The private addresses are refused, and the calendar is skipped
+	assert.Equal(t, false, addExternalCalendars(ctx, cal, []string{srv.URL}))
+	assert.Equal(t, 0, len(cal.Events()))
+	assert.Equal(t, 1, len(ctx.Errors))
+}
+
+func Test_customCalInvalidExternal(t *testing.T) {
+	r := setupRouter(testCourses)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/cal/custom?teachings=8009:1:28004_1&external=ftp://example.com/cal.ics", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "Invalid external calendars"))
+}
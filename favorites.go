@@ -0,0 +1,109 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The courses starred by a visitor are saved in a cookie, like the recently
+// viewed ones (see recent.go), so that no account is needed.
+const (
+	favoritesCookie = "favorite_courses"
+	// maxFavorites is the number of courses a visitor can star
+	maxFavorites = 20
+	favoritesTTL = time.Hour * 24 * 400
+)
+
+// favoriteYear are the subscription links of the calendar of a year of a
+// starred course.
+type favoriteYear struct {
+	Year int
+	// Webcal is trusted by the templates, which otherwise filter the webcal
+	// scheme out of the links
+	Webcal template.URL
+	Ics    string
+}
+
+// favoriteCourse is a starred course with the links of its years.
+type favoriteCourse struct {
+	Course unibo_integ.Course
+	Years  []favoriteYear
+}
+
+// favoriteCodes returns the codes of the courses starred by the visitor.
+func favoriteCodes(c *gin.Context) []int {
+	return cookieCourseCodes(c, favoritesCookie, maxFavorites)
+}
+
+// isFavorite reports whether the visitor starred the course.
+func isFavorite(c *gin.Context, code int) bool {
+	return slices.Contains(favoriteCodes(c), code)
+}
+
+// toggleFavorite stars the course, or removes the star if already starred.
+// The oldest star is dropped beyond maxFavorites.
+func toggleFavorite(codes []int, code int) []int {
+	if slices.Contains(codes, code) {
+		return slices.DeleteFunc(slices.Clone(codes), func(c int) bool { return c == code })
+	}
+	codes = append(slices.Clone(codes), code)
+	if len(codes) > maxFavorites {
+		codes = codes[len(codes)-maxFavorites:]
+	}
+	return codes
+}
+
+// favoriteCourses returns the starred courses, with the subscription links
+// of the calendars of every year.
+func favoriteCourses(c *gin.Context, courses *unibo_integ.CoursesMap) []favoriteCourse {
+	var favorites []favoriteCourse
+	for _, course := range findCourses(courses, favoriteCodes(c)) {
+		f := favoriteCourse{Course: course}
+		for year := 1; year <= course.DurataAnni; year++ {
+			p := calPath(feedRef{Course: course.Codice, Year: year})
+			f.Years = append(f.Years, favoriteYear{Year: year, Webcal: template.URL(webcalUrl(c, p)), Ics: appUrl(p)})
+		}
+		favorites = append(favorites, f)
+	}
+	return favorites
+}
+
+// favoritesPage renders the starred courses of the visitor.
+func favoritesPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		renderHTML(c, http.StatusOK, "favorites", gin.H{
+			"favorites": favoriteCourses(c, courses.Load()),
+		})
+	}
+}
+
+// setFavorite stars the course of the path, or removes its star, and goes
+// back to the course page, or to the starred courses with ?back=favorites.
+func setFavorite(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		code, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			errorPage(c, http.StatusBadRequest, "Codice del corso non valido")
+			return
+		}
+		if _, found := courses.Load().FindById(code); !found {
+			errorPage(c, http.StatusNotFound, "Corso non trovato")
+			return
+		}
+
+		setCourseCodesCookie(c, favoritesCookie, toggleFavorite(favoriteCodes(c), code), favoritesTTL)
+
+		target := "/courses/" + strconv.Itoa(code)
+		if c.Query("back") == "favorites" {
+			target = "/favorites"
+		}
+		c.Redirect(http.StatusSeeOther, appUrl(target))
+	}
+}
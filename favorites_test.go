@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_toggleFavorite(t *testing.T) {
+	assert.Equal(t, []int{8009}, toggleFavorite(nil, 8009))
+	assert.Equal(t, []int{8009, 9254}, toggleFavorite([]int{8009}, 9254))
+	assert.Equal(t, []int{9254}, toggleFavorite([]int{8009, 9254}, 8009))
+
+	full := make([]int, maxFavorites)
+	for i := range full {
+		full[i] = i + 1
+	}
+	codes := toggleFavorite(full, 1000)
+	assert.Equal(t, maxFavorites, len(codes))
+	assert.Equal(t, 2, codes[0])
+	assert.Equal(t, 1000, codes[maxFavorites-1])
+}
+
+func Test_setFavorite(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/favorites/9254?back=favorites", nil)
+	req.AddCookie(&http.Cookie{Name: favoritesCookie, Value: "8009"})
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/favorites", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	assert.Equal(t, "8009.9254", cookies[0].Value)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/favorites/1234", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_favoritesPage(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/favorites", nil)
+	req.Host = "unibocalendar.it"
+	req.AddCookie(&http.Cookie{Name: favoritesCookie, Value: "9254"})
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Equal(t, true, strings.Contains(body, "INGEGNERIA INFORMATICA"))
+	assert.Equal(t, true, strings.Contains(body, `href="webcal://unibocalendar.it/cal/9254/2"`))
+	assert.Equal(t, false, strings.Contains(body, "/cal/9254/3"))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Flag is the configuration of a single feature flag.
+type Flag struct {
+	Enabled bool `json:"enabled"`
+	// Rollout is the percentage (0-100) of keys the flag is enabled for, see
+	// [FeatureFlags.EnabledFor]. A missing rollout means every key.
+	Rollout *int `json:"rollout,omitempty"`
+}
+
+// FeatureFlags is a set of feature flags, read from a JSON file such as:
+//
+//	{
+//	  "event_dedup": {"enabled": true},
+//	  "rrule_compression": {"enabled": true, "rollout": 10}
+//	}
+//
+// The file is reloaded when it changes, so features can be rolled out or
+// disabled without a redeploy. Unknown flags are disabled.
+type FeatureFlags struct {
+	flags atomic.Pointer[map[string]Flag]
+	// mu serializes the updates, see Update
+	mu sync.Mutex
+}
+
+var featureFlags = &FeatureFlags{}
+
+// Enabled reports whether the flag is enabled, ignoring its rollout.
+func (f *FeatureFlags) Enabled(name string) bool {
+	flag, found := f.get(name)
+	return found && flag.Enabled
+}
+
+// EnabledFor reports whether the flag is enabled for the given key (e.g. a
+// calendar cache key). The same key always gets the same answer, so a feed
+// does not flip between the old and new behaviour on every request.
+func (f *FeatureFlags) EnabledFor(name string, key string) bool {
+	flag, found := f.get(name)
+	if !found || !flag.Enabled {
+		return false
+	}
+	if flag.Rollout == nil {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + "/" + key))
+	return int(h.Sum32()%100) < *flag.Rollout
+}
+
+// EnabledNames returns the names of the enabled flags, sorted.
+func (f *FeatureFlags) EnabledNames() []string {
+	flags := f.flags.Load()
+	if flags == nil {
+		return nil
+	}
+	var names []string
+	for name, flag := range *flags {
+		if flag.Enabled {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (f *FeatureFlags) get(name string) (Flag, bool) {
+	flags := f.flags.Load()
+	if flags == nil {
+		return Flag{}, false
+	}
+	flag, found := (*flags)[name]
+	return flag, found
+}
+
+// Set replaces all the flags.
+func (f *FeatureFlags) Set(flags map[string]Flag) {
+	f.flags.Store(&flags)
+}
+
+// Update changes a single flag, and saves all the flags to the given file.
+func (f *FeatureFlags) Update(file string, name string, flag Flag) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flags := make(map[string]Flag)
+	if current := f.flags.Load(); current != nil {
+		maps.Copy(flags, *current)
+	}
+	flags[name] = flag
+
+	err := saveJsonFile(file, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	f.Set(flags)
+	return nil
+}
+
+// Load reads the flags from the given file.
+func (f *FeatureFlags) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to read feature flags: %w", err)
+	}
+
+	flags := make(map[string]Flag)
+	err = json.Unmarshal(data, &flags)
+	if err != nil {
+		return fmt.Errorf("unable to decode feature flags: %w", err)
+	}
+
+	f.Set(flags)
+	return nil
+}
+
+// Watch loads the flags from the file, and reloads them every interval if the
+// file has been modified. A missing file means no flags are enabled.
+func (f *FeatureFlags) Watch(file string, interval time.Duration) {
+	var lastMod time.Time
+	for {
+		stat, err := os.Stat(file)
+		switch {
+		case err != nil && os.IsNotExist(err):
+			if !lastMod.IsZero() {
+				log.Info().Str("file", file).Msg("feature flags file removed, disabling all flags")
+				f.Set(nil)
+				lastMod = time.Time{}
+			}
+		case err != nil:
+			log.Warn().Err(err).Str("file", file).Msg("unable to stat feature flags file")
+		case stat.ModTime() != lastMod:
+			err = f.Load(file)
+			if err != nil {
+				log.Warn().Err(err).Msg("unable to reload feature flags, keeping the previous ones")
+			} else {
+				log.Info().Str("file", file).Msg("feature flags loaded")
+				lastMod = stat.ModTime()
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_FeatureFlags(t *testing.T) {
+	rollout := 30
+	flags := &FeatureFlags{}
+	assert.Equal(t, false, flags.Enabled("event_dedup"))
+
+	flags.Set(map[string]Flag{
+		"event_dedup":       {Enabled: true},
+		"rrule_compression": {Enabled: true, Rollout: &rollout},
+		"disabled":          {Enabled: false},
+	})
+
+	assert.Equal(t, true, flags.Enabled("event_dedup"))
+	assert.Equal(t, true, flags.EnabledFor("event_dedup", "8009-1"))
+	assert.Equal(t, false, flags.Enabled("disabled"))
+	assert.Equal(t, false, flags.Enabled("unknown"))
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		if flags.EnabledFor("rrule_compression", key) {
+			enabled++
+		}
+		// The same key always gets the same answer
+		assert.Equal(t, flags.EnabledFor("rrule_compression", key), flags.EnabledFor("rrule_compression", key))
+	}
+	assert.Equal(t, true, enabled > 200 && enabled < 400)
+}
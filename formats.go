@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// feedFormat is a representation of the timetable of a course year.
+type feedFormat struct {
+	ContentType string
+	// Extension is used for the name of the downloaded file
+	Extension string
+	// Render renders the timetable. If something goes wrong, the error
+	// response is already written and the boolean is false.
+	Render func(ctx *gin.Context, t timetable.Timetable, course *unibo_integ.Course, year int, opts calOptions) ([]byte, bool)
+}
+
+// defaultFormat is the format used when none is requested.
+const defaultFormat = "ics"
+
+// feedFormats are the formats that can be requested with ?format=, or with
+// the Accept header, see requestFormat.
+var feedFormats = map[string]feedFormat{
+	"ics": {
+		ContentType: "text/calendar; charset=utf-8",
+		Extension:   "ics",
+		Render:      buildCalendar,
+	},
+	"jsonfeed": {
+		ContentType: "application/feed+json; charset=utf-8",
+		Extension:   "json",
+		Render:      buildJsonFeed,
+	},
+	"org": {
+		ContentType: "text/org; charset=utf-8",
+		Extension:   "org",
+		Render:      buildOrgAgenda,
+	},
+	"md": {
+		ContentType: "text/markdown; charset=utf-8",
+		Extension:   "md",
+		Render:      buildMarkdownAgenda,
+	},
+	"json": {
+		ContentType: "application/json; charset=utf-8",
+		Extension:   "json",
+		Render:      buildLessonJson,
+	},
+	"csv": {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		Render:      buildLessonCsv,
+	},
+}
+
+// negotiatedFormats are the formats that can be requested with the Accept
+// header, in order of preference when more are accepted (e.g. "*/*").
+var negotiatedFormats = []string{"ics", "json", "csv", "jsonfeed", "md", "org"}
+
+// mediaType returns the media type of the format, without the parameters.
+func (f feedFormat) mediaType() string {
+	mediaType, _, _ := strings.Cut(f.ContentType, ";")
+	return mediaType
+}
+
+// requestFormat returns the name of the format requested with ?format=, or
+// otherwise the one negotiated with the Accept header (e.g. "text/csv").
+// When nothing acceptable is offered, the default format is used anyway:
+// the calendar clients send all sorts of headers.
+func requestFormat(ctx *gin.Context) string {
+	if format, found := ctx.GetQuery("format"); found {
+		return format
+	}
+
+	ctx.Writer.Header().Add("Vary", "Accept")
+	offers := make([]string, len(negotiatedFormats))
+	for i, name := range negotiatedFormats {
+		offers[i] = feedFormats[name].mediaType()
+	}
+	if i := slices.Index(offers, ctx.NegotiateFormat(offers...)); i >= 0 {
+		return negotiatedFormats[i]
+	}
+	return defaultFormat
+}
+
+// successFeed writes the rendered feed to the response.
+//
+// The cached bytes are written as they are, without copying them.
+func successFeed(c *gin.Context, format feedFormat, data []byte) {
+	switch format.Extension {
+	case "ics", "csv":
+		c.Header("Content-Disposition", "attachment; filename=lezioni."+format.Extension)
+	}
+	c.Data(http.StatusOK, format.ContentType, data)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The fragments are pieces of pages, swapped in by HTMX, so that the pages
+// are interactive while still rendered by the templates. Every page works
+// without them.
+
+// fragments are the templates in templates/fragments, rendered alone by the
+// fragment endpoints and included by the pages.
+var fragments = []string{"course-rows", "curriculum-select", "week-grid"}
+
+// weekDays is the number of days of the week grid, from Monday to Saturday.
+const weekDays = 6
+
+// weekLesson is a lesson in the week grid.
+type weekLesson struct {
+	Start   string
+	End     string
+	Title   string
+	Room    string
+	Teacher string
+	// Overlap is set when comparing timetables, see markOverlaps
+	Overlap bool
+}
+
+// weekDay is a column of the week grid.
+type weekDay struct {
+	Name    string
+	Date    string
+	Lessons []weekLesson
+}
+
+// weekStart returns the midnight of the Monday of the week of day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	y, m, d := day.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, day.Location())
+}
+
+// parseWeek returns the Monday of the week of the day raw (YYYY-MM-DD), or of
+// the current week if raw is empty.
+func parseWeek(param string, raw string) (time.Time, *paramError) {
+	day := time.Now().In(romeLocation)
+	if raw != "" {
+		var err error
+		day, err = time.ParseInLocation(time.DateOnly, raw, romeLocation)
+		if err != nil {
+			return time.Time{}, &paramError{param, http.StatusBadRequest, codeInvalidParameter,
+				param + " must be a date in the YYYY-MM-DD format"}
+		}
+	}
+	return weekStart(day), nil
+}
+
+// newWeekGrid returns the lessons of the timetable in the week starting on
+// monday, by day.
+func newWeekGrid(t timetable.Timetable, monday time.Time) []weekDay {
+	days := make([]weekDay, weekDays)
+	for i := range days {
+		day := monday.AddDate(0, 0, i)
+		days[i] = weekDay{Name: italianWeekdays[day.Weekday()], Date: day.Format("02/01")}
+	}
+
+	end := monday.AddDate(0, 0, weekDays)
+	for _, e := range t {
+		start := e.Start.In(monday.Location())
+		if start.Before(monday) || !start.Before(end) {
+			continue
+		}
+		i := int(start.Sub(monday).Hours()) / 24
+		days[i].Lessons = append(days[i].Lessons, weekLesson{
+			Start:   start.Format("15:04"),
+			End:     e.End.In(monday.Location()).Format("15:04"),
+			Title:   e.Title,
+			Room:    eventRoom(e),
+			Teacher: e.Teacher,
+		})
+	}
+
+	for i := range days {
+		slices.SortFunc(days[i].Lessons, func(a, b weekLesson) int {
+			switch {
+			case a.Start < b.Start:
+				return -1
+			case a.Start > b.Start:
+				return 1
+			}
+			return 0
+		})
+	}
+	return days
+}
+
+// courseRowsFragment renders the rows of the course table, filtered like the
+// course list (see courseFilter).
+func courseRowsFragment(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var filter courseFilter
+		_ = c.ShouldBindQuery(&filter)
+		renderHTML(c, http.StatusOK, "fragments/course-rows", gin.H{
+			"courses": filterCourses(courses.Load().ToList(), filter),
+		})
+	}
+}
+
+// curriculumSelectFragment renders the year and curriculum selectors of the
+// course given by ?course=, with the curricula of ?year= (default the first).
+func curriculumSelectFragment(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		course, perr := parseCourse(courses.Load(), "course", c.Query("course"))
+		if perr != nil {
+			writeParamError(c, perr)
+			return
+		}
+
+		year := 1
+		if raw := c.Query("year"); raw != "" {
+			year, perr = parseYear(course, "year", raw)
+			if perr != nil {
+				writeParamError(c, perr)
+				return
+			}
+		}
+
+		curricula, err := getAllCurricula(course)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("unable to retrieve curricula: %w", err))
+		}
+
+		renderHTML(c, http.StatusOK, "fragments/curriculum-select", gin.H{
+			"course":    course,
+			"year":      year,
+			"curricula": curricula[year],
+		})
+	}
+}
+
+// weekGridFragment renders the lessons of a week of the course year, the
+// current one or the one of ?week= (YYYY-MM-DD).
+func weekGridFragment(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		feed, perr := bindFeed(c, courses.Load())
+		if perr != nil {
+			writeParamError(c, perr)
+			return
+		}
+
+		monday, perr := parseWeek("week", c.Query("week"))
+		if perr != nil {
+			writeParamError(c, perr)
+			return
+		}
+
+		t, err := getTimetable(feed.Course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+		if err != nil {
+			_ = c.Error(err)
+			renderHTML(c, http.StatusOK, "fragments/week-grid", gin.H{"error": "Impossibile scaricare l'orario da Unibo, riprova più tardi."})
+			return
+		}
+
+		path := appUrl(fmt.Sprintf("/fragments/courses/%d/%d/week?curr=%s&week=", feed.Course.Codice, feed.Year, feed.Curriculum))
+		renderHTML(c, http.StatusOK, "fragments/week-grid", gin.H{
+			"days":     newWeekGrid(t, monday),
+			"monday":   monday.Format("02/01/2006"),
+			"previous": path + monday.AddDate(0, 0, -7).Format(time.DateOnly),
+			"next":     path + monday.AddDate(0, 0, 7).Format(time.DateOnly),
+			"jsonLd":   lessonsJsonLd(lessonsBetween(t, monday, monday.AddDate(0, 0, weekDays)), feed.Course),
+		})
+	}
+}
+
+// setupFragments registers the fragment endpoints.
+func setupFragments(r *gin.Engine, courses *unibo_integ.Courses) {
+	f := r.Group("/fragments")
+	f.GET("/courses", courseRowsFragment(courses))
+	f.GET("/curricula", curriculumSelectFragment(courses))
+	f.GET("/courses/:id/:anno/week", weekGridFragment(courses))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_weekStart(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+
+	monday := time.Date(2024, time.September, 30, 0, 0, 0, 0, rome)
+	assert.Equal(t, monday, weekStart(time.Date(2024, time.October, 1, 11, 0, 0, 0, rome)))
+	assert.Equal(t, monday, weekStart(monday))
+	assert.Equal(t, monday, weekStart(time.Date(2024, time.October, 6, 23, 0, 0, 0, rome)))
+}
+
+func Test_newWeekGrid(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	tt := testTimetable()
+	// Swapped, to check the sorting
+	tt[0], tt[1] = tt[1], tt[0]
+
+	days := newWeekGrid(tt, time.Date(2024, time.September, 30, 0, 0, 0, 0, rome))
+	assert.Equal(t, weekDays, len(days))
+	assert.Equal(t, "lunedì", days[0].Name)
+	assert.Equal(t, 0, len(days[0].Lessons))
+	assert.Equal(t, "martedì", days[1].Name)
+	assert.Equal(t, "01/10", days[1].Date)
+	assert.Equal(t, 2, len(days[1].Lessons))
+	assert.Equal(t, weekLesson{Start: "09:00", End: "11:00", Title: "ALGEBRA", Room: "AULA 1", Teacher: "Mario Rossi"}, days[1].Lessons[0])
+	assert.Equal(t, "ANALISI MATEMATICA", days[1].Lessons[1].Title)
+
+	days = newWeekGrid(tt, time.Date(2024, time.October, 7, 0, 0, 0, 0, rome))
+	assert.Equal(t, 0, len(days[1].Lessons))
+}
+
+func Test_courseRowsFragment(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/fragments/courses?campus=Cesena", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Equal(t, true, strings.Contains(body, "/courses/9254"))
+	assert.Equal(t, false, strings.Contains(body, "/courses/8009"))
+	// Only the rows, not the page
+	assert.Equal(t, false, strings.Contains(body, "<html"))
+	assert.Equal(t, true, strings.HasPrefix(strings.TrimSpace(body), "<tr>"))
+}
+
+func Test_curriculumSelectFragment(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/fragments/curricula?course=0", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fragments/curricula?course=8009&year=9", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_weekGridFragmentInvalidWeek(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/fragments/courses/8009/1/week?week=tomorrow", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// freeSlotDays are the days searched for free slots.
+var freeSlotDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// timeWindow is an interval of a day, in minutes from midnight.
+type timeWindow struct {
+	Start int
+	End   int
+}
+
+// freeSlot is a window of a weekday without lessons in any teaching week.
+type freeSlot struct {
+	Weekday time.Weekday
+	timeWindow
+}
+
+// minutesOfDay returns the minutes from the midnight of t.
+func minutesOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// findFreeSlots returns the windows between from and to, of at least
+// minLength minutes, in which no timetable ever has a lesson on that weekday,
+// and the number of teaching weeks (the weeks with at least a lesson).
+//
+// The times of the lessons are taken in loc.
+func findFreeSlots(timetables []timetable.Timetable, loc *time.Location, from, to, minLength int) ([]freeSlot, int) {
+	busy := make(map[time.Weekday][]timeWindow)
+	weeks := make(map[time.Time]bool)
+	for _, t := range timetables {
+		for _, e := range t {
+			start, end := e.Start.In(loc), e.End.In(loc)
+			weeks[weekStart(start)] = true
+
+			w := timeWindow{minutesOfDay(start), minutesOfDay(end)}
+			if end.YearDay() != start.YearDay() {
+				w.End = 24 * 60
+			}
+			busy[start.Weekday()] = append(busy[start.Weekday()], w)
+		}
+	}
+	if len(weeks) == 0 {
+		return nil, 0
+	}
+
+	var slots []freeSlot
+	for _, day := range freeSlotDays {
+		lessons := busy[day]
+		slices.SortFunc(lessons, func(a, b timeWindow) int { return a.Start - b.Start })
+
+		// Walk the lessons, sorted by start, keeping the gaps between them
+		free := from
+		for _, l := range lessons {
+			if l.Start > free && min(l.Start, to)-free >= minLength {
+				slots = append(slots, freeSlot{day, timeWindow{free, min(l.Start, to)}})
+			}
+			free = max(free, l.End)
+			if free >= to {
+				break
+			}
+		}
+		if to-free >= minLength {
+			slots = append(slots, freeSlot{day, timeWindow{free, to}})
+		}
+	}
+	return slots, len(weeks)
+}
+
+// parseTimeOfDay parses a time in the HH:MM format, in minutes from midnight.
+func parseTimeOfDay(param string, raw string) (int, *paramError) {
+	t, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, &paramError{param, http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("%s must be a time in the HH:MM format, got %q", param, raw)}
+	}
+	return minutesOfDay(t), nil
+}
+
+// freeSlotsQuery are the parameters of apiFreeSlots.
+type freeSlotsQuery struct {
+	Feeds    []feedParams
+	From, To int
+	Min      int
+}
+
+func bindFreeSlotsQuery(ctx *gin.Context, courses *unibo_integ.CoursesMap) (freeSlotsQuery, *paramError) {
+	var q freeSlotsQuery
+	var perr *paramError
+
+	for _, spec := range strings.Split(ctx.Query("feeds"), ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		var feed feedParams
+		feed, perr = parseFeedSpec(courses, "feeds", spec)
+		if perr != nil {
+			return q, perr
+		}
+		q.Feeds = append(q.Feeds, feed)
+	}
+	if len(q.Feeds) == 0 || len(q.Feeds) > maxCustomFeeds {
+		return q, &paramError{"feeds", http.StatusBadRequest, codeInvalidParameter,
+			fmt.Sprintf("feeds must be a comma separated list of 1 to %d course-year[-curriculum]", maxCustomFeeds)}
+	}
+
+	q.From, perr = parseTimeOfDay("from", ctx.DefaultQuery("from", "08:00"))
+	if perr != nil {
+		return q, perr
+	}
+	q.To, perr = parseTimeOfDay("to", ctx.DefaultQuery("to", "20:00"))
+	if perr != nil {
+		return q, perr
+	}
+	if q.To <= q.From {
+		return q, &paramError{"to", http.StatusBadRequest, codeInvalidParameter, "to must be after from"}
+	}
+
+	var err error
+	q.Min, err = strconv.Atoi(ctx.DefaultQuery("min", "60"))
+	if err != nil || q.Min <= 0 {
+		return q, &paramError{"min", http.StatusBadRequest, codeInvalidParameter, "min must be a positive number of minutes"}
+	}
+	return q, nil
+}
+
+type apiFreeSlot struct {
+	Weekday string `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+type apiFreeTime struct {
+	// Weeks is the number of teaching weeks in which the slots are free
+	Weeks int           `json:"weeks"`
+	Slots []apiFreeSlot `json:"slots"`
+}
+
+func formatTimeOfDay(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// apiFreeSlots returns the windows of the week that are free in every
+// teaching week of the course years given as ?feeds= (in the form accepted
+// by parseFeedSpec), e.g. to schedule meetings or tutoring. The windows are
+// searched between ?from= and ?to= (HH:MM, default 08:00-20:00) and last at
+// least ?min= minutes (default 60).
+func apiFreeSlots(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		q, perr := bindFreeSlotsQuery(ctx, courses.Load())
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		timetables := make([]timetable.Timetable, 0, len(q.Feeds))
+		for _, feed := range q.Feeds {
+			t, err := getTimetable(feed.Course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+			if errors.Is(err, errOverloaded) {
+				overloaded(ctx)
+				return
+			} else if err != nil {
+				_ = ctx.Error(err)
+				writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable,
+					fmt.Sprintf("Unable to retrieve timetable of course %d", feed.Course.Codice))
+				return
+			}
+			timetables = append(timetables, t)
+		}
+
+		slots, weeks := findFreeSlots(timetables, romeLocation, q.From, q.To, q.Min)
+		res := apiFreeTime{Weeks: weeks, Slots: make([]apiFreeSlot, 0, len(slots))}
+		for _, s := range slots {
+			res.Slots = append(res.Slots, apiFreeSlot{
+				Weekday: strings.ToLower(s.Weekday.String()),
+				Start:   formatTimeOfDay(s.Start),
+				End:     formatTimeOfDay(s.End),
+			})
+		}
+		ctx.JSON(http.StatusOK, res)
+	}
+}
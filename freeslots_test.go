@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_findFreeSlots(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	at := func(day, hour int) timetable.CalendarTime {
+		return timetable.CalendarTime{Time: time.Date(2024, time.October, day, hour, 0, 0, 0, rome)}
+	}
+
+	// Tuesday 1 and 8 October, in two timetables
+	a := timetable.Timetable{{Start: at(1, 9), End: at(1, 11)}, {Start: at(8, 14), End: at(8, 16)}}
+	b := timetable.Timetable{{Start: at(1, 10), End: at(1, 13)}}
+
+	slots, weeks := findFreeSlots([]timetable.Timetable{a, b}, rome, 8*60, 18*60, 60)
+	assert.Equal(t, 2, weeks)
+
+	var tuesday []timeWindow
+	for _, s := range slots {
+		if s.Weekday == time.Tuesday {
+			tuesday = append(tuesday, s.timeWindow)
+		}
+	}
+	assert.Equal(t, []timeWindow{{8 * 60, 9 * 60}, {13 * 60, 14 * 60}, {16 * 60, 18 * 60}}, tuesday)
+	// The other days are always free
+	assert.Equal(t, 4, len(slots)-len(tuesday))
+
+	slots, _ = findFreeSlots([]timetable.Timetable{a, b}, rome, 8*60, 18*60, 90)
+	for _, s := range slots {
+		if s.Weekday == time.Tuesday {
+			assert.Equal(t, timeWindow{16 * 60, 18 * 60}, s.timeWindow)
+		}
+	}
+
+	slots, weeks = findFreeSlots(nil, rome, 8*60, 18*60, 60)
+	assert.Equal(t, 0, weeks)
+	assert.Equal(t, 0, len(slots))
+}
+
+func Test_apiFreeSlotsInvalid(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	for _, query := range []string{"", "feeds=8009", "feeds=8009-1&from=9", "feeds=8009-1&from=10:00&to=09:00", "feeds=8009-1&min=0"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/free-slots?"+query, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+const googleCalendarApi = "https://www.googleapis.com/calendar/v3"
+
+// googleRevokeUrl is the endpoint to revoke the tokens of the users.
+var googleRevokeUrl = "https://oauth2.googleapis.com/revoke"
+
+// googleOAuth is the OAuth client used to act on the calendars of the users.
+var googleOAuth = &oauth2.Config{
+	ClientID:     config.GoogleClientId,
+	ClientSecret: config.GoogleClientSecret,
+	RedirectURL:  config.GoogleRedirectUrl,
+	Endpoint:     endpoints.Google,
+	// Only the calendars created by the service can be accessed
+	Scopes: []string{"https://www.googleapis.com/auth/calendar.app.created"},
+}
+
+// googleStates contains the feeds requested by the users who are being
+// redirected to the Google consent screen, by OAuth state.
+var googleStates = cache.New(time.Minute*10, time.Minute*20)
+
+// googleEnabled reports whether the Google Calendar integration is configured.
+func googleEnabled() bool {
+	return config.GoogleClientId != "" && config.GoogleClientSecret != "" && config.GoogleRedirectUrl != ""
+}
+
+// googleSubscription is a secondary calendar on Google Calendar kept in sync
+// with the timetable of a course year.
+type googleSubscription struct {
+	Id         string        `json:"id"`
+	Token      *oauth2.Token `json:"token"`
+	CalendarId string        `json:"calendar_id"`
+
+	Course     int      `json:"course"`
+	Year       int      `json:"year"`
+	Curriculum string   `json:"curriculum,omitempty"`
+	Subjects   []string `json:"subjects,omitempty"`
+
+	// Events are the hashes of the events on the calendar, by event id
+	Events   map[string]string `json:"events"`
+	SyncedAt time.Time         `json:"synced_at"`
+}
+
+// googleSubscriptions are the calendars kept in sync, saved as JSON.
+type googleSubscriptions struct {
+	mu   sync.Mutex
+	file string
+	subs map[string]*googleSubscription
+}
+
+var googleSubs = &googleSubscriptions{file: config.GoogleSubscriptionsFile}
+
+// load reads the subscriptions from their file. A missing file is an empty
+// list of subscriptions.
+func (g *googleSubscriptions) load() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.subs = map[string]*googleSubscription{}
+	data, err := os.ReadFile(g.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read google subscriptions: %w", err)
+	}
+
+	err = json.Unmarshal(data, &g.subs)
+	if err != nil {
+		return fmt.Errorf("unable to decode google subscriptions: %w", err)
+	}
+	return nil
+}
+
+// save writes the subscriptions to their file. The caller must hold the lock.
+func (g *googleSubscriptions) save() error {
+	// The file contains the tokens of the users, so it's not readable by
+	// others.
+	return saveJsonFile(g.file, g.subs, 0o600)
+}
+
+// add stores a new subscription.
+func (g *googleSubscriptions) add(sub *googleSubscription) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.subs == nil {
+		g.subs = map[string]*googleSubscription{}
+	}
+	g.subs[sub.Id] = sub
+	return g.save()
+}
+
+// get returns the subscription with the given id.
+func (g *googleSubscriptions) get(id string) (googleSubscription, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sub, found := g.subs[id]
+	if !found {
+		return googleSubscription{}, false
+	}
+	return *sub, true
+}
+
+// disconnect deletes the calendar of the subscription from Google Calendar,
+// if asked, and revokes the access given by the user.
+func (g *googleSubscriptions) disconnect(ctx context.Context, sub googleSubscription, deleteCalendar bool) error {
+	if sub.Token == nil {
+		return nil
+	}
+
+	if deleteCalendar && sub.CalendarId != "" {
+		client := &googleClient{http: googleOAuth.Client(ctx, sub.Token)}
+		err := client.deleteCalendar(sub.CalendarId)
+		if err != nil {
+			return err
+		}
+	}
+	return revokeGoogleToken(ctx, sub.Token)
+}
+
+// remove deletes the subscription, whose calendar is not synced anymore.
+func (g *googleSubscriptions) remove(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.subs, id)
+	return g.save()
+}
+
+// Run syncs every subscription once per interval.
+func (g *googleSubscriptions) Run(courses *unibo_integ.Courses, interval time.Duration) {
+	err := g.load()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load google subscriptions")
+		return
+	}
+
+	for {
+		g.mu.Lock()
+		subs := make([]*googleSubscription, 0, len(g.subs))
+		for _, sub := range g.subs {
+			subs = append(subs, sub)
+		}
+		g.mu.Unlock()
+
+		for _, sub := range subs {
+			// The subscription can be removed while the others are synced
+			if _, found := g.get(sub.Id); found {
+				g.sync(courses, sub)
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// sync pushes the changes of the timetable to the calendar of the
+// subscription, then saves the new state.
+func (g *googleSubscriptions) sync(courses *unibo_integ.Courses, sub *googleSubscription) {
+	logger := log.With().Str("subscription", secretLogId(sub.Id)).Int("course-code", sub.Course).Logger()
+
+	course, found := courses.Load().FindById(sub.Course)
+	if !found {
+		logger.Warn().Msg("course of google subscription not found")
+		return
+	}
+
+	t, err := getTimetable(course, sub.Year, curriculum.Curriculum{Value: sub.Curriculum})
+	if err != nil {
+		logger.Warn().Err(err).Msg("unable to retrieve timetable for google sync")
+		return
+	}
+	if sub.Subjects != nil {
+		t = filterTimetableBySubjects(nil, t, sub.Subjects)
+	}
+
+	inserts, updates, deletes := googleDiff(sub.Events, t)
+	if len(inserts)+len(updates)+len(deletes) == 0 {
+		return
+	}
+
+	client := &googleClient{http: googleOAuth.Client(context.Background(), sub.Token)}
+	events := make(map[string]string, len(t))
+	for id, hash := range sub.Events {
+		events[id] = hash
+	}
+
+	push := func() error {
+		for _, event := range inserts {
+			if err := client.insertEvent(sub.CalendarId, event); err != nil {
+				return err
+			}
+			events[eventUid(event)] = eventHash(event)
+		}
+		for _, event := range updates {
+			if err := client.updateEvent(sub.CalendarId, event); err != nil {
+				return err
+			}
+			events[eventUid(event)] = eventHash(event)
+		}
+		for _, id := range deletes {
+			if err := client.deleteEvent(sub.CalendarId, id); err != nil {
+				return err
+			}
+			delete(events, id)
+		}
+		return nil
+	}
+
+	err = push()
+	if err != nil {
+		// The events pushed so far are saved, so the next sync resumes
+		logger.Warn().Err(err).Msg("unable to sync google calendar")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sub.Events = events
+	sub.SyncedAt = time.Now()
+	err = g.save()
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to save google subscriptions")
+	}
+
+	logger.Info().Int("inserted", len(inserts)).Int("updated", len(updates)).Int("deleted", len(deletes)).
+		Msg("google calendar synced")
+}
+
+// googleDiff compares the events already on the calendar, as hashes by
+// event id, with the timetable, and returns the changes to push.
+func googleDiff(synced map[string]string, t timetable.Timetable) (inserts, updates timetable.Timetable, deletes []string) {
+	current := make(map[string]bool, len(t))
+	for _, event := range t {
+		id := eventUid(event)
+		current[id] = true
+
+		hash, found := synced[id]
+		switch {
+		case !found:
+			inserts = append(inserts, event)
+		case hash != eventHash(event):
+			updates = append(updates, event)
+		}
+	}
+
+	for id := range synced {
+		if !current[id] {
+			deletes = append(deletes, id)
+		}
+	}
+	return inserts, updates, deletes
+}
+
+// googleEvent is an event of the Google Calendar API.
+type googleEvent struct {
+	Id          string         `json:"id,omitempty"`
+	Summary     string         `json:"summary"`
+	Description string         `json:"description,omitempty"`
+	Location    string         `json:"location,omitempty"`
+	Start       googleDateTime `json:"start"`
+	End         googleDateTime `json:"end"`
+	Source      *googleSource  `json:"source,omitempty"`
+}
+
+type googleDateTime struct {
+	DateTime string `json:"dateTime"`
+}
+
+type googleSource struct {
+	Title string `json:"title"`
+	Url   string `json:"url"`
+}
+
+// newGoogleEvent converts the event of the timetable. The id is the UID of
+// the ICS event, which is a valid Google event id (lowercase hex).
+func newGoogleEvent(event timetable.Event) googleEvent {
+	ge := googleEvent{
+		Id:          eventUid(event),
+		Summary:     event.Title,
+		Description: eventDescription(event),
+		Start:       googleDateTime{DateTime: event.Start.Format(time.RFC3339)},
+		End:         googleDateTime{DateTime: event.End.Format(time.RFC3339)},
+	}
+	if len(event.Classrooms) > 0 {
+		ge.Location = event.Classrooms[0].ResourceDesc
+	}
+	if u, ok := teachingUrl(event); ok {
+		ge.Source = &googleSource{Title: event.Title, Url: u}
+	}
+	return ge
+}
+
+// googleClient is a minimal client of the Google Calendar API.
+type googleClient struct {
+	http *http.Client
+}
+
+// do sends the request, encoding body as JSON if not nil, and decodes the
+// response in out if not nil. Status codes in ok are not errors.
+func (c *googleClient) do(method string, url string, body any, out any, ok ...int) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to reach google calendar: %w", err)
+	}
+	defer res.Body.Close()
+
+	for _, code := range ok {
+		if res.StatusCode == code {
+			return res.StatusCode, nil
+		}
+	}
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return res.StatusCode, fmt.Errorf("google calendar returned %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return res.StatusCode, fmt.Errorf("unable to decode google calendar response: %w", err)
+		}
+	}
+	return res.StatusCode, nil
+}
+
+// createCalendar creates a secondary calendar and returns its id.
+func (c *googleClient) createCalendar(name string) (string, error) {
+	var cal struct {
+		Id string `json:"id"`
+	}
+	_, err := c.do(http.MethodPost, googleCalendarApi+"/calendars", map[string]string{
+		"summary":  name,
+		"timeZone": "Europe/Rome",
+	}, &cal)
+	return cal.Id, err
+}
+
+func (c *googleClient) eventsUrl(calendarId string) string {
+	return googleCalendarApi + "/calendars/" + url.PathEscape(calendarId) + "/events"
+}
+
+// insertEvent creates the event, or updates it if it already exists (e.g.
+// when a previous sync failed before saving).
+func (c *googleClient) insertEvent(calendarId string, event timetable.Event) error {
+	status, err := c.do(http.MethodPost, c.eventsUrl(calendarId), newGoogleEvent(event), nil, http.StatusConflict)
+	if status == http.StatusConflict {
+		return c.updateEvent(calendarId, event)
+	}
+	return err
+}
+
+func (c *googleClient) updateEvent(calendarId string, event timetable.Event) error {
+	_, err := c.do(http.MethodPut, c.eventsUrl(calendarId)+"/"+eventUid(event), newGoogleEvent(event), nil)
+	return err
+}
+
+// deleteEvent deletes the event. Events already deleted by the user are not
+// an error.
+func (c *googleClient) deleteEvent(calendarId string, id string) error {
+	_, err := c.do(http.MethodDelete, c.eventsUrl(calendarId)+"/"+id, nil, nil, http.StatusNotFound, http.StatusGone)
+	return err
+}
+
+// deleteCalendar deletes the secondary calendar. Calendars already deleted
+// by the user are not an error.
+func (c *googleClient) deleteCalendar(calendarId string) error {
+	_, err := c.do(http.MethodDelete, googleCalendarApi+"/calendars/"+url.PathEscape(calendarId), nil, nil,
+		http.StatusNotFound, http.StatusGone)
+	return err
+}
+
+// revokeGoogleToken revokes the access given by the user: the refresh
+// token, if any, revokes the access token too.
+func revokeGoogleToken(ctx context.Context, token *oauth2.Token) error {
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleRevokeUrl, strings.NewReader(url.Values{"token": {value}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach google: %w", err)
+	}
+	defer res.Body.Close()
+
+	// An invalid token is already revoked, e.g. from the settings of the
+	// account
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("google returned %s revoking the token", res.Status)
+	}
+	return nil
+}
+
+// randomId returns a random hex string, unguessable by other users.
+func randomId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// googleConnect redirects the user to the consent screen of Google, to sync
+// the calendar of the course year given in the query.
+func googleConnect(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Query("course"))
+		if err != nil {
+			errorPage(ctx, http.StatusBadRequest, "Codice del corso non valido")
+			return
+		}
+		course, found := courses.Load().FindById(id)
+		if !found {
+			errorPage(ctx, http.StatusNotFound, "Corso non trovato")
+			return
+		}
+		year, err := strconv.Atoi(ctx.Query("anno"))
+		if err != nil || year <= 0 || year > course.DurataAnni {
+			errorPage(ctx, http.StatusBadRequest, "Anno non valido")
+			return
+		}
+		if perr := checkCurriculum(course, "curr", year, ctx.Query("curr")); perr != nil {
+			errorPage(ctx, perr.Status, curriculumErrorText(perr))
+			return
+		}
+
+		sub := &googleSubscription{
+			Id:         randomId(),
+			Course:     course.Codice,
+			Year:       year,
+			Curriculum: ctx.Query("curr"),
+			Subjects:   parseCalOptions(ctx).Subjects,
+		}
+		state := randomId()
+		googleStates.Set(state, sub, cache.DefaultExpiration)
+
+		ctx.Redirect(http.StatusFound, googleOAuth.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce))
+	}
+}
+
+// googleCallback completes the authorization, creates the calendar and
+// starts the first sync.
+func googleCallback(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		state := ctx.Query("state")
+		s, found := googleStates.Get(state)
+		if !found {
+			errorPage(ctx, http.StatusBadRequest, "Autorizzazione non valida o scaduta, riprova")
+			return
+		}
+		googleStates.Delete(state)
+		sub := s.(*googleSubscription)
+
+		if ctx.Query("error") != "" {
+			errorPage(ctx, http.StatusForbidden, "Autorizzazione negata")
+			return
+		}
+
+		// The course could have been removed from the open data meanwhile
+		course, found := courses.Load().FindById(sub.Course)
+		if !found {
+			errorPage(ctx, http.StatusNotFound, "Corso non trovato")
+			return
+		}
+
+		token, err := googleOAuth.Exchange(ctx, ctx.Query("code"))
+		if err != nil {
+			_ = ctx.Error(err)
+			errorPage(ctx, http.StatusBadGateway, "Impossibile completare l'autorizzazione con Google")
+			return
+		}
+		sub.Token = token
+
+		client := &googleClient{http: googleOAuth.Client(ctx, token)}
+		sub.CalendarId, err = client.createCalendar(fmt.Sprintf("%s - %d anno", course.Descrizione, sub.Year))
+		if err != nil {
+			_ = ctx.Error(err)
+			errorPage(ctx, http.StatusBadGateway, "Impossibile creare il calendario su Google Calendar")
+			return
+		}
+
+		err = googleSubs.add(sub)
+		if err != nil {
+			_ = ctx.Error(err)
+			errorPage(ctx, http.StatusInternalServerError, "Impossibile salvare il calendario")
+			return
+		}
+
+		go googleSubs.sync(courses, sub)
+		ctx.String(http.StatusOK, "Il calendario è stato creato su Google Calendar, le lezioni verranno aggiunte a breve.\n\n"+
+			"Per interrompere la sincronizzazione ed eliminare i tuoi dati: %s", meLink(sub.Id))
+	}
+}
+
+// setupGoogle registers the endpoints of the Google Calendar integration.
+func setupGoogle(r *gin.Engine, courses *unibo_integ.Courses) {
+	r.GET("/google/connect", googleConnect(courses))
+	r.GET("/google/callback", googleCallback(courses))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"github.com/patrickmn/go-cache"
+)
+
+func Test_googleDiff(t *testing.T) {
+	tt := testTimetable()
+
+	inserts, updates, deletes := googleDiff(nil, tt)
+	assert.Equal(t, 2, len(inserts))
+	assert.Equal(t, 0, len(updates))
+	assert.Equal(t, 0, len(deletes))
+
+	synced := map[string]string{
+		eventUid(tt[0]): eventHash(tt[0]),
+		eventUid(tt[1]): "outdated",
+		"removed":       "hash",
+	}
+	inserts, updates, deletes = googleDiff(synced, tt)
+	assert.Equal(t, 0, len(inserts))
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, "ANALISI MATEMATICA", updates[0].Title)
+	assert.Equal(t, []string{"removed"}, deletes)
+}
+
+func Test_newGoogleEvent(t *testing.T) {
+	event := newGoogleEvent(testTimetable()[0])
+
+	assert.Equal(t, eventUid(testTimetable()[0]), event.Id)
+	assert.Equal(t, "ALGEBRA", event.Summary)
+	assert.Equal(t, "AULA 1", event.Location)
+	assert.Equal(t, "2024-10-01T09:00:00+02:00", event.Start.DateTime)
+	assert.NotEqual(t, nil, event.Source)
+}
+
+func Test_googleCallback_removedCourse(t *testing.T) {
+	googleStates.Set("state", &googleSubscription{Id: randomId(), Course: 1, Year: 1}, cache.DefaultExpiration)
+
+	r := gin.New()
+	r.HTMLRender = createMyRender()
+	r.GET("/google/callback", googleCallback(testCourses))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/google/callback?state=state&code=code", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// homeCourses is the number of most followed courses shown on the homepage.
+const homeCourses = 6
+
+// popularCourses returns the n courses whose calendars have the most
+// requests, skipping the ones never requested.
+func popularCourses(courses []unibo_integ.Course, requests map[int]int, n int) []unibo_integ.Course {
+	var popular []unibo_integ.Course
+	for _, c := range courses {
+		if requests[c.Codice] > 0 {
+			popular = append(popular, c)
+		}
+	}
+	slices.SortStableFunc(popular, func(a, b unibo_integ.Course) int {
+		return cmp.Compare(requests[b.Codice], requests[a.Codice])
+	})
+	if len(popular) > n {
+		popular = popular[:n]
+	}
+	return popular
+}
+
+// sortByPopularity sorts the groups by the requests of the calendars of
+// their courses, the most followed first. Groups with the same requests
+// keep their order.
+func sortByPopularity(groups []CourseGroup, requests map[int]int) {
+	total := func(g CourseGroup) int {
+		n := 0
+		for _, c := range g.Courses {
+			n += requests[c.Codice]
+		}
+		return n
+	}
+	slices.SortStableFunc(groups, func(a, b CourseGroup) int {
+		return cmp.Compare(total(b), total(a))
+	})
+}
+
+// indexPage renders the homepage, with the courses recently viewed by the
+// visitor, the most followed courses and the campuses, the most followed
+// first.
+func indexPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		m := courses.Load()
+		list := m.ToList()
+		requests := calStats.courseRequests()
+
+		campuses := groupByCampus(list)
+		sortByPopularity(campuses, requests)
+
+		renderHTML(c, http.StatusOK, "index", gin.H{
+			"recent":   recentCourses(c, m),
+			"popular":  popularCourses(list, requests, homeCourses),
+			"campuses": campuses,
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_popularCourses(t *testing.T) {
+	list := testCourses.Load().ToList()
+	requests := map[int]int{8009: 3, 9254: 10}
+
+	popular := popularCourses(list, requests, 1)
+	assert.Equal(t, 1, len(popular))
+	assert.Equal(t, 9254, popular[0].Codice)
+
+	popular = popularCourses(list, map[int]int{8009: 1}, homeCourses)
+	assert.Equal(t, 1, len(popular))
+	assert.Equal(t, 8009, popular[0].Codice)
+}
+
+func Test_sortByPopularity(t *testing.T) {
+	campuses := groupByCampus(testCourses.Load().ToList())
+	assert.Equal(t, "Bologna", campuses[0].Name)
+
+	sortByPopularity(campuses, map[int]int{9254: 1})
+	assert.Equal(t, "Cesena", campuses[0].Name)
+	assert.Equal(t, "Bologna", campuses[1].Name)
+}
+
+func Test_indexPage(t *testing.T) {
+	calStats.hit(feedRef{Course: 9254, Year: 1})
+
+	r := setupRouter(testCourses)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "I corsi più seguiti"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "INGEGNERIA INFORMATICA"))
+}
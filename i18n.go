@@ -0,0 +1,96 @@
+package main
+
+import (
+	"cmp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The pages are written in Italian. The templates pass their texts to tr,
+// which returns the English translation for the English visitors, see
+// requestLang. The texts without a translation stay Italian.
+
+// englishTexts are the English translations of the texts of the pages.
+var englishTexts = map[string]string{
+	// header and footer
+	"Corsi":                  "Courses",
+	"I miei corsi":           "My courses",
+	"Crea il tuo calendario": "Build your calendar",
+	"Scuole":                 "Schools",
+	"Docenti":                "Teachers",
+	"Confronta":              "Compare",
+	"Sovrapposizioni":        "Overlaps",
+	"Stato del servizio":     "Service status",
+	"Eventi di Ateneo":       "University events",
+	"Codice sorgente":        "Source code",
+
+	// home
+	"Vai ai Corsi":        "Go to the courses",
+	"Sfoglia per Scuola":  "Browse by school",
+	"Cerca per Docente":   "Search by teacher",
+	"Apri calendario":     "Open calendar",
+	"Visti di recente":    "Recently viewed",
+	"I corsi più seguiti": "Most followed courses",
+
+	// favorites
+	"Rimuovi":                               "Remove",
+	"Rimuovi dai miei corsi":                "Remove from my courses",
+	"anno":                                  "year",
+	"Scarica il file ICS":                   "Download the ICS file",
+	"Non hai ancora aggiunto nessun corso.": "You haven't added any course yet.",
+	`Apri la pagina di un corso e premi "Aggiungi ai miei corsi" per ritrovarlo qui, con i link per iscriverti ai calendari di ogni anno.`: `Open the page of a course and press "Add to my courses" to find it here, with the links to subscribe to the calendars of every year.`,
+
+	// data deletion
+	"I tuoi dati": "Your data",
+	"I tuoi dati sono stati eliminati, non riceverai più email né notifiche.": "Your data has been deleted, you won't receive emails or notifications anymore.",
+	"Torna alla home":                                      "Back to the home page",
+	"A questo link sono associati:":                        "This link is associated with:",
+	"iscrizioni al riepilogo delle lezioni dell'indirizzo": "subscriptions to the lesson digest of the address",
+	"un webhook di una chat":                               "a chat webhook",
+	"un calendario sincronizzato su Google Calendar":       "a calendar synced to Google Calendar",
+	"Eliminandoli non riceverai più email né notifiche, e il calendario su Google Calendar non verrà più aggiornato. L'operazione non può essere annullata.": "Deleting it you won't receive emails or notifications anymore, and the calendar on Google Calendar won't be updated anymore. This can't be undone.",
+	"Elimina i miei dati":                            "Delete my data",
+	"Elimina anche il calendario da Google Calendar": "Delete the calendar from Google Calendar too",
+	"I corsi seguiti da una stanza Matrix non sono legati a questo link: si rimuovono scrivendo nella stanza !orari smetti <id corso> <anno>.": "The courses followed by a Matrix room are not tied to this link: remove them by writing !orari smetti <course id> <year> in the room.",
+
+	// errors
+	"Errore":                              "Error",
+	"Cerca un corso":                      "Search a course",
+	"Cerca":                               "Search",
+	"Torna alla lista dei corsi":          "Back to the course list",
+	"Calendario":                          "Calendar",
+	"Pagina non trovata":                  "Page not found",
+	"Anno non valido":                     "Invalid year",
+	"Curriculum non valido":               "Invalid curriculum",
+	"Corso non trovato":                   "Course not found",
+	"Codice del corso non valido":         "Invalid course code",
+	"Iscrizione non trovata":              "Subscription not found",
+	"Indirizzo email non valido":          "Invalid email address",
+	"Nessun dato associato a questo link": "No data is associated with this link",
+	"Si è verificato un errore, riprova più tardi":                            "Something went wrong, try again later",
+	"Impossibile scaricare i curricula da Unibo, riprova più tardi":           "Unable to download the curricula from Unibo, try again later",
+	"Non è stato possibile verificare il corso dell'indirizzo, scegli il tuo": "The course of the address couldn't be verified, choose yours",
+}
+
+// tr returns the text in the language of the page.
+func tr(lang string, text string) string {
+	if lang != langEnglish {
+		return text
+	}
+	if translated, found := englishTexts[text]; found {
+		return translated
+	}
+	return text
+}
+
+// renderHTML renders the page with the data, adding the language of the
+// page as "Lang" (see requestLang) and remembering the one asked with
+// ?lang=.
+func renderHTML(c *gin.Context, status int, name string, data gin.H) {
+	rememberLang(c)
+	if data == nil {
+		data = gin.H{}
+	}
+	data["Lang"] = cmp.Or(requestLang(c), langItalian)
+	c.HTML(status, name, data)
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// maxExternalOccurrences is the maximum number of events read from an
+// external calendar, after expanding the recurrences.
+const maxExternalOccurrences = 5000
+
+// busyInterval is an event of an external calendar, e.g. a work shift.
+type busyInterval struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// recurrence is the subset of the RRULE of RFC 5545 supported when reading
+// external calendars: daily and weekly events, with INTERVAL, COUNT, UNTIL
+// and, for the weekly ones, BYDAY. This covers shifts and courses, which
+// calendar apps export this way.
+type recurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseRecurrence parses the value of an RRULE property.
+func parseRecurrence(rule string, loc *time.Location) (recurrence, error) {
+	r := recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval <= 0 {
+				err = errors.New("interval must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = parseIcsTime(value, loc)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, found := icsWeekdays[strings.ToUpper(day)]
+				if !found {
+					return r, fmt.Errorf("unsupported BYDAY %q", day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "WKST":
+		default:
+			return r, fmt.Errorf("unsupported rule part %q", name)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	if r.Freq != "DAILY" && r.Freq != "WEEKLY" {
+		return r, fmt.Errorf("unsupported frequency %q", r.Freq)
+	}
+	if r.Freq == "DAILY" && r.ByDay != nil {
+		return r, errors.New("BYDAY is only supported for weekly events")
+	}
+	return r, nil
+}
+
+// occurrences returns the starts of the occurrences of the event starting at
+// start, from the given time and before the limit. excluded are the starts
+// removed by EXDATE.
+//
+// The occurrences before from are skipped, without expanding them one by
+// one, but they still count for COUNT.
+func (r recurrence) occurrences(start time.Time, from time.Time, limit time.Time, excluded []time.Time) []time.Time {
+	var starts []time.Time
+	count := 0
+	emit := func(t time.Time) bool {
+		if (r.Count > 0 && count >= r.Count) || (!r.Until.IsZero() && t.After(r.Until)) || !t.Before(limit) ||
+			len(starts) >= maxExternalOccurrences {
+			return false
+		}
+		count++
+		if !t.Before(from) && !slices.ContainsFunc(excluded, t.Equal) {
+			starts = append(starts, t)
+		}
+		return true
+	}
+
+	if r.Freq == "DAILY" || r.ByDay == nil {
+		step := r.Interval
+		if r.Freq == "WEEKLY" {
+			step *= 7
+		}
+		// One step less, since daysBetween counts the days of Europe/Rome and
+		// the event can be in another time zone
+		skipped := max(daysBetween(start, from)/step-1, 0)
+		count = skipped
+		for t := start.AddDate(0, 0, skipped*step); emit(t); t = t.AddDate(0, 0, step) {
+		}
+		return starts
+	}
+
+	days := slices.Clone(r.ByDay)
+	// The days of the week in order, starting from Monday
+	slices.SortFunc(days, func(a, b time.Weekday) int { return (int(a)+6)%7 - (int(b)+6)%7 })
+	monday := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+
+	// One week less, as above
+	skipped := max(daysBetween(monday, from)/(7*r.Interval)-1, 0)
+	if skipped > 0 {
+		// The days of the first week before the start are not occurrences
+		before := 0
+		for _, day := range days {
+			if monday.AddDate(0, 0, (int(day)+6)%7).Before(start) {
+				before++
+			}
+		}
+		count = skipped*len(days) - before
+	}
+	for week := monday.AddDate(0, 0, skipped*7*r.Interval); ; week = week.AddDate(0, 0, 7*r.Interval) {
+		for _, day := range days {
+			t := week.AddDate(0, 0, (int(day)+6)%7)
+			if t.Before(start) {
+				continue
+			}
+			if !emit(t) {
+				return starts
+			}
+		}
+	}
+}
+
+// parseIcsTime parses a DATE or DATE-TIME value. Floating times are in loc.
+func parseIcsTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	if len(value) == len("20060102") {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// eventLocation returns the location of the times of a property: its TZID,
+// or loc for the floating ones.
+func eventLocation(prop *ics.IANAProperty, loc *time.Location) *time.Location {
+	if prop == nil {
+		return loc
+	}
+	if tzid, found := prop.ICalParameters["TZID"]; found && len(tzid) == 1 {
+		if l, err := time.LoadLocation(tzid[0]); err == nil {
+			return l
+		}
+	}
+	return loc
+}
+
+// eventTimes returns the start and the end of the event. Events without an
+// end last a day if they are all-day ones, otherwise they take no time.
+func eventTimes(e *ics.VEvent, loc *time.Location) (time.Time, time.Time, error) {
+	startProp := e.GetProperty(ics.ComponentPropertyDtStart)
+	if startProp == nil {
+		return time.Time{}, time.Time{}, errors.New("missing DTSTART")
+	}
+	start, err := parseIcsTime(startProp.Value, eventLocation(startProp, loc))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	endProp := e.GetProperty(ics.ComponentPropertyDtEnd)
+	if endProp == nil {
+		if len(startProp.Value) == len("20060102") {
+			return start, start.AddDate(0, 0, 1), nil
+		}
+		return start, start, nil
+	}
+	end, err := parseIcsTime(endProp.Value, eventLocation(endProp, loc))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// parseBusyIntervals returns the busy events of the external calendar that
+// end after from and start before the limit, expanding the recurring ones
+// (see recurrence). Floating times are in loc. The events that can't be
+// read, or whose recurrence is not supported, are counted as skipped.
+func parseBusyIntervals(data []byte, loc *time.Location, from time.Time, limit time.Time) ([]busyInterval, int, error) {
+	cal, err := ics.ParseCalendar(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var intervals []busyInterval
+	skipped := 0
+events:
+	for _, e := range cal.Events() {
+		if transp := e.GetProperty(ics.ComponentPropertyTransp); transp != nil &&
+			strings.EqualFold(transp.Value, string(ics.TransparencyTransparent)) {
+			continue
+		}
+
+		start, end, err := eventTimes(e, loc)
+		if err != nil || end.Before(start) {
+			skipped++
+			continue
+		}
+		summary := ""
+		if p := e.GetProperty(ics.ComponentPropertySummary); p != nil {
+			summary = p.Value
+		}
+
+		starts := []time.Time{start}
+		if rule := e.GetProperty(ics.ComponentPropertyRrule); rule != nil {
+			r, err := parseRecurrence(rule.Value, loc)
+			if err != nil {
+				skipped++
+				continue
+			}
+
+			var excluded []time.Time
+			for i := range e.Properties {
+				prop := &e.Properties[i]
+				if prop.IANAToken != string(ics.ComponentPropertyExdate) {
+					continue
+				}
+				for _, value := range strings.Split(prop.Value, ",") {
+					if t, err := parseIcsTime(value, eventLocation(prop, loc)); err == nil {
+						excluded = append(excluded, t)
+					}
+				}
+			}
+			// The occurrences starting before from can still end after it
+			starts = r.occurrences(start, from.Add(-end.Sub(start)), limit, excluded)
+		}
+
+		for _, s := range starts {
+			busy := busyInterval{Summary: summary, Start: s, End: s.Add(end.Sub(start))}
+			if !busy.End.After(from) || !busy.Start.Before(limit) {
+				continue
+			}
+			if len(intervals) >= maxExternalOccurrences {
+				break events
+			}
+			intervals = append(intervals, busy)
+		}
+	}
+
+	slices.SortFunc(intervals, func(a, b busyInterval) int { return a.Start.Compare(b.Start) })
+	return intervals, skipped, nil
+}
+
+// externalConflict is a lesson overlapping an event of an external calendar.
+type externalConflict struct {
+	Lesson timetable.Event
+	Event  busyInterval
+}
+
+// findExternalConflicts returns the lessons of t overlapping the intervals,
+// which must be sorted by start.
+func findExternalConflicts(t timetable.Timetable, intervals []busyInterval) []externalConflict {
+	var conflicts []externalConflict
+	for _, lesson := range t {
+		for _, busy := range intervals {
+			if !busy.Start.Before(lesson.End.Time) {
+				break
+			}
+			if busy.End.After(lesson.Start.Time) {
+				conflicts = append(conflicts, externalConflict{lesson, busy})
+			}
+		}
+	}
+	return conflicts
+}
+
+// readExternalCalendar returns the calendar given with the request: linked
+// with the "url" parameter, uploaded as the "file" form field, or sent as the
+// body with the text/calendar content type. Uploads are bounded by the
+// request size limiter of the router: when exceeded, the request is aborted
+// and the response already written.
+func readExternalCalendar(ctx *gin.Context) ([]byte, int, error) {
+	if rawUrl := ctx.Request.FormValue("url"); rawUrl != "" {
+		data, err := fetchCalendar(rawUrl)
+		if err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+		return data, http.StatusOK, nil
+	}
+
+	var data []byte
+	var err error
+	if header, ferr := ctx.FormFile("file"); ferr == nil {
+		var file io.ReadCloser
+		file, err = header.Open()
+		if err == nil {
+			defer file.Close()
+			data, err = io.ReadAll(file)
+		}
+	} else if ctx.ContentType() == "text/calendar" {
+		data, err = io.ReadAll(ctx.Request.Body)
+	} else if !ctx.IsAborted() {
+		return nil, http.StatusBadRequest,
+			errors.New("missing calendar: send it as the file field, as a text/calendar body or link it with url")
+	}
+
+	if ctx.IsAborted() {
+		return nil, http.StatusRequestEntityTooLarge, errors.New("calendar too large")
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("unable to read the calendar")
+	}
+	return data, http.StatusOK, nil
+}
+
+type apiExternalEvent struct {
+	Summary string    `json:"summary"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+type apiExternalConflict struct {
+	Lesson apiConflictLesson `json:"lesson"`
+	Event  apiExternalEvent  `json:"event"`
+}
+
+type apiExternalConflicts struct {
+	// Events is the number of events of the external calendar considered
+	Events    int                   `json:"events"`
+	Skipped   int                   `json:"skipped"`
+	Conflicts []apiExternalConflict `json:"conflicts"`
+}
+
+// checkExternalConflicts compares the timetable of the course year with the
+// external calendar.
+func checkExternalConflicts(feed feedParams, data []byte) (apiExternalConflicts, int, error) {
+	t, err := getTimetable(feed.Course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+	if errors.Is(err, errOverloaded) {
+		return apiExternalConflicts{}, http.StatusServiceUnavailable, err
+	} else if err != nil {
+		return apiExternalConflicts{}, http.StatusBadGateway, errors.New("unable to retrieve timetable")
+	}
+
+	// Recurrences are expanded only over the timetable
+	var from, limit time.Time
+	for _, e := range t {
+		if from.IsZero() || e.Start.Before(from) {
+			from = e.Start.Time
+		}
+		if e.End.After(limit) {
+			limit = e.End.Time
+		}
+	}
+
+	intervals, skipped, err := parseBusyIntervals(data, romeLocation, from, limit)
+	if err != nil {
+		return apiExternalConflicts{}, http.StatusBadRequest, fmt.Errorf("invalid calendar: %w", err)
+	}
+
+	conflicts := findExternalConflicts(t, intervals)
+	res := apiExternalConflicts{Events: len(intervals), Skipped: skipped, Conflicts: make([]apiExternalConflict, 0, len(conflicts))}
+	for _, c := range conflicts {
+		res.Conflicts = append(res.Conflicts, apiExternalConflict{
+			Lesson: newApiConflictLesson(c.Lesson),
+			Event:  apiExternalEvent{Summary: c.Event.Summary, Start: c.Event.Start, End: c.Event.End},
+		})
+	}
+	return res, http.StatusOK, nil
+}
+
+// apiExternalConflictsHandler reports the lessons of the course year that
+// overlap the events of an external calendar (work shifts, the gym), see
+// readExternalCalendar.
+func apiExternalConflictsHandler(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		feed, perr := bindFeed(ctx, courses.Load())
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		data, status, err := readExternalCalendar(ctx)
+		if ctx.IsAborted() {
+			return
+		} else if err != nil {
+			writeProblem(ctx, status, customProblemCodes[status], err.Error())
+			return
+		}
+
+		res, status, err := checkExternalConflicts(feed, data)
+		if status == http.StatusServiceUnavailable {
+			overloaded(ctx)
+			return
+		} else if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, status, customProblemCodes[status], err.Error())
+			return
+		}
+		ctx.JSON(http.StatusOK, res)
+	}
+}
+
+// externalConflictsPage is the form to upload or link a calendar and check
+// it against a course year, in the form accepted by parseFeedSpec.
+func externalConflictsPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		data := gin.H{"feed": ctx.Request.FormValue("feed"), "url": ctx.Request.FormValue("url")}
+		if ctx.Request.Method != http.MethodPost {
+			renderHTML(ctx, http.StatusOK, "conflicts", data)
+			return
+		}
+
+		feed, perr := parseFeedSpec(courses.Load(), "feed", ctx.Request.FormValue("feed"))
+		if perr != nil {
+			data["error"] = perr.Message
+			renderHTML(ctx, perr.Status, "conflicts", data)
+			return
+		}
+
+		cal, status, err := readExternalCalendar(ctx)
+		if ctx.IsAborted() {
+			return
+		} else if err != nil {
+			data["error"] = "Carica un file ICS o indica il suo indirizzo."
+			if status == http.StatusBadGateway {
+				data["error"] = "Impossibile scaricare il calendario dall'indirizzo indicato."
+			}
+			renderHTML(ctx, status, "conflicts", data)
+			return
+		}
+
+		res, status, err := checkExternalConflicts(feed, cal)
+		if err != nil {
+			_ = ctx.Error(err)
+			data["error"] = "Impossibile scaricare l'orario da Unibo, riprova più tardi."
+			if status == http.StatusBadRequest {
+				data["error"] = "Il calendario caricato non è valido."
+			}
+			renderHTML(ctx, status, "conflicts", data)
+			return
+		}
+		data["course"] = feed.Course
+		data["result"] = res
+		renderHTML(ctx, http.StatusOK, "conflicts", data)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+const testExternalCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:test
+BEGIN:VEVENT
+UID:shift
+SUMMARY:Turno
+DTSTART;TZID=Europe/Rome:20241001T100000
+DTEND;TZID=Europe/Rome:20241001T120000
+RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4
+EXDATE;TZID=Europe/Rome:20241003T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:free
+SUMMARY:Promemoria
+DTSTART:20241001T080000Z
+DTEND:20241001T090000Z
+TRANSP:TRANSPARENT
+END:VEVENT
+BEGIN:VEVENT
+UID:monthly
+SUMMARY:Riunione
+DTSTART:20241001T080000
+DTEND:20241001T090000
+RRULE:FREQ=MONTHLY
+END:VEVENT
+END:VCALENDAR
+`
+
+func Test_recurrenceOccurrences(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	start := time.Date(2024, time.October, 2, 18, 0, 0, 0, rome) // Wednesday
+	limit := time.Date(2024, time.November, 1, 0, 0, 0, 0, rome)
+
+	r, err := parseRecurrence("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", rome)
+	assert.Equal(t, nil, err)
+	starts := r.occurrences(start, start, limit, nil)
+	// The Monday before the start is skipped
+	assert.Equal(t, []time.Time{start, start.AddDate(0, 0, 12), start.AddDate(0, 0, 14), start.AddDate(0, 0, 26), start.AddDate(0, 0, 28)}, starts)
+
+	r, err = parseRecurrence("FREQ=DAILY;UNTIL=20241004T235959Z", rome)
+	assert.Equal(t, nil, err)
+	// 2, 3 and 4 October, without the excluded 3
+	assert.Equal(t, 2, len(r.occurrences(start, start, limit, []time.Time{start.AddDate(0, 0, 1)})))
+
+	// The occurrences before from are skipped, but counted
+	r, err = parseRecurrence("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", rome)
+	assert.Equal(t, nil, err)
+	from := time.Date(2024, time.October, 20, 0, 0, 0, 0, rome)
+	assert.Equal(t, starts[3:], r.occurrences(start, from, limit, nil))
+	r, err = parseRecurrence("FREQ=DAILY;COUNT=30", rome)
+	assert.Equal(t, nil, err)
+	from = time.Date(2024, time.October, 30, 0, 0, 0, 0, rome)
+	assert.Equal(t, []time.Time{start.AddDate(0, 0, 28), start.AddDate(0, 0, 29)}, r.occurrences(start, from, limit, nil))
+	old := time.Date(1990, time.January, 1, 18, 0, 0, 0, rome)
+	r, err = parseRecurrence("FREQ=DAILY", rome)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 30, len(r.occurrences(old, start, limit, nil)))
+
+	_, err = parseRecurrence("FREQ=YEARLY", rome)
+	assert.NotEqual(t, nil, err)
+	_, err = parseRecurrence("FREQ=WEEKLY;BYDAY=1MO", rome)
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_externalConflicts(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+
+	intervals, skipped, err := parseBusyIntervals([]byte(strings.ReplaceAll(testExternalCalendar, "\n", "\r\n")), rome, time.Time{},
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, rome))
+	assert.Equal(t, nil, err)
+	// The monthly event is not supported, the transparent one is not busy
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, 3, len(intervals))
+	assert.Equal(t, "Turno", intervals[0].Summary)
+	assert.Equal(t, time.Date(2024, time.October, 8, 10, 0, 0, 0, rome), intervals[1].Start)
+	assert.Equal(t, time.Date(2024, time.October, 8, 12, 0, 0, 0, rome), intervals[1].End)
+
+	// ALGEBRA on 1 October from 9 to 11 overlaps the shift, ANALISI from 11 to 13 too
+	conflicts := findExternalConflicts(testTimetable(), intervals)
+	assert.Equal(t, 2, len(conflicts))
+	assert.Equal(t, "ALGEBRA", conflicts[0].Lesson.Title)
+	assert.Equal(t, "ANALISI MATEMATICA", conflicts[1].Lesson.Title)
+}
+
+func Test_parseBusyIntervalsLimit(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	calendar := `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:late
+DTSTART;TZID=Europe/Rome:20300101T100000
+DTEND;TZID=Europe/Rome:20300101T110000
+RRULE:FREQ=WEEKLY
+END:VEVENT
+BEGIN:VEVENT
+UID:daily
+DTSTART;TZID=Europe/Rome:20240101T100000
+DTEND;TZID=Europe/Rome:20240101T110000
+RRULE:FREQ=DAILY
+END:VEVENT
+END:VCALENDAR
+`
+	from := time.Date(2024, time.October, 1, 0, 0, 0, 0, rome)
+	intervals, _, err := parseBusyIntervals([]byte(strings.ReplaceAll(calendar, "\n", "\r\n")), rome, from,
+		time.Date(2100, time.January, 1, 0, 0, 0, 0, rome))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, maxExternalOccurrences, len(intervals))
+	// The occurrences before from are not counted, and the ones kept at the
+	// limit are sorted
+	assert.Equal(t, time.Date(2024, time.October, 1, 10, 0, 0, 0, rome), intervals[0].Start)
+	assert.Equal(t, true, slices.IsSortedFunc(intervals, func(a, b busyInterval) int { return a.Start.Compare(b.Start) }))
+}
+
+func Test_apiExternalConflictsMissingCalendar(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/conflicts/8009/1", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// identityKey returns a string identifying the options that change which
+// events are in the calendar. The ones changing only how the events look
+// (titles, language, format...) keep the identity of the calendar.
+func (o calOptions) identityKey() string {
+	return fmt.Sprintf("%s-%s-%v-%d-%t", o.Subjects, o.Types, o.SkipDays, o.Period, o.Combined)
+}
+
+// calendarIdentity returns the identifier of the calendar of the feed with
+// the given options, a name based UUID, so that it's the same at every
+// download.
+func calendarIdentity(feed string, opts calOptions) string {
+	sum := sha1.Sum([]byte(feed + "|" + opts.identityKey()))
+	// Version 5 and RFC 4122 variant, like uuid.NewSHA1
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// setCalendarIdentity sets the identity of the calendar, as X-WR-RELCALID,
+// used by Apple Calendar and Outlook, and as the UID of RFC 7986. Clients
+// keying on it treat the downloads of the same feed as the same calendar.
+func setCalendarIdentity(cal *ics.Calendar, id string) {
+	cal.SetXWRCalID(id)
+	for i := range cal.CalendarProperties {
+		if cal.CalendarProperties[i].IANAToken == string(ics.PropertyUid) {
+			cal.CalendarProperties[i].Value = id
+			return
+		}
+	}
+	cal.CalendarProperties = append(cal.CalendarProperties, ics.CalendarProperty{
+		BaseProperty: ics.BaseProperty{IANAToken: string(ics.PropertyUid), Value: id},
+	})
+}
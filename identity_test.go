@@ -0,0 +1,40 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_calendarIdentity(t *testing.T) {
+	id := calendarIdentity("course-8009-1-", calOptions{})
+	assert.MatchRegex(t, id, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+	assert.Equal(t, id, calendarIdentity("course-8009-1-", calOptions{}))
+
+	// Options changing only how the events look keep the identity
+	assert.Equal(t, id, calendarIdentity("course-8009-1-", calOptions{Titles: titlesShort, Lang: langEnglish, Strict: true}))
+
+	assert.NotEqual(t, id, calendarIdentity("course-8009-2-", calOptions{}))
+	assert.NotEqual(t, id, calendarIdentity("course-8009-1-", calOptions{Subjects: []string{"28004_1"}}))
+	assert.NotEqual(t, id, calendarIdentity("course-8009-1-", calOptions{SkipDays: []time.Weekday{time.Saturday}}))
+}
+
+func Test_createCal_identity(t *testing.T) {
+	course := testCourse(8009)
+	cal, err := createCal(testTimetable(), &course, 1, calOptions{Curriculum: "A58-000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := calendarIdentity("course-8009-1-A58-000", calOptions{})
+
+	out := cal.Serialize()
+	assert.Equal(t, true, strings.Contains(out, "X-WR-RELCALID:"+id+"\r\n"))
+	assert.Equal(t, true, strings.Contains(out, "\r\nUID:"+id+"\r\n"))
+
+	// Setting it again doesn't duplicate the properties
+	setCalendarIdentity(cal, id)
+	assert.Equal(t, 1, strings.Count(cal.Serialize(), "\r\nUID:"+id))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// maxJsonFeedItems is the number of upcoming lessons in a JSON feed.
+const maxJsonFeedItems = 100
+
+// jsonFeed is a JSON Feed (https://www.jsonfeed.org/version/1.1/) of the
+// upcoming lessons of a course year.
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	HomePageUrl string         `json:"home_page_url,omitempty"`
+	FeedUrl     string         `json:"feed_url,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	Id            string         `json:"id"`
+	Url           string         `json:"url,omitempty"`
+	Title         string         `json:"title"`
+	ContentText   string         `json:"content_text"`
+	DatePublished string         `json:"date_published"`
+	Tags          []string       `json:"tags,omitempty"`
+	Lesson        jsonFeedLesson `json:"_lesson"`
+}
+
+// jsonFeedLesson is a JSON Feed extension with the details of the lesson.
+type jsonFeedLesson struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Teacher   string `json:"teacher,omitempty"`
+	Classroom string `json:"classroom,omitempty"`
+	Module    string `json:"module"`
+	Cfu       int    `json:"cfu,omitempty"`
+	// Type is the lesson type, see lessonType
+	Type string `json:"type"`
+}
+
+// newJsonFeed creates a JSON feed with the lessons of the timetable not yet
+// ended at now, sorted by start, named according to the ?titles= mode.
+func newJsonFeed(t timetable.Timetable, course *unibo_integ.Course, year int, titles string, now time.Time) jsonFeed {
+	upcoming := make(timetable.Timetable, 0, len(t))
+	for _, e := range t {
+		if e.End.After(now) {
+			upcoming = append(upcoming, e)
+		}
+	}
+	slices.SortFunc(upcoming, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+	if len(upcoming) > maxJsonFeedItems {
+		upcoming = upcoming[:maxJsonFeedItems]
+	}
+
+	items := make([]jsonFeedItem, 0, len(upcoming))
+	for _, e := range upcoming {
+		title, description := eventSummary(e, titles)
+		item := jsonFeedItem{
+			Id:            eventUid(e),
+			Title:         title,
+			ContentText:   description,
+			DatePublished: e.Start.Format(time.RFC3339),
+			Lesson: jsonFeedLesson{
+				Start:   e.Start.Format(time.RFC3339),
+				End:     e.End.Format(time.RFC3339),
+				Teacher: e.Teacher,
+				Module:  e.CodModulo,
+				Cfu:     e.Cfu,
+				Type:    lessonType(e),
+			},
+		}
+		if u, ok := teachingUrl(e); ok {
+			item.Url = u
+		}
+		if len(e.Classrooms) > 0 {
+			item.Lesson.Classroom = e.Classrooms[0].ResourceDesc
+		}
+		if code := subjectCode(e); code != "" {
+			item.Tags = append(item.Tags, code)
+		}
+		items = append(items, item)
+	}
+
+	return jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       fmt.Sprintf("%s - %d year", course.Descrizione, year),
+		Description: fmt.Sprintf("Prossime lezioni del %d anno del corso di %s", year, course.Descrizione),
+		HomePageUrl: course.Url,
+		Items:       items,
+	}
+}
+
+// buildJsonFeed renders the upcoming lessons of the timetable as a JSON feed.
+func buildJsonFeed(ctx *gin.Context, t timetable.Timetable, course *unibo_integ.Course, year int, opts calOptions) ([]byte, bool) {
+	t = applyCalOptions(t, opts)
+
+	data, err := json.Marshal(newJsonFeed(t, course, year, opts.Titles, time.Now()))
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create feed")
+		return nil, false
+	}
+	return data, true
+}
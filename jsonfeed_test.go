@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_newJsonFeed(t *testing.T) {
+	course := testCourse(8009)
+	tt := testTimetable()
+
+	// Only the second lesson is not ended yet
+	now := tt[0].End.Add(time.Minute)
+	feed := newJsonFeed(tt, &course, 1, titlesFull, now)
+
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assert.Equal(t, 1, len(feed.Items))
+	assert.Equal(t, "ANALISI MATEMATICA", feed.Items[0].Title)
+	assert.Equal(t, eventUid(tt[1]), feed.Items[0].Id)
+	assert.Equal(t, "2024-10-01T11:00:00+02:00", feed.Items[0].Lesson.Start)
+}
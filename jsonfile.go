@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// saveJsonFile writes v as JSON to file, creating its folder. The file is
+// replaced atomically, so readers never see a partial write.
+func saveJsonFile(file string, v any, perm os.FileMode) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to encode %s: %w", file, err)
+	}
+
+	err = os.MkdirAll(path.Dir(file), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("unable to create folder of %s: %w", file, err)
+	}
+
+	tmp := file + ".tmp"
+	err = os.WriteFile(tmp, data, perm)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", file, err)
+	}
+	return os.Rename(tmp, file)
+}
+
+// loadJsonFile decodes the JSON file in v. A missing file is not an error,
+// and leaves v untouched.
+func loadJsonFile(file string, v any) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read %s: %w", file, err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("unable to decode %s: %w", file, err)
+	}
+	return nil
+}
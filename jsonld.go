@@ -0,0 +1,162 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The course pages and the week grids describe the course and its lessons
+// as schema.org structured data (JSON-LD), for the search engines and the
+// assistants. The templates encode it as JSON inside the
+// <script type="application/ld+json"> tags.
+
+const (
+	// jsonLdDays is how many days of lessons are in the course pages
+	jsonLdDays = 7
+	// maxJsonLdLessons limits the lessons in the course pages
+	maxJsonLdLessons = 50
+)
+
+// uniboJsonLd is the university, provider of the courses and organizer of
+// the lessons.
+var uniboJsonLd = gin.H{
+	"@type": "CollegeOrUniversity",
+	"name":  "Alma Mater Studiorum - Università di Bologna",
+	"url":   "https://www.unibo.it",
+}
+
+// courseJsonLd returns the schema.org Course of the course page at pageUrl.
+func courseJsonLd(course *unibo_integ.Course, pageUrl string) gin.H {
+	description := course.Tipologia + " in " + course.Descrizione
+	if course.Campus != "" {
+		description += " - " + course.Campus
+	}
+
+	data := gin.H{
+		"@context":    "https://schema.org",
+		"@type":       "Course",
+		"name":        course.Descrizione,
+		"description": description,
+		"courseCode":  fmt.Sprint(course.Codice),
+		"url":         pageUrl,
+		"inLanguage":  "it",
+		"provider":    uniboJsonLd,
+	}
+	if course.Url != "" {
+		data["sameAs"] = course.Url
+	}
+	if course.DurataAnni > 0 {
+		data["timeToComplete"] = fmt.Sprintf("P%dY", course.DurataAnni)
+	}
+	if course.Campus != "" {
+		data["hasCourseInstance"] = gin.H{
+			"@type":      "CourseInstance",
+			"courseMode": "onsite",
+			"location":   gin.H{"@type": "Place", "name": course.Campus},
+		}
+	}
+	return data
+}
+
+// lessonJsonLd returns the schema.org Event of a lesson of the course.
+func lessonJsonLd(e timetable.Event, course *unibo_integ.Course) gin.H {
+	location := gin.H{"@type": "Place", "name": course.Campus}
+	if len(e.Classrooms) > 0 && e.Classrooms[0].ResourceDesc != "" {
+		room := unibo.NewRoom(e.Classrooms[0])
+		location["name"] = room.Name
+		if room.Address != "" {
+			location["address"] = room.Address
+		} else if course.Campus != "" {
+			location["address"] = course.Campus
+		}
+	}
+
+	data := gin.H{
+		"@context":            "https://schema.org",
+		"@type":               "Event",
+		"name":                e.Title,
+		"startDate":           e.Start.Format(time.RFC3339),
+		"endDate":             e.End.Format(time.RFC3339),
+		"eventStatus":         "https://schema.org/EventScheduled",
+		"eventAttendanceMode": "https://schema.org/OfflineEventAttendanceMode",
+		"location":            location,
+		"organizer":           uniboJsonLd,
+	}
+	if e.Teacher != "" {
+		data["performer"] = gin.H{"@type": "Person", "name": e.Teacher}
+	}
+	return data
+}
+
+// lessonsJsonLd returns the Events of the lessons.
+func lessonsJsonLd(lessons []timetable.Event, course *unibo_integ.Course) []gin.H {
+	data := make([]gin.H, len(lessons))
+	for i, e := range lessons {
+		data[i] = lessonJsonLd(e, course)
+	}
+	return data
+}
+
+// lessonsBetween returns the lessons of t starting between from and to.
+func lessonsBetween(t timetable.Timetable, from time.Time, to time.Time) []timetable.Event {
+	var lessons []timetable.Event
+	for _, e := range t {
+		if !e.Start.Before(from) && e.Start.Before(to) {
+			lessons = append(lessons, e)
+		}
+	}
+	return lessons
+}
+
+// upcomingLessons returns the lessons of the course between from and to, in
+// the timetables of the curricula of every year, sorted by start and
+// without the ones shared by more curricula. At most max are returned.
+func upcomingLessons(course *unibo_integ.Course, curricula map[int]curriculum.Curricula, from time.Time, to time.Time, max int) []timetable.Event {
+	type lessonKey struct {
+		title string
+		start time.Time
+		room  string
+	}
+	seen := make(map[lessonKey]bool)
+
+	var lessons []timetable.Event
+	for year, cs := range curricula {
+		for _, curr := range cs {
+			t, err := getTimetable(course, year, curr)
+			if err != nil {
+				continue
+			}
+			for _, e := range lessonsBetween(t, from, to) {
+				key := lessonKey{e.Title, e.Start.Time, eventRoom(e)}
+				if !seen[key] {
+					seen[key] = true
+					lessons = append(lessons, e)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(lessons, func(a, b timetable.Event) int {
+		return cmp.Or(a.Start.Compare(b.Start.Time), cmp.Compare(a.Title, b.Title))
+	})
+	if len(lessons) > max {
+		lessons = lessons[:max]
+	}
+	return lessons
+}
+
+// coursePageJsonLd returns the structured data of the course page: the
+// course and its lessons of the next days.
+func coursePageJsonLd(course *unibo_integ.Course, curricula map[int]curriculum.Curricula, pageUrl string, now time.Time) []gin.H {
+	lessons := upcomingLessons(course, curricula, now, now.AddDate(0, 0, jsonLdDays), maxJsonLdLessons)
+	return append([]gin.H{courseJsonLd(course, pageUrl)}, lessonsJsonLd(lessons, course)...)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func testLesson(title string, start time.Time) timetable.Event {
+	var e timetable.Event
+	e.Title = title
+	e.Teacher = "Mario Rossi"
+	e.Start.Time = start
+	e.End.Time = start.Add(time.Hour * 2)
+	return e
+}
+
+func Test_courseJsonLd(t *testing.T) {
+	course := testCourse(9254)
+	data := courseJsonLd(&course, "https://unibocalendar.it/courses/9254")
+
+	assert.Equal(t, "Course", data["@type"])
+	assert.Equal(t, "INGEGNERIA INFORMATICA", data["name"])
+	assert.Equal(t, "9254", data["courseCode"])
+	assert.Equal(t, "P2Y", data["timeToComplete"])
+	assert.Equal(t, "Laurea Magistrale in INGEGNERIA INFORMATICA - Cesena", data["description"])
+}
+
+func Test_lessonJsonLd(t *testing.T) {
+	course := testCourse(9254)
+	start := time.Date(2024, 10, 14, 9, 0, 0, 0, time.UTC)
+	data := lessonJsonLd(testLesson("Reti", start), &course)
+
+	assert.Equal(t, "Event", data["@type"])
+	assert.Equal(t, "Reti", data["name"])
+	assert.Equal(t, "2024-10-14T09:00:00Z", data["startDate"])
+	assert.Equal(t, "2024-10-14T11:00:00Z", data["endDate"])
+	assert.Equal(t, gin.H{"@type": "Place", "name": "Cesena"}, data["location"])
+	assert.Equal(t, gin.H{"@type": "Person", "name": "Mario Rossi"}, data["performer"])
+}
+
+func Test_lessonsBetween(t *testing.T) {
+	monday := time.Date(2024, 10, 14, 0, 0, 0, 0, time.UTC)
+	tt := timetable.Timetable{
+		testLesson("Prima", monday.AddDate(0, 0, -1)),
+		testLesson("Dentro", monday.Add(time.Hour*9)),
+		testLesson("Dopo", monday.AddDate(0, 0, 7)),
+	}
+
+	lessons := lessonsBetween(tt, monday, monday.AddDate(0, 0, 7))
+	assert.Equal(t, 1, len(lessons))
+	assert.Equal(t, "Dentro", lessons[0].Title)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+const (
+	// langEnglish is the value of ?lang= for the English pages and names of
+	// the teachings.
+	langEnglish = "en"
+	// langItalian is the value of ?lang= for the Italian ones, the default.
+	langItalian = "it"
+)
+
+// The language chosen with ?lang= on a page is remembered in a cookie, for
+// the next pages.
+const (
+	langCookie = "lang"
+	langTTL    = time.Hour * 24 * 400
+)
+
+// parseLang returns the supported language of a language tag (e.g. "en-GB"
+// is English), empty if unsupported.
+func parseLang(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	switch primary = strings.ToLower(primary); primary {
+	case langEnglish, langItalian:
+		return primary
+	}
+	return ""
+}
+
+// acceptLanguage returns the supported language preferred by the
+// Accept-Language header (e.g. "en-US,en;q=0.9,it;q=0.8"), empty if none is
+// accepted.
+func acceptLanguage(header string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang := parseLang(tag)
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if raw, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, weighted{lang, q})
+		}
+	}
+	if len(accepted) == 0 {
+		return ""
+	}
+
+	// Stable, so the first one wins the ties
+	slices.SortStableFunc(accepted, func(a, b weighted) int {
+		return cmp.Compare(b.q, a.q)
+	})
+	return accepted[0].lang
+}
+
+// requestLang returns the language of the request, empty for Italian: the
+// one asked with ?lang=, otherwise the one remembered in the cookie,
+// otherwise the one preferred by the Accept-Language header.
+func requestLang(ctx *gin.Context) string {
+	lang := parseLang(ctx.Query("lang"))
+	if lang == "" {
+		// The response depends on the headers, not only on the URL
+		ctx.Writer.Header().Add("Vary", "Accept-Language, Cookie")
+
+		cookie, _ := ctx.Cookie(langCookie)
+		lang = cmp.Or(parseLang(cookie), acceptLanguage(ctx.GetHeader("Accept-Language")))
+	}
+
+	if lang == langEnglish {
+		return langEnglish
+	}
+	return ""
+}
+
+// rememberLang saves in the cookie the language asked with ?lang=, if any.
+func rememberLang(ctx *gin.Context) {
+	lang := parseLang(ctx.Query("lang"))
+	if lang == "" {
+		return
+	}
+	scheme, _ := requestOrigin(ctx)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(langCookie, lang, int(langTTL.Seconds()), appUrl("/"), "", scheme == "https", true)
+}
+
+// titleIndex contains the English names of the teachings, by subject code.
+//
+// The timetables of the international courses, published on the English
+// version of the course websites, use the English names. The same
+// teachings are often shared with Italian courses, whose timetables only
+// have the Italian names.
+type titleIndex struct {
+	mutex  sync.RWMutex
+	titles map[string]string
+}
+
+var englishTitles = &titleIndex{titles: map[string]string{}}
+
+// isEnglishWebsite reports whether the timetable of the course comes from an
+// English course website (e.g. https://corsi.unibo.it/2cycle/...).
+func isEnglishWebsite(course *unibo_integ.Course) bool {
+	id, found := unibo_integ.Unibo.CachedWebsiteId(course.Codice)
+	return found && strings.Contains(id.Tipologia, "cycle")
+}
+
+// index saves the names of the teachings of the timetable.
+func (ti *titleIndex) index(t timetable.Timetable) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	for _, e := range t {
+		if code := subjectCode(e); code != "" && e.Title != "" {
+			ti.titles[code] = e.Title
+		}
+	}
+}
+
+func (ti *titleIndex) title(e timetable.Event) (string, bool) {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+
+	title, found := ti.titles[subjectCode(e)]
+	return title, found
+}
+
+// localizeEvent returns the event with the title in the given language, if
+// known.
+func localizeEvent(e timetable.Event, lang string) timetable.Event {
+	if lang != langEnglish {
+		return e
+	}
+	if title, found := englishTitles.title(e); found {
+		e.Title = title
+	}
+	return e
+}
+
+// localizeTimetable returns a copy of the timetable with the titles in the
+// given language, if known.
+func localizeTimetable(t timetable.Timetable, lang string) timetable.Timetable {
+	if lang != langEnglish {
+		return t
+	}
+
+	res := make(timetable.Timetable, 0, len(t))
+	for _, e := range t {
+		res = append(res, localizeEvent(e, lang))
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_localizeTimetable(t *testing.T) {
+	english := testTimetable()
+	english[0].Title = "ALGEBRA AND GEOMETRY"
+	englishTitles.index(english[:1])
+
+	tt := testTimetable()
+	localized := localizeTimetable(tt, langEnglish)
+	assert.Equal(t, "ALGEBRA AND GEOMETRY", localized[0].Title)
+	assert.Equal(t, "ANALISI MATEMATICA", localized[1].Title)
+	// The cached timetable is not modified
+	assert.Equal(t, "ALGEBRA", tt[0].Title)
+
+	assert.Equal(t, "ALGEBRA", localizeTimetable(tt, "")[0].Title)
+
+	cal, err := createEventsCal(tt, calOptions{Lang: langEnglish})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, strings.Contains(cal.Serialize(), "SUMMARY:ALGEBRA AND GEOMETRY"))
+}
+
+func Test_acceptLanguage(t *testing.T) {
+	assert.Equal(t, "en", acceptLanguage("en-US,en;q=0.9,it;q=0.8"))
+	assert.Equal(t, "it", acceptLanguage("it-IT,it;q=0.9,en-US;q=0.8"))
+	assert.Equal(t, "it", acceptLanguage("de-DE, en;q=0.5, it;q=0.7"))
+	assert.Equal(t, "en", acceptLanguage("fr, en-GB;q=0.8"))
+	assert.Equal(t, "it", acceptLanguage("en;q=0, it"))
+	assert.Equal(t, "", acceptLanguage("de-DE,fr;q=0.9"))
+	assert.Equal(t, "", acceptLanguage(""))
+}
+
+func Test_requestLang(t *testing.T) {
+	lang := func(target string, acceptLanguage string, cookie string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Request.Header.Set("Accept-Language", acceptLanguage)
+		if cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: langCookie, Value: cookie})
+		}
+		return requestLang(c)
+	}
+
+	assert.Equal(t, "en", lang("/", "en-US", ""))
+	assert.Equal(t, "", lang("/", "it-IT", ""))
+	assert.Equal(t, "", lang("/", "", ""))
+	// The cookie wins over the header, the parameter over both
+	assert.Equal(t, "", lang("/", "en-US", "it"))
+	assert.Equal(t, "en", lang("/", "it-IT", "en"))
+	assert.Equal(t, "", lang("/?lang=it", "en-US", "en"))
+	assert.Equal(t, "en", lang("/?lang=EN", "it-IT", "it"))
+	assert.Equal(t, "en", lang("/?lang=xx", "en-US", ""))
+}
+
+func Test_englishPages(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-GB,en;q=0.9")
+	r.ServeHTTP(w, req)
+	body := w.Body.String()
+	assert.Equal(t, true, strings.Contains(body, `<html lang="en">`))
+	assert.Equal(t, true, strings.Contains(body, "Service status"))
+	assert.Equal(t, true, strings.Contains(body, `href="?lang=it"`))
+	assert.Equal(t, true, strings.Contains(w.Header().Get("Vary"), "Accept-Language"))
+
+	// ?lang= is remembered for the next pages
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/?lang=it", nil)
+	req.Header.Set("Accept-Language", "en-GB,en;q=0.9")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `<html lang="it">`))
+	assert.Equal(t, true, strings.Contains(w.Header().Get("Set-Cookie"), "lang=it"))
+}
+
+func Test_englishTexts(t *testing.T) {
+	// Every text translated by the templates has a translation
+	texts := regexp.MustCompile("tr \\$?\\.Lang (\"[^\"]+\"|`[^`]+`)")
+	files, err := fs.Glob(templatesFS, "templates/*/*.gohtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ := fs.Glob(templatesFS, "templates/*.gohtml")
+	for _, file := range append(files, pages...) {
+		data, err := fs.ReadFile(templatesFS, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range texts.FindAllStringSubmatch(string(data), -1) {
+			text := strings.Trim(match[1], "\"`")
+			if _, found := englishTexts[text]; !found {
+				t.Errorf("%s: no translation of %q", file, text)
+			}
+		}
+	}
+}
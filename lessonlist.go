@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// lessonRow is a lesson of the json and csv formats, which list every lesson
+// of the timetable, for spreadsheets and scripts.
+type lessonRow struct {
+	Title     string `json:"title"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Teacher   string `json:"teacher,omitempty"`
+	Classroom string `json:"classroom,omitempty"`
+	Module    string `json:"module"`
+	Subject   string `json:"subject,omitempty"`
+	Cfu       int    `json:"cfu,omitempty"`
+	// Type is the lesson type, see lessonType
+	Type string `json:"type"`
+	Url  string `json:"url,omitempty"`
+}
+
+// lessonCsvHeader are the columns of the csv format, in the order of
+// lessonRow.csv.
+var lessonCsvHeader = []string{"title", "start", "end", "teacher", "classroom", "module", "subject", "cfu", "type", "url"}
+
+func (r lessonRow) csv() []string {
+	return []string{r.Title, r.Start, r.End, r.Teacher, r.Classroom, r.Module, r.Subject, strconv.Itoa(r.Cfu), r.Type, r.Url}
+}
+
+// newLessonRows returns the lessons of the timetable sorted by start, named
+// according to the ?titles= mode.
+func newLessonRows(t timetable.Timetable, titles string) []lessonRow {
+	sorted := slices.Clone(t)
+	slices.SortStableFunc(sorted, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+
+	rows := make([]lessonRow, 0, len(sorted))
+	for _, e := range sorted {
+		title, _ := eventSummary(e, titles)
+		row := lessonRow{
+			Title:     title,
+			Start:     e.Start.Format(time.RFC3339),
+			End:       e.End.Format(time.RFC3339),
+			Teacher:   e.Teacher,
+			Classroom: eventRoom(e),
+			Module:    e.CodModulo,
+			Subject:   subjectCode(e),
+			Cfu:       e.Cfu,
+			Type:      lessonType(e),
+		}
+		if u, ok := teachingUrl(e); ok {
+			row.Url = u
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// buildLessonJson renders the lessons of the timetable as a JSON array.
+func buildLessonJson(ctx *gin.Context, t timetable.Timetable, _ *unibo_integ.Course, _ int, opts calOptions) ([]byte, bool) {
+	data, err := json.Marshal(newLessonRows(applyCalOptions(t, opts), opts.Titles))
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create the lesson list")
+		return nil, false
+	}
+	return data, true
+}
+
+// buildLessonCsv renders the lessons of the timetable as CSV, with a header
+// row.
+func buildLessonCsv(ctx *gin.Context, t timetable.Timetable, _ *unibo_integ.Course, _ int, opts calOptions) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(lessonCsvHeader)
+	for _, row := range newLessonRows(applyCalOptions(t, opts), opts.Titles) {
+		_ = w.Write(row.csv())
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create the lesson list")
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_newLessonRows(t *testing.T) {
+	tt := testTimetable()
+	rows := newLessonRows(timetable.Timetable{tt[1], tt[0]}, titlesFull)
+
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "ALGEBRA", rows[0].Title)
+	assert.Equal(t, "2024-10-01T09:00:00+02:00", rows[0].Start)
+	assert.Equal(t, "AULA 1", rows[0].Classroom)
+	assert.Equal(t, "00001", rows[0].Subject)
+	assert.Equal(t, "lecture", rows[0].Type)
+	assert.Equal(t, "ANALISI MATEMATICA", rows[1].Title)
+}
+
+func Test_buildLessonCsv(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	course := testCourse(8009)
+	data, ok := buildLessonCsv(ctx, testTimetable(), &course, 1, calOptions{Titles: titlesFull})
+	assert.Equal(t, true, ok)
+
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, lessonCsvHeader, records[0])
+	assert.Equal(t, "ALGEBRA", records[1][0])
+	assert.Equal(t, "6", records[1][7])
+}
+
+func Test_requestFormat(t *testing.T) {
+	format := func(target string, accept string) string {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			ctx.Request.Header.Set("Accept", accept)
+		}
+		return requestFormat(ctx)
+	}
+
+	assert.Equal(t, "ics", format("/cal/8009/1", ""))
+	assert.Equal(t, "ics", format("/cal/8009/1", "*/*"))
+	assert.Equal(t, "ics", format("/cal/8009/1", "text/calendar"))
+	assert.Equal(t, "json", format("/cal/8009/1", "application/json"))
+	assert.Equal(t, "csv", format("/cal/8009/1", "text/csv, */*;q=0.1"))
+	assert.Equal(t, "jsonfeed", format("/cal/8009/1", "application/feed+json"))
+	// Browsers accept anything, after the HTML
+	assert.Equal(t, "ics", format("/cal/8009/1", "text/html,application/xhtml+xml,*/*;q=0.8"))
+	// Nothing acceptable is offered
+	assert.Equal(t, "ics", format("/cal/8009/1", "image/png"))
+	// ?format= wins over the header
+	assert.Equal(t, "md", format("/cal/8009/1?format=md", "application/json"))
+}
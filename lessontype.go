@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// Lesson types, see lessonType.
+const (
+	lessonLecture  = "lecture"
+	lessonLab      = "lab"
+	lessonExercise = "exercise"
+	lessonSeminar  = "seminar"
+)
+
+// lessonTypeWords are the words in the titles of the events identifying the
+// type of the lesson, since the upstream does not have a type field.
+var lessonTypeWords = []struct {
+	lessonType string
+	words      []string
+}{
+	{lessonLab, []string{"laborator", "lab.", "(lab)", " lab "}},
+	{lessonExercise, []string{"esercitazion", "exercise", "tutorat", "tutorial"}},
+	{lessonSeminar, []string{"seminar"}},
+}
+
+// lessonTypeAliases are the accepted names of the lesson types in ?types=.
+var lessonTypeAliases = map[string]string{
+	"lecture":       lessonLecture,
+	"lezione":       lessonLecture,
+	"lab":           lessonLab,
+	"laboratory":    lessonLab,
+	"laboratorio":   lessonLab,
+	"exercise":      lessonExercise,
+	"exercises":     lessonExercise,
+	"esercitazione": lessonExercise,
+	"seminar":       lessonSeminar,
+	"seminario":     lessonSeminar,
+}
+
+// lessonType classifies the event as a lecture, laboratory, exercise
+// session or seminar, from its title. Events without any hint are lectures.
+func lessonType(e timetable.Event) string {
+	title := " " + strings.ToLower(e.Title) + " "
+	for _, t := range lessonTypeWords {
+		for _, w := range t.words {
+			if strings.Contains(title, w) {
+				return t.lessonType
+			}
+		}
+	}
+	return lessonLecture
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_lessonType(t *testing.T) {
+	assert.Equal(t, lessonLecture, lessonType(timetable.Event{Title: "ALGEBRA"}))
+	assert.Equal(t, lessonLab, lessonType(timetable.Event{Title: "LABORATORIO DI PROGRAMMAZIONE"}))
+	assert.Equal(t, lessonLab, lessonType(timetable.Event{Title: "Physics Lab"}))
+	assert.Equal(t, lessonExercise, lessonType(timetable.Event{Title: "ANALISI MATEMATICA (Esercitazioni)"}))
+	assert.Equal(t, lessonSeminar, lessonType(timetable.Event{Title: "Seminari di cultura digitale"}))
+	// "lab" inside another word is not a laboratory
+	assert.Equal(t, lessonLecture, lessonType(timetable.Event{Title: "Syllabus"}))
+}
+
+func Test_filterTimetableByType(t *testing.T) {
+	tt := testTimetable()
+	tt[1].Title = "LABORATORIO DI ANALISI"
+
+	filtered := filterTimetable(nil, tt, calOptions{Types: []string{lessonLab}})
+	assert.Equal(t, 1, len(filtered))
+	assert.Equal(t, "LABORATORIO DI ANALISI", filtered[0].Title)
+
+	filtered = filterTimetable(nil, tt, calOptions{Types: []string{lessonLab}, Subjects: []string{"00001_1"}})
+	assert.Equal(t, 0, len(filtered))
+}
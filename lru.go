@@ -0,0 +1,151 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is an in-memory cache whose entries expire after a TTL, like
+// go-cache, but bounded in number of entries and total size: when a limit is
+// exceeded the least recently used entries are evicted, so requesting every
+// course and year can't make the process run out of memory.
+type lruCache[V any] struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items map[string]*list.Element
+	// order has the most recently used entries at the front
+	order *list.List
+	bytes int
+
+	// maxEntries and maxBytes are the limits, ignored if not positive
+	maxEntries int
+	maxBytes   int
+	// size returns the size of a value in bytes, nil if maxBytes is unused
+	size func(V) int
+
+	evictions int
+}
+
+type lruEntry[V any] struct {
+	key     string
+	value   V
+	size    int
+	expires time.Time
+}
+
+// newLruCache creates a cache whose entries expire after ttl. Expired entries
+// are removed every cleanupInterval.
+func newLruCache[V any](ttl time.Duration, cleanupInterval time.Duration, maxEntries int, maxBytes int, size func(V) int) *lruCache[V] {
+	c := &lruCache[V]{
+		ttl:        ttl,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		size:       size,
+	}
+	if cleanupInterval > 0 {
+		go func() {
+			for range time.Tick(cleanupInterval) {
+				c.DeleteExpired()
+			}
+		}()
+	}
+	return c
+}
+
+// Get returns the value of the key, if present and not expired.
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, found := c.items[key]
+	if !found {
+		return zero, false
+	}
+
+	entry := el.Value.(*lruEntry[V])
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set saves the value, evicting the least recently used entries if the
+// cache gets too big. A value bigger than maxBytes is not cached.
+func (c *lruCache[V]) Set(key string, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL is like Set, but the entry expires after ttl instead of the
+// TTL of the cache.
+func (c *lruCache[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := 0
+	if c.size != nil {
+		size = c.size(value)
+	}
+
+	if el, found := c.items[key]; found {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	entry := &lruEntry[V]{key: key, value: value, size: size, expires: time.Now().Add(ttl)}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += size
+
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// DeleteExpired removes the expired entries.
+func (c *lruCache[V]) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, el := range c.items {
+		if now.After(el.Value.(*lruEntry[V]).expires) {
+			c.remove(el)
+		}
+	}
+}
+
+// Purge removes every entry, and returns how many there were.
+func (c *lruCache[V]) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+	return n
+}
+
+// Stats returns the number of entries, their total size and how many
+// entries were evicted to respect the limits.
+func (c *lruCache[V]) Stats() (entries int, bytes int, evictions int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len(), c.bytes, c.evictions
+}
+
+// remove deletes the entry. The mutex must be held.
+func (c *lruCache[V]) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry[V])
+	c.order.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
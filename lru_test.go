@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_lruCacheMaxEntries(t *testing.T) {
+	c := newLruCache[int](time.Minute, 0, 2, 0, nil)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	_, found := c.Get("b")
+	assert.Equal(t, false, found)
+	v, found := c.Get("a")
+	assert.Equal(t, true, found)
+	assert.Equal(t, 1, v)
+
+	entries, _, evictions := c.Stats()
+	assert.Equal(t, 2, entries)
+	assert.Equal(t, 1, evictions)
+}
+
+func Test_lruCacheMaxBytes(t *testing.T) {
+	c := newLruCache(time.Minute, 0, 0, 10, func(b []byte) int { return len(b) })
+	c.Set("a", make([]byte, 4))
+	c.Set("b", make([]byte, 4))
+	c.Set("c", make([]byte, 4))
+	c.Set("huge", make([]byte, 11))
+
+	_, found := c.Get("a")
+	assert.Equal(t, false, found)
+	_, found = c.Get("huge")
+	assert.Equal(t, false, found)
+
+	entries, bytes, _ := c.Stats()
+	assert.Equal(t, 2, entries)
+	assert.Equal(t, 8, bytes)
+}
+
+func Test_lruCacheExpiration(t *testing.T) {
+	c := newLruCache[int](-time.Second, 0, 0, 0, nil)
+	c.Set("a", 1)
+
+	_, found := c.Get("a")
+	assert.Equal(t, false, found)
+}
@@ -3,21 +3,21 @@ package main
 import (
 	"bytes"
 	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"path"
 	"slices"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/csunibo/unibo-go/curriculum"
 	"github.com/csunibo/unibo-go/timetable"
 
 	ics "github.com/arran4/golang-ical"
-	"github.com/gin-contrib/multitemplate"
 	limits "github.com/gin-contrib/size"
 	"github.com/gin-gonic/gin"
 	"github.com/lf4096/gin-compress"
@@ -30,282 +30,587 @@ import (
 
 //go:generate pnpm run css:build
 
-const templateDir = "./templates"
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-func createMyRender() multitemplate.Renderer {
-	funcMap := template.FuncMap{"anniRange": func(end int) []int {
-		r := make([]int, 0, end)
-		for i := 1; i <= end; i++ {
-			r = append(r, i)
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		err := runBench(os.Args[2:])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Benchmark failed")
 		}
-		return r
-	}}
-
-	r := multitemplate.NewRenderer()
-
-	r.AddFromFiles("base", path.Join(templateDir, "base.gohtml"))
-	r.AddFromFilesFuncs("index", funcMap,
-		path.Join(templateDir, "index.gohtml"), path.Join(templateDir, "base.gohtml"),
-	)
-	r.AddFromFilesFuncs("courses", funcMap,
-		path.Join(templateDir, "courses.gohtml"), path.Join(templateDir, "base.gohtml"),
-	)
-	r.AddFromFilesFuncs("course", funcMap,
-		path.Join(templateDir, "course.gohtml"), path.Join(templateDir, "base.gohtml"),
-	)
-	return r
-}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		err := runAdmin(os.Args[2:])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Admin command failed")
+		}
+		return
+	}
 
-func main() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	unibo_integ.InstrumentDefaultClient()
 
-	downloadOpenDataIfNewer()
+	flag.BoolVar(&config.Mock, "mock", config.Mock, "serve the bundled fixtures instead of the Unibo data")
+	// Already read by loadConfig, see dataDirArg
+	flag.StringVar(&config.DataDir, "data-dir", config.DataDir, "folder of the data files")
+	flag.Parse()
+	logBuildInfo()
 
-	courses, err := openData()
+	data, err := loadCourses()
 	if err != nil {
-		log.Fatal().Err(err).Msg("Unable to open open data file")
+		log.Fatal().Err(err).Msg("Unable to load the courses")
 	}
+	courses := unibo_integ.NewCourses(data)
+
+	loadAcademicCalendar()
+	go uniboEvents.Watch(config.EventsFile, time.Minute)
 
+	go featureFlags.Watch(config.FeatureFlagsFile, time.Second*30)
+	if !config.Mock && config.OpenDataRefreshInterval > 0 {
+		go refreshOpenData(courses, config.OpenDataRefreshInterval)
+	}
+	go fillCurriculaCache(courses)
 	go fillSubjectsCache(courses)
+	if googleEnabled() {
+		go googleSubs.Run(courses, config.GoogleSyncInterval)
+	}
+	if digestEnabled() {
+		go digestSubs.Run(courses)
+	}
+	if matrixEnabled() {
+		go matrixRooms.Run(courses)
+	}
+	go webhooks.Run(courses)
+
+	err = calStats.load()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to load calendar stats")
+	}
+	go calStats.Run(time.Minute)
+	if config.BackupInterval > 0 {
+		go runBackups(config.BackupInterval)
+	}
 
+	logAdminAuth()
 	r := setupRouter(courses)
 
-	err = r.Run()
+	if config.WarmupCalendars > 0 {
+		warmUp(r, config.WarmupCalendars, config.WarmupTimeout)
+	}
+
+	err = runServer(r)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Unable to start server")
 	}
 }
 
-func setupRouter(courses unibo_integ.CoursesMap) *gin.Engine {
-	r := gin.Default()
+// loadCourses returns the courses of the open data, downloading it if
+// there is a newer version, or the fixtures in mock mode.
+func loadCourses() (*unibo_integ.CoursesMap, error) {
+	if config.Mock {
+		log.Info().Msg("Mock mode: serving the bundled fixtures")
+		return mockCourses()
+	}
+
+	if _, err := downloadOpenDataIfNewer(); err != nil {
+		return nil, err
+	}
+	return openData()
+}
+
+func setupRouter(courses *unibo_integ.Courses) *gin.Engine {
+	r := gin.New()
+	// Also used by ClientIP, which by default trusts every X-Forwarded-For
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Warn().Err(err).Msg("invalid trusted proxies")
+	}
+	r.Use(gin.Logger(), gin.CustomRecovery(recovered))
+	r.NoRoute(notFound)
 	r.Use(compress.Compress())
 	// Limit payload to 10 MB. This fixes zip bombs.
 	r.Use(limits.RequestSizeLimiter(10 * 1024 * 1024))
 	r.HTMLRender = createMyRender()
 
 	r.Static("/static", "./static")
-
-	r.GET("/", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index", gin.H{})
-	})
-
-	coursesList := courses.ToList()
-	slices.SortFunc(coursesList, func(a, b unibo_integ.Course) int {
-		return b.Codice - a.Codice
-	})
-	r.GET("/courses", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "courses", gin.H{
-			"courses": coursesList,
+	r.GET("/manifest.webmanifest", manifestHandler)
+	r.GET("/icon.svg", iconHandler)
+	r.GET("/sw.js", serviceWorkerHandler)
+
+	r.GET("/", indexPage(courses))
+	r.GET("/courses", coursesPage(courses))
+	r.GET("/favorites", favoritesPage(courses))
+	r.POST("/favorites/:id", setFavorite(courses))
+
+	r.GET("/schools", func(c *gin.Context) {
+		renderHTML(c, http.StatusOK, "schools", gin.H{
+			"schools": groupBySchool(courses.Load().ToList()),
 		})
 	})
+	r.GET("/schools/:id", schoolPage(courses))
+
+	r.GET("/campus/:name", campusPage(courses))
 
 	r.GET("/courses/:id", coursePage(courses))
 
-	r.GET("/cal/:id/:anno", getCoursesCal(&courses))
+	apiKeys, err := loadApiKeys(config.ApiKeysFile)
+	if err != nil {
+		log.Err(err).Msg("Unable to load API keys, only anonymous access is allowed")
+		apiKeys = map[string]*ApiKey{}
+	}
+	limiter := rateLimit(newRateLimiter(config.RateLimitWindow), apiKeys)
+
+	// Rendering the images fetches the timetables
+	r.GET("/og/:file", limiter, ogImageHandler(courses))
+	r.GET("/cal/custom", cors(), limiter, getCustomCal(courses))
+	r.GET("/cal/events", cors(), limiter, getUniboEventsCal)
+	r.GET("/cal/:id/:anno", cors(), limiter, getCoursesCal(courses))
+	r.OPTIONS("/cal/:id/:anno", cors(), preflight)
+	// Resolving an URL can scrape the websites of the courses
+	r.GET("/resolve", limiter, resolveTimetableUrl(courses))
+	r.GET("/status", statusPage)
+	r.GET("/version", versionHandler)
+	r.GET("/builder", timetableBuilder(courses))
+	r.GET("/teachers", teachersPage(courses))
+	r.GET("/compare", comparePage(courses))
+	r.GET("/conflicts", externalConflictsPage(courses))
+	r.POST("/conflicts", limiter, externalConflictsPage(courses))
+	setupFragments(r, courses)
+
+	setupApiV1(r, courses, cors(), limiter)
+	setupCalDav(r, courses, limiter)
+	if googleEnabled() {
+		setupGoogle(r, courses)
+	}
+	if digestEnabled() {
+		setupDigest(r, courses, limiter)
+	}
+	if webPushEnabled() {
+		setupWebPush(r, courses, limiter)
+	}
+
+	setupMe(r, limiter)
+	setupAdmin(r, courses)
 	return r
 }
 
-func coursePage(courses unibo_integ.CoursesMap) func(c *gin.Context) {
+func coursePage(courses *unibo_integ.Courses) func(c *gin.Context) {
 	return func(ctx *gin.Context) {
 		courseId := ctx.Param("id")
 		if courseId == "" {
-			ctx.String(http.StatusBadRequest, "Invalid course id")
+			errorPage(ctx, http.StatusBadRequest, "Codice del corso non valido")
 			return
 		}
 
 		courseIdInt, err := strconv.Atoi(courseId)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "Invalid course id")
+			errorPage(ctx, http.StatusBadRequest, "Codice del corso non valido")
 			return
 		}
 
-		course, found := courses.FindById(courseIdInt)
+		course, found := courses.Load().FindById(courseIdInt)
 		if !found {
-			ctx.String(http.StatusNotFound, "Course not found")
+			errorPage(ctx, http.StatusNotFound, "Corso non trovato")
 			return
 		}
 
-		curricula, err := course.GetAllCurricula()
+		curricula, err := getAllCurricula(course)
 		if err != nil {
 			_ = ctx.Error(fmt.Errorf("unable to retrieve curricula: %w", err))
 			curricula = nil
 		}
 
+		rememberCourse(ctx, course.Codice)
+		pageUrl := absoluteUrl(ctx, fmt.Sprintf("/courses/%d", course.Codice))
+
 		m, err := getSubjectsMapFromCourseAndCurricula(course, curricula)
 		if err != nil {
 			_ = ctx.Error(fmt.Errorf("unable to retrieve subjects: %w", err))
 		}
 
-		ctx.HTML(http.StatusOK, "course", gin.H{
+		renderHTML(ctx, http.StatusOK, "course", gin.H{
 			"Course":    course,
 			"Curricula": curricula,
 			"Teachings": m,
+			"Favorite":  isFavorite(ctx, course.Codice),
+			// OpenGraph metadata, for the previews of the shared links
+			"PageUrl": pageUrl,
+			"OgImage": absoluteUrl(ctx, ogImagePath(course.Codice)),
+			// Structured data for the search engines, see jsonld.go
+			"JsonLd": coursePageJsonLd(course, curricula, pageUrl, time.Now()),
+			// GoogleSync enables the link to sync the calendar on Google Calendar
+			"GoogleSync": googleEnabled(),
+			// Digest enables the form to subscribe to the daily digest email
+			"Digest": digestEnabled(),
+			// WebPush enables the button to get notified of the schedule changes
+			"WebPush": webPushEnabled(),
 		})
 	}
 }
 
-var calcache = cache.New(time.Minute*10, time.Minute*30)
+// calFreshness is how long a cached feed is served without checking whether
+// the upstream timetable changed, adapted to the academic period, see
+// adaptiveTTL.
+const calFreshness = time.Minute * 10
+
+// cachedFeed is a rendered feed and the version of the data it was rendered
+// from, see feedVersion.
+type cachedFeed struct {
+	data    []byte
+	version string
+	// freshUntil is when the feed must be revalidated
+	freshUntil time.Time
+}
 
-func getCoursesCal(courses *unibo_integ.CoursesMap) func(c *gin.Context) {
-	return func(ctx *gin.Context) {
-		id := ctx.Param("id")
-		anno := ctx.Param("anno")
+func newCachedFeed(data []byte, version string) cachedFeed {
+	return cachedFeed{data: data, version: version, freshUntil: time.Now().Add(adaptiveTTL(calFreshness, time.Now()))}
+}
 
-		// Check if id is a number, otherwise return 400
-		annoInt, err := strconv.Atoi(anno)
-		if err != nil {
-			ctx.String(http.StatusBadRequest, "Invalid year")
+func (f cachedFeed) fresh() bool {
+	return time.Now().Before(f.freshUntil)
+}
+
+// calcache contains the rendered feeds, bounded by
+// [Config.CalCacheMaxEntries] and [Config.CalCacheMaxBytes]. Feeds are kept
+// after they stop being fresh, so that they can be reused if the upstream
+// timetable did not change.
+var calcache = newLruCache(time.Hour*24, time.Minute*30, config.CalCacheMaxEntries, config.CalCacheMaxBytes,
+	func(f cachedFeed) int { return len(f.data) })
+
+// feedVersion identifies the data a feed of the course is rendered from,
+// given the hash of the timetable. Options are part of the cache key, but the
+// combined feeds also depend on the academic calendar, which can be
+// reloaded.
+func feedVersion(course *unibo_integ.Course, timetableHash string, opts calOptions) string {
+	data, _ := json.Marshal(course)
+	version := fmt.Sprintf("%x-%s", sha1.Sum(data), timetableHash)
+	if opts.Combined {
+		version += "-" + academicCalendarVersion()
+	}
+	return version
+}
+
+// cacheHeader tells whether the calendar was served from calcache ("HIT"),
+// reused from calcache because the upstream timetable did not change
+// ("REVALIDATED") or generated by the request ("MISS").
+const cacheHeader = "X-Cache"
+
+func getCoursesCal(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		feed, perr := bindFeed(ctx, courses.Load())
+		if perr != nil {
+			// Probably a link opened in the browser, rather than a subscription
+			if perr.Param == "anno" && wantsHtml(ctx) {
+				yearNotFoundPage(ctx, feed.Course, ctx.Param("anno"))
+				return
+			}
+			writeParamError(ctx, perr)
 			return
 		}
+		course, annoInt := feed.Course, feed.Year
+		curr := curriculum.Curriculum{Value: feed.Curriculum}
+		if !isSelfRequest(ctx) {
+			calStats.hit(feedRef{Course: course.Codice, Year: annoInt, Curriculum: curr.Value})
+		}
 
-		// Check if id is a number, otherwise return 400
-		idInt, err := strconv.Atoi(id)
-		if err != nil {
-			ctx.String(http.StatusBadRequest, "Invalid id")
+		opts := parseCalOptions(ctx)
+		opts.Curriculum = curr.Value
+		format, perr := checkFormat(opts.Format)
+		if perr != nil {
+			writeParamError(ctx, perr)
 			return
 		}
 
-		// Check if course exists, otherwise return 404
-		course, found := courses.FindById(idInt)
-		if !found {
-			ctx.String(http.StatusNotFound, "Course not found")
+		// Serve the timetable as it looked at a past date, if requested
+		asOf := ctx.Query("as_of")
+		if asOf != "" {
+			asOfTime, err := time.Parse(snapshotDateLayout, asOf)
+			if err != nil {
+				writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "as_of must be a date in the YYYY-MM-DD format")
+				return
+			}
+
+			snapshot, found, err := loadSnapshot(course.Codice, annoInt, curr.Value, asOfTime)
+			if err != nil {
+				_ = ctx.Error(err)
+				writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to retrieve snapshot")
+				return
+			}
+			if !found {
+				writeProblem(ctx, http.StatusNotFound, codeNotFound, "No snapshot available for the given date")
+				return
+			}
+
+			data, ok := format.Render(ctx, snapshot, course, annoInt, opts)
+			if ok {
+				successFeed(ctx, format, data)
+			}
 			return
 		}
 
-		if annoInt <= 0 || annoInt > course.DurataAnni {
-			ctx.String(http.StatusBadRequest, "Invalid year")
+		cacheKey := fmt.Sprintf("%d-%d-%s-%s", course.Codice, annoInt, curr.Value, opts.key())
+		if cal, found := calcache.Get(cacheKey); found && cal.fresh() {
+			ctx.Header(cacheHeader, "HIT")
+			successFeed(ctx, format, cal.data)
 			return
 		}
 
-		curriculumId := ctx.Query("curr")
-		curr := curriculum.Curriculum{}
-		if curriculumId != "" {
-			curr.Value = curriculumId
+		// Only a few requests can generate the same feed at the same time, the
+		// others wait and then, most likely, find it in the cache.
+		release, err := acquireFeed(fmt.Sprintf("%d-%d", course.Codice, annoInt))
+		if err != nil {
+			overloaded(ctx)
+			return
 		}
+		defer release()
 
-		subjectIds := ctx.Query("subjects")
-		var subjects []string
-		if subjectIds != "" {
-			tmp := strings.Split(subjectIds, ",")
-			for i := range tmp {
-				if len(tmp[i]) != 0 {
-					subjects = append(subjects, tmp[i])
-				}
-			}
-			log.Debug().Strs("subjects", subjects).Msg("queried subjects")
+		cal, found := calcache.Get(cacheKey)
+		if found && cal.fresh() {
+			ctx.Header(cacheHeader, "HIT")
+			successFeed(ctx, format, cal.data)
+			return
 		}
 
-		slices.Sort(subjects)
-
-		cacheKey := fmt.Sprintf("%s-%s-%s-%s", id, anno, curr.Value, subjects)
-		if cal, found := calcache.Get(cacheKey); found {
-			successCalendar(ctx, cal.(*bytes.Buffer))
+		// Try to retrieve timetable, otherwise return 502
+		cached, err := getCachedTimetable(course, annoInt, curr)
+		if errors.Is(err, errOverloaded) {
+			overloaded(ctx)
+			return
+		} else if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable, "Unable to retrieve timetable")
 			return
 		}
 
-		// Try to retrieve timetable, otherwise return 500
-		courseTimetable, err := course.GetTimetable(annoInt, curr, nil)
-		if err != nil {
-			_ = ctx.Error(err)
-			ctx.String(http.StatusInternalServerError, "Unable to retrieve timetable")
+		if cached.stale {
+			// Not cached, so the feed is fresh again as soon as the upstream is
+			ctx.Header(staleHeader, "true")
+			opts.Stale = true
+			data, ok := format.Render(ctx, cached.timetable, course, annoInt, opts)
+			if ok {
+				successFeed(ctx, format, data)
+			}
 			return
 		}
 
-		cal, err := createCal(courseTimetable, course, annoInt, subjects)
-		if err != nil {
-			_ = ctx.Error(err)
-			ctx.String(http.StatusInternalServerError, "Unable to create calendar")
+		// The upstream timetable did not change, so neither did the feed
+		version := feedVersion(course, cached.hash, opts)
+		if found && cal.version == version {
+			calcache.Set(cacheKey, newCachedFeed(cal.data, version))
+			ctx.Header(cacheHeader, "REVALIDATED")
+			successFeed(ctx, format, cal.data)
 			return
 		}
+		ctx.Header(cacheHeader, "MISS")
 
-		buf := bytes.NewBuffer(nil)
-		err = cal.SerializeTo(buf)
-		if err != nil {
-			_ = ctx.Error(err)
-			ctx.String(http.StatusInternalServerError, "Unable to serialize calendar")
+		data, ok := format.Render(ctx, cached.timetable, course, annoInt, opts)
+		if !ok {
 			return
 		}
 
-		calcache.Set(cacheKey, buf, cache.DefaultExpiration)
+		calcache.Set(cacheKey, newCachedFeed(data, version))
+
+		successFeed(ctx, format, data)
+	}
+}
+
+// overloaded sheds the request, when too many are being processed.
+func overloaded(ctx *gin.Context) {
+	ctx.Header("Retry-After", strconv.Itoa(int(retryAfterOverload.Seconds())))
+	writeProblem(ctx, http.StatusServiceUnavailable, codeOverloaded, "Too many requests, retry later")
+}
 
-		successCalendar(ctx, buf)
+// buildCalendar creates and serializes the calendar for the given timetable.
+//
+// If something goes wrong, the error response is already written and the
+// boolean is false.
+func buildCalendar(ctx *gin.Context, t timetable.Timetable, course *unibo_integ.Course, year int, opts calOptions) ([]byte, bool) {
+	cal, err := createCal(t, course, year, opts)
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to create calendar")
+		return nil, false
 	}
+
+	return serializeCalendar(ctx, cal, opts)
 }
 
-func successCalendar(c *gin.Context, cal *bytes.Buffer) {
-	c.Header("Content-Type", "text/calendar; charset=utf-8")
-	c.Header("Content-Disposition", "attachment; filename=lezioni.ics")
-	// Allow CORS
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, Authorization")
-	c.Header("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+// serializeCalendar serializes the calendar.
+//
+// If something goes wrong, the error response is already written and the
+// boolean is false.
+func serializeCalendar(ctx *gin.Context, cal *ics.Calendar, opts calOptions) ([]byte, bool) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	err := cal.SerializeTo(buf)
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to serialize calendar")
+		return nil, false
+	}
+
+	if opts.Strict {
+		checkStrictCalendar(buf.Bytes(), ctx.Request.URL.String())
+	}
 
-	c.String(http.StatusOK, cal.String())
+	// The pooled buffer is reused, so the calendar is copied in a slice of
+	// the exact size before being cached.
+	return bytes.Clone(buf.Bytes()), true
+}
+
+// successCalendar writes the serialized calendar to the response.
+func successCalendar(c *gin.Context, cal []byte) {
+	successFeed(c, feedFormats[defaultFormat], cal)
 }
 
 // createCal creates a calendar from the given timetable.
 //
-// If subjectCodes is not nil, it will be used to filter the timetable by subjects.
+// The events are filtered by the options, see calOptions.includes.
 func createCal(
 	timetable timetable.Timetable,
 	course *unibo_integ.Course,
 	year int,
-	subjectCodes []string,
+	opts calOptions,
 ) (*ics.Calendar, error) {
+	cal, err := createEventsCal(timetable, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	calName := fmt.Sprintf("%s - %d year", course.Name(opts.Lang), year)
+	cal.SetName(calName)
+
+	calDesc := fmt.Sprintf("Orario delle lezioni del %d anno del corso di %s",
+		year, course.Descrizione)
+	cal.SetDescription(calDesc)
+	setCalendarIdentity(cal, calendarIdentity(fmt.Sprintf("course-%d-%d-%s", course.Codice, year, opts.Curriculum), opts))
+
+	if opts.Combined {
+		addAcademicEvents(cal, academicEvents(course, timetable))
+	}
 
+	return cal, nil
+}
+
+// createEventsCal creates a calendar containing the events of the timetable,
+// without name and description.
+func createEventsCal(timetable timetable.Timetable, opts calOptions) (*ics.Calendar, error) {
 	// Filter timetable by subjects
-	if subjectCodes != nil {
-		timetable = filterTimetableBySubjects(timetable, subjectCodes)
+	if opts.filters() {
+		filtered := getEvents()
+		defer putEvents(filtered)
+
+		*filtered = filterTimetable(*filtered, timetable, opts)
+		timetable = *filtered
+	}
+	if opts.Merge {
+		timetable = mergeAdjacentEvents(timetable)
 	}
 
 	cal := ics.NewCalendar()
-	cal.SetMethod(ics.MethodRequest)
+	cal.SetProductId(config.IcsProdId)
+	if config.IcsCalscale != "" {
+		cal.SetCalscale(config.IcsCalscale)
+	}
+	if opts.Strict {
+		// REQUEST is an iTIP invitation, subscriptions are published calendars
+		cal.SetMethod(ics.MethodPublish)
+	} else if config.IcsMethod != "" {
+		cal.SetMethod(ics.Method(config.IcsMethod))
+	}
+	addRomeTimezone(cal)
+
+	if opts.Stale {
+		addStaleWarning(cal, time.Now())
+	}
 
+	if opts.Recurring {
+		addRecurringLessons(cal, timetable, opts)
+		return cal, nil
+	}
 	for _, event := range timetable {
-		sha := sha1.New()
-		_, err := sha.Write([]byte(fmt.Sprintf("%s%s%s", event.CodModulo, event.Start, event.End)))
-		if err != nil {
-			return nil, err
-		}
+		addLessonEvent(cal, eventUid(event), event, opts)
+	}
 
-		eventUid := fmt.Sprintf("%x", sha.Sum(nil))
+	return cal, nil
+}
 
-		e := cal.AddEvent(eventUid)
+// addLessonEvent adds the lesson to the calendar, as the event with the
+// given UID.
+func addLessonEvent(cal *ics.Calendar, uid string, event timetable.Event, opts calOptions) *ics.VEvent {
+	event = localizeEvent(event, opts.Lang)
+	if opts.TitleCase {
+		event.Title = titleCase(event.Title)
+	}
+
+	e := cal.AddEvent(uid)
+	if opts.Strict {
+		// ORGANIZER must be a cal-address, but we only know the name of
+		// the teacher, which is already in the description.
+		event = sanitizeEvent(event)
+	} else {
 		e.SetOrganizer(event.Teacher)
-		e.SetSummary(event.Title)
-		e.SetStartAt(event.Start.Time)
-		e.SetEndAt(event.End.Time)
-
-		e.SetDtStampTime(time.Now()) // https://www.kanzaki.com/docs/ical/dtstamp.html
-
-		b := strings.Builder{}
-		b.WriteString(fmt.Sprintf("Docente: %s\n", event.Teacher))
-		if len(event.Classrooms) > 0 {
-			classroom := event.Classrooms[0]
-			b.WriteString(fmt.Sprintf("Aula: %s\n", classroom.ResourceDesc))
-			e.SetLocation(classroom.ResourceDesc)
-		}
-		b.WriteString(fmt.Sprintf("Cfu: %d\n", event.Cfu))
-		b.WriteString(fmt.Sprintf("Periodo: %s\n", event.Interval))
-		b.WriteString(fmt.Sprintf("Codice modulo: %s\n", event.CodModulo))
+	}
+	summary, description := eventSummary(event, opts.Titles)
+	e.SetSummary(summary)
+	setRomeTimes(e, event.Start.Time, event.End.Time)
+
+	e.SetDtStampTime(time.Now()) // https://www.kanzaki.com/docs/ical/dtstamp.html
 
-		e.SetDescription(b.String())
+	if u, ok := teachingUrl(event); ok {
+		e.SetURL(u)
 	}
 
-	calName := fmt.Sprintf("%s - %d year", course.Descrizione, year)
-	cal.SetName(calName)
+	if len(event.Classrooms) > 0 {
+		e.SetLocation(event.Classrooms[0].ResourceDesc)
+	}
+	if code := subjectCode(event); code != "" {
+		e.AddCategory(code)
+	}
+	if event.Cfu > 0 {
+		e.AddCategory(fmt.Sprintf("%d CFU", event.Cfu))
+	}
+	if opts.Combined {
+		e.AddCategory(categoryLesson)
+	}
 
-	calDesc := fmt.Sprintf("Orario delle lezioni del %d anno del corso di %s",
-		year, course.Descrizione)
-	cal.SetDescription(calDesc)
+	e.SetDescription(description)
+	return e
+}
 
-	return cal, nil
+// eventUid returns a stable identifier of the event, so calendar clients
+// update it instead of creating a duplicate on every refresh.
+func eventUid(event timetable.Event) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s%s%s", event.CodModulo, event.Start, event.End)))
+	return fmt.Sprintf("%x", sum)
 }
 
-func filterTimetableBySubjects(t timetable.Timetable, codes []string) timetable.Timetable {
-	filtered := make([]timetable.Event, 0, len(t))
+// eventDescription returns the details of the event, one per line.
+func eventDescription(event timetable.Event) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("Docente: %s\n", event.Teacher))
+	if len(event.Classrooms) > 0 {
+		b.WriteString(fmt.Sprintf("Aula: %s\n", event.Classrooms[0].ResourceDesc))
+	}
+	b.WriteString(fmt.Sprintf("Cfu: %d\n", event.Cfu))
+	b.WriteString(fmt.Sprintf("Periodo: %s\n", event.Interval))
+	b.WriteString(fmt.Sprintf("Codice modulo: %s\n", event.CodModulo))
+	if code := subjectCode(event); code != "" {
+		b.WriteString(fmt.Sprintf("Codice insegnamento: %s\n", code))
+	}
+	return b.String()
+}
+
+// filterTimetableBySubjects appends to dst the events of t whose module code
+// is in codes, and returns the extended slice.
+//
+// Codes can also select a single group of a module which is split in more
+// groups (e.g. "28004_1--A-K"), using the code of the group.
+func filterTimetableBySubjects(dst, t timetable.Timetable, codes []string) timetable.Timetable {
+	filtered := dst
 	for _, event := range t {
-		if slices.Contains(codes, event.CodModulo) {
+		if slices.Contains(codes, event.CodModulo) ||
+			(event.CodSdoppiamento != "" && slices.Contains(codes, event.CodSdoppiamento)) {
 			filtered = append(filtered, event)
 		}
 	}
@@ -345,7 +650,9 @@ func getSubjectsMapFromCourseAndCurricula(course *unibo_integ.Course, curricula
 				continue
 			}
 
-			courseTimetable, err := course.GetTimetable(y, c, nil)
+			// Cleaned, validated and indexed as the timetables of the feeds, so
+			// the change detector always compares the same kind of timetable
+			courseTimetable, err := getTimetable(course, y, c)
 			if err != nil {
 				// Can't do much. We return nil so the caller can retry
 				return nil, fmt.Errorf("unable to retrieve timetable for subjects: %w", err)
@@ -363,26 +670,23 @@ func getSubjectsMapFromCourseAndCurricula(course *unibo_integ.Course, curricula
 
 // This functions calls getSubjectsMapFromCourseAndCurricula for every course,
 // so the cache is always full and users do not see a slow site
-func fillSubjectsCache(courses unibo_integ.CoursesMap) {
+func fillSubjectsCache(courses *unibo_integ.Courses) {
 	// This is to make sure everything is started
 	time.Sleep(time.Second * 5)
 
-	for _, course := range courses {
+	prefetchCourses(courses, config.PrefetchDelay, func(course *unibo_integ.Course) {
 		log.Debug().Int("course-code", course.Codice).Str("course-name", course.Descrizione).Msg("queried subjects")
 
-		curricula, err := course.GetAllCurricula()
+		curricula, err := getAllCurricula(course)
 		if err != nil {
 			log.Err(err).Int("course-code", course.Codice).Str("course-name", course.Descrizione).Msg("Can't get curricula in workerfor course")
-			continue
+			return
 		}
-		_, err = getSubjectsMapFromCourseAndCurricula(&course, curricula)
+		_, err = getSubjectsMapFromCourseAndCurricula(course, curricula)
 		if err != nil {
 			log.Err(err).Msg("Can't subjects in worker")
-			continue
 		}
-
-		time.Sleep(time.Second * 30)
-	}
+	})
 
 	time.Sleep(subjectsCacheExpirationTime)
 }
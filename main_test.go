@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
 )
 
 func Test_coursePage(t *testing.T) {
@@ -19,9 +21,9 @@ func Test_coursePage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r := setupRouter(data)
+	r := setupRouter(unibo_integ.NewCourses(data))
 
-	for _, course := range data {
+	for _, course := range data.ToList() {
 		c := course
 		t.Run(strconv.Itoa(c.Codice), func(t *testing.T) {
 			t.Parallel()
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// matrixCommand is the prefix of the messages addressed to the bot.
+const matrixCommand = "!orari"
+
+// matrixEnabled reports whether the Matrix bot is configured.
+func matrixEnabled() bool {
+	return config.MatrixHomeserver != "" && config.MatrixAccessToken != ""
+}
+
+// matrixFollow is a course year followed by a Matrix room.
+type matrixFollow struct {
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum,omitempty"`
+	// Daily enables the post with the lessons of the next day
+	Daily bool `json:"daily"`
+}
+
+func (f matrixFollow) feed() feedRef {
+	return feedRef{Course: f.Course, Year: f.Year, Curriculum: f.Curriculum}
+}
+
+// matrixBot posts the schedule changes and the daily schedules of the
+// followed courses in the Matrix rooms it's invited to. The rooms choose the
+// courses with the commands in matrixHelp.
+type matrixBot struct {
+	mu   sync.Mutex
+	file string
+	// rooms are the courses followed by every room, by room id
+	rooms map[string][]matrixFollow
+}
+
+var matrixRooms = &matrixBot{file: config.MatrixRoomsFile}
+
+const matrixHelp = matrixCommand + ` segui <id corso> <anno> [curriculum]: pubblica le modifiche all'orario del corso
+` + matrixCommand + ` smetti <id corso> <anno>: smette di seguire il corso
+` + matrixCommand + ` giornaliero <id corso> <anno>: attiva o disattiva le lezioni del giorno dopo, ogni sera
+` + matrixCommand + ` lista: i corsi seguiti nella stanza`
+
+// load reads the rooms from their file.
+func (m *matrixBot) load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rooms = map[string][]matrixFollow{}
+	return loadJsonFile(m.file, &m.rooms)
+}
+
+// command executes the command of a message sent in the room, and returns
+// the reply. Messages which are not commands have no reply.
+func (m *matrixBot) command(courses *unibo_integ.Courses, roomId string, message string) string {
+	args := strings.Fields(message)
+	if len(args) == 0 || args[0] != matrixCommand {
+		return ""
+	}
+	if len(args) == 1 {
+		return matrixHelp
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rooms == nil {
+		m.rooms = map[string][]matrixFollow{}
+	}
+	follows := m.rooms[roomId]
+
+	if args[1] == "lista" {
+		if len(follows) == 0 {
+			return "La stanza non segue nessun corso."
+		}
+		b := strings.Builder{}
+		for _, f := range follows {
+			b.WriteString(fmt.Sprintf("- %s, %d anno", courseDescription(courses.Load(), f.Course), f.Year))
+			if f.Daily {
+				b.WriteString(" (con le lezioni del giorno dopo)")
+			}
+			b.WriteString("\n")
+		}
+		return b.String()
+	}
+
+	if len(args) < 4 {
+		return matrixHelp
+	}
+	id, err := strconv.Atoi(args[2])
+	course, found := courses.Load().FindById(id)
+	if err != nil || !found {
+		return "Corso non trovato."
+	}
+	year, err := strconv.Atoi(args[3])
+	if err != nil || year <= 0 || year > course.DurataAnni {
+		return "Anno non valido."
+	}
+	i := slices.IndexFunc(follows, func(f matrixFollow) bool {
+		return f.Course == id && f.Year == year
+	})
+
+	var reply string
+	switch args[1] {
+	case "segui":
+		f := matrixFollow{Course: id, Year: year}
+		if len(args) > 4 {
+			if perr := checkCurriculum(course, "curriculum", year, args[4]); perr != nil {
+				return curriculumErrorText(perr) + "."
+			}
+			f.Curriculum = args[4]
+		}
+		if i >= 0 {
+			follows[i].Curriculum = f.Curriculum
+		} else {
+			follows = append(follows, f)
+		}
+		reply = fmt.Sprintf("Da ora pubblicherò le modifiche all'orario del %d anno di %s.", year, course.Descrizione)
+	case "smetti":
+		if i < 0 {
+			return "La stanza non segue il corso."
+		}
+		follows = slices.Delete(follows, i, i+1)
+		reply = fmt.Sprintf("Non seguo più il %d anno di %s.", year, course.Descrizione)
+	case "giornaliero":
+		if i < 0 {
+			return "La stanza non segue il corso, usa prima " + matrixCommand + " segui."
+		}
+		follows[i].Daily = !follows[i].Daily
+		if follows[i].Daily {
+			reply = fmt.Sprintf("Ogni sera alle %s pubblicherò le lezioni del giorno dopo.", config.DigestTime)
+		} else {
+			reply = "Non pubblicherò più le lezioni del giorno dopo."
+		}
+	default:
+		return matrixHelp
+	}
+
+	m.rooms[roomId] = follows
+	err = saveJsonFile(m.file, m.rooms, 0o644)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to save matrix rooms")
+		return "Errore nel salvataggio, riprova più tardi."
+	}
+	return reply
+}
+
+// following returns the rooms with a follow matching, with every matching
+// follow of the room.
+func (m *matrixBot) following(match func(f matrixFollow) bool) map[string][]matrixFollow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rooms := map[string][]matrixFollow{}
+	for roomId, follows := range m.rooms {
+		for _, f := range follows {
+			if match(f) {
+				rooms[roomId] = append(rooms[roomId], f)
+			}
+		}
+	}
+	return rooms
+}
+
+// notifyChanges posts the changes of the feed in the rooms following it.
+func (m *matrixBot) notifyChanges(courses *unibo_integ.Courses, feed feedRef, changes []scheduleChange) {
+	rooms := m.following(func(f matrixFollow) bool { return f.feed() == feed })
+	if len(rooms) == 0 {
+		return
+	}
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("Modifiche all'orario del %d anno di %s:\n", feed.Year, courseDescription(courses.Load(), feed.Course)))
+	for _, c := range changes {
+		b.WriteString("- " + c.String() + "\n")
+	}
+	for roomId := range rooms {
+		err := m.send(roomId, b.String())
+		if err != nil {
+			log.Warn().Err(err).Str("room", roomId).Msg("unable to post schedule changes on matrix")
+		}
+	}
+}
+
+// postDaily posts the lessons of the day in the rooms which asked for them.
+func (m *matrixBot) postDaily(courses *unibo_integ.Courses, day time.Time) {
+	for roomId, follows := range m.following(func(f matrixFollow) bool { return f.Daily }) {
+		for _, f := range follows {
+			course, t, err := feedTimetable(courses, f.feed(), nil)
+			if err != nil {
+				log.Warn().Err(err).Int("course-code", f.Course).Msg("unable to retrieve timetable for matrix")
+				continue
+			}
+			lessons := lessonsOn(t, day)
+			if len(lessons) == 0 {
+				continue
+			}
+
+			text := fmt.Sprintf("Lezioni di domani del %d anno di %s:\n\n%s", f.Year, course.Descrizione, formatLessons(lessons))
+			err = m.send(roomId, text)
+			if err != nil {
+				log.Warn().Err(err).Str("room", roomId).Msg("unable to post daily schedule on matrix")
+			}
+		}
+	}
+}
+
+// matrixSyncTimeout is how long the homeserver holds a /sync request when
+// there are no new events.
+const matrixSyncTimeout = time.Second * 30
+
+// matrixClient makes the requests to the homeserver, and matrixSyncClient
+// the long polls of /sync, which last up to matrixSyncTimeout.
+var (
+	matrixClient     = &http.Client{Timeout: time.Second * 10}
+	matrixSyncClient = &http.Client{Timeout: matrixSyncTimeout + time.Second*30}
+)
+
+// do calls the client-server API of the homeserver with the client,
+// decoding the response in out if not nil.
+func (m *matrixBot) do(client *http.Client, method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(config.MatrixHomeserver, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.MatrixAccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach matrix homeserver: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("matrix homeserver returned %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(res.Body).Decode(out)
+	}
+	return nil
+}
+
+// send posts a notice, the message type of bots, in the room.
+func (m *matrixBot) send(roomId string, text string) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(roomId), randomId())
+	return m.do(matrixClient, http.MethodPut, path, map[string]string{"msgtype": "m.notice", "body": text}, nil)
+}
+
+// matrixSync is the part of the /sync response used by the bot.
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Invite map[string]json.RawMessage `json:"invite"`
+		Join   map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Content struct {
+						MsgType string `json:"msgtype"`
+						Body    string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// Run accepts the invites to the rooms and answers the commands, posting
+// the schedule changes and the daily schedules.
+func (m *matrixBot) Run(courses *unibo_integ.Courses) {
+	err := m.load()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load matrix rooms")
+		return
+	}
+
+	scheduleChanges.onChange(func(feed feedRef, changes []scheduleChange) {
+		m.notifyChanges(courses, feed, changes)
+	})
+	go runDaily(func(tomorrow time.Time) {
+		m.postDaily(courses, tomorrow)
+	})
+
+	since := ""
+	for {
+		q := url.Values{"timeout": {strconv.FormatInt(matrixSyncTimeout.Milliseconds(), 10)}}
+		if since != "" {
+			q.Set("since", since)
+		}
+
+		var res matrixSync
+		err := m.do(matrixSyncClient, http.MethodGet, "/_matrix/client/v3/sync?"+q.Encode(), nil, &res)
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to sync with matrix homeserver")
+			time.Sleep(time.Second * 30)
+			continue
+		}
+
+		for roomId := range res.Rooms.Invite {
+			err = m.do(matrixClient, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(roomId), map[string]string{}, nil)
+			if err != nil {
+				log.Warn().Err(err).Str("room", roomId).Msg("unable to join matrix room")
+			}
+		}
+
+		// The first sync returns the last messages, already answered before
+		// a restart
+		if since != "" {
+			for roomId, room := range res.Rooms.Join {
+				for _, e := range room.Timeline.Events {
+					if e.Type != "m.room.message" || e.Content.MsgType != "m.text" {
+						continue
+					}
+					if reply := m.command(courses, roomId, e.Content.Body); reply != "" {
+						err = m.send(roomId, reply)
+						if err != nil {
+							log.Warn().Err(err).Str("room", roomId).Msg("unable to reply on matrix")
+						}
+					}
+				}
+			}
+		}
+		since = res.NextBatch
+	}
+}
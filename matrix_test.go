@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_matrixCommand(t *testing.T) {
+	bot := &matrixBot{file: path.Join(t.TempDir(), "matrix.json")}
+
+	assert.Equal(t, "", bot.command(testCourses, "!room", "ciao"))
+	assert.Equal(t, matrixHelp, bot.command(testCourses, "!room", "!orari"))
+	assert.Equal(t, "Corso non trovato.", bot.command(testCourses, "!room", "!orari segui 1 1"))
+	assert.Equal(t, "Anno non valido.", bot.command(testCourses, "!room", "!orari segui 8009 4"))
+
+	reply := bot.command(testCourses, "!room", "!orari segui 8009 1")
+	assert.Equal(t, true, strings.Contains(reply, "INFORMATICA"))
+	bot.command(testCourses, "!room", "!orari giornaliero 8009 1")
+	assert.Equal(t, []matrixFollow{{Course: 8009, Year: 1, Daily: true}}, bot.rooms["!room"])
+
+	// The rooms are saved
+	loaded := &matrixBot{file: bot.file}
+	assert.Equal(t, nil, loaded.load())
+	assert.Equal(t, bot.rooms, loaded.rooms)
+
+	bot.command(testCourses, "!room", "!orari smetti 8009 1")
+	assert.Equal(t, "La stanza non segue nessun corso.", bot.command(testCourses, "!room", "!orari lista"))
+}
+
+func Test_matrixNotifyChanges(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, true, strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		posted = append(posted, body["body"])
+		_, _ = w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer server.Close()
+
+	config.MatrixHomeserver, config.MatrixAccessToken = server.URL, "token"
+	defer func() { config.MatrixHomeserver, config.MatrixAccessToken = "", "" }()
+
+	bot := &matrixBot{rooms: map[string][]matrixFollow{"!room:example.org": {{Course: 8009, Year: 1}}}}
+	changes := []scheduleChange{{Kind: changeCancelled, Event: testTimetable()[0]}}
+	bot.notifyChanges(testCourses, feedRef{Course: 8009, Year: 2}, changes)
+	assert.Equal(t, 0, len(posted))
+
+	bot.notifyChanges(testCourses, feedRef{Course: 8009, Year: 1}, changes)
+	assert.Equal(t, []string{"Modifiche all'orario del 1 anno di INFORMATICA:\n- Lezione annullata: ALGEBRA, 01/10 09:00\n"}, posted)
+}
+
+func Test_matrixFollowing(t *testing.T) {
+	bot := &matrixBot{rooms: map[string][]matrixFollow{
+		"!room":  {{Course: 8009, Year: 1, Daily: true}, {Course: 8009, Year: 2}, {Course: 9254, Year: 1, Daily: true}},
+		"!other": {{Course: 8009, Year: 2}},
+	}}
+
+	// Every follow of a room is kept, not only the last one
+	daily := bot.following(func(f matrixFollow) bool { return f.Daily })
+	assert.Equal(t, map[string][]matrixFollow{
+		"!room": {{Course: 8009, Year: 1, Daily: true}, {Course: 9254, Year: 1, Daily: true}},
+	}, daily)
+
+	rooms := bot.following(func(f matrixFollow) bool { return f.Year == 2 })
+	assert.Equal(t, 2, len(rooms))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The users can delete the data stored about them, linked to the secret id
+// (the token) they received: the one of a digest subscription, in the
+// emails, of a webhook, returned when it's created, or of a Google Calendar
+// subscription, shown after the authorization. The Web Push subscriptions
+// are removed by the browser itself, see pushUnsubscribe, and the courses
+// followed by a Matrix room, which has no token, with the "smetti" command
+// of the bot (see matrixHelp).
+
+// userData is the data stored for the owner of a token.
+type userData struct {
+	// Digests are all the subscriptions of the address of the token
+	Digests  []digestSubscription
+	Webhooks []chatWebhook
+	Google   []googleSubscription
+}
+
+func (u userData) empty() bool {
+	return len(u.Digests)+len(u.Webhooks)+len(u.Google) == 0
+}
+
+// email returns the address of the digest subscriptions, if any.
+func (u userData) email() string {
+	if len(u.Digests) == 0 {
+		return ""
+	}
+	return u.Digests[0].Email
+}
+
+// findUserData returns the data of the owner of the token.
+func findUserData(token string) userData {
+	var data userData
+	if token == "" {
+		return data
+	}
+
+	data.Digests = digestSubs.owned(token)
+	data.Webhooks = webhooks.matching(func(w *chatWebhook) bool { return w.Id == token })
+	if sub, found := googleSubs.get(token); found {
+		data.Google = append(data.Google, sub)
+	}
+	return data
+}
+
+// deleteUserData deletes the data of the owner of the token, so no email,
+// webhook post or Google Calendar sync is made for them anymore. The access
+// to Google Calendar is revoked, and the synced calendar is deleted too if
+// deleteCalendar is true.
+func deleteUserData(ctx context.Context, token string, deleteCalendar bool) (userData, error) {
+	data := findUserData(token)
+
+	if len(data.Digests) > 0 {
+		err := digestSubs.update(func(subs map[string]*digestSubscription) error {
+			for _, sub := range data.Digests {
+				delete(subs, sub.Id)
+			}
+			return nil
+		})
+		if err != nil {
+			return data, fmt.Errorf("unable to delete digest subscriptions: %w", err)
+		}
+	}
+	if len(data.Webhooks) > 0 {
+		err := webhooks.update(func(hooks map[string]*chatWebhook) error {
+			delete(hooks, token)
+			return nil
+		})
+		if err != nil {
+			return data, fmt.Errorf("unable to delete webhook: %w", err)
+		}
+	}
+	if len(data.Google) > 0 {
+		// The calendar can only be deleted with the token, so this is done
+		// before forgetting it
+		err := googleSubs.disconnect(ctx, data.Google[0], deleteCalendar)
+		if err != nil {
+			return data, fmt.Errorf("unable to disconnect google calendar: %w", err)
+		}
+		err = googleSubs.remove(token)
+		if err != nil {
+			return data, fmt.Errorf("unable to delete google subscription: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// meLink returns the absolute URL of the page to delete the data of the
+// token.
+func meLink(token string) string {
+	return strings.TrimSuffix(config.PublicUrl, "/") + "/me/" + token
+}
+
+// apiDeleteMe deletes the data of the token, see deleteUserData. The
+// calendar on Google Calendar is deleted with ?delete_calendar=true.
+func apiDeleteMe(ctx *gin.Context) {
+	data, err := deleteUserData(ctx, ctx.Param("token"), ctx.Query("delete_calendar") == "true")
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to delete the data")
+		return
+	} else if data.empty() {
+		writeProblem(ctx, http.StatusNotFound, codeNotFound, "No data associated with the token")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// mePage shows the data of the token, and asks to confirm the deletion.
+func mePage(ctx *gin.Context) {
+	data := findUserData(ctx.Param("token"))
+	if data.empty() {
+		errorPage(ctx, http.StatusNotFound, "Nessun dato associato a questo link")
+		return
+	}
+
+	renderHTML(ctx, http.StatusOK, "me", gin.H{
+		"token":    ctx.Param("token"),
+		"email":    data.email(),
+		"digests":  len(data.Digests),
+		"webhooks": len(data.Webhooks),
+		"google":   len(data.Google),
+	})
+}
+
+// meDelete deletes the data of the token, after the confirmation of
+// mePage.
+func meDelete(ctx *gin.Context) {
+	data, err := deleteUserData(ctx, ctx.Param("token"), ctx.PostForm("delete_calendar") == "on")
+	if err != nil {
+		_ = ctx.Error(err)
+		errorPage(ctx, http.StatusInternalServerError, "Si è verificato un errore, riprova più tardi")
+		return
+	} else if data.empty() {
+		errorPage(ctx, http.StatusNotFound, "Nessun dato associato a questo link")
+		return
+	}
+	renderHTML(ctx, http.StatusOK, "me", gin.H{"deleted": true})
+}
+
+// setupMe registers the endpoints to delete the data of the users.
+func setupMe(r *gin.Engine, limiter gin.HandlerFunc) {
+	r.GET("/me/:token", limiter, mePage)
+	r.POST("/me/:token", limiter, meDelete)
+	r.DELETE("/api/me/:token", cors(), limiter, apiDeleteMe)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"golang.org/x/oauth2"
+)
+
+// useTestUserData replaces the stores of the user data with ones in a
+// temporary folder.
+func useTestUserData(t *testing.T) {
+	dir := t.TempDir()
+	digestFile, digests := digestSubs.file, digestSubs.subs
+	hooksFile := webhooks.file
+	googleFile, google := googleSubs.file, googleSubs.subs
+	t.Cleanup(func() {
+		digestSubs.file, digestSubs.subs = digestFile, digests
+		webhooks.file, webhooks.hooks = hooksFile, nil
+		googleSubs.file, googleSubs.subs = googleFile, google
+	})
+
+	digestSubs.file, digestSubs.subs = path.Join(dir, "digest.json"), map[string]*digestSubscription{
+		"d1": {Id: "d1", Email: "student@example.com", Course: 8009, Year: 1},
+		"d2": {Id: "d2", Email: "student@example.com", Course: 8009, Year: 2},
+		"d3": {Id: "d3", Email: "other@example.com", Course: 8009, Year: 1},
+	}
+	webhooks.file, webhooks.hooks = path.Join(dir, "webhooks.json"), map[string]*chatWebhook{
+		"w1": {Id: "w1", Kind: "discord", Course: 8009, Year: 1},
+	}
+	googleSubs.file, googleSubs.subs = path.Join(dir, "google.json"), map[string]*googleSubscription{
+		"g1": {Id: "g1", Course: 8009, Year: 1},
+	}
+}
+
+func Test_findUserData(t *testing.T) {
+	useTestUserData(t)
+
+	// Every subscription of the address
+	data := findUserData("d1")
+	assert.Equal(t, 2, len(data.Digests))
+	assert.Equal(t, "student@example.com", data.email())
+
+	assert.Equal(t, 1, len(findUserData("w1").Webhooks))
+	assert.Equal(t, 1, len(findUserData("g1").Google))
+	assert.Equal(t, true, findUserData("unknown").empty())
+	assert.Equal(t, true, findUserData("").empty())
+}
+
+func Test_deleteMe(t *testing.T) {
+	useTestUserData(t)
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/me/d2", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "student@example.com"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/me/d2", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(digestSubs.subs))
+	assert.Equal(t, "other@example.com", digestSubs.subs["d3"].Email)
+
+	for _, token := range []string{"w1", "g1"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodDelete, "/api/me/"+token, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+	assert.Equal(t, 0, len(webhooks.hooks))
+	assert.Equal(t, 0, len(googleSubs.subs))
+
+	// The data is already deleted
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/api/me/w1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/me/d1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_deleteUserDataRevokesGoogle(t *testing.T) {
+	useTestUserData(t)
+
+	var revoked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revoked = append(revoked, r.FormValue("token"))
+	}))
+	defer srv.Close()
+	oldRevokeUrl := googleRevokeUrl
+	googleRevokeUrl = srv.URL
+	t.Cleanup(func() { googleRevokeUrl = oldRevokeUrl })
+
+	googleSubs.subs["g1"].Token = &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	data, err := deleteUserData(context.Background(), "g1", false)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(data.Google))
+	assert.Equal(t, []string{"refresh"}, revoked)
+	assert.Equal(t, 0, len(googleSubs.subs))
+}
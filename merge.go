@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// mergeAdjacentEvents returns the events of t sorted by start, where the
+// lessons of the same module, teacher and room which start when the
+// previous one ends are merged into a single longer event.
+//
+// t is not modified, the merged events are copies.
+func mergeAdjacentEvents(t timetable.Timetable) timetable.Timetable {
+	sorted := slices.Clone(t)
+	slices.SortStableFunc(sorted, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+
+	merged := make(timetable.Timetable, 0, len(sorted))
+	// open are the indexes in merged of the last event of every module, the
+	// only ones that can still be extended
+	open := map[string]int{}
+	for _, e := range sorted {
+		if i, found := open[e.CodModulo]; found && adjacent(merged[i], e) {
+			merged[i].End = e.End
+			continue
+		}
+		open[e.CodModulo] = len(merged)
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// adjacent reports whether next continues the lesson prev, in the same room
+// with the same teacher.
+func adjacent(prev, next timetable.Event) bool {
+	return prev.CodModulo == next.CodModulo &&
+		prev.Teacher == next.Teacher &&
+		eventRoom(prev) == eventRoom(next) &&
+		prev.End.Equal(next.Start.Time)
+}
+
+func eventRoom(e timetable.Event) string {
+	if len(e.Classrooms) == 0 {
+		return ""
+	}
+	return e.Classrooms[0].ResourceDesc
+}
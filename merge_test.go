@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_mergeAdjacentEvents(t *testing.T) {
+	tt := testTimetable()
+	second := tt[0]
+	second.Start, second.End = tt[0].End, timetable.CalendarTime{Time: tt[0].End.Add(2 * time.Hour)}
+	// In another room, can't be merged
+	third := second
+	third.Start, third.End = second.End, timetable.CalendarTime{Time: second.End.Add(time.Hour)}
+	third.Classrooms = []timetable.Classroom{{ResourceDesc: "AULA 2"}}
+	tt = append(tt, third, second)
+
+	merged := mergeAdjacentEvents(tt)
+	assert.Equal(t, 3, len(merged))
+	assert.Equal(t, "00001_1", merged[0].CodModulo)
+	assert.Equal(t, tt[0].Start, merged[0].Start)
+	assert.Equal(t, second.End, merged[0].End)
+	assert.Equal(t, "00002_1", merged[1].CodModulo)
+	assert.Equal(t, "AULA 2", merged[2].Classrooms[0].ResourceDesc)
+
+	// The original timetable is untouched
+	assert.Equal(t, 11, tt[0].End.Hour())
+}
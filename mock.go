@@ -0,0 +1,122 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// mockFixtures contains the courses, curricula and timetables served in
+// mock mode (see [Config.Mock]) instead of the Unibo ones.
+//
+// The timetables contain a single week of lessons, repeated around the
+// current week by mockTimetable.
+//
+//go:embed fixtures
+var mockFixtures embed.FS
+
+// mockWeeksBefore and mockWeeksAfter are how many times the week of the
+// fixture timetables is repeated before and after the current one.
+const (
+	mockWeeksBefore = 4
+	mockWeeksAfter  = 12
+)
+
+// readMockFixture decodes the JSON fixture with the given name.
+func readMockFixture(name string, v any) error {
+	data, err := mockFixtures.ReadFile(path.Join("fixtures", name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// mockCourses returns the courses of the fixtures, remembering their
+// website ids so the course websites are never scraped.
+func mockCourses() (*unibo_integ.CoursesMap, error) {
+	var courses []unibo_integ.Course
+	err := readMockFixture("courses.json", &courses)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mock courses: %w", err)
+	}
+
+	var websites map[int]unibo_integ.CourseId
+	err = readMockFixture("websites.json", &websites)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mock website ids: %w", err)
+	}
+	for code, id := range websites {
+		unibo_integ.Unibo.RememberWebsiteId(code, id)
+	}
+
+	return unibo_integ.NewCoursesMap(courses), nil
+}
+
+// mockAllCurricula returns the curricula of the course from the fixtures.
+func mockAllCurricula(course *unibo_integ.Course) (map[int]curriculum.Curricula, error) {
+	var curricula map[int]map[int]curriculum.Curricula
+	err := readMockFixture("curricula.json", &curricula)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mock curricula: %w", err)
+	}
+
+	c, found := curricula[course.Codice]
+	if !found {
+		return nil, fmt.Errorf("no mock curricula for course %d", course.Codice)
+	}
+	return c, nil
+}
+
+// mockTimetable returns the timetable of the course year from the fixtures,
+// the same for every curriculum. Years without a fixture have no lessons.
+func mockTimetable(course *unibo_integ.Course, year int) (timetable.Timetable, error) {
+	var week timetable.Timetable
+	err := readMockFixture(fmt.Sprintf("timetables/%d-%d.json", course.Codice, year), &week)
+	if errors.Is(err, fs.ErrNotExist) {
+		return timetable.Timetable{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read mock timetable: %w", err)
+	}
+
+	return repeatWeek(week, time.Now()), nil
+}
+
+// repeatWeek moves the events of a week to the week of now, and repeats
+// them from mockWeeksBefore weeks before to mockWeeksAfter weeks after it.
+func repeatWeek(week timetable.Timetable, now time.Time) timetable.Timetable {
+	if len(week) == 0 {
+		return week
+	}
+
+	shift := weeksBetween(week[0].Start.Time, now)
+
+	t := make(timetable.Timetable, 0, len(week)*(mockWeeksBefore+1+mockWeeksAfter))
+	for w := shift - mockWeeksBefore; w <= shift+mockWeeksAfter; w++ {
+		for _, e := range week {
+			// AddDate keeps the time of the day across DST changes
+			e.Start.Time = e.Start.AddDate(0, 0, 7*w)
+			e.End.Time = e.End.AddDate(0, 0, 7*w)
+			t = append(t, e)
+		}
+	}
+	return t
+}
+
+// weeksBetween returns the number of weeks from the week of a to the week
+// of b, with weeks starting on Monday.
+func weeksBetween(a, b time.Time) int {
+	monday := func(t time.Time) time.Time {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return int(monday(b).Sub(monday(a)).Hours()) / (24 * 7)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_mockFixtures(t *testing.T) {
+	courses, err := mockCourses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, course := range courses.ToList() {
+		curricula, err := mockAllCurricula(&course)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, course.DurataAnni, len(curricula))
+
+		for year := 1; year <= course.DurataAnni; year++ {
+			tt, err := mockTimetable(&course, year)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, nil, checkTimetableSchema(tt))
+		}
+	}
+}
+
+func Test_repeatWeek(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, rome)
+	week := timetable.Timetable{{
+		Start: timetable.CalendarTime{Time: time.Date(2024, time.September, 23, 9, 0, 0, 0, rome)},
+		End:   timetable.CalendarTime{Time: time.Date(2024, time.September, 23, 11, 0, 0, 0, rome)},
+	}}
+
+	repeated := repeatWeek(week, now)
+	assert.Equal(t, mockWeeksBefore+1+mockWeeksAfter, len(repeated))
+
+	current := repeated[mockWeeksBefore].Start.Time
+	assert.Equal(t, time.Date(2026, time.October, 12, 9, 0, 0, 0, rome), current)
+	// The lessons after the end of DST start at the same hour
+	assert.Equal(t, 9, repeated[len(repeated)-1].Start.Hour())
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The share images (OpenGraph) of the course pages are rendered on the fly,
+// so that the links shared in the chats show the course and its next lesson.
+
+const (
+	ogWidth  = 1200
+	ogHeight = 630
+	ogMargin = 60
+	// ogTTL is how long an image is cached, so the next lesson is at most
+	// that late
+	ogTTL = time.Minute * 30
+)
+
+var (
+	ogBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	ogAccent     = color.RGBA{R: 0xbb, G: 0x2e, B: 0x29, A: 0xff}
+	ogText       = color.RGBA{R: 0x22, G: 0x22, B: 0x22, A: 0xff}
+	ogMuted      = color.RGBA{R: 0x66, G: 0x66, B: 0x66, A: 0xff}
+)
+
+var ogCache = newLruCache[[]byte](ogTTL, time.Minute*10, 500, 0, nil)
+
+// nextLesson returns the first lesson of the course starting after now, in
+// the timetables of the default curriculum of every year.
+func nextLesson(course *unibo_integ.Course, now time.Time) (timetable.Event, bool) {
+	var next timetable.Event
+	found := false
+	for year := 1; year <= course.DurataAnni; year++ {
+		t, err := getTimetable(course, year, curriculum.Curriculum{})
+		if err != nil {
+			continue
+		}
+		for _, e := range t {
+			if e.Start.After(now) && (!found || e.Start.Before(next.Start.Time)) {
+				next, found = e, true
+			}
+		}
+	}
+	return next, found
+}
+
+// nextLessonText returns the lines describing the next lesson, in Italian.
+func nextLessonText(e timetable.Event) (string, string) {
+	start := e.Start.In(romeLocation)
+	when := fmt.Sprintf("Prossima lezione: %s %s ore %s",
+		italianWeekdays[start.Weekday()], start.Format("02/01"), start.Format("15:04"))
+	what := e.Title
+	if room := eventRoom(e); room != "" {
+		what += " - " + room
+	}
+	return when, what
+}
+
+// renderOgImage draws the share image of the course, with its next lesson
+// if not nil.
+func renderOgImage(course unibo_integ.Course, next *timetable.Event) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, ogWidth, ogHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, ogWidth, 100), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+	drawText(img, glyphText("UniboCalendar"), ogMargin, 29, 6, ogBackground)
+
+	width := ogWidth - 2*ogMargin
+	y := 150
+	for _, line := range wrapText(glyphText(course.Descrizione), width, 8, 3) {
+		drawText(img, line, ogMargin, y, 8, ogText)
+		y += 80
+	}
+
+	subtitle := course.Tipologia
+	if course.Campus != "" {
+		subtitle += " - " + course.Campus
+	}
+	for _, line := range wrapText(glyphText(subtitle), width, 5, 1) {
+		drawText(img, line, ogMargin, y+10, 5, ogMuted)
+		y += 60
+	}
+
+	if next != nil {
+		when, what := nextLessonText(*next)
+		y = ogHeight - ogMargin - 2*45
+		draw.Draw(img, image.Rect(ogMargin, y-25, ogWidth-ogMargin, y-21), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+		for _, line := range wrapText(glyphText(when), width, 4, 1) {
+			drawText(img, line, ogMargin, y, 4, ogAccent)
+		}
+		for _, line := range wrapText(glyphText(what), width, 4, 1) {
+			drawText(img, line, ogMargin, y+45, 4, ogText)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ogImagePath returns the path of the share image of the course.
+func ogImagePath(code int) string {
+	return fmt.Sprintf("/og/%d.png", code)
+}
+
+// ogImageHandler serves the share image of the course of /og/:id.png.
+func ogImageHandler(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		raw, found := strings.CutSuffix(c.Param("file"), ".png")
+		code, err := strconv.Atoi(raw)
+		if !found || err != nil {
+			errorPage(c, http.StatusNotFound, "Pagina non trovata")
+			return
+		}
+		course, found := courses.Load().FindById(code)
+		if !found {
+			errorPage(c, http.StatusNotFound, "Corso non trovato")
+			return
+		}
+
+		key := strconv.Itoa(code)
+		data, found := ogCache.Get(key)
+		if !found {
+			var next *timetable.Event
+			if e, ok := nextLesson(course, time.Now()); ok {
+				next = &e
+			}
+			data, err = renderOgImage(*course, next)
+			if err != nil {
+				_ = c.Error(err)
+				errorPage(c, http.StatusInternalServerError, "Si è verificato un errore, riprova più tardi")
+				return
+			}
+			ogCache.Set(key, data)
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ogTTL.Seconds())))
+		c.Data(http.StatusOK, "image/png", data)
+	}
+}
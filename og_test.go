@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_glyphText(t *testing.T) {
+	assert.Equal(t, "FORLI - 1° ANNO", glyphText("Forlì - 1° anno"))
+	assert.Equal(t, "A?B", glyphText("a#b"))
+}
+
+func Test_wrapText(t *testing.T) {
+	// 5 characters per line at scale 1
+	assert.Equal(t, []string{"AB CD", "EFG"}, wrapText("AB CD EFG", 29, 1, 3))
+	assert.Equal(t, []string{"ABCDE", "FG"}, wrapText("ABCDEFG", 29, 1, 3))
+	assert.Equal(t, []string{"AB..."}, wrapText("AB CD EFG", 29, 1, 1))
+}
+
+func Test_renderOgImage(t *testing.T) {
+	next := timetable.Event{Title: "ALGORITMI E STRUTTURE DI DATI"}
+	next.Start.Time = time.Date(2024, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	data, err := renderOgImage(testCourse(8009), &next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ogWidth, img.Bounds().Dx())
+	assert.Equal(t, ogHeight, img.Bounds().Dy())
+}
+
+func Test_ogImageNotFound(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	for _, p := range []string{"/og/1234.png", "/og/8009.jpg"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", p, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// openDataInfoPath contains the details of the last download of the open
+// data, see openDataInfo.
+var openDataInfoPath = path.Join(config.DataDir, "opendata.json")
+
+// openDataInfo describes the last downloaded open data file.
+type openDataInfo struct {
+	DownloadedAt time.Time   `json:"downloaded_at"`
+	Size         int         `json:"size"`
+	Checksum     string      `json:"checksum"`
+	Delta        courseDelta `json:"delta"`
+}
+
+// courseDelta contains the differences between two versions of the open
+// data.
+type courseDelta struct {
+	Added   []int          `json:"added"`
+	Removed []int          `json:"removed"`
+	Changed []courseChange `json:"changed"`
+}
+
+// courseChange lists the fields of a course that changed.
+type courseChange struct {
+	Code   int      `json:"code"`
+	Fields []string `json:"fields"`
+}
+
+// Empty reports whether the two versions contain the same courses.
+func (d courseDelta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// diffCourses compares the previous courses with the new ones.
+//
+// A nil previous means there were no courses.
+func diffCourses(previous *unibo_integ.CoursesMap, courses []unibo_integ.Course) courseDelta {
+	if previous == nil {
+		previous = unibo_integ.NewCoursesMap(nil)
+	}
+
+	delta := courseDelta{Added: []int{}, Removed: []int{}, Changed: []courseChange{}}
+
+	seen := make(map[int]bool, len(courses))
+	for _, c := range courses {
+		seen[c.Codice] = true
+
+		old, found := previous.FindById(c.Codice)
+		if !found {
+			delta.Added = append(delta.Added, c.Codice)
+			continue
+		}
+
+		if fields := changedFields(*old, c); len(fields) > 0 {
+			delta.Changed = append(delta.Changed, courseChange{Code: c.Codice, Fields: fields})
+		}
+	}
+
+	for _, c := range previous.ToList() {
+		if !seen[c.Codice] {
+			delta.Removed = append(delta.Removed, c.Codice)
+		}
+	}
+
+	slices.Sort(delta.Added)
+	slices.Sort(delta.Removed)
+	slices.SortFunc(delta.Changed, func(a, b courseChange) int { return a.Code - b.Code })
+	return delta
+}
+
+// changedFields returns the names of the fields which differ between the
+// two versions of the course.
+func changedFields(a, b unibo_integ.Course) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		if va.Field(i).Interface() != vb.Field(i).Interface() {
+			fields = append(fields, va.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
+
+func saveOpenDataInfo(info openDataInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(openDataInfoPath, data, 0o644)
+}
+
+func loadOpenDataInfo() (openDataInfo, error) {
+	var info openDataInfo
+	data, err := os.ReadFile(openDataInfoPath)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+// adminOpenData returns the details of the last open data download, with
+// the courses added, removed and changed by it.
+func adminOpenData(c *gin.Context) {
+	info, err := loadOpenDataInfo()
+	if os.IsNotExist(err) {
+		writeProblem(c, http.StatusNotFound, codeNotFound, "Open data not downloaded yet")
+		return
+	} else if err != nil {
+		_ = c.Error(err)
+		writeProblem(c, http.StatusInternalServerError, codeInternal, "Unable to read open data info")
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+func Test_diffCourses(t *testing.T) {
+	changed := testCourse(9254)
+	changed.Campus = "Forlì"
+	changed.DurataAnni = 3
+
+	delta := diffCourses(testCourses.Load(), []unibo_integ.Course{
+		changed,
+		{Codice: 1234, Descrizione: "FISICA"},
+	})
+	assert.Equal(t, []int{1234}, delta.Added)
+	assert.Equal(t, []int{8009}, delta.Removed)
+	assert.Equal(t, []courseChange{{Code: 9254, Fields: []string{"Campus", "DurataAnni"}}}, delta.Changed)
+	assert.Equal(t, false, delta.Empty())
+
+	assert.Equal(t, true, diffCourses(testCourses.Load(), testCourses.Load().ToList()).Empty())
+}
+
+func Test_validateCourses(t *testing.T) {
+	assert.Equal(t, nil, validateCourses(testCourses.Load().ToList()))
+	assert.NotEqual(t, nil, validateCourses(nil))
+	assert.NotEqual(t, nil, validateCourses([]unibo_integ.Course{testCourse(8009), testCourse(8009)}))
+	assert.NotEqual(t, nil, validateCourses([]unibo_integ.Course{{Codice: 1234, Descrizione: "FISICA"}}))
+}
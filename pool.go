@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// Pools used by the /cal hot path, to reduce allocations when many
+// subscriptions are refreshed at the same time.
+var (
+	bufferPool = sync.Pool{
+		New: func() any { return new(bytes.Buffer) },
+	}
+	eventsPool = sync.Pool{
+		New: func() any { return new(timetable.Timetable) },
+	}
+)
+
+// maxPooledBufferSize is the capacity above which buffers are not returned
+// to the pool, so a single huge calendar does not stay in memory forever.
+const maxPooledBufferSize = 4 * 1024 * 1024
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+func getEvents() *timetable.Timetable {
+	events := eventsPool.Get().(*timetable.Timetable)
+	*events = (*events)[:0]
+	return events
+}
+
+func putEvents(events *timetable.Timetable) {
+	// Drop the references to the events, so they can be garbage collected
+	clear(*events)
+	eventsPool.Put(events)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of the error responses of the API,
+// see RFC 7807.
+const problemContentType = "application/problem+json"
+
+// The machine-readable codes of the problems, in the "code" member.
+const (
+	codeInvalidId           = "invalid_id"
+	codeInvalidYear         = "invalid_year"
+	codeInvalidParameter    = "invalid_parameter"
+	codeCourseNotFound      = "course_not_found"
+	codeNotFound            = "not_found"
+	codeUpstreamUnavailable = "upstream_unavailable"
+	codeOverloaded          = "overloaded"
+	codeRateLimited         = "rate_limited"
+	codeInvalidApiKey       = "invalid_api_key"
+	codeMissingApiKey       = "missing_api_key"
+	codeUnauthorized        = "unauthorized"
+	codeInternal            = "internal_error"
+)
+
+// problem is an RFC 7807 problem details object.
+//
+// The type is always "about:blank", so the title is the HTTP status text and
+// clients should switch on Code instead.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Error is the same as Detail. It was the only member of the errors
+	// before problem details, and it's kept for the clients of /api/v1.
+	Error string `json:"error,omitempty"`
+}
+
+// writeProblem aborts the request with a problem details response.
+func writeProblem(c *gin.Context, status int, code string, detail string) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+		Error:    detail,
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxOfflineCourses is the number of course pages the service worker keeps
+// for offline use, the most recently viewed ones.
+const maxOfflineCourses = 5
+
+// webManifest returns the web app manifest, which makes the site installable
+// on phones.
+func webManifest() gin.H {
+	return gin.H{
+		"name":             "UniboCalendar",
+		"short_name":       "UniboCal",
+		"description":      "Calendari delle lezioni dei corsi dell'Università di Bologna",
+		"lang":             "it",
+		"start_url":        appUrl("/"),
+		"scope":            appUrl("/"),
+		"display":          "standalone",
+		"background_color": "#ffffff",
+		"theme_color":      "#bb2e29",
+		"icons": []gin.H{
+			{"src": appUrl("/icon.svg"), "sizes": "any", "type": "image/svg+xml", "purpose": "any"},
+		},
+	}
+}
+
+// appIcon is the icon of the installed app.
+const appIcon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">
+<rect width="64" height="64" rx="12" fill="#bb2e29"/>
+<rect x="12" y="16" width="40" height="36" rx="4" fill="#fff"/>
+<rect x="12" y="16" width="40" height="10" rx="4" fill="#7d1f1b"/>
+<g fill="#bb2e29"><rect x="18" y="31" width="8" height="6"/><rect x="28" y="31" width="8" height="6"/><rect x="38" y="31" width="8" height="6"/><rect x="18" y="41" width="8" height="6"/><rect x="28" y="41" width="8" height="6"/></g>
+</svg>`
+
+// serviceWorker keeps the course list and the last viewed course pages
+// available offline. Pages are fetched from the network first, so they are
+// never stale when online. CACHE_VERSION changes with the templates, which
+// drops the pages cached by the previous versions.
+const serviceWorker = `// Generated by the server, see pwa.go.
+const CACHE = "unibocalendar-CACHE_VERSION";
+const BASE = "APP_BASE";
+const PRECACHE = [BASE + "/", BASE + "/courses", BASE + "/static/style.css"];
+const MAX_COURSES = MAX_OFFLINE_COURSES;
+const OFFLINE = "<!doctype html><html lang=\"it\"><meta charset=\"utf-8\"><meta name=\"viewport\" content=\"width=device-width\">" +
+    "<title>UniboCalendar | Offline</title><body style=\"font-family:sans-serif;margin:2rem\">" +
+    "<h1>Sei offline</h1><p>Questa pagina non è disponibile senza connessione. " +
+    "<a href=\"" + BASE + "/courses\">Torna all'elenco dei corsi</a>.</p></body></html>";
+
+self.addEventListener("install", (event) => {
+    event.waitUntil(caches.open(CACHE).then((cache) => cache.addAll(PRECACHE)).then(() => self.skipWaiting()));
+});
+
+self.addEventListener("activate", (event) => {
+    event.waitUntil(caches.keys()
+        .then((keys) => Promise.all(keys.filter((k) => k.startsWith("unibocalendar-") && k !== CACHE).map((k) => caches.delete(k))))
+        .then(() => self.clients.claim()));
+});
+
+// The path of the URL without the base path of the app
+function appPath(url) {
+    const path = new URL(url).pathname;
+    return path.startsWith(BASE + "/") ? path.slice(BASE.length) : path;
+}
+
+// Keeps only the most recently viewed course pages
+async function trimCourses(cache) {
+    const courses = (await cache.keys()).filter((req) => /^\/courses\/\d+/.test(appPath(req.url)));
+    for (const req of courses.slice(0, Math.max(courses.length - MAX_COURSES, 0))) {
+        await cache.delete(req);
+    }
+}
+
+async function networkFirst(request) {
+    const cache = await caches.open(CACHE);
+    const path = appPath(request.url);
+    try {
+        const response = await fetch(request);
+        if (response.ok) {
+            if (/^\/courses\/\d+/.test(path)) {
+                // Moved last, so it's trimmed last
+                await cache.delete(request, {ignoreSearch: true});
+            }
+            await cache.put(request, response.clone());
+            await trimCourses(cache);
+        }
+        return response;
+    } catch (err) {
+        const cached = await cache.match(request, {ignoreSearch: path !== "/courses"});
+        if (cached) {
+            return cached;
+        }
+        if (request.mode === "navigate") {
+            return new Response(OFFLINE, {headers: {"Content-Type": "text/html; charset=utf-8"}});
+        }
+        throw err;
+    }
+}
+
+self.addEventListener("fetch", (event) => {
+    const url = new URL(event.request.url);
+    if (event.request.method !== "GET" || url.origin !== self.location.origin) {
+        return;
+    }
+    const path = appPath(event.request.url);
+    if (path === "/" || path === "/courses" || /^\/courses\/\d+$/.test(path) || path === "/static/style.css") {
+        event.respondWith(networkFirst(event.request));
+    }
+});
+`
+
+var (
+	serviceWorkerOnce   sync.Once
+	serviceWorkerScript string
+)
+
+// renderServiceWorker returns the service worker, with a cache version
+// derived from the embedded templates.
+func renderServiceWorker() string {
+	serviceWorkerOnce.Do(func() {
+		h := sha1.New()
+		_ = fs.WalkDir(templatesFS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := templatesFS.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			h.Write(data)
+			return nil
+		})
+		version := fmt.Sprintf("%x", h.Sum(nil))[:12]
+
+		serviceWorkerScript = strings.NewReplacer(
+			"CACHE_VERSION", version,
+			"MAX_OFFLINE_COURSES", fmt.Sprint(maxOfflineCourses),
+			"APP_BASE", config.BasePath,
+		).Replace(serviceWorker)
+	})
+	return serviceWorkerScript
+}
+
+func manifestHandler(c *gin.Context) {
+	c.Header("Content-Type", "application/manifest+json")
+	c.JSON(http.StatusOK, webManifest())
+}
+
+func iconHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/svg+xml", []byte(appIcon))
+}
+
+// serviceWorkerHandler serves the service worker from the root of the app, so
+// that its scope is the whole site.
+func serviceWorkerHandler(c *gin.Context) {
+	// Browsers must always check for a new version
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", []byte(renderServiceWorker()))
+}
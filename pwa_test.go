@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_manifest(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/manifest.webmanifest", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/manifest+json", w.Header().Get("Content-Type"))
+
+	var m map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/", m["start_url"])
+}
+
+func Test_serviceWorker(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/sw.js", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "CACHE_VERSION"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "const MAX_COURSES = 5;"))
+}
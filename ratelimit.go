@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// ApiKey is a key given to a heavy programmatic consumer (student apps,
+// bots), with its own quota.
+type ApiKey struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// Limit is the number of requests allowed every [Config.RateLimitWindow]
+	Limit int `json:"limit"`
+
+	used atomic.Int64
+}
+
+// id identifies the key in the rate limiter and in the logs without
+// revealing it: the first bytes of its hash.
+func (k *ApiKey) id() string {
+	sum := sha256.Sum256([]byte(k.Key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadApiKeys reads the API keys from the given JSON file. A missing file
+// means there are no keys.
+func loadApiKeys(file string) (map[string]*ApiKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*ApiKey{}, nil
+		}
+		return nil, fmt.Errorf("unable to read api keys: %w", err)
+	}
+
+	var keys []*ApiKey
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode api keys: %w", err)
+	}
+
+	m := make(map[string]*ApiKey, len(keys))
+	for _, k := range keys {
+		m[k.Key] = k
+	}
+	return m, nil
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// rateLimiter is a fixed window rate limiter.
+type rateLimiter struct {
+	window  time.Duration
+	mutex   sync.Mutex
+	clients map[string]*rateWindow
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	l := &rateLimiter{window: window, clients: make(map[string]*rateWindow)}
+	go l.cleanup()
+	return l
+}
+
+// Allow counts a request of the client, and reports whether it is within the
+// limit. It also returns the remaining requests and the end of the window.
+func (l *rateLimiter) Allow(client string, limit int) (bool, int, time.Time) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	w, found := l.clients[client]
+	if !found || now.After(w.reset) {
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.clients[client] = w
+	}
+
+	if w.count >= limit {
+		return false, 0, w.reset
+	}
+	w.count++
+	return true, limit - w.count, w.reset
+}
+
+// cleanup periodically removes the expired windows, so the map doesn't grow
+// with every client ever seen.
+func (l *rateLimiter) cleanup() {
+	for {
+		time.Sleep(l.window)
+
+		now := time.Now()
+		l.mutex.Lock()
+		for client, w := range l.clients {
+			if now.After(w.reset) {
+				delete(l.clients, client)
+			}
+		}
+		l.mutex.Unlock()
+	}
+}
+
+// apiKeyFromRequest returns the API key given in the X-API-Key header. It's
+// not read from the query, which ends up in the logs of the proxies and in
+// the history of the browsers.
+func apiKeyFromRequest(c *gin.Context) string {
+	return c.GetHeader("X-API-Key")
+}
+
+// rateLimit limits the requests of every client: requests with an API key
+// use the quota of the key, anonymous requests are limited by IP address
+// with [Config.AnonRateLimit].
+//
+// The X-RateLimit-* headers tell clients their quota, and 429 responses
+// include Retry-After so they can back off.
+func rateLimit(limiter *rateLimiter, keys map[string]*ApiKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The request that made it was already limited
+		if isSelfRequest(c) {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		limit := config.AnonRateLimit
+
+		if key := apiKeyFromRequest(c); key != "" {
+			apiKey, found := keys[key]
+			if !found {
+				writeProblem(c, http.StatusUnauthorized, codeInvalidApiKey, "Invalid API key")
+				return
+			}
+
+			apiKey.used.Add(1)
+			c.Set("apiKey", apiKey)
+			client = "key:" + apiKey.id()
+			limit = apiKey.Limit
+		}
+
+		// A non positive limit disables rate limiting
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		allowed, remaining, reset := limiter.Allow(client, limit)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !allowed {
+			log.Debug().Str("client", client).Msg("rate limit exceeded")
+
+			// Round up, so clients don't retry a moment too early
+			retryAfter := int(math.Ceil(time.Until(reset).Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			writeProblem(c, http.StatusTooManyRequests, codeRateLimited, "Too many requests")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// apiUsage returns the usage of the API key of the request.
+func apiUsage(c *gin.Context) {
+	value, found := c.Get("apiKey")
+	if !found {
+		writeProblem(c, http.StatusUnauthorized, codeMissingApiKey, "Missing API key")
+		return
+	}
+
+	apiKey := value.(*ApiKey)
+	c.JSON(http.StatusOK, gin.H{
+		"name":     apiKey.Name,
+		"limit":    apiKey.Limit,
+		"window":   config.RateLimitWindow.String(),
+		"requests": apiKey.used.Load(),
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_rateLimit(t *testing.T) {
+	keys := map[string]*ApiKey{"secret": {Key: "secret", Name: "bot", Limit: 3}}
+
+	r := gin.New()
+	r.GET("/", rateLimit(newRateLimiter(time.Minute), keys), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		w := request("secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+	}
+	assert.Equal(t, int64(3), keys["secret"].used.Load())
+
+	w := request("secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEqual(t, "", w.Header().Get("Retry-After"))
+
+	// Anonymous clients have their own quota
+	w = request("")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The key is read only from the header
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?api_key=secret", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(4), keys["secret"].used.Load())
+}
+
+func Test_ApiKey_id(t *testing.T) {
+	key := &ApiKey{Key: "secret"}
+	assert.Equal(t, 16, len(key.id()))
+	assert.Equal(t, false, strings.Contains(key.id(), "secret"))
+	assert.NotEqual(t, key.id(), (&ApiKey{Key: "other"}).id())
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// The course pages opened by a visitor are remembered in a cookie, so that
+// the homepage can list them: students come back to the same courses.
+const (
+	recentCookie = "recent_courses"
+	// maxRecentCourses is the number of courses remembered
+	maxRecentCourses = 5
+	recentTTL        = time.Hour * 24 * 180
+)
+
+// parseCourseCodes returns the course codes of a cookie value (e.g.
+// "8009.9254"), at most max, skipping the invalid and repeated ones.
+func parseCourseCodes(value string, max int) []int {
+	var codes []int
+	for _, raw := range strings.Split(value, ".") {
+		code, err := strconv.Atoi(raw)
+		if err != nil || code <= 0 || slices.Contains(codes, code) {
+			continue
+		}
+		codes = append(codes, code)
+		if len(codes) == max {
+			break
+		}
+	}
+	return codes
+}
+
+// cookieCourseCodes returns the course codes saved in the cookie of the
+// request.
+func cookieCourseCodes(c *gin.Context, name string, max int) []int {
+	value, err := c.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return parseCourseCodes(value, max)
+}
+
+// setCourseCodesCookie saves the course codes in the cookie, for the whole
+// app.
+func setCourseCodesCookie(c *gin.Context, name string, codes []int, ttl time.Duration) {
+	raw := make([]string, len(codes))
+	for i, code := range codes {
+		raw[i] = strconv.Itoa(code)
+	}
+
+	scheme, _ := requestOrigin(c)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, strings.Join(raw, "."), int(ttl.Seconds()), appUrl("/"), "", scheme == "https", true)
+}
+
+// findCourses returns the courses of the codes, skipping the ones not in
+// the open data anymore.
+func findCourses(courses *unibo_integ.CoursesMap, codes []int) []unibo_integ.Course {
+	var found []unibo_integ.Course
+	for _, code := range codes {
+		if course, ok := courses.FindById(code); ok {
+			found = append(found, *course)
+		}
+	}
+	return found
+}
+
+// addRecent moves the course to the front of the codes, dropping the oldest
+// ones beyond maxRecentCourses.
+func addRecent(codes []int, code int) []int {
+	codes = slices.DeleteFunc(slices.Clone(codes), func(c int) bool { return c == code })
+	codes = slices.Insert(codes, 0, code)
+	if len(codes) > maxRecentCourses {
+		codes = codes[:maxRecentCourses]
+	}
+	return codes
+}
+
+// rememberCourse saves the course as the most recently viewed.
+func rememberCourse(c *gin.Context, code int) {
+	codes := addRecent(cookieCourseCodes(c, recentCookie, maxRecentCourses), code)
+	setCourseCodesCookie(c, recentCookie, codes, recentTTL)
+}
+
+// recentCourses returns the courses recently viewed by the visitor, the
+// most recent first.
+func recentCourses(c *gin.Context, courses *unibo_integ.CoursesMap) []unibo_integ.Course {
+	return findCourses(courses, cookieCourseCodes(c, recentCookie, maxRecentCourses))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseCourseCodes(t *testing.T) {
+	assert.Equal(t, []int(nil), parseCourseCodes("", maxRecentCourses))
+	assert.Equal(t, []int{8009, 9254}, parseCourseCodes("8009.x.9254.8009.-1", maxRecentCourses))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, parseCourseCodes("1.2.3.4.5.6", maxRecentCourses))
+}
+
+func Test_addRecent(t *testing.T) {
+	assert.Equal(t, []int{8009}, addRecent(nil, 8009))
+	assert.Equal(t, []int{9254, 8009}, addRecent([]int{8009, 9254}, 9254))
+	assert.Equal(t, []int{6, 1, 2, 3, 4}, addRecent([]int{1, 2, 3, 4, 5}, 6))
+}
+
+func Test_recentCourses(t *testing.T) {
+	r := setupRouter(testCourses)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: recentCookie, Value: "9254.1234"})
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "Visti di recente"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `href="/courses/9254">INGEGNERIA INFORMATICA</a>`))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// timetableUrl is an official Unibo timetable URL, such as
+// https://corsi.unibo.it/laurea/IngegneriaInformatica/orario-lezioni?anno=2
+type timetableUrl struct {
+	WebsiteId  unibo_integ.CourseId
+	Year       int
+	Curriculum string
+}
+
+// parseTimetableUrl parses an official Unibo timetable URL.
+//
+// The scheme can be omitted, and a missing year defaults to the first one.
+func parseTimetableUrl(raw string) (timetableUrl, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return timetableUrl{}, fmt.Errorf("invalid url: %w", err)
+	}
+
+	if u.Hostname() != "corsi.unibo.it" {
+		return timetableUrl{}, fmt.Errorf("not a corsi.unibo.it url")
+	}
+
+	// /laurea/IngegneriaInformatica/orario-lezioni -> [laurea IngegneriaInformatica orario-lezioni]
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 3 || (segments[2] != "orario-lezioni" && segments[2] != "timetable") {
+		return timetableUrl{}, fmt.Errorf("not a timetable url")
+	}
+
+	year := 1
+	if anno := u.Query().Get("anno"); anno != "" {
+		year, err = strconv.Atoi(anno)
+		if err != nil {
+			return timetableUrl{}, fmt.Errorf("invalid year: %w", err)
+		}
+	}
+
+	return timetableUrl{
+		WebsiteId:  unibo_integ.CourseId{Tipologia: segments[0], Id: segments[1]},
+		Year:       year,
+		Curriculum: u.Query().Get("curricula"),
+	}, nil
+}
+
+// maxResolveScrapes is the maximum number of course websites scraped to
+// resolve a timetable URL.
+const maxResolveScrapes = 10
+
+// nameStopwords are the words ignored when comparing the names of the
+// courses with the website ids, which often omit them.
+var nameStopwords = []string{
+	"e", "ed", "di", "del", "dell", "della", "delle", "dei", "degli", "in", "per", "a", "al", "all", "nel", "nell",
+	"la", "le", "il", "lo", "gli", "and", "of", "the", "for", "to",
+}
+
+// nameWords returns the words of a course name, lowercase and without
+// accents and stopwords.
+func nameWords(name string) []string {
+	name = accentReplacer.Replace(strings.ToLower(name))
+	var words []string
+	for _, w := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if !slices.Contains(nameStopwords, w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// websiteIdWords returns the words of a website id, split at the
+// capital letters: "IngegneriaInformatica" is "ingegneria informatica", and
+// "ICTEngineering" is "ict engineering".
+func websiteIdWords(id string) []string {
+	runes := []rune(id)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return nameWords(b.String())
+}
+
+// findCourseByWebsiteId finds the course with the given website id.
+//
+// Courses whose website id has already been scraped are checked first. Then,
+// only the courses whose name contains every word of the website id (e.g.
+// "INGEGNERIA INFORMATICA" for "IngegneriaInformatica") are scraped, so that
+// a single request does not scrape the whole university. The scrapes share
+// the limit of the requests to Unibo, and stop if ctx is done.
+//
+// If no course is found, the candidates whose website couldn't be checked
+// are returned, the ones with the same words of the website id first: the
+// user can choose among them.
+func findCourseByWebsiteId(ctx context.Context, courses *unibo_integ.CoursesMap, id unibo_integ.CourseId) (*unibo_integ.Course, []*unibo_integ.Course) {
+	for _, course := range courses.ToList() {
+		websiteId, found := unibo_integ.Unibo.CachedWebsiteId(course.Codice)
+		if found && websiteId == id {
+			c, _ := courses.FindById(course.Codice)
+			return c, nil
+		}
+	}
+
+	wanted := websiteIdWords(id.Id)
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	var exact, partial []*unibo_integ.Course
+	for _, course := range courses.ToList() {
+		if _, found := unibo_integ.Unibo.CachedWebsiteId(course.Codice); found {
+			continue
+		}
+		words := nameWords(course.Descrizione)
+		if !containsWords(words, wanted) {
+			continue
+		}
+		c, _ := courses.FindById(course.Codice)
+		if len(words) == len(wanted) {
+			exact = append(exact, c)
+		} else {
+			partial = append(partial, c)
+		}
+	}
+
+	var unverified []*unibo_integ.Course
+	for i, course := range append(exact, partial...) {
+		if i >= maxResolveScrapes || ctx.Err() != nil {
+			unverified = append(unverified, course)
+			continue
+		}
+		websiteId, err := withUpstreamSlot(ctx, func(ctx context.Context) (unibo_integ.CourseId, error) {
+			return unibo_integ.Unibo.WebsiteId(ctx, *course)
+		})
+		if err != nil {
+			log.Warn().Err(err).Int("course-code", course.Codice).Msg("unable to scrape course website id")
+			unverified = append(unverified, course)
+			continue
+		}
+		if websiteId == id {
+			return course, nil
+		}
+	}
+	return nil, unverified
+}
+
+// containsWords reports whether words contains every wanted word.
+func containsWords(words []string, wanted []string) bool {
+	for _, w := range wanted {
+		if !slices.Contains(words, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// timetableLocation returns the path of the calendar of the timetable URL
+// for the course.
+func timetableLocation(course *unibo_integ.Course, parsed timetableUrl) string {
+	location := fmt.Sprintf("/cal/%d/%d", course.Codice, parsed.Year)
+	if parsed.Curriculum != "" {
+		location += "?" + url.Values{"curr": {parsed.Curriculum}}.Encode()
+	}
+	return appUrl(location)
+}
+
+// resolveTimetableUrl redirects an official Unibo timetable URL (passed in
+// the "url" query parameter) to the corresponding calendar.
+func resolveTimetableUrl(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		parsed, err := parseTimetableUrl(ctx.Query("url"))
+		if err != nil {
+			errorPage(ctx, http.StatusBadRequest, fmt.Sprintf("Indirizzo dell'orario non valido: %s", err))
+			return
+		}
+
+		course, candidates := findCourseByWebsiteId(ctx.Request.Context(), courses.Load(), parsed.WebsiteId)
+		if course == nil && len(candidates) == 0 {
+			errorPage(ctx, http.StatusNotFound, "Corso non trovato")
+			return
+		} else if course == nil {
+			candidatesPage(ctx, candidates, parsed)
+			return
+		}
+
+		if parsed.Year <= 0 || parsed.Year > course.DurataAnni {
+			yearNotFoundPage(ctx, course, strconv.Itoa(parsed.Year))
+			return
+		}
+
+		ctx.Redirect(http.StatusFound, timetableLocation(course, parsed))
+	}
+}
+
+// resolveCandidate is a course that could have the timetable URL.
+type resolveCandidate struct {
+	Course *unibo_integ.Course
+	// Path is the calendar of the year of the URL, empty if the course
+	// doesn't have it
+	Path string
+}
+
+// candidatesPage lets the user choose the course of the timetable URL among
+// the candidates, with status 300.
+func candidatesPage(ctx *gin.Context, courses []*unibo_integ.Course, parsed timetableUrl) {
+	candidates := make([]resolveCandidate, 0, len(courses))
+	for _, course := range courses {
+		c := resolveCandidate{Course: course}
+		if parsed.Year > 0 && parsed.Year <= course.DurataAnni {
+			c.Path = timetableLocation(course, parsed)
+		}
+		candidates = append(candidates, c)
+	}
+
+	ctx.Abort()
+	renderHTML(ctx, http.StatusMultipleChoices, "error", gin.H{
+		"status":     http.StatusMultipleChoices,
+		"message":    "Non è stato possibile verificare il corso dell'indirizzo, scegli il tuo",
+		"candidates": candidates,
+	})
+}
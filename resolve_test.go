@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+func Test_parseTimetableUrl(t *testing.T) {
+	parsed, err := parseTimetableUrl("https://corsi.unibo.it/laurea/IngegneriaInformatica/orario-lezioni?anno=2&curricula=A58-000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, unibo_integ.CourseId{Tipologia: "laurea", Id: "IngegneriaInformatica"}, parsed.WebsiteId)
+	assert.Equal(t, 2, parsed.Year)
+	assert.Equal(t, "A58-000", parsed.Curriculum)
+
+	parsed, err = parseTimetableUrl("corsi.unibo.it/2cycle/ComputerScience/timetable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "2cycle", parsed.WebsiteId.Tipologia)
+	assert.Equal(t, 1, parsed.Year)
+
+	_, err = parseTimetableUrl("https://www.unibo.it/laurea/IngegneriaInformatica/orario-lezioni")
+	assert.NotEqual(t, nil, err)
+
+	_, err = parseTimetableUrl("https://corsi.unibo.it/laurea/IngegneriaInformatica")
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_websiteIdWords(t *testing.T) {
+	assert.Equal(t, []string{"ingegneria", "informatica"}, websiteIdWords("IngegneriaInformatica"))
+	assert.Equal(t, []string{"ict", "engineering"}, websiteIdWords("ICTEngineering"))
+	assert.Equal(t, []string{"scienze", "comunicazione"}, websiteIdWords("ScienzeDellaComunicazione"))
+	assert.Equal(t, []string{"ingegneria", "scienze", "informatiche"}, nameWords("INGEGNERIA E SCIENZE INFORMATICHE"))
+	assert.Equal(t, []string{"attivita", "motorie"}, nameWords("Attività Motorie"))
+}
+
+// resolveTestCourses are courses with no website, so they can't be scraped.
+var resolveTestCourses = []unibo_integ.Course{
+	{Codice: 990001, Descrizione: "INFORMATICA", Tipologia: "Laurea", Campus: "Bologna", DurataAnni: 3},
+	{Codice: 990002, Descrizione: "INGEGNERIA INFORMATICA", Tipologia: "Laurea", Campus: "Cesena", DurataAnni: 3},
+	{Codice: 990003, Descrizione: "INFORMATICA PER IL MANAGEMENT", Tipologia: "Laurea", Campus: "Bologna", DurataAnni: 3},
+	{Codice: 990004, Descrizione: "BIOINFORMATICA", Tipologia: "Laurea Magistrale", Campus: "Bologna", DurataAnni: 2},
+}
+
+func Test_findCourseByWebsiteId(t *testing.T) {
+	unibo_integ.Unibo.RememberWebsiteId(990002, unibo_integ.CourseId{Tipologia: "laurea", Id: "IngegneriaInformatica"})
+	t.Cleanup(func() { unibo_integ.Unibo.ForgetWebsiteId(990002) })
+	courses := unibo_integ.NewCoursesMap(resolveTestCourses)
+
+	course, candidates := findCourseByWebsiteId(context.Background(), courses, unibo_integ.CourseId{Tipologia: "laurea", Id: "IngegneriaInformatica"})
+	assert.Equal(t, 990002, course.Codice)
+	assert.Equal(t, 0, len(candidates))
+
+	// The exact match first, and not BIOINFORMATICA
+	course, candidates = findCourseByWebsiteId(context.Background(), courses, unibo_integ.CourseId{Tipologia: "laurea", Id: "Informatica"})
+	assert.Equal(t, (*unibo_integ.Course)(nil), course)
+	assert.Equal(t, 2, len(candidates))
+	assert.Equal(t, 990001, candidates[0].Codice)
+	assert.Equal(t, 990003, candidates[1].Codice)
+
+	course, candidates = findCourseByWebsiteId(context.Background(), courses, unibo_integ.CourseId{Tipologia: "laurea", Id: "Fisica"})
+	assert.Equal(t, (*unibo_integ.Course)(nil), course)
+	assert.Equal(t, 0, len(candidates))
+}
+
+func Test_resolveTimetableUrl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.HTMLRender = createMyRender()
+	r.GET("/resolve", resolveTimetableUrl(unibo_integ.NewCourses(unibo_integ.NewCoursesMap(resolveTestCourses))))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resolve?url=corsi.unibo.it/laurea/Informatica/orario-lezioni?anno=3", nil))
+	assert.Equal(t, http.StatusMultipleChoices, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `href="/cal/990001/3"`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `href="/cal/990003/3"`))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resolve?url=corsi.unibo.it/laurea/Fisica/orario-lezioni", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
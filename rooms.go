@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+)
+
+// roomLesson is a lesson taking place in a room.
+type roomLesson struct {
+	// Building is the slug of the building name, used in the URLs
+	Building     string
+	BuildingName string
+	Room         string
+	Floor        string
+	Start, End   time.Time
+	Title        string
+	Teacher      string
+	Feed         feedRef
+}
+
+// roomIndex contains the lessons of every room, from the timetables
+// fetched so far.
+type roomIndex struct {
+	mutex sync.RWMutex
+	feeds map[feedRef][]roomLesson
+}
+
+var rooms = &roomIndex{feeds: map[feedRef][]roomLesson{}}
+
+// index replaces the lessons of the feed with the ones of the timetable.
+func (ri *roomIndex) index(feed feedRef, t timetable.Timetable) {
+	var lessons []roomLesson
+	for _, e := range t {
+		for _, c := range e.Classrooms {
+			room := unibo.NewRoom(c)
+			slug := slugify(room.Building)
+			if slug == "" || room.Name == "" {
+				continue
+			}
+
+			lessons = append(lessons, roomLesson{
+				Building:     slug,
+				BuildingName: room.Building,
+				Room:         room.Name,
+				Floor:        room.Floor,
+				Start:        e.Start.Time,
+				End:          e.End.Time,
+				Title:        e.Title,
+				Teacher:      e.Teacher,
+				Feed:         feed,
+			})
+		}
+	}
+
+	ri.mutex.Lock()
+	defer ri.mutex.Unlock()
+	ri.feeds[feed] = lessons
+}
+
+// forget removes the lessons of the feed.
+func (ri *roomIndex) forget(feed feedRef) {
+	ri.mutex.Lock()
+	defer ri.mutex.Unlock()
+	delete(ri.feeds, feed)
+}
+
+// occupancy returns the lessons in the building on the given day, sorted by
+// room and start. The same lesson can be in more feeds (e.g. shared
+// between courses), so duplicates are removed.
+func (ri *roomIndex) occupancy(building string, day time.Time) []roomLesson {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	ri.mutex.RLock()
+	defer ri.mutex.RUnlock()
+
+	type lessonKey struct {
+		room  string
+		start time.Time
+		title string
+	}
+	seen := map[lessonKey]bool{}
+
+	var lessons []roomLesson
+	for _, feedLessons := range ri.feeds {
+		for _, l := range feedLessons {
+			if l.Building != building || !l.Start.Before(end) || !l.End.After(start) {
+				continue
+			}
+			key := lessonKey{l.Room, l.Start, l.Title}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			lessons = append(lessons, l)
+		}
+	}
+
+	slices.SortFunc(lessons, func(a, b roomLesson) int {
+		if c := strings.Compare(a.Room, b.Room); c != 0 {
+			return c
+		}
+		return a.Start.Compare(b.Start)
+	})
+	return lessons
+}
+
+type apiRoomLesson struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Title   string    `json:"title"`
+	Teacher string    `json:"teacher"`
+	Course  int       `json:"course"`
+	Year    int       `json:"year"`
+}
+
+type apiRoom struct {
+	Name    string          `json:"name"`
+	Floor   string          `json:"floor,omitempty"`
+	Lessons []apiRoomLesson `json:"lessons"`
+}
+
+type apiOccupancy struct {
+	Building string    `json:"building"`
+	Name     string    `json:"name"`
+	Date     string    `json:"date"`
+	Rooms    []apiRoom `json:"rooms"`
+}
+
+func newApiOccupancy(building string, day time.Time, lessons []roomLesson) apiOccupancy {
+	res := apiOccupancy{Building: building, Date: day.Format(time.DateOnly), Rooms: []apiRoom{}}
+	for _, l := range lessons {
+		res.Name = l.BuildingName
+		if len(res.Rooms) == 0 || res.Rooms[len(res.Rooms)-1].Name != l.Room {
+			res.Rooms = append(res.Rooms, apiRoom{Name: l.Room, Floor: l.Floor})
+		}
+		room := &res.Rooms[len(res.Rooms)-1]
+		room.Lessons = append(room.Lessons, apiRoomLesson{
+			Start:   l.Start,
+			End:     l.End,
+			Title:   l.Title,
+			Teacher: l.Teacher,
+			Course:  l.Feed.Course,
+			Year:    l.Feed.Year,
+		})
+	}
+	return res
+}
+
+// apiRoomOccupancy returns the lessons in the rooms of a building on the
+// day given by ?date= (default today).
+func apiRoomOccupancy(c *gin.Context) {
+	day := time.Now().In(romeLocation)
+	if date := c.Query("date"); date != "" {
+		var err error
+		day, err = time.ParseInLocation(time.DateOnly, date, romeLocation)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	building := slugify(c.Param("building"))
+	c.JSON(http.StatusOK, newApiOccupancy(building, day, rooms.occupancy(building, day)))
+}
+
+type apiBuilding struct {
+	Building string `json:"building"`
+	Name     string `json:"name"`
+	Rooms    int    `json:"rooms"`
+}
+
+// buildings returns the buildings with at least a lesson, sorted by name.
+func (ri *roomIndex) buildings() []apiBuilding {
+	ri.mutex.RLock()
+	defer ri.mutex.RUnlock()
+
+	names := map[string]string{}
+	roomsOf := map[string]map[string]bool{}
+	for _, feedLessons := range ri.feeds {
+		for _, l := range feedLessons {
+			names[l.Building] = l.BuildingName
+			if roomsOf[l.Building] == nil {
+				roomsOf[l.Building] = map[string]bool{}
+			}
+			roomsOf[l.Building][l.Room] = true
+		}
+	}
+
+	res := make([]apiBuilding, 0, len(names))
+	for slug, name := range names {
+		res = append(res, apiBuilding{Building: slug, Name: name, Rooms: len(roomsOf[slug])})
+	}
+	slices.SortFunc(res, func(a, b apiBuilding) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+func apiBuildings(c *gin.Context) {
+	c.JSON(http.StatusOK, rooms.buildings())
+}
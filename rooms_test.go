@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_roomIndex(t *testing.T) {
+	tt := testTimetable()
+	tt[0].Classrooms = []timetable.Classroom{{ResourceDesc: "AULA 1", BuildingDesc: "Plesso Ercolani"}}
+	tt[1].Classrooms = []timetable.Classroom{{ResourceDesc: "AULA 1", BuildingDesc: "Plesso Ercolani"}}
+
+	ri := &roomIndex{feeds: map[feedRef][]roomLesson{}}
+	ri.index(feedRef{Course: 8009, Year: 1}, tt)
+	// The same lessons, shared with another course
+	ri.index(feedRef{Course: 9254, Year: 1}, tt[:1])
+
+	day := tt[0].Start.Time
+	lessons := ri.occupancy("plesso-ercolani", day)
+	assert.Equal(t, 2, len(lessons))
+	assert.Equal(t, "ALGEBRA", lessons[0].Title)
+
+	assert.Equal(t, 0, len(ri.occupancy("plesso-ercolani", day.AddDate(0, 0, 1))))
+
+	occupancy := newApiOccupancy("plesso-ercolani", day, lessons)
+	assert.Equal(t, "Plesso Ercolani", occupancy.Name)
+	assert.Equal(t, 1, len(occupancy.Rooms))
+	assert.Equal(t, 2, len(occupancy.Rooms[0].Lessons))
+	assert.Equal(t, "2024-10-01", occupancy.Date)
+
+	assert.Equal(t, []apiBuilding{{Building: "plesso-ercolani", Name: "Plesso Ercolani", Rooms: 1}}, ri.buildings())
+}
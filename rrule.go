@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// flagRruleCompression is the feature flag emitting the weekly lessons as
+// recurring events, see addRecurringLessons. ?rrule= overrides it.
+const flagRruleCompression = "rrule_compression"
+
+// maxSkippedWeeks is the longest break of a recurring lesson: after a longer
+// one (e.g. between the semesters) a new series starts, rather than listing
+// every skipped week.
+const maxSkippedWeeks = 3
+
+const icsUtcLayout = "20060102T150405Z"
+
+// lessonSeries is a lesson repeated every week: the same module and teacher,
+// on the same weekday at the same time.
+type lessonSeries struct {
+	// Lessons are the occurrences, sorted by start
+	Lessons []timetable.Event
+	// Skipped are the starts of the weeks without the lesson
+	Skipped []time.Time
+}
+
+// seriesKey returns the key of the series of the lesson. The times are the
+// local ones of Europe/Rome, so a series continues across the DST switches:
+// its start has a TZID, see setRomeTimes.
+func seriesKey(e timetable.Event) string {
+	start, end := e.Start.In(romeLocation), e.End.In(romeLocation)
+	return fmt.Sprintf("%s|%s|%s|%d|%s|%s",
+		e.CodModulo, e.Title, e.Teacher, start.Weekday(), start.Format("15:04"), end.Format("15:04"))
+}
+
+// groupLessonSeries groups the lessons of the timetable in weekly series, sorted
+// by the start of their first lesson.
+func groupLessonSeries(t timetable.Timetable) []lessonSeries {
+	sorted := slices.Clone(t)
+	slices.SortStableFunc(sorted, func(a, b timetable.Event) int {
+		return a.Start.Compare(b.Start.Time)
+	})
+
+	var series []lessonSeries
+	// open are the indexes in series of the last series of every key, the
+	// only ones that can still be extended
+	open := map[string]int{}
+	for _, e := range sorted {
+		key := seriesKey(e)
+		if i, found := open[key]; found {
+			s := &series[i]
+			last := s.Lessons[len(s.Lessons)-1].Start.Time
+			weeks := daysBetween(last, e.Start.Time) / 7
+			if weeks >= 1 && weeks-1 <= maxSkippedWeeks {
+				for w := 1; w < weeks; w++ {
+					s.Skipped = append(s.Skipped, last.In(romeLocation).AddDate(0, 0, 7*w))
+				}
+				s.Lessons = append(s.Lessons, e)
+				continue
+			}
+		}
+		open[key] = len(series)
+		series = append(series, lessonSeries{Lessons: []timetable.Event{e}})
+	}
+	return series
+}
+
+// usualRoom returns the room of most lessons of the series, the first one
+// in case of a tie.
+func (s lessonSeries) usualRoom() string {
+	counts := map[string]int{}
+	room := eventRoom(s.Lessons[0])
+	for _, e := range s.Lessons {
+		r := eventRoom(e)
+		counts[r]++
+		if counts[r] > counts[room] {
+			room = r
+		}
+	}
+	return room
+}
+
+// addRecurringLessons adds the lessons to the calendar as weekly recurring
+// events, which keeps big feeds small. The skipped weeks are EXDATEs and the
+// lessons in an unusual room are overriding events (with a RECURRENCE-ID),
+// so the calendar is as accurate as the one with an event per lesson.
+func addRecurringLessons(cal *ics.Calendar, t timetable.Timetable, opts calOptions) {
+	for _, s := range groupLessonSeries(t) {
+		first := s.Lessons[0]
+		uid := eventUid(first)
+		if len(s.Lessons) == 1 {
+			addLessonEvent(cal, uid, first, opts)
+			continue
+		}
+
+		room := s.usualRoom()
+		master := first
+		for _, e := range s.Lessons {
+			if eventRoom(e) == room {
+				master.Classrooms = e.Classrooms
+				break
+			}
+		}
+
+		last := s.Lessons[len(s.Lessons)-1]
+		e := addLessonEvent(cal, uid, master, opts)
+		e.AddRrule("FREQ=WEEKLY;UNTIL=" + last.Start.UTC().Format(icsUtcLayout))
+		for _, skipped := range s.Skipped {
+			e.AddExdate(romeTimeValue(skipped), withRomeTzid())
+		}
+
+		for _, lesson := range s.Lessons {
+			if eventRoom(lesson) == room {
+				continue
+			}
+			o := addLessonEvent(cal, uid, lesson, opts)
+			o.SetProperty(ics.ComponentProperty(ics.PropertyRecurrenceId), romeTimeValue(lesson.Start.Time), withRomeTzid())
+		}
+	}
+}
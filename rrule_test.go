@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+// recurringTimetable returns the lessons of testTimetable, with ALGEBRA
+// repeated on the following Tuesdays: skipped on the 15th, in another room
+// on the 22nd, and after the end of the summer time on the 29th.
+func recurringTimetable() timetable.Timetable {
+	tt := testTimetable()
+	algebra := tt[0]
+	for _, days := range []int{7, 21, 28} {
+		e := algebra
+		e.Start = timetable.CalendarTime{Time: algebra.Start.AddDate(0, 0, days)}
+		e.End = timetable.CalendarTime{Time: algebra.End.AddDate(0, 0, days)}
+		if days == 21 {
+			e.Classrooms = []timetable.Classroom{{ResourceDesc: "AULA 2"}}
+		}
+		tt = append(tt, e)
+	}
+	return tt
+}
+
+func Test_groupLessonSeries(t *testing.T) {
+	series := groupLessonSeries(recurringTimetable())
+	assert.Equal(t, 2, len(series))
+
+	// The series continues after the end of the summer time, since the
+	// local time is the same
+	algebra := series[0]
+	assert.Equal(t, 4, len(algebra.Lessons))
+	assert.Equal(t, 29, algebra.Lessons[3].Start.Day())
+	assert.Equal(t, 1, len(algebra.Skipped))
+	assert.Equal(t, 15, algebra.Skipped[0].Day())
+	assert.Equal(t, 9, algebra.Skipped[0].Hour())
+	assert.Equal(t, "AULA 1", algebra.usualRoom())
+
+	assert.Equal(t, "00002_1", series[1].Lessons[0].CodModulo)
+
+	// A long break starts a new series
+	tt := testTimetable()[:1]
+	later := tt[0]
+	later.Start = timetable.CalendarTime{Time: tt[0].Start.Add(5 * 7 * 24 * time.Hour)}
+	later.End = timetable.CalendarTime{Time: tt[0].End.Add(5 * 7 * 24 * time.Hour)}
+	assert.Equal(t, 2, len(groupLessonSeries(append(tt, later))))
+}
+
+func Test_createEventsCal_recurring(t *testing.T) {
+	cal, err := createEventsCal(recurringTimetable(), calOptions{Recurring: true, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := cal.Serialize()
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+	assert.Equal(t, 3, strings.Count(data, "BEGIN:VEVENT"))
+	assert.Equal(t, 1, strings.Count(data, "FREQ=WEEKLY;"))
+	// UNTIL is in UTC, the other times are local
+	assert.Equal(t, true, strings.Contains(data, "RRULE:FREQ=WEEKLY;UNTIL=20241029T080000Z\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "DTSTART;TZID=Europe/Rome:20241001T090000\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "EXDATE;TZID=Europe/Rome:20241015T090000\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "RECURRENCE-ID;TZID=Europe/Rome:20241022T090000\r\n"))
+
+	// The overriding event has the same UID as the recurring one
+	uid := "UID:" + eventUid(recurringTimetable()[0]) + "\r\n"
+	assert.Equal(t, 2, strings.Count(data, uid))
+}
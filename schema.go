@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/rs/zerolog/log"
+)
+
+// sourceTimetableSchema tracks, on the status page, whether the timetables
+// returned by Unibo still have the expected format.
+const sourceTimetableSchema = "timetable_schema"
+
+// maxMissingFieldRatio is the fraction of events missing a required field
+// above which the upstream format is considered changed.
+const maxMissingFieldRatio = 0.5
+
+// minDroppedEvents is the number of events a timetable must have had before
+// becoming empty for the drop to be reported.
+const minDroppedEvents = 20
+
+var errSchemaChanged = errors.New("the format of the upstream timetable changed")
+
+// timetableFields are the fields the calendars can't be generated without.
+var timetableFields = []struct {
+	name    string
+	missing func(e timetable.Event) bool
+}{
+	{"cod_modulo", func(e timetable.Event) bool { return e.CodModulo == "" }},
+	{"title", func(e timetable.Event) bool { return e.Title == "" }},
+	{"start", func(e timetable.Event) bool { return e.Start.IsZero() }},
+	{"end", func(e timetable.Event) bool { return e.End.IsZero() || !e.End.After(e.Start.Time) }},
+}
+
+// checkTimetableSchema returns errSchemaChanged if most events of the
+// timetable are missing one of the required fields, which happens when the
+// upstream renames or moves them.
+func checkTimetableSchema(t timetable.Timetable) error {
+	if len(t) == 0 {
+		return nil
+	}
+
+	var broken []string
+	for _, field := range timetableFields {
+		missing := 0
+		for _, e := range t {
+			if field.missing(e) {
+				missing++
+			}
+		}
+		if float64(missing)/float64(len(t)) > maxMissingFieldRatio {
+			broken = append(broken, field.name)
+		}
+	}
+
+	if len(broken) > 0 {
+		return fmt.Errorf("%w: %d events, missing %s", errSchemaChanged, len(t), strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+// eventCounts are the number of events of the last fetch of every feed.
+var eventCounts = struct {
+	sync.Mutex
+	counts map[string]int
+}{counts: map[string]int{}}
+
+// checkEventCount reports whether a feed which had many events became
+// empty, which is suspicious but may also happen at the end of the
+// lessons, so it's only reported.
+func checkEventCount(key string, count int) bool {
+	eventCounts.Lock()
+	defer eventCounts.Unlock()
+
+	previous, found := eventCounts.counts[key]
+	eventCounts.counts[key] = count
+	return found && previous >= minDroppedEvents && count == 0
+}
+
+// validateTimetable checks the format of a timetable just fetched for the
+// feed, alerting the operator if it changed. An error means the
+// timetable must not be used.
+func validateTimetable(key string, t timetable.Timetable) error {
+	err := checkTimetableSchema(t)
+	upstream.record(sourceTimetableSchema, err)
+	if err != nil {
+		log.Error().Err(err).Str("feed", key).Str("alert", "schema_drift").Msg("upstream timetable format changed")
+		return err
+	}
+
+	if checkEventCount(key, len(t)) {
+		log.Warn().Str("feed", key).Str("alert", "events_dropped").Msg("upstream timetable became empty")
+	}
+	return nil
+}
+
+// lastGoodTimetable returns the most recent snapshot of the feed, to be
+// used when the upstream returns a timetable in an unknown format.
+func lastGoodTimetable(courseId int, year int, curriculum string) (timetable.Timetable, bool) {
+	t, found, err := loadSnapshot(courseId, year, curriculum, time.Now())
+	if err != nil {
+		log.Warn().Err(err).Int("course-code", courseId).Msg("unable to load last timetable snapshot")
+		return nil, false
+	}
+	return t, found
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_checkTimetableSchema(t *testing.T) {
+	assert.Equal(t, nil, checkTimetableSchema(testTimetable()))
+	assert.Equal(t, nil, checkTimetableSchema(nil))
+
+	// e.g. the upstream renamed "title" and "start"
+	renamed := testTimetable()
+	for i := range renamed {
+		renamed[i].Title = ""
+		renamed[i].Start = timetable.CalendarTime{}
+	}
+	err := checkTimetableSchema(renamed)
+	assert.Equal(t, true, errors.Is(err, errSchemaChanged))
+	assert.Equal(t, "the format of the upstream timetable changed: 2 events, missing title, start", err.Error())
+}
+
+func Test_checkEventCount(t *testing.T) {
+	assert.Equal(t, false, checkEventCount("test-1", 0))
+	assert.Equal(t, false, checkEventCount("test-1", 50))
+	assert.Equal(t, true, checkEventCount("test-1", 0))
+	assert.Equal(t, false, checkEventCount("test-1", 0))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// CourseGroup is a named group of degree programmes, e.g. all the courses of
+// a school.
+type CourseGroup struct {
+	Name    string
+	Slug    string
+	Courses []unibo_integ.Course
+}
+
+// groupBySchool groups the (already sorted) courses by their school/department
+// (the "Ambiti" field of the open data).
+//
+// The returned schools are sorted by name, while the order of the courses
+// inside each school is preserved.
+func groupBySchool(courses []unibo_integ.Course) []CourseGroup {
+	return groupCourses(courses, func(c unibo_integ.Course) string { return c.Ambiti })
+}
+
+// groupCourses groups the courses by the value returned by key. Courses with
+// an empty key are skipped.
+func groupCourses(courses []unibo_integ.Course, key func(c unibo_integ.Course) string) []CourseGroup {
+	indexes := make(map[string]int)
+	var groups []CourseGroup
+
+	for _, course := range courses {
+		name := strings.TrimSpace(key(course))
+		if name == "" {
+			continue
+		}
+
+		i, found := indexes[name]
+		if !found {
+			i = len(groups)
+			indexes[name] = i
+			groups = append(groups, CourseGroup{Name: name, Slug: slugify(name)})
+		}
+		groups[i].Courses = append(groups[i].Courses, course)
+	}
+
+	slices.SortFunc(groups, func(a, b CourseGroup) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return groups
+}
+
+// groupByCampus groups the (already sorted) courses by their campus.
+func groupByCampus(courses []unibo_integ.Course) []CourseGroup {
+	return groupCourses(courses, func(c unibo_integ.Course) string { return c.Campus })
+}
+
+// filterByClass returns the courses of the given degree class (e.g.
+// "LM-18"), ignoring case and spaces.
+func filterByClass(courses []unibo_integ.Course, class string) []unibo_integ.Course {
+	class = normalizeClass(class)
+
+	var res []unibo_integ.Course
+	for _, c := range courses {
+		if normalizeClass(c.Classe) == class {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// normalizeClass converts e.g. "lm 18" to "LM-18".
+func normalizeClass(class string) string {
+	class = strings.ToUpper(strings.TrimSpace(class))
+	return strings.Join(strings.FieldsFunc(class, func(r rune) bool {
+		return r == ' ' || r == '-'
+	}), "-")
+}
+
+// accentReplacer removes the accents used in Italian names, so that slugs
+// can be typed on every keyboard (e.g. "Forlì" -> "forli").
+var accentReplacer = strings.NewReplacer(
+	"à", "a", "è", "e", "é", "e", "ì", "i", "ò", "o", "ù", "u",
+)
+
+// slugify converts a name to a lowercase, URL friendly identifier,
+// e.g. "Ingegneria e Architettura" -> "ingegneria-e-architettura".
+func slugify(name string) string {
+	b := strings.Builder{}
+	dash := false
+	for _, r := range accentReplacer.Replace(strings.ToLower(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func schoolPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return groupPage(courses, groupBySchool, "id", "Scuola non trovata")
+}
+
+func campusPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return groupPage(courses, groupByCampus, "name", "Campus non trovato")
+}
+
+// groupPage renders the course list of the group whose slug matches the given
+// path parameter.
+func groupPage(
+	courses *unibo_integ.Courses,
+	group func([]unibo_integ.Course) []CourseGroup,
+	param string,
+	notFound string,
+) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		slug := slugify(ctx.Param(param))
+		groups := group(courses.Load().ToList())
+
+		i := slices.IndexFunc(groups, func(g CourseGroup) bool { return g.Slug == slug })
+		if i < 0 {
+			errorPage(ctx, http.StatusNotFound, notFound)
+			return
+		}
+
+		renderHTML(ctx, http.StatusOK, "courses", gin.H{
+			"heading": groups[i].Name,
+			"courses": groups[i].Courses,
+		})
+	}
+}
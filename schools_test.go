@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+func Test_slugify(t *testing.T) {
+	assert.Equal(t, "ingegneria-e-architettura", slugify("Ingegneria e Architettura"))
+	assert.Equal(t, "economia-e-management", slugify("  Economia  e Management "))
+	assert.Equal(t, "forli", slugify("Forlì"))
+}
+
+func Test_groupBySchool(t *testing.T) {
+	courses := []unibo_integ.Course{
+		{Codice: 3, Ambiti: "Scienze"},
+		{Codice: 2, Ambiti: "Ingegneria e Architettura"},
+		{Codice: 1, Ambiti: "Scienze"},
+		{Codice: 0, Ambiti: ""},
+	}
+
+	schools := groupBySchool(courses)
+
+	assert.Equal(t, 2, len(schools))
+	assert.Equal(t, "ingegneria-e-architettura", schools[0].Slug)
+	assert.Equal(t, 1, len(schools[0].Courses))
+	assert.Equal(t, "scienze", schools[1].Slug)
+	assert.Equal(t, 3, schools[1].Courses[0].Codice)
+	assert.Equal(t, 1, schools[1].Courses[1].Codice)
+}
+
+func Test_filterByClass(t *testing.T) {
+	courses := []unibo_integ.Course{
+		{Codice: 1, Classe: "LM-18"},
+		{Codice: 2, Classe: "L-31"},
+		{Codice: 3},
+	}
+
+	assert.Equal(t, 1, len(filterByClass(courses, "lm 18")))
+	assert.Equal(t, 2, filterByClass(courses, "L-31")[0].Codice)
+	assert.Equal(t, 0, len(filterByClass(courses, "LM-32")))
+	assert.Equal(t, "LM-18", normalizeClass(" lm-18 "))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+)
+
+// periodCurrent is the ?period= value selecting the semester of today.
+const periodCurrent = "current"
+
+// semesterOf returns the semester of the academic year a month belongs to:
+// the first goes from August to January, the second from February to July.
+func semesterOf(month time.Month) int {
+	if month >= time.February && month <= time.July {
+		return 2
+	}
+	return 1
+}
+
+// lessonSemester returns the semester of the teaching of the lesson, from
+// the start of its teaching period (see [unibo.ParsePeriod]). Lessons
+// without a valid period use their own date.
+func lessonSemester(e timetable.Event) int {
+	if period := unibo.ParsePeriod(e.CalendarInterval, e.Interval); period.Valid() {
+		return semesterOf(period.Start.Month())
+	}
+	return semesterOf(e.Start.Month())
+}
+
+// parsePeriod parses the ?period= parameter: 1 or 2 for a semester, or
+// "current" for the semester of now. Other values are ignored.
+func parsePeriod(raw string, now time.Time) int {
+	if raw == periodCurrent {
+		return semesterOf(now.Month())
+	}
+	period, err := strconv.Atoi(raw)
+	if err != nil || (period != 1 && period != 2) {
+		return 0
+	}
+	return period
+}
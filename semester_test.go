@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_lessonSemester(t *testing.T) {
+	at := func(month time.Month) timetable.CalendarTime {
+		return timetable.CalendarTime{Time: time.Date(2024, month, 10, 9, 0, 0, 0, time.UTC)}
+	}
+
+	assert.Equal(t, 1, lessonSemester(timetable.Event{CalendarInterval: "18 settembre 2023 - 20 dicembre 2023", Start: at(time.March)}))
+	assert.Equal(t, 2, lessonSemester(timetable.Event{CalendarInterval: "19 Febbraio 2024 - 31 maggio 2024", Start: at(time.October)}))
+	// The lessons of January belong to the first semester
+	assert.Equal(t, 1, lessonSemester(timetable.Event{Interval: "8 gennaio 2024 - 31 gennaio 2024"}))
+	// Without a period, the date of the lesson is used
+	assert.Equal(t, 2, lessonSemester(timetable.Event{CalendarInterval: "boh", Start: at(time.April)}))
+}
+
+func Test_parsePeriod(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 1, parsePeriod("1", now))
+	assert.Equal(t, 2, parsePeriod("2", now))
+	assert.Equal(t, 2, parsePeriod(periodCurrent, now))
+	assert.Equal(t, 0, parsePeriod("3", now))
+	assert.Equal(t, 0, parsePeriod("", now))
+}
+
+func Test_filterTimetableByPeriod(t *testing.T) {
+	tt := testTimetable()
+	tt[1].CalendarInterval = "19 febbraio 2024 - 31 maggio 2024"
+
+	first := filterTimetable(nil, tt, calOptions{Period: 1})
+	assert.Equal(t, 1, len(first))
+	assert.Equal(t, "ALGEBRA", first[0].Title)
+	assert.Equal(t, 1, len(filterTimetable(nil, tt, calOptions{Period: 2})))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+)
+
+// runServer starts serving the router.
+//
+// When a TLS certificate is configured, the server speaks HTTP/2 and, if
+// enabled, also HTTP/3 on the same port (UDP). Otherwise, it serves plain
+// HTTP/1.1, as expected behind a reverse proxy.
+func runServer(r *gin.Engine) error {
+	addr := ":" + config.Port
+	app := withBasePath(r, config.BasePath)
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		log.Info().Str("addr", addr).Str("base-path", config.BasePath).Msg("Listening for HTTP requests")
+		return http.ListenAndServe(addr, app)
+	}
+
+	handler := app
+	if config.HTTP3 {
+		h3 := &http3.Server{Addr: addr, Handler: app}
+
+		// Advertise HTTP/3 to the clients connecting over TCP
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_ = h3.SetQUICHeaders(w.Header())
+			app.ServeHTTP(w, req)
+		})
+
+		go func() {
+			log.Info().Str("addr", addr).Msg("Listening for HTTP/3 requests")
+			err := h3.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+			if err != nil {
+				log.Err(err).Msg("HTTP/3 server stopped")
+			}
+		}()
+	}
+
+	// HTTP/2 is enabled automatically by net/http when serving TLS
+	server := &http.Server{Addr: addr, Handler: handler}
+	log.Info().Str("addr", addr).Msg("Listening for HTTPS requests")
+
+	err := server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to serve https: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// snapshotsDir contains the snapshots of the timetables, see saveSnapshot.
+var snapshotsDir = path.Join(config.DataDir, "snapshots")
+
+const snapshotDateLayout = "2006-01-02"
+
+// snapshotPath returns the folder containing every snapshot of the timetable
+// of the given course, year and curriculum.
+//
+// The curriculum comes from the requests, so it's refused if it isn't a
+// valid code (see validCurriculum) and could point outside of snapshotsDir.
+func snapshotPath(courseId int, year int, curriculum string) (string, error) {
+	if curriculum == "" {
+		curriculum = "default"
+	}
+	if !validCurriculum.MatchString(curriculum) || !filepath.IsLocal(curriculum) {
+		return "", fmt.Errorf("invalid curriculum %q", curriculum)
+	}
+	return path.Join(snapshotsDir, fmt.Sprintf("%d", courseId), fmt.Sprintf("%d-%s", year, curriculum)), nil
+}
+
+// saveSnapshot stores the timetable as it looked on the given day.
+//
+// Only one snapshot per day is kept, later calls on the same day overwrite it.
+// The file is replaced atomically, so the readers (and the backups) never see
+// a partial snapshot; concurrent saves use their own temporary file.
+func saveSnapshot(courseId int, year int, curriculum string, day time.Time, t timetable.Timetable) error {
+	dir, err := snapshotPath(courseId, year, curriculum)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot folder: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, ".snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot file: %w", err)
+	}
+
+	err = json.NewEncoder(file).Encode(t)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	return os.Rename(file.Name(), path.Join(dir, day.Format(snapshotDateLayout)+".json"))
+}
+
+// loadSnapshot returns the most recent snapshot taken on or before asOf.
+//
+// The boolean is false if no such snapshot exists.
+func loadSnapshot(courseId int, year int, curriculum string, asOf time.Time) (timetable.Timetable, bool, error) {
+	dir, err := snapshotPath(courseId, year, curriculum)
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("unable to read snapshot folder: %w", err)
+	}
+
+	want := asOf.Format(snapshotDateLayout)
+
+	// Snapshot names are ISO dates, so they sort chronologically.
+	var days []string
+	for _, entry := range entries {
+		day, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(snapshotDateLayout, day); err != nil {
+			continue
+		}
+		if day <= want {
+			days = append(days, day)
+		}
+	}
+
+	if len(days) == 0 {
+		return nil, false, nil
+	}
+	slices.Sort(days)
+
+	file, err := os.Open(path.Join(dir, days[len(days)-1]+".json"))
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var t timetable.Timetable
+	err = json.NewDecoder(file).Decode(&t)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+
+	return t, true, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_snapshotPath(t *testing.T) {
+	p, err := snapshotPath(8009, 1, "")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, path.Join(snapshotsDir, "8009", "1-default"), p)
+
+	p, err = snapshotPath(8009, 2, "A58-000")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, path.Join(snapshotsDir, "8009", "2-A58-000"), p)
+
+	for _, curr := range []string{"../../../tmp", "a/b", `a\b`, "..", "."} {
+		_, err = snapshotPath(8009, 1, curr)
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func Test_saveSnapshot(t *testing.T) {
+	old := snapshotsDir
+	snapshotsDir = t.TempDir()
+	t.Cleanup(func() { snapshotsDir = old })
+
+	day := time.Date(2024, time.October, 1, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, nil, saveSnapshot(8009, 1, "", day, testTimetable()))
+	assert.Equal(t, nil, saveSnapshot(8009, 1, "", day, testTimetable()[1:]))
+
+	loaded, found, err := loadSnapshot(8009, 1, "", day)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, found)
+	assert.Equal(t, len(testTimetable())-1, len(loaded))
+
+	// Only the snapshot, no temporary files
+	dir, _ := snapshotPath(8009, 1, "")
+	entries, _ := os.ReadDir(dir)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "2024-10-01.json", entries[0].Name())
+}
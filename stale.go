@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// staleHeader is set when the feed was generated from the last snapshot of
+// the timetable, because the upstream is failing.
+const staleHeader = "X-Stale"
+
+const staleWarningSummary = "⚠ Orario potenzialmente non aggiornato"
+
+const staleWarningDescription = "Il sito di Unibo non risponde, quindi questo calendario mostra l'ultimo orario " +
+	"scaricato, che potrebbe non essere aggiornato. Controlla eventuali modifiche sul sito del corso. " +
+	"L'avviso sparirà da solo quando l'orario tornerà disponibile."
+
+// addStaleWarning adds to the calendar an all-day event, today, warning the
+// subscribers that the lessons may be outdated. Its UID changes every day, so
+// it doesn't linger in the past days once the upstream is back.
+func addStaleWarning(cal *ics.Calendar, now time.Time) {
+	today := romeDate(now)
+
+	e := cal.AddEvent("stale-" + today.Format("20060102") + "@unibocalendar")
+	e.SetSummary(staleWarningSummary)
+	e.SetDescription(staleWarningDescription)
+	e.SetAllDayStartAt(today)
+	e.SetAllDayEndAt(today.AddDate(0, 0, 1))
+	e.SetDtStampTime(now)
+	e.SetTimeTransparency(ics.TransparencyTransparent)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_addStaleWarning(t *testing.T) {
+	cal := ics.NewCalendar()
+	// Late in the evening in Rome, already the next day in UTC
+	addStaleWarning(cal, time.Date(2024, 3, 4, 23, 30, 0, 0, time.UTC))
+
+	out := cal.Serialize()
+	assert.Equal(t, true, strings.Contains(out, "UID:stale-20240305@unibocalendar"))
+	assert.Equal(t, true, strings.Contains(out, "DTSTART;VALUE=DATE:20240305"))
+	assert.Equal(t, true, strings.Contains(out, "DTEND;VALUE=DATE:20240306"))
+	assert.Equal(t, true, strings.Contains(out, "TRANSP:TRANSPARENT"))
+}
+
+func Test_createEventsCal_stale(t *testing.T) {
+	cal, err := createEventsCal(testTimetable(), calOptions{Stale: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, strings.Contains(cal.Serialize(), "SUMMARY:"+staleWarningSummary))
+
+	cal, err = createEventsCal(testTimetable(), calOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, strings.Contains(cal.Serialize(), staleWarningSummary))
+}
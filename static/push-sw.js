@@ -0,0 +1,15 @@
+// Service worker showing the notifications of the schedule changes, sent by
+// the server with Web Push (see webpush.go).
+
+self.addEventListener("push", (event) => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(self.registration.showNotification(data.title || "UniboCalendar", {
+        body: data.body,
+        data: {url: data.url || "/"},
+    }));
+});
+
+self.addEventListener("notificationclick", (event) => {
+    event.notification.close();
+    event.waitUntil(clients.openWindow(event.notification.data.url));
+});
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// feedCount is the number of requests of the calendar of a course year.
+type feedCount struct {
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum,omitempty"`
+	Requests   int    `json:"requests"`
+}
+
+func (f feedCount) feed() feedRef {
+	return feedRef{Course: f.Course, Year: f.Year, Curriculum: f.Curriculum}
+}
+
+// feedStats counts the requests of every calendar, saved as JSON so that
+// they survive a restart.
+type feedStats struct {
+	mu     sync.Mutex
+	file   string
+	counts map[feedRef]int
+	dirty  bool
+}
+
+var calStats = &feedStats{file: config.StatsFile, counts: map[feedRef]int{}}
+
+func (s *feedStats) load() error {
+	var list []feedCount
+	err := loadJsonFile(s.file, &list)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range list {
+		s.counts[f.feed()] += f.Requests
+	}
+	return nil
+}
+
+func (s *feedStats) save() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	list := s.list()
+	s.dirty = false
+	s.mu.Unlock()
+
+	return saveJsonFile(s.file, list, 0o644)
+}
+
+// hit counts a request of the calendar.
+func (s *feedStats) hit(feed feedRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[feed]++
+	s.dirty = true
+}
+
+// list returns the counts, the most requested first. The mutex must be held.
+func (s *feedStats) list() []feedCount {
+	list := make([]feedCount, 0, len(s.counts))
+	for f, n := range s.counts {
+		list = append(list, feedCount{Course: f.Course, Year: f.Year, Curriculum: f.Curriculum, Requests: n})
+	}
+	slices.SortFunc(list, func(a, b feedCount) int {
+		if a.Requests != b.Requests {
+			return b.Requests - a.Requests
+		}
+		return compareFeeds(a.feed(), b.feed())
+	})
+	return list
+}
+
+// top returns the n most requested calendars.
+func (s *feedStats) top(n int) []feedCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.list()
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// courseRequests returns the requests of every course, summed over its
+// years and curricula.
+func (s *feedStats) courseRequests() map[int]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make(map[int]int)
+	for f, n := range s.counts {
+		requests[f.Course] += n
+	}
+	return requests
+}
+
+// Run saves the counts every interval.
+func (s *feedStats) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		err := s.save()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to save calendar stats")
+		}
+	}
+}
+
+func compareFeeds(a, b feedRef) int {
+	if a.Course != b.Course {
+		return a.Course - b.Course
+	}
+	if a.Year != b.Year {
+		return a.Year - b.Year
+	}
+	switch {
+	case a.Curriculum < b.Curriculum:
+		return -1
+	case a.Curriculum > b.Curriculum:
+		return 1
+	}
+	return 0
+}
+
+// calPath returns the path of the calendar of the course year, with the
+// default options.
+func calPath(feed feedRef) string {
+	p := fmt.Sprintf("/cal/%d/%d", feed.Course, feed.Year)
+	if feed.Curriculum != "" {
+		p += "?curr=" + url.QueryEscape(feed.Curriculum)
+	}
+	return p
+}
+
+// warmUp generates the n most requested calendars, so they are cached
+// before the server accepts requests. It gives up after timeout, leaving the
+// remaining calendars to be generated on demand.
+func warmUp(r *gin.Engine, n int, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	feeds := calStats.top(n)
+	warmed := 0
+	var mu sync.Mutex
+
+	pool := newWorkerPool(config.PrefetchWorkers, 0)
+	for _, f := range feeds {
+		if ctx.Err() != nil {
+			break
+		}
+		pool.Submit(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			w, err := serveSelf(r, calPath(f.feed()))
+			if err != nil || w.Code != http.StatusOK {
+				log.Warn().Int("course-code", f.Course).Int("year", f.Year).Msg("unable to warm up calendar")
+				return
+			}
+			mu.Lock()
+			warmed++
+			mu.Unlock()
+		})
+	}
+	pool.Wait()
+
+	log.Info().
+		Int("calendars", warmed).
+		Int("wanted", len(feeds)).
+		Dur("duration", time.Since(start)).
+		Bool("timed-out", ctx.Err() != nil).
+		Msg("cache warmed up")
+}
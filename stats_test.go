@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_feedStats(t *testing.T) {
+	file := path.Join(t.TempDir(), "stats.json")
+	s := &feedStats{file: file, counts: map[feedRef]int{}}
+
+	s.hit(feedRef{Course: 8009, Year: 1})
+	s.hit(feedRef{Course: 8009, Year: 2})
+	s.hit(feedRef{Course: 8009, Year: 2})
+	s.hit(feedRef{Course: 9254, Year: 1, Curriculum: "A58-000"})
+
+	top := s.top(2)
+	assert.Equal(t, []feedCount{
+		{Course: 8009, Year: 2, Requests: 2},
+		{Course: 8009, Year: 1, Requests: 1},
+	}, top)
+
+	err := s.save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &feedStats{file: file, counts: map[feedRef]int{}}
+	err = loaded.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.counts, loaded.counts)
+}
+
+func Test_calPath(t *testing.T) {
+	assert.Equal(t, "/cal/8009/1", calPath(feedRef{Course: 8009, Year: 1}))
+	assert.Equal(t, "/cal/9254/2?curr=A58-000", calPath(feedRef{Course: 9254, Year: 2, Curriculum: "A58-000"}))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// Upstream data sources tracked by the status page.
+const (
+	sourceOpenData  = "opendata"
+	sourceTimetable = "timetable"
+	sourceCurricula = "curricula"
+)
+
+// statusWindowSize is the number of recent results used to compute the error
+// rate of every source.
+const statusWindowSize = 100
+
+// SourceStatus is the health of an upstream data source.
+type SourceStatus struct {
+	Name        string    `json:"name"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+	// LastError is a generic description of the last error, see
+	// publicError: the error itself can contain URLs and addresses of the
+	// internal network, so it's only shown to the admins
+	LastError string `json:"last_error,omitempty"`
+	// ErrorRate is the fraction of failed requests among the recent ones
+	ErrorRate float64 `json:"error_rate"`
+	Requests  int     `json:"recent_requests"`
+	// ConsecutiveFailures is the number of requests failed since the last
+	// successful one
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Degraded is true when ConsecutiveFailures reached config.AlertThreshold
+	Degraded bool `json:"degraded"`
+
+	lastErrorDetail string
+	results         []bool // ring buffer of the recent results, true on failure
+	next            int
+}
+
+// adminSourceStatus is the health of a source with its last error, for the
+// admins.
+type adminSourceStatus struct {
+	SourceStatus
+	LastErrorDetail string `json:"last_error_detail,omitempty"`
+}
+
+// publicError returns a description of the error of a request to Unibo that
+// can be shown to everyone.
+func publicError(err error) string {
+	var statusErr *unibo.StatusError
+	var netErr net.Error
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("status %d", statusErr.StatusCode)
+	} else if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "request failed"
+}
+
+type upstreamStatus struct {
+	mutex   sync.Mutex
+	sources map[string]*SourceStatus
+}
+
+var upstream = &upstreamStatus{sources: make(map[string]*SourceStatus)}
+
+// record saves the result of a request to the given upstream source. The
+// operator is alerted when the source becomes degraded and when it recovers.
+func (u *upstreamStatus) record(source string, err error) {
+	alert, changed := u.update(source, err)
+	if changed && alertsEnabled() {
+		go sendAlert(alert)
+	}
+}
+
+// update saves the result of the request and reports whether the source
+// became degraded or recovered.
+func (u *upstreamStatus) update(source string, err error) (operatorAlert, bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	s, found := u.sources[source]
+	if !found {
+		s = &SourceStatus{Name: source}
+		u.sources[source] = s
+	}
+
+	now := time.Now()
+	wasDegraded := s.Degraded
+	if err != nil {
+		s.LastFailure = now
+		s.LastError = publicError(err)
+		s.lastErrorDetail = err.Error()
+		s.ConsecutiveFailures++
+		s.Degraded = config.AlertThreshold > 0 && s.ConsecutiveFailures >= config.AlertThreshold
+	} else {
+		s.LastSuccess = now
+		s.ConsecutiveFailures = 0
+		s.Degraded = false
+	}
+
+	if len(s.results) < statusWindowSize {
+		s.results = append(s.results, err != nil)
+	} else {
+		s.results[s.next] = err != nil
+		s.next = (s.next + 1) % statusWindowSize
+	}
+
+	alert := operatorAlert{
+		Source:              source,
+		Degraded:            s.Degraded,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		LastError:           s.lastErrorDetail,
+		Time:                now,
+	}
+	return alert, s.Degraded != wasDegraded
+}
+
+// degraded reports whether at least one of the sources is degraded.
+func degraded(sources []SourceStatus) bool {
+	for _, s := range sources {
+		if s.Degraded {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns a copy of the status of every source, sorted by name.
+func (u *upstreamStatus) snapshot() []SourceStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	res := make([]SourceStatus, 0, len(u.sources))
+	for _, s := range u.sources {
+		failures := 0
+		for _, failed := range s.results {
+			if failed {
+				failures++
+			}
+		}
+
+		c := *s
+		c.results = nil
+		c.Requests = len(s.results)
+		if c.Requests > 0 {
+			c.ErrorRate = float64(failures) / float64(c.Requests)
+		}
+		res = append(res, c)
+	}
+
+	slices.SortFunc(res, func(a, b SourceStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+func statusPage(c *gin.Context) {
+	sources := upstream.snapshot()
+	renderHTML(c, http.StatusOK, "status", gin.H{
+		"sources":  sources,
+		"degraded": degraded(sources),
+	})
+}
+
+func apiStatus(c *gin.Context) {
+	sources := upstream.snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"sources":  sources,
+		"degraded": degraded(sources),
+	})
+}
+
+// adminStatus returns the status of the sources with their last errors.
+func adminStatus(c *gin.Context) {
+	sources := upstream.snapshot()
+	res := make([]adminSourceStatus, 0, len(sources))
+	for _, s := range sources {
+		res = append(res, adminSourceStatus{SourceStatus: s, LastErrorDetail: s.lastErrorDetail})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sources":  res,
+		"degraded": degraded(sources),
+	})
+}
+
+// metricsHandler exposes the metrics of the requests to Unibo and of the
+// caches, in the Prometheus text format.
+func metricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	err := unibo_integ.WriteMetrics(c.Writer)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	writeCacheMetrics(c.Writer)
+}
+
+// writeCacheMetrics writes the size of the bounded caches.
+func writeCacheMetrics(w io.Writer) {
+	calEntries, calBytes, calEvictions := calcache.Stats()
+	timetableEntries, _, timetableEvictions := timetableCache.Stats()
+
+	fmt.Fprintln(w, "# HELP cache_entries Number of entries in the cache.")
+	fmt.Fprintln(w, "# TYPE cache_entries gauge")
+	fmt.Fprintf(w, "cache_entries{cache=\"calendars\"} %d\n", calEntries)
+	fmt.Fprintf(w, "cache_entries{cache=\"timetables\"} %d\n", timetableEntries)
+	fmt.Fprintln(w, "# HELP cache_bytes Total size of the entries in the cache.")
+	fmt.Fprintln(w, "# TYPE cache_bytes gauge")
+	fmt.Fprintf(w, "cache_bytes{cache=\"calendars\"} %d\n", calBytes)
+	fmt.Fprintln(w, "# HELP cache_evictions_total Entries evicted because the cache was full.")
+	fmt.Fprintln(w, "# TYPE cache_evictions_total counter")
+	fmt.Fprintf(w, "cache_evictions_total{cache=\"calendars\"} %d\n", calEvictions)
+	fmt.Fprintf(w, "cache_evictions_total{cache=\"timetables\"} %d\n", timetableEvictions)
+}
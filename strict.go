@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/rs/zerolog/log"
+)
+
+// flagStrictIcs is the feature flag enabling the strict RFC 5545 compliance
+// mode for every calendar.
+//
+// In strict mode:
+//   - the calendar always uses METHOD:PUBLISH, whatever [Config.IcsMethod] is;
+//   - events have no ORGANIZER, since we don't have a valid cal-address;
+//   - control characters are removed from text values;
+//   - the serialized calendar is validated, and problems are logged.
+const flagStrictIcs = "strict_ics"
+
+// sanitizeText removes the characters that are not allowed in TEXT values
+// (RFC 5545, section 3.3.11). Newlines are kept, since they are escaped by
+// the serializer.
+func sanitizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sanitizeEvent returns a copy of the event with every text field sanitized.
+func sanitizeEvent(e timetable.Event) timetable.Event {
+	e.Title = sanitizeText(e.Title)
+	e.Teacher = sanitizeText(e.Teacher)
+	e.Interval = sanitizeText(e.Interval)
+	e.CodModulo = sanitizeText(e.CodModulo)
+
+	classrooms := make([]timetable.Classroom, len(e.Classrooms))
+	for i, c := range e.Classrooms {
+		c.ResourceDesc = sanitizeText(c.ResourceDesc)
+		classrooms[i] = c
+	}
+	e.Classrooms = classrooms
+	return e
+}
+
+// checkStrictCalendar validates the serialized calendar, logging the problems
+// found, so regressions in the generation pipeline are noticed.
+func checkStrictCalendar(data []byte, url string) {
+	problems := validateICS(data)
+	if len(problems) == 0 {
+		return
+	}
+
+	log.Warn().
+		Str("url", url).
+		Int("problems", len(problems)).
+		Str("first-problem", problems[0].Message).
+		Msg("generated calendar is not RFC 5545 compliant")
+}
@@ -3,6 +3,8 @@ const { addDynamicIconSelectors } = require('@iconify/tailwind');
 module.exports = {
     content: [
         "templates/*.gohtml",
+        "templates/partials/*.gohtml",
+        "templates/fragments/*.gohtml",
     ],
     theme: {
         extend: {},
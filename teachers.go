@@ -0,0 +1,230 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// maxTeacherResults is the number of teachers returned by a search.
+const maxTeacherResults = 50
+
+// teacherFeed is a course year, with the module codes taught by a teacher.
+type teacherFeed struct {
+	Course     int
+	Year       int
+	Curriculum string
+	Subjects   []string
+}
+
+// teacherIndex maps the teachers to the feeds they teach in, built from the
+// timetables fetched so far.
+type teacherIndex struct {
+	mutex sync.RWMutex
+	// names are the names of the teachers, by normalized name
+	names map[string]string
+	// feeds are the module codes of every teacher, by normalized name and
+	// feed
+	feeds map[string]map[feedRef][]string
+}
+
+var teachers = &teacherIndex{
+	names: map[string]string{},
+	feeds: map[string]map[feedRef][]string{},
+}
+
+// normalizeName converts a name to lowercase, without accents and extra
+// spaces, so that searches are not picky about how the name is written.
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(accentReplacer.Replace(strings.ToLower(name))), " ")
+}
+
+// index replaces the teachers of the feed with the ones of the timetable.
+func (ti *teacherIndex) index(feed feedRef, t timetable.Timetable) {
+	names := map[string]string{}
+	subjects := map[string][]string{}
+	for _, event := range t {
+		for _, name := range unibo.ParseTeachers(event.Teacher) {
+			key := normalizeName(name)
+			names[key] = name
+			if !slices.Contains(subjects[key], event.CodModulo) {
+				subjects[key] = append(subjects[key], event.CodModulo)
+			}
+		}
+	}
+
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	for key, name := range names {
+		ti.names[key] = name
+	}
+
+	// Teachers who don't teach in the feed anymore
+	for key := range ti.feeds {
+		if _, found := subjects[key]; !found {
+			ti.remove(key, feed)
+		}
+	}
+
+	for key, codes := range subjects {
+		slices.Sort(codes)
+		if ti.feeds[key] == nil {
+			ti.feeds[key] = map[feedRef][]string{}
+		}
+		ti.feeds[key][feed] = codes
+	}
+}
+
+// forget removes the teachers of the feed.
+func (ti *teacherIndex) forget(feed feedRef) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	for key := range ti.feeds {
+		ti.remove(key, feed)
+	}
+}
+
+// remove removes the feed from the teacher, and the teacher if they don't
+// teach in any feed anymore. The mutex must be held.
+func (ti *teacherIndex) remove(key string, feed feedRef) {
+	delete(ti.feeds[key], feed)
+	if len(ti.feeds[key]) == 0 {
+		delete(ti.feeds, key)
+		delete(ti.names, key)
+	}
+}
+
+// teacherResult is a teacher found by search.
+type teacherResult struct {
+	Name  string
+	Feeds []teacherFeed
+}
+
+// search returns the teachers whose name contains every word of the query,
+// sorted by name.
+func (ti *teacherIndex) search(query string) []teacherResult {
+	words := strings.Fields(normalizeName(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+
+	var results []teacherResult
+	for key, feeds := range ti.feeds {
+		if len(feeds) == 0 || !containsAll(key, words) {
+			continue
+		}
+
+		res := teacherResult{Name: ti.names[key]}
+		for feed, codes := range feeds {
+			res.Feeds = append(res.Feeds, teacherFeed{
+				Course:     feed.Course,
+				Year:       feed.Year,
+				Curriculum: feed.Curriculum,
+				Subjects:   codes,
+			})
+		}
+		slices.SortFunc(res.Feeds, func(a, b teacherFeed) int {
+			if a.Course != b.Course {
+				return a.Course - b.Course
+			}
+			return a.Year - b.Year
+		})
+		results = append(results, res)
+	}
+
+	slices.SortFunc(results, func(a, b teacherResult) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	if len(results) > maxTeacherResults {
+		results = results[:maxTeacherResults]
+	}
+	return results
+}
+
+func containsAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+type apiTeacherFeed struct {
+	Course      int      `json:"course"`
+	Description string   `json:"description"`
+	Year        int      `json:"year"`
+	Curriculum  string   `json:"curriculum,omitempty"`
+	Subjects    []string `json:"subjects"`
+	// Calendar is the path of the calendar with only the subjects of the
+	// teacher
+	Calendar string `json:"calendar"`
+}
+
+type apiTeacher struct {
+	Name  string           `json:"name"`
+	Feeds []apiTeacherFeed `json:"feeds"`
+}
+
+func newApiTeachers(courses *unibo_integ.CoursesMap, results []teacherResult) []apiTeacher {
+	res := make([]apiTeacher, 0, len(results))
+	for _, r := range results {
+		t := apiTeacher{Name: r.Name, Feeds: make([]apiTeacherFeed, 0, len(r.Feeds))}
+		for _, f := range r.Feeds {
+			t.Feeds = append(t.Feeds, apiTeacherFeed{
+				Course:      f.Course,
+				Description: courseDescription(courses, f.Course),
+				Year:        f.Year,
+				Curriculum:  f.Curriculum,
+				Subjects:    f.Subjects,
+				Calendar:    calendarPath(f.Course, f.Year, f.Curriculum, f.Subjects),
+			})
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// teachersPage lets users search the courses a teacher teaches in.
+func teachersPage(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		query := ctx.Query("q")
+		renderHTML(ctx, http.StatusOK, "teachers", gin.H{
+			"query":    query,
+			"teachers": newApiTeachers(courses.Load(), teachers.search(query)),
+		})
+	}
+}
+
+// apiTeachers returns the teachers matching the query.
+func apiTeachers(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		query := ctx.Query("q")
+		if strings.TrimSpace(query) == "" {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Missing query")
+			return
+		}
+		ctx.JSON(http.StatusOK, newApiTeachers(courses.Load(), teachers.search(query)))
+	}
+}
+
+// courseDescription returns the name of the course with the given code, or
+// an empty string if it does not exist anymore.
+func courseDescription(courses *unibo_integ.CoursesMap, code int) string {
+	course, found := courses.FindById(code)
+	if !found {
+		return ""
+	}
+	return course.Descrizione
+}
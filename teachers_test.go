@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_teacherIndex(t *testing.T) {
+	ti := &teacherIndex{names: map[string]string{}, feeds: map[string]map[feedRef][]string{}}
+	feed := feedRef{Course: 8009, Year: 1}
+	ti.index(feed, testTimetable())
+
+	results := ti.search("rossi")
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "Mario Rossi", results[0].Name)
+	assert.Equal(t, []string{"00001_1"}, results[0].Feeds[0].Subjects)
+
+	assert.Equal(t, 1, len(ti.search("  ANNA   bianchi ")))
+	assert.Equal(t, 0, len(ti.search("mario bianchi")))
+	assert.Equal(t, 0, len(ti.search("")))
+
+	// Mario Rossi doesn't teach in the feed anymore
+	ti.index(feed, testTimetable()[1:])
+	assert.Equal(t, 0, len(ti.search("rossi")))
+
+	api := newApiTeachers(testCourses.Load(), ti.search("bianchi"))
+	assert.Equal(t, "INFORMATICA", api[0].Feeds[0].Description)
+	assert.Equal(t, "/cal/8009/1?subjects=00002_1", api[0].Feeds[0].Calendar)
+
+	ti.forget(feed)
+	assert.Equal(t, 0, len(ti.search("bianchi")))
+	assert.Equal(t, 0, len(ti.names))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// teachingUrl returns the URL of the page of the teaching (insegnamento) of
+// the event on unibo.it, where the syllabus is published.
+func teachingUrl(event timetable.Event) (string, bool) {
+	page, found := unibo.ParseTeachingPage(event.ExtCode)
+	if !found {
+		return "", false
+	}
+	return page.Url(), true
+}
+
+// subjectCode returns the code of the subject of the event, which is the
+// module code without the module number, e.g. "28004" for "28004_1".
+func subjectCode(event timetable.Event) string {
+	return unibo.ParseTeachingCode(event.CodModulo).Subject
+}
+
+// apiTeaching is a teaching (or a module of it) of a course year.
+type apiTeaching struct {
+	// Code is the module code, to be used in ?subjects=
+	Code        string   `json:"code"`
+	SubjectCode string   `json:"subject_code"`
+	Title       string   `json:"title"`
+	Teachers    []string `json:"teachers"`
+	Cfu         int      `json:"cfu"`
+	// Groups are the codes of the groups the module is split in, if any
+	Groups      []string  `json:"groups"`
+	Lessons     int       `json:"lessons"`
+	FirstLesson time.Time `json:"first_lesson"`
+	LastLesson  time.Time `json:"last_lesson"`
+	Url         string    `json:"url,omitempty"`
+}
+
+// newTeachings lists the teachings of the timetable, sorted by title.
+func newTeachings(t timetable.Timetable) []apiTeaching {
+	indexes := make(map[string]int)
+	teachings := make([]apiTeaching, 0)
+
+	for _, e := range t {
+		i, found := indexes[e.CodModulo]
+		if !found {
+			i = len(teachings)
+			indexes[e.CodModulo] = i
+			u, _ := teachingUrl(e)
+			teachings = append(teachings, apiTeaching{
+				Code:        e.CodModulo,
+				SubjectCode: subjectCode(e),
+				Title:       e.Title,
+				Teachers:    []string{},
+				Cfu:         e.Cfu,
+				Groups:      []string{},
+				FirstLesson: e.Start.Time,
+				LastLesson:  e.Start.Time,
+				Url:         u,
+			})
+		}
+
+		teaching := &teachings[i]
+		teaching.Lessons++
+		if e.Start.Before(teaching.FirstLesson) {
+			teaching.FirstLesson = e.Start.Time
+		}
+		if e.Start.After(teaching.LastLesson) {
+			teaching.LastLesson = e.Start.Time
+		}
+		for _, teacher := range unibo.ParseTeachers(e.Teacher) {
+			if !slices.Contains(teaching.Teachers, teacher) {
+				teaching.Teachers = append(teaching.Teachers, teacher)
+			}
+		}
+		if e.CodSdoppiamento != "" && !slices.Contains(teaching.Groups, e.CodSdoppiamento) {
+			teaching.Groups = append(teaching.Groups, e.CodSdoppiamento)
+		}
+	}
+
+	slices.SortFunc(teachings, func(a, b apiTeaching) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+	return teachings
+}
+
+// teachingIndex contains the teachings of every timetable fetched so far.
+type teachingIndex struct {
+	mutex sync.RWMutex
+	feeds map[feedRef][]apiTeaching
+}
+
+var teachings = &teachingIndex{feeds: map[feedRef][]apiTeaching{}}
+
+// index replaces the teachings of the feed with the ones of the timetable.
+func (ti *teachingIndex) index(feed feedRef, t timetable.Timetable) {
+	list := newTeachings(t)
+
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	ti.feeds[feed] = list
+}
+
+// forget removes the teachings of the feed.
+func (ti *teachingIndex) forget(feed feedRef) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	delete(ti.feeds, feed)
+}
+
+func (ti *teachingIndex) get(feed feedRef) ([]apiTeaching, bool) {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+	list, found := ti.feeds[feed]
+	return list, found
+}
+
+// apiTeachings returns the teachings of the course year. If its timetable
+// was not fetched yet, it is fetched now.
+func apiTeachings(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		p, perr := bindFeed(ctx, courses.Load())
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+		course, year := p.Course, p.Year
+
+		feed := feedRef{Course: course.Codice, Year: year, Curriculum: p.Curriculum}
+		list, found := teachings.get(feed)
+		if !found {
+			t, err := getTimetable(course, year, curriculum.Curriculum{Value: feed.Curriculum})
+			if errors.Is(err, errOverloaded) {
+				overloaded(ctx)
+				return
+			} else if err != nil {
+				_ = ctx.Error(err)
+				writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable, "Unable to retrieve timetable")
+				return
+			}
+			list = newTeachings(t)
+		}
+
+		ctx.JSON(http.StatusOK, list)
+	}
+}
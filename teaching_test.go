@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_teachingUrl(t *testing.T) {
+	u, ok := teachingUrl(timetable.Event{ExtCode: "2023-000-356354--I"})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "https://www.unibo.it/it/didattica/insegnamenti/insegnamento/2023/356354", u)
+
+	_, ok = teachingUrl(timetable.Event{ExtCode: ""})
+	assert.Equal(t, false, ok)
+}
+
+func Test_subjectCode(t *testing.T) {
+	assert.Equal(t, "28004", subjectCode(timetable.Event{CodModulo: "28004_1"}))
+	assert.Equal(t, "28004", subjectCode(timetable.Event{CodModulo: "28004"}))
+}
+
+func Test_newTeachings(t *testing.T) {
+	tt := testTimetable()
+	tt = append(tt, tt[0])
+	tt[2].Start.Time = tt[2].Start.AddDate(0, 0, 7)
+	tt[2].CodSdoppiamento = "00001_1--A-K"
+
+	list := newTeachings(tt)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "ALGEBRA", list[0].Title)
+	assert.Equal(t, 2, list[0].Lessons)
+	assert.Equal(t, []string{"Mario Rossi"}, list[0].Teachers)
+	assert.Equal(t, []string{"00001_1--A-K"}, list[0].Groups)
+	assert.Equal(t, tt[2].Start.Time, list[0].LastLesson)
+	assert.Equal(t, "00001", list[0].SubjectCode)
+	assert.Equal(t, "", list[1].Url)
+}
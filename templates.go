@@ -0,0 +1,110 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/gin-contrib/multitemplate"
+)
+
+// templateDir is where the templates are read from in development mode,
+// otherwise the ones embedded in the binary are used.
+const templateDir = "./templates"
+
+//go:embed templates/*.gohtml templates/partials/*.gohtml templates/fragments/*.gohtml
+var templatesFS embed.FS
+
+// pages are the templates rendered inside base.gohtml.
+var pages = []string{"index", "courses", "schools", "status", "builder", "teachers", "course", "error", "compare", "conflicts", "favorites", "me"}
+
+// The templates are organized in:
+//   - base.gohtml, the layout of every page, which renders the "title" and
+//     "body" templates defined by the page;
+//   - partials/, the named templates shared by the pages and the fragments
+//     ("header", "footer", "course-card", "event-row");
+//   - fragments/, parts of the pages also rendered alone, see fragments.go.
+
+var templateFuncs = template.FuncMap{
+	"anniRange": func(end int) []int {
+		r := make([]int, 0, end)
+		for i := 1; i <= end; i++ {
+			r = append(r, i)
+		}
+		return r
+	},
+	"percent": func(f float64) float64 { return f * 100 },
+	// url returns the path of a page of the app, see appUrl
+	"url": appUrl,
+	// tr returns a text in the language of the page, see englishTexts
+	"tr": tr,
+}
+
+// templateSet registers the templates in a renderer.
+type templateSet struct {
+	r    multitemplate.Renderer
+	fsys fs.FS
+	// dev parses the templates again on every request
+	dev bool
+}
+
+// add registers the template name, parsed from the files matching the
+// patterns (relative to the templates directory). The first file is the one
+// executed.
+func (s templateSet) add(name string, patterns ...string) {
+	if !s.dev {
+		t := template.New(path.Base(patterns[0])).Funcs(templateFuncs)
+		s.r.Add(name, template.Must(t.ParseFS(s.fsys, patterns...)))
+		return
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(s.fsys, pattern)
+		if err != nil {
+			panic(err)
+		}
+		for _, match := range matches {
+			files = append(files, path.Join(templateDir, match))
+		}
+	}
+	s.r.AddFromFilesFuncs(name, templateFuncs, files...)
+}
+
+// addPage registers a page, rendered inside the base layout. Pages can use
+// the partials and include the fragments with
+// {{ template "<fragment>.gohtml" . }}.
+func (s templateSet) addPage(page string) {
+	s.add(page, page+".gohtml", "base.gohtml", "partials/*.gohtml", "fragments/*.gohtml")
+}
+
+// addFragment registers a fragment, rendered alone as "fragments/<name>".
+func (s templateSet) addFragment(fragment string) {
+	s.add("fragments/"+fragment, "fragments/"+fragment+".gohtml", "partials/*.gohtml")
+}
+
+func createMyRender() multitemplate.Renderer {
+	var s templateSet
+	// In development mode the templates are parsed again on every request,
+	// so they can be edited without recompiling.
+	if config.DevMode {
+		s = templateSet{r: multitemplate.NewDynamic(), fsys: os.DirFS(templateDir), dev: true}
+	} else {
+		sub, err := fs.Sub(templatesFS, "templates")
+		if err != nil {
+			panic(err)
+		}
+		s = templateSet{r: multitemplate.New(), fsys: sub}
+	}
+
+	s.add("base", "base.gohtml", "partials/*.gohtml")
+	for _, page := range pages {
+		s.addPage(page)
+	}
+	for _, fragment := range fragments {
+		s.addFragment(fragment)
+	}
+	return s.r
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_pagesLayout(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		config.DevMode = dev
+		r := setupRouter(testCourses)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/builder?course=8009&year=1", nil)
+		r.ServeHTTP(w, req)
+
+		body := w.Body.String()
+		// The partials are rendered inside the layout
+		assert.Equal(t, true, strings.Contains(body, `<header class="navbar`))
+		assert.Equal(t, true, strings.Contains(body, "Stato del servizio"))
+		assert.Equal(t, true, strings.Contains(body, `<h3 class="card-title"><a class="link" href="/courses/8009">`))
+	}
+	config.DevMode = false
+}
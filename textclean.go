@@ -0,0 +1,106 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// The text of the upstream events sometimes contains HTML entities (e.g.
+// "Lingua e letteratura francese &amp; francofona", even double encoded),
+// tags (e.g. "<br>" in the names of the teachers) and irregular spacing.
+// The timetables are cleaned once, when they are fetched, so every format
+// serializes the same text.
+
+// htmlTag matches an HTML tag, e.g. "<br/>" or "</b>".
+var htmlTag = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+
+// maxUnescapes is how many times cleanText decodes the entities, for the
+// text encoded more than once.
+const maxUnescapes = 3
+
+// cleanText decodes the HTML entities of s, removes its tags and collapses
+// its whitespace.
+func cleanText(s string) string {
+	for range maxUnescapes {
+		unescaped := html.UnescapeString(s)
+		if unescaped == s {
+			break
+		}
+		s = unescaped
+	}
+	s = htmlTag.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cleanEvent returns the event with its text fields cleaned, see cleanText.
+func cleanEvent(e timetable.Event) timetable.Event {
+	e.Title = cleanText(e.Title)
+	e.Teacher = cleanText(e.Teacher)
+	e.Interval = cleanText(e.Interval)
+	e.CalendarInterval = cleanText(e.CalendarInterval)
+
+	if len(e.Classrooms) > 0 {
+		classrooms := make([]timetable.Classroom, len(e.Classrooms))
+		for i, c := range e.Classrooms {
+			c.ResourceDesc = cleanText(c.ResourceDesc)
+			c.BuildingDesc = cleanText(c.BuildingDesc)
+			c.AddressDesc = cleanText(c.AddressDesc)
+			classrooms[i] = c
+		}
+		e.Classrooms = classrooms
+	}
+	return e
+}
+
+// cleanTimetable cleans the events of t in place.
+func cleanTimetable(t timetable.Timetable) {
+	for i := range t {
+		t[i] = cleanEvent(t[i])
+	}
+}
+
+// titleCase converts a name written in uppercase, as most teachings are
+// (e.g. "ANALISI MATEMATICA T-1"), to title case ("Analisi Matematica T-1").
+// The stop words are lowercase, except the first one, and the words with
+// digits and the roman numerals are kept. Names not in uppercase are
+// returned as they are, since their casing is intentional.
+func titleCase(s string) string {
+	if strings.ToUpper(s) != s || !strings.ContainsFunc(s, unicode.IsLetter) {
+		return s
+	}
+
+	words := strings.Split(s, " ")
+	for i, word := range words {
+		words[i] = titleCaseWord(word, i == 0)
+	}
+	return strings.Join(words, " ")
+}
+
+// titleCaseWord converts a word of titleCase, handling the elisions (e.g.
+// "DELL'INFORMAZIONE" becomes "dell'Informazione").
+func titleCaseWord(word string, first bool) string {
+	if prefix, rest, found := strings.Cut(word, "'"); found && rest != "" {
+		return titleCaseWord(prefix, first) + "'" + titleCaseWord(rest, true)
+	}
+
+	switch {
+	case strings.ContainsFunc(word, unicode.IsDigit) || isRomanNumeral(word):
+		return word
+	case titleStopWords[strings.ToLower(word)] && !first:
+		return strings.ToLower(word)
+	}
+
+	// The first letter, not rune, e.g. "(ESERCITAZIONI)"
+	lower := strings.ToLower(word)
+	i := strings.IndexFunc(lower, unicode.IsLetter)
+	if i < 0 {
+		return lower
+	}
+	r, size := utf8.DecodeRuneInString(lower[i:])
+	return lower[:i] + string(unicode.ToUpper(r)) + lower[i+size:]
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_cleanText(t *testing.T) {
+	assert.Equal(t, "Lingua & letteratura", cleanText("Lingua &amp; letteratura"))
+	assert.Equal(t, "Lingua & letteratura", cleanText("Lingua &amp;amp; letteratura"))
+	assert.Equal(t, "Mario Rossi Anna Bianchi", cleanText("Mario Rossi<br/>Anna  Bianchi "))
+	assert.Equal(t, "L'aula è chiusa", cleanText("L&#39;aula &egrave;\n chiusa"))
+	assert.Equal(t, "a < b", cleanText("a &lt; b"))
+}
+
+func Test_cleanEvent(t *testing.T) {
+	var c timetable.Classroom
+	c.ResourceDesc = "AULA&nbsp;1"
+	original := timetable.Event{
+		Title:      "FISICA <b>GENERALE</b>",
+		Teacher:    "Mario  Rossi",
+		Classrooms: []timetable.Classroom{c},
+	}
+
+	e := cleanEvent(original)
+	assert.Equal(t, "FISICA GENERALE", e.Title)
+	assert.Equal(t, "Mario Rossi", e.Teacher)
+	assert.Equal(t, "AULA 1", e.Classrooms[0].ResourceDesc)
+	// The classrooms of the original event are not modified
+	assert.Equal(t, "AULA&nbsp;1", original.Classrooms[0].ResourceDesc)
+}
+
+func Test_titleCase(t *testing.T) {
+	assert.Equal(t, "Analisi Matematica T-1", titleCase("ANALISI MATEMATICA T-1"))
+	assert.Equal(t, "Fondamenti di Informatica II", titleCase("FONDAMENTI DI INFORMATICA II"))
+	assert.Equal(t, "Teoria dell'Informazione (Esercitazioni)", titleCase("TEORIA DELL'INFORMAZIONE (ESERCITAZIONI)"))
+	assert.Equal(t, "Il Laboratorio", titleCase("IL LABORATORIO"))
+	assert.Equal(t, "Fisica I", titleCase("FISICA I"))
+	assert.Equal(t, "Basi di dati", titleCase("Basi di dati"))
+	assert.Equal(t, "123", titleCase("123"))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// cachedTimetable is a timetable with the hash of its content.
+type cachedTimetable struct {
+	timetable timetable.Timetable
+	hash      string
+	// stale is set when the timetable is the last snapshot, because the
+	// upstream is failing
+	stale bool
+}
+
+// timetableTTL is how long a timetable is cached, adapted to the academic
+// period, see adaptiveTTL.
+const timetableTTL = time.Minute * 10
+
+// staleTimetableTTL is how long a stale timetable is cached, before trying
+// the upstream again.
+const staleTimetableTTL = time.Minute
+
+// timetableCache contains the timetables fetched from the upstream, for the
+// features that need the events rather than a rendered feed, bounded by
+// [Config.TimetableCacheMaxEntries].
+var timetableCache = newLruCache[cachedTimetable](timetableTTL, time.Minute*30, config.TimetableCacheMaxEntries, 0, nil)
+
+// getTimetable returns the timetable of the course year, using the cache
+// when possible. Fetched timetables are also saved as snapshots.
+//
+// If the upstream fails or returns a timetable with an unknown format (see
+// validateTimetable), the last snapshot is returned instead.
+func getTimetable(course *unibo_integ.Course, year int, curr curriculum.Curriculum) (timetable.Timetable, error) {
+	cached, err := getCachedTimetable(course, year, curr)
+	return cached.timetable, err
+}
+
+// getCachedTimetable is like getTimetable, but also returns the hash of the
+// content of the timetable, which changes only when the upstream one does,
+// and whether it is a stale snapshot.
+func getCachedTimetable(course *unibo_integ.Course, year int, curr curriculum.Curriculum) (cachedTimetable, error) {
+	key := fmt.Sprintf("%d-%d-%s", course.Codice, year, curr.Value)
+	if cached, found := timetableCache.Get(key); found {
+		return cached, nil
+	}
+
+	t, err := fetchTimetable(course, year, curr)
+	if err == nil {
+		cleanTimetable(t)
+		err = validateTimetable(key, t)
+	}
+	if errors.Is(err, errOverloaded) {
+		return cachedTimetable{}, err
+	} else if err != nil {
+		// Better an outdated calendar than none, or a garbage one
+		last, found := lastGoodTimetable(course.Codice, year, curr.Value)
+		if !found {
+			return cachedTimetable{}, err
+		}
+		log.Warn().Err(err).Int("course-code", course.Codice).Msg("serving the last timetable snapshot")
+
+		cached := cachedTimetable{timetable: last, hash: timetableHash(last), stale: true}
+		timetableCache.SetWithTTL(key, cached, staleTimetableTTL)
+		return cached, nil
+	}
+
+	indexTimetable(course, feedRef{Course: course.Codice, Year: year, Curriculum: curr.Value}, t)
+
+	err = saveSnapshot(course.Codice, year, curr.Value, time.Now(), t)
+	if err != nil {
+		log.Warn().Err(err).Int("course-code", course.Codice).Msg("unable to save timetable snapshot")
+	}
+
+	cached := cachedTimetable{timetable: t, hash: timetableHash(t)}
+	timetableCache.SetWithTTL(key, cached, adaptiveTTL(timetableTTL, time.Now()))
+	return cached, nil
+}
+
+// timetableHash returns a hash of the content of the timetable.
+func timetableHash(t timetable.Timetable) string {
+	data, _ := json.Marshal(t)
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
+// indexRetention is how long a feed stays in the indexes built from the
+// timetables after its timetable was last fetched.
+const indexRetention = time.Hour * 24 * 7
+
+// indexedFeeds are the feeds in the indexes built from the timetables, at
+// most as many as the timetables cached (see
+// [Config.TimetableCacheMaxEntries]).
+var indexedFeeds = &feedTracker{last: map[feedRef]time.Time{}}
+
+// feedTracker tracks when the feeds were last indexed, so the indexes only
+// keep the feeds still in use.
+type feedTracker struct {
+	mu   sync.Mutex
+	last map[feedRef]time.Time
+}
+
+// touch records that the feed was indexed at now, and returns the feeds to
+// remove from the indexes: the ones not indexed for indexRetention and, if
+// there are more than maxFeeds, the least recently indexed ones.
+func (ft *feedTracker) touch(feed feedRef, now time.Time, maxFeeds int) []feedRef {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.last[feed] = now
+
+	var expired []feedRef
+	for f, last := range ft.last {
+		if now.Sub(last) > indexRetention {
+			expired = append(expired, f)
+			delete(ft.last, f)
+		}
+	}
+
+	if len(ft.last) > maxFeeds {
+		feeds := make([]feedRef, 0, len(ft.last))
+		for f := range ft.last {
+			feeds = append(feeds, f)
+		}
+		slices.SortFunc(feeds, func(a, b feedRef) int { return ft.last[a].Compare(ft.last[b]) })
+		for _, f := range feeds[:len(feeds)-maxFeeds] {
+			expired = append(expired, f)
+			delete(ft.last, f)
+		}
+	}
+	return expired
+}
+
+// indexTimetable adds a timetable just fetched to the indexes built from
+// the timetables, and removes the feeds not used anymore.
+func indexTimetable(course *unibo_integ.Course, feed feedRef, t timetable.Timetable) {
+	if isEnglishWebsite(course) {
+		englishTitles.index(t)
+	}
+	teachers.index(feed, t)
+	teachings.index(feed, t)
+	rooms.index(feed, t)
+	scheduleChanges.observe(feed, t, time.Now())
+
+	for _, f := range indexedFeeds.touch(feed, time.Now(), max(config.TimetableCacheMaxEntries, 1)) {
+		teachers.forget(f)
+		teachings.forget(f)
+		rooms.forget(f)
+		scheduleChanges.forget(f)
+	}
+}
+
+// eventHash returns a hash of every field of the event, which changes only
+// when the event changes.
+func eventHash(event timetable.Event) string {
+	data, _ := json.Marshal(event)
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
+// feedTimetable returns the timetable of the feed, with only the subjects
+// with the module codes if not nil.
+func feedTimetable(courses *unibo_integ.Courses, feed feedRef, codes []string) (*unibo_integ.Course, timetable.Timetable, error) {
+	course, found := courses.Load().FindById(feed.Course)
+	if !found {
+		return nil, nil, fmt.Errorf("course %d not found", feed.Course)
+	}
+
+	t, err := getTimetable(course, feed.Year, curriculum.Curriculum{Value: feed.Curriculum})
+	if err != nil {
+		return nil, nil, err
+	}
+	if codes != nil {
+		t = filterTimetableBySubjects(nil, t, codes)
+	}
+	return course, t, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_feedVersion(t *testing.T) {
+	start := time.Date(2024, time.September, 23, 9, 0, 0, 0, time.UTC)
+	t1 := timetable.Timetable{{CodModulo: "28004_1", Title: "ALGEBRA", Start: timetable.CalendarTime{Time: start}}}
+	t2 := timetable.Timetable{{CodModulo: "28004_1", Title: "ALGEBRA", Start: timetable.CalendarTime{Time: start.Add(time.Hour)}}}
+
+	course := testCourse(8009)
+	assert.Equal(t, feedVersion(&course, timetableHash(t1), calOptions{}), feedVersion(&course, timetableHash(t1), calOptions{}))
+	assert.NotEqual(t, feedVersion(&course, timetableHash(t1), calOptions{}), feedVersion(&course, timetableHash(t2), calOptions{}))
+
+	renamed := course
+	renamed.Descrizione = "INFORMATICA PER IL MANAGEMENT"
+	assert.NotEqual(t, feedVersion(&course, timetableHash(t1), calOptions{}), feedVersion(&renamed, timetableHash(t1), calOptions{}))
+
+	// The combined feeds change with the academic calendar
+	defer academicCalendar.Store(academicCalendar.Load())
+	combined := calOptions{Combined: true}
+	before := feedVersion(&course, timetableHash(t1), combined)
+	lessons := feedVersion(&course, timetableHash(t1), calOptions{})
+	entries := append(slices.Clone(academicEntries()), academicEntry{Name: "Sessione invernale", Category: categoryExams, Start: "2025-01-07", End: "2025-02-21"})
+	academicCalendar.Store(&entries)
+	assert.NotEqual(t, before, feedVersion(&course, timetableHash(t1), combined))
+	assert.Equal(t, lessons, feedVersion(&course, timetableHash(t1), calOptions{}))
+}
+
+func Test_cachedFeedFresh(t *testing.T) {
+	f := newCachedFeed([]byte("BEGIN:VCALENDAR"), "v1")
+	assert.Equal(t, true, f.fresh())
+
+	f.freshUntil = time.Now().Add(-time.Second)
+	assert.Equal(t, false, f.fresh())
+}
+
+func Test_feedTracker(t *testing.T) {
+	ft := &feedTracker{last: map[feedRef]time.Time{}}
+	now := time.Date(2024, time.October, 1, 9, 0, 0, 0, time.UTC)
+	a, b, c := feedRef{Course: 8009, Year: 1}, feedRef{Course: 8009, Year: 2}, feedRef{Course: 9254, Year: 1}
+
+	assert.Equal(t, 0, len(ft.touch(a, now, 2)))
+	assert.Equal(t, 0, len(ft.touch(b, now.Add(time.Minute), 2)))
+	assert.Equal(t, 0, len(ft.touch(a, now.Add(time.Minute*2), 2)))
+
+	// b is the least recently indexed
+	assert.Equal(t, []feedRef{b}, ft.touch(c, now.Add(time.Minute*3), 2))
+
+	assert.Equal(t, []feedRef{c}, ft.touch(a, now.Add(indexRetention+time.Minute*4), 2))
+	assert.Equal(t, 1, len(ft.last))
+}
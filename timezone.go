@@ -0,0 +1,91 @@
+package main
+
+import (
+	"time"
+	// The container image has no timezone database, and without it
+	// Europe/Rome can't be loaded
+	_ "time/tzdata"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// The lessons take place in Italy, so their times are emitted as local times
+// of Europe/Rome (DTSTART;TZID=Europe/Rome:20241028T090000), described by a
+// VTIMEZONE, rather than converted to UTC. This way a lesson at 9:00 stays at
+// 9:00 across the DST switches of March and October, also when it recurs.
+
+const romeTzid = "Europe/Rome"
+
+// icsLocalLayout is the layout of the local date-times of RFC 5545.
+const icsLocalLayout = "20060102T150405"
+
+// romeLocation is the timezone of the lessons.
+var romeLocation = mustLoadLocation(romeTzid)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		// The database is embedded, so this never happens
+		panic(err)
+	}
+	return loc
+}
+
+// romeDate returns the day of t in Europe/Rome, at midnight UTC, so that the
+// days can be compared and subtracted without the DST switches in between.
+func romeDate(t time.Time) time.Time {
+	y, m, d := t.In(romeLocation).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween returns the number of days from the day of a to the day of b,
+// in Europe/Rome. Around a DST switch a day lasts 23 or 25 hours, so this is
+// not b.Sub(a) / 24h.
+func daysBetween(a, b time.Time) int {
+	return int(romeDate(b).Sub(romeDate(a)).Hours()) / 24
+}
+
+// romeTimeValue returns t as a local date-time of Europe/Rome, to be used
+// with withRomeTzid.
+func romeTimeValue(t time.Time) string {
+	return t.In(romeLocation).Format(icsLocalLayout)
+}
+
+// withRomeTzid is the TZID parameter of the date-times of romeTimeValue.
+func withRomeTzid() ics.PropertyParameter {
+	return &ics.KeyValues{Key: string(ics.ParameterTzid), Value: []string{romeTzid}}
+}
+
+// setRomeTimes sets DTSTART and DTEND of the event to local times of
+// Europe/Rome.
+func setRomeTimes(e *ics.VEvent, start time.Time, end time.Time) {
+	e.SetProperty(ics.ComponentPropertyDtStart, romeTimeValue(start), withRomeTzid())
+	e.SetProperty(ics.ComponentPropertyDtEnd, romeTimeValue(end), withRomeTzid())
+}
+
+// addRomeTimezone adds the VTIMEZONE of Europe/Rome to the calendar, needed
+// by the TZID parameters. It has the EU rules of the DST, in force since
+// 1996: CEST from the last Sunday of March at 2:00 to the last Sunday of
+// October at 3:00.
+func addRomeTimezone(cal *ics.Calendar) {
+	cal.SetXWRTimezone(romeTzid)
+
+	tz := cal.AddTimezone(romeTzid)
+	tz.SetProperty("X-LIC-LOCATION", romeTzid)
+
+	daylight := &ics.Daylight{}
+	daylight.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetfrom), "+0100")
+	daylight.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetto), "+0200")
+	daylight.SetProperty(ics.ComponentProperty(ics.PropertyTzname), "CEST")
+	daylight.SetProperty(ics.ComponentPropertyDtStart, "19700329T020000")
+	daylight.SetProperty(ics.ComponentPropertyRrule, "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU")
+
+	standard := &ics.Standard{}
+	standard.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetfrom), "+0200")
+	standard.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetto), "+0100")
+	standard.SetProperty(ics.ComponentProperty(ics.PropertyTzname), "CET")
+	standard.SetProperty(ics.ComponentPropertyDtStart, "19701025T030000")
+	standard.SetProperty(ics.ComponentPropertyRrule, "FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU")
+
+	tz.Components = append(tz.Components, daylight, standard)
+}
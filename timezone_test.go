@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+// dstTimetable returns a lesson at 9:00 on the Mondays around the switches
+// to and from the summer time of 2025 (March 30th and October 26th), as
+// returned by Unibo.
+func dstTimetable(t *testing.T) timetable.Timetable {
+	var tt timetable.Timetable
+	err := json.Unmarshal([]byte(`[
+		{"cod_modulo": "00001_1", "title": "ALGEBRA", "start": "2025-03-24T09:00:00", "end": "2025-03-24T11:00:00"},
+		{"cod_modulo": "00001_1", "title": "ALGEBRA", "start": "2025-03-31T09:00:00", "end": "2025-03-31T11:00:00"},
+		{"cod_modulo": "00002_1", "title": "FISICA", "start": "2025-10-20T09:00:00", "end": "2025-10-20T11:00:00"},
+		{"cod_modulo": "00002_1", "title": "FISICA", "start": "2025-10-27T09:00:00", "end": "2025-10-27T11:00:00"}
+	]`), &tt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tt
+}
+
+func Test_daysBetween(t *testing.T) {
+	// 22 hours and a half, but on the next day
+	a := time.Date(2025, time.March, 29, 12, 0, 0, 0, romeLocation)
+	b := time.Date(2025, time.March, 30, 11, 30, 0, 0, romeLocation)
+	assert.Equal(t, 1, daysBetween(a, b))
+
+	// A week of 169 hours
+	a = time.Date(2025, time.October, 20, 9, 0, 0, 0, romeLocation)
+	assert.Equal(t, 7, daysBetween(a, a.AddDate(0, 0, 7)))
+	assert.Equal(t, 169*time.Hour, a.AddDate(0, 0, 7).Sub(a))
+
+	assert.Equal(t, time.Date(2025, time.October, 26, 0, 0, 0, 0, time.UTC),
+		romeDate(time.Date(2025, time.October, 25, 23, 30, 0, 0, time.UTC)))
+}
+
+func Test_createEventsCal_dst(t *testing.T) {
+	tt := dstTimetable(t)
+	// 9:00 is 8:00 UTC in winter and 7:00 UTC in summer
+	assert.Equal(t, 8, tt[0].Start.UTC().Hour())
+	assert.Equal(t, 7, tt[1].Start.UTC().Hour())
+
+	cal, err := createEventsCal(tt, calOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := cal.Serialize()
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+	assert.Equal(t, true, strings.Contains(data, "X-WR-TIMEZONE:Europe/Rome\r\n"))
+	assert.Equal(t, 1, strings.Count(data, "BEGIN:VTIMEZONE"))
+	for _, day := range []string{"20250324", "20250331", "20251020", "20251027"} {
+		assert.Equal(t, true, strings.Contains(data, "DTSTART;TZID=Europe/Rome:"+day+"T090000\r\n"))
+		assert.Equal(t, true, strings.Contains(data, "DTEND;TZID=Europe/Rome:"+day+"T110000\r\n"))
+	}
+}
+
+func Test_createEventsCal_dstRecurring(t *testing.T) {
+	tt := dstTimetable(t)
+	assert.Equal(t, 2, len(groupLessonSeries(tt)))
+
+	cal, err := createEventsCal(tt, calOptions{Recurring: true, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := cal.Serialize()
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+	assert.Equal(t, 2, strings.Count(data, "BEGIN:VEVENT"))
+	assert.Equal(t, true, strings.Contains(data, "RRULE:FREQ=WEEKLY;UNTIL=20250331T070000Z\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "RRULE:FREQ=WEEKLY;UNTIL=20251027T080000Z\r\n"))
+}
+
+func Test_validateICS_timezones(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:test",
+		"BEGIN:VEVENT",
+		"UID:1",
+		"DTSTAMP:20250101T000000Z",
+		"DTSTART;TZID=Europe/Rome:20250331T090000",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	problems := validateICS([]byte(data))
+	assert.Equal(t, 1, len(problems))
+	assert.Equal(t, "TZID Europe/Rome has no VTIMEZONE", problems[0].Message)
+	assert.Equal(t, 7, problems[0].Line)
+}
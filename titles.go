@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// Values of ?titles=
+const (
+	// titlesFull uses the official names of the teachings (the default)
+	titlesFull = "full"
+	// titlesShort abbreviates the long names, see shortTitle
+	titlesShort = "short"
+	// titlesCode uses the subject codes
+	titlesCode = "code"
+)
+
+// maxShortTitleLen is the length of the names that shortTitle doesn't
+// abbreviate.
+const maxShortTitleLen = 20
+
+// titleStopWords are not included in the abbreviations.
+var titleStopWords = map[string]bool{
+	"di": true, "del": true, "dello": true, "della": true, "dei": true, "degli": true, "delle": true,
+	"e": true, "ed": true, "a": true, "al": true, "alla": true, "per": true, "in": true, "il": true,
+	"la": true, "le": true, "lo": true, "gli": true, "i": true, "con": true, "l": true, "dell": true,
+	"all": true, "nell": true, "nel": true, "nella": true, "un": true, "una": true,
+	"of": true, "and": true, "the": true, "to": true, "for": true,
+}
+
+// shortTitle abbreviates a long teaching name to the initials of its words,
+// keeping the words with digits, the roman numerals and the text in
+// parentheses, e.g. "ANALISI MATEMATICA T-1 (Esercitazioni)" becomes
+// "AM T-1 (Esercitazioni)".
+func shortTitle(title string) string {
+	if utf8.RuneCountInString(title) <= maxShortTitleLen {
+		return title
+	}
+
+	suffix := ""
+	if i := strings.Index(title, "("); i > 0 {
+		title, suffix = title[:i], " "+strings.TrimSpace(title[i:])
+	}
+
+	var initials strings.Builder
+	var kept []string
+	for _, word := range strings.FieldsFunc(title, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\'' || r == '’'
+	}) {
+		switch {
+		case strings.ContainsFunc(word, unicode.IsDigit) || isRomanNumeral(word):
+			kept = append(kept, word)
+		case titleStopWords[strings.ToLower(word)]:
+		default:
+			if r, _ := utf8.DecodeRuneInString(word); unicode.IsLetter(r) {
+				initials.WriteRune(unicode.ToUpper(r))
+			}
+		}
+	}
+
+	short := strings.Join(append([]string{initials.String()}, kept...), " ")
+	return strings.TrimSpace(short) + suffix
+}
+
+func isRomanNumeral(word string) bool {
+	return strings.Trim(strings.ToUpper(word), "IVX") == "" && len(word) <= 4
+}
+
+// eventSummary returns the SUMMARY of the event according to the ?titles=
+// mode, and its description. When the summary is not the full name, the
+// name is added to the description.
+func eventSummary(event timetable.Event, mode string) (string, string) {
+	summary := event.Title
+	switch mode {
+	case titlesShort:
+		summary = shortTitle(event.Title)
+	case titlesCode:
+		if code := subjectCode(event); code != "" {
+			summary = code
+		}
+	}
+
+	description := eventDescription(event)
+	if summary != event.Title {
+		description = event.Title + "\n" + description
+	}
+	return summary, description
+}
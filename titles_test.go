@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_shortTitle(t *testing.T) {
+	assert.Equal(t, "ALGEBRA", shortTitle("ALGEBRA"))
+	assert.Equal(t, "LP", shortTitle("LABORATORIO DI PROGRAMMAZIONE"))
+	assert.Equal(t, "AM T-1 (Esercitazioni)", shortTitle("ANALISI MATEMATICA T-1 (Esercitazioni)"))
+	assert.Equal(t, "FMA II", shortTitle("FISICA MATEMATICA APPLICATA II"))
+	assert.Equal(t, "FI", shortTitle("FONDAMENTI DELL'INFORMATICA"))
+}
+
+func Test_eventSummary(t *testing.T) {
+	tt := testTimetable()
+	tt[1].Title = "ANALISI MATEMATICA E GEOMETRIA"
+
+	summary, description := eventSummary(tt[1], titlesShort)
+	assert.Equal(t, "AMG", summary)
+	assert.Equal(t, true, strings.HasPrefix(description, "ANALISI MATEMATICA E GEOMETRIA\n"))
+
+	summary, _ = eventSummary(tt[0], titlesCode)
+	assert.Equal(t, "00001", summary)
+
+	summary, description = eventSummary(tt[1], "")
+	assert.Equal(t, tt[1].Title, summary)
+	assert.Equal(t, eventDescription(tt[1]), description)
+}
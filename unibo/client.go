@@ -0,0 +1,205 @@
+package unibo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxCurriculaFetches is the maximum number of concurrent requests made by
+// [Client.AllCurricula].
+const maxCurriculaFetches = 4
+
+// Client fetches the data of the courses from the Unibo websites. It
+// remembers the website ids of the courses, so they are scraped only once.
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	http *http.Client
+
+	mu         sync.RWMutex
+	websiteIds map[int]CourseId
+}
+
+// NewClient creates a client making the requests with httpClient, or with
+// [http.DefaultClient] if nil.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{http: httpClient, websiteIds: make(map[int]CourseId)}
+}
+
+// get returns the body of the page at url.
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{Url: url, StatusCode: res.StatusCode}
+	}
+	return io.ReadAll(res.Body)
+}
+
+// CachedWebsiteId returns the website id of the course, only if it's already
+// known.
+func (c *Client) CachedWebsiteId(code int) (CourseId, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, found := c.websiteIds[code]
+	return id, found
+}
+
+// RememberWebsiteId sets the website id of the course, so it's not scraped.
+func (c *Client) RememberWebsiteId(code int, id CourseId) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.websiteIds[code] = id
+}
+
+// ForgetWebsiteId removes the website id of the course, so it's scraped
+// again, e.g. when the website of the course changes.
+func (c *Client) ForgetWebsiteId(code int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.websiteIds, code)
+}
+
+// WebsiteId returns the [CourseId] of the course, scraping it from the course
+// website if it's not already known.
+func (c *Client) WebsiteId(ctx context.Context, course Course) (CourseId, error) {
+	if id, found := c.CachedWebsiteId(course.Codice); found {
+		return id, nil
+	}
+
+	body, err := c.get(ctx, course.Url)
+	if err != nil {
+		return CourseId{}, fmt.Errorf("unable to get course website: %w", err)
+	}
+	id, err := parseWebsiteId(string(body))
+	if err != nil {
+		return CourseId{}, err
+	}
+
+	c.RememberWebsiteId(course.Codice, id)
+	return id, nil
+}
+
+var websiteLink = regexp.MustCompile(`<a .* href="https://corsi\.unibo\.it/(.+?)"`)
+
+// parseWebsiteId finds the [CourseId] in the link to corsi.unibo.it of the
+// course website.
+func parseWebsiteId(page string) (CourseId, error) {
+	found := websiteLink.FindStringSubmatch(page)
+	if found == nil {
+		return CourseId{}, ErrWebsiteNotFound
+	}
+
+	// laurea/IngegneriaInformatica -> IngegneriaInformatica
+	split := strings.Split(found[1], "/")
+	if len(split) != 2 {
+		return CourseId{}, fmt.Errorf("%w: course website link %q", ErrUnexpectedResponse, found[1])
+	}
+	return CourseId{split[0], split[1]}, nil
+}
+
+// Curricula returns the curricula of a year of the course.
+func (c *Client) Curricula(ctx context.Context, course Course, year int) (Curricula, error) {
+	id, err := c.WebsiteId(ctx, course)
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchCurricula(ctx, id, year)
+}
+
+func (c *Client) fetchCurricula(ctx context.Context, id CourseId, year int) (Curricula, error) {
+	url := curriculum.GetCurriculaUrl(id.Tipologia, id.Id, year)
+	body, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	// The website responds with an error page, but status 200
+	if strings.Contains(string(body), "error") {
+		return nil, fmt.Errorf("%w: error page for %s", ErrUnexpectedResponse, url)
+	}
+
+	var curricula Curricula
+	err = json.Unmarshal(body, &curricula)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)
+	}
+	return curricula, nil
+}
+
+// AllCurricula returns the curricula of every year of the course.
+//
+// The years are fetched concurrently, at most maxCurriculaFetches at a time.
+func (c *Client) AllCurricula(ctx context.Context, course Course) (map[int]Curricula, error) {
+	id, err := c.WebsiteId(ctx, course)
+	if err != nil {
+		return nil, fmt.Errorf("could not get course website id: %w", err)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxCurriculaFetches)
+
+	var mu sync.Mutex
+	curriculaMap := make(map[int]Curricula, course.DurataAnni)
+
+	for year := 1; year <= course.DurataAnni; year++ {
+		g.Go(func() error {
+			curricula, err := c.fetchCurricula(ctx, id, year)
+			if err != nil {
+				return fmt.Errorf("could not fetch curricula for year %d: %w", year, err)
+			}
+
+			mu.Lock()
+			curriculaMap[year] = curricula
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	err = g.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return curriculaMap, nil
+}
+
+// Timetable returns the lessons of a year and curriculum of the course, in
+// the period if not nil.
+func (c *Client) Timetable(ctx context.Context, course Course, year int, curr Curriculum, period *Interval) (Timetable, error) {
+	id, err := c.WebsiteId(ctx, course)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(ctx, timetable.GetTimetableUrl(id.Tipologia, id.Id, curr.Value, year, period))
+	if err != nil {
+		return nil, err
+	}
+
+	var t Timetable
+	err = json.Unmarshal(body, &t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,100 @@
+package unibo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// fakeTransport responds to the requests with the pages of the paths.
+type fakeTransport struct {
+	pages    map[string]string
+	requests int
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	page, found := t.pages[req.URL.Path]
+	status := http.StatusOK
+	if !found {
+		status = http.StatusNotFound
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(page)), Request: req}, nil
+}
+
+var testCourse = Course{Codice: 9254, Descrizione: "INGEGNERIA INFORMATICA", DurataAnni: 2,
+	Url: "https://www.unibo.it/it/didattica/corsi-di-studio/corso/2024/9254"}
+
+func newTestClient() (*Client, *fakeTransport) {
+	transport := &fakeTransport{pages: map[string]string{
+		"/it/didattica/corsi-di-studio/corso/2024/9254":                          `<a class="x" href="https://corsi.unibo.it/magistrale/IngegneriaInformatica">Sito</a>`,
+		"/magistrale/IngegneriaInformatica/orario-lezioni/@@available_curricula": `[{"selected":false,"value":"000-000","label":"GENERALE"}]`,
+		"/magistrale/IngegneriaInformatica/orario-lezioni/@@orario_reale_json":   `[{"title":"RETI","docente":"Mario Rossi","start":"2024-10-14T09:00:00","end":"2024-10-14T11:00:00"}]`,
+	}}
+	return NewClient(&http.Client{Transport: transport}), transport
+}
+
+func Test_parseWebsiteId(t *testing.T) {
+	id, err := parseWebsiteId(`<a class="x" href="https://corsi.unibo.it/laurea/Informatica">`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, CourseId{"laurea", "Informatica"}, id)
+
+	_, err = parseWebsiteId(`<p>Nessun link</p>`)
+	assert.Equal(t, true, errors.Is(err, ErrWebsiteNotFound))
+
+	_, err = parseWebsiteId(`<a class="x" href="https://corsi.unibo.it/laurea/Informatica/orario">`)
+	assert.Equal(t, true, errors.Is(err, ErrUnexpectedResponse))
+}
+
+func Test_ClientWebsiteId(t *testing.T) {
+	client, transport := newTestClient()
+
+	id, err := client.WebsiteId(context.Background(), testCourse)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, CourseId{"magistrale", "IngegneriaInformatica"}, id)
+
+	// Scraped only once
+	_, _ = client.WebsiteId(context.Background(), testCourse)
+	assert.Equal(t, 1, transport.requests)
+
+	client.ForgetWebsiteId(testCourse.Codice)
+	_, found := client.CachedWebsiteId(testCourse.Codice)
+	assert.Equal(t, false, found)
+}
+
+func Test_ClientAllCurricula(t *testing.T) {
+	client, _ := newTestClient()
+
+	curricula, err := client.AllCurricula(context.Background(), testCourse)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(curricula))
+	assert.Equal(t, "000-000", curricula[2][0].Value)
+}
+
+func Test_ClientTimetable(t *testing.T) {
+	client, _ := newTestClient()
+
+	tt, err := client.Timetable(context.Background(), testCourse, 1, Curriculum{Value: "000-000"}, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(tt))
+	assert.Equal(t, "RETI", tt[0].Title)
+	assert.Equal(t, 9, tt[0].Start.Hour())
+}
+
+func Test_ClientStatusError(t *testing.T) {
+	client, _ := newTestClient()
+
+	course := testCourse
+	course.Codice = 1234
+	course.Url = "https://www.unibo.it/missing"
+	_, err := client.WebsiteId(context.Background(), course)
+
+	var statusErr *StatusError
+	assert.Equal(t, true, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
@@ -0,0 +1,79 @@
+package unibo
+
+import (
+	"strings"
+
+	"github.com/csunibo/unibo-go/curriculum"
+	"github.com/csunibo/unibo-go/timetable"
+)
+
+// Course is a degree programme, as described by the open data of the
+// university.
+type Course struct {
+	AnnoAccademico       string
+	Immatricolabile      string
+	Codice               int
+	Descrizione          string
+	Url                  string
+	Campus               string
+	Ambiti               string
+	Tipologia            string
+	DurataAnni           int
+	Internazionale       bool
+	InternazionaleTitolo string
+	InternazionaleLingua string
+	Lingue               string
+	Accesso              string
+	SedeDidattica        string
+	// Classe is the degree class (e.g. "LM-18"), empty if the open data
+	// does not contain it
+	Classe string
+	// DescrizioneEn and UrlEn are the English name and page of the course,
+	// from the English open data, empty if unknown
+	DescrizioneEn string
+	UrlEn         string
+}
+
+// Name returns the name of the course in the given language ("en" for
+// English), falling back to the Italian one.
+func (c Course) Name(lang string) string {
+	if lang == "en" && c.DescrizioneEn != "" {
+		return c.DescrizioneEn
+	}
+	return c.Descrizione
+}
+
+// Website returns the URL of the page of the course in the given language,
+// falling back to the Italian one.
+func (c Course) Website(lang string) string {
+	if lang == "en" && c.UrlEn != "" {
+		return c.UrlEn
+	}
+	return c.Url
+}
+
+// CourseId identifies the website of a course on corsi.unibo.it, e.g.
+// {"laurea", "IngegneriaInformatica"} for
+// corsi.unibo.it/laurea/IngegneriaInformatica.
+type CourseId struct {
+	Tipologia string
+	Id        string
+}
+
+// CourseSlug returns the slug of a course name, which is how the name
+// appears in the course website, lowercased (e.g. "INGEGNERIA INFORMATICA"
+// -> "ingegneriainformatica" for corsi.unibo.it/laurea/IngegneriaInformatica).
+func CourseSlug(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// The types of the curricula and of the timetables, from unibo-go, so that
+// the users of the package don't need to import it.
+type (
+	Curriculum = curriculum.Curriculum
+	Curricula  = curriculum.Curricula
+	Timetable  = timetable.Timetable
+	Event      = timetable.Event
+	Interval   = timetable.Interval
+	Classroom  = timetable.Classroom
+)
@@ -0,0 +1,14 @@
+// Package unibo fetches the degree programmes of the University of Bologna
+// from their websites: the curricula and the timetables of the lessons.
+//
+// The courses are identified by the [Course] of the open data. Their website
+// id (e.g. "laurea/IngegneriaInformatica"), needed by every other request, is
+// scraped from the course website and remembered by the [Client]:
+//
+//	client := unibo.NewClient(nil)
+//	curricula, err := client.AllCurricula(ctx, course)
+//	t, err := client.Timetable(ctx, course, 1, curricula[1][0], nil)
+//
+// The package has no global state, so more clients, with different HTTP
+// clients, can be used at the same time.
+package unibo
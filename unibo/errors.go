@@ -0,0 +1,25 @@
+package unibo
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrWebsiteNotFound is returned when the course website has no link to
+	// its pages on corsi.unibo.it, so its [CourseId] is unknown.
+	ErrWebsiteNotFound = errors.New("unibo: course website not found")
+	// ErrUnexpectedResponse is returned when a response of Unibo can't be
+	// understood, usually because the website has changed.
+	ErrUnexpectedResponse = errors.New("unibo: unexpected response")
+)
+
+// StatusError is returned when Unibo responds with an error status.
+type StatusError struct {
+	Url        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unibo: status %d for %s", e.StatusCode, e.Url)
+}
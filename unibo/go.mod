@@ -0,0 +1,9 @@
+module github.com/VaiTon/unibocalendar/unibo
+
+go 1.22.1
+
+require (
+	github.com/csunibo/unibo-go v0.0.12
+	github.com/go-playground/assert/v2 v2.2.0
+	golang.org/x/sync v0.10.0
+)
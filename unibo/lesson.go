@@ -0,0 +1,225 @@
+package unibo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lesson is an [Event] of a timetable, with the data that Unibo encodes in
+// strings decoded into typed fields.
+type Lesson struct {
+	Event
+	// Teachers are the names of the teachers of the lesson
+	Teachers []string
+	// Teaching is the code of the teaching and of its module
+	Teaching TeachingCode
+	// Page is the page of the teaching on unibo.it, if known
+	Page TeachingPage
+	// Rooms are the classrooms of the lesson
+	Rooms []Room
+	// Period is the teaching period the lesson belongs to
+	Period Period
+}
+
+// NewLesson decodes the fields of the event.
+func NewLesson(e Event) Lesson {
+	l := Lesson{
+		Event:    e,
+		Teachers: ParseTeachers(e.Teacher),
+		Teaching: ParseTeachingCode(e.CodModulo),
+		Period:   ParsePeriod(e.CalendarInterval, e.Interval),
+	}
+	l.Page, _ = ParseTeachingPage(e.ExtCode)
+	for _, c := range e.Classrooms {
+		l.Rooms = append(l.Rooms, NewRoom(c))
+	}
+	return l
+}
+
+// Lessons decodes the events of the timetable.
+func Lessons(t Timetable) []Lesson {
+	lessons := make([]Lesson, len(t))
+	for i, e := range t {
+		lessons[i] = NewLesson(e)
+	}
+	return lessons
+}
+
+// ParseTeachers returns the names of the teachers of a lesson, which Unibo
+// separates with commas (e.g. "Mario Rossi, Anna Bianchi").
+func ParseTeachers(s string) []string {
+	var teachers []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		if name != "" {
+			teachers = append(teachers, name)
+		}
+	}
+	return teachers
+}
+
+// TeachingCode is the code of a module of a teaching, e.g. "28004_1" for the
+// module 1 of the teaching 28004.
+type TeachingCode struct {
+	Subject string
+	// Module is empty for the teachings without modules
+	Module string
+}
+
+// ParseTeachingCode decodes the cod_modulo of an event.
+func ParseTeachingCode(s string) TeachingCode {
+	subject, module, _ := strings.Cut(strings.TrimSpace(s), "_")
+	return TeachingCode{Subject: subject, Module: module}
+}
+
+// String returns the code as written by Unibo, e.g. "28004_1".
+func (c TeachingCode) String() string {
+	if c.Module == "" {
+		return c.Subject
+	}
+	return c.Subject + "_" + c.Module
+}
+
+// TeachingPage identifies the page of a teaching on unibo.it, where the
+// syllabus is published.
+type TeachingPage struct {
+	// Year is the academic year of the teaching, e.g. 2023 for 2023/2024
+	Year int
+	Id   string
+}
+
+// extCodeRegex matches the extCode of an event, e.g. "2023-000-356354--I",
+// made of the academic year and the id of the teaching.
+var extCodeRegex = regexp.MustCompile(`^(\d{4})-\d+-(\d+)`)
+
+// ParseTeachingPage decodes the extCode of an event.
+func ParseTeachingPage(s string) (TeachingPage, bool) {
+	match := extCodeRegex.FindStringSubmatch(s)
+	if match == nil {
+		return TeachingPage{}, false
+	}
+	year, _ := strconv.Atoi(match[1])
+	return TeachingPage{Year: year, Id: match[2]}, true
+}
+
+// Url returns the URL of the page.
+func (p TeachingPage) Url() string {
+	return fmt.Sprintf("https://www.unibo.it/it/didattica/insegnamenti/insegnamento/%d/%s", p.Year, p.Id)
+}
+
+// Room is a classroom of a lesson.
+type Room struct {
+	Name     string
+	Floor    string
+	Building string
+	Address  string
+	City     string
+	Seats    int
+	// Lat and Lng are zero if the position is unknown
+	Lat float64
+	Lng float64
+}
+
+// NewRoom decodes a classroom of an event, using its raw data when the
+// descriptions are missing.
+func NewRoom(c Classroom) Room {
+	r := Room{
+		Name:     c.ResourceDesc,
+		Floor:    c.FloorDesc,
+		Building: c.BuildingDesc,
+		Address:  c.AddressDesc,
+		City:     c.Raw.Building.Comune,
+		Seats:    c.Raw.Seats,
+		Lat:      c.Raw.Building.Geo.Lat,
+		Lng:      c.Raw.Building.Geo.Lng,
+	}
+	if r.Name == "" {
+		r.Name = c.Raw.Description
+	}
+	if r.Building == "" {
+		r.Building = c.Raw.Building.Description
+	}
+	if r.Address == "" && c.Raw.Building.Via != "" {
+		r.Address = strings.TrimSuffix(c.Raw.Building.Via+" - "+c.Raw.Building.Comune, " - ")
+	}
+	return r
+}
+
+// Period is the teaching period of a lesson, e.g. the first semester.
+type Period struct {
+	// Label is the name of the period, e.g. "1° Ciclo Semestrale"
+	Label string
+	// Cycle is the number of the period in the academic year, 0 if unknown
+	Cycle int
+	// Start and End are the first and the last day of the period, zero if
+	// unknown
+	Start time.Time
+	End   time.Time
+}
+
+// Valid reports whether the days of the period are known.
+func (p Period) Valid() bool {
+	return !p.Start.IsZero() && !p.End.IsZero()
+}
+
+var cycleRegex = regexp.MustCompile(`^(\d+)°`)
+
+// ParsePeriod decodes the periodo_calendario (e.g. "1° Ciclo Semestrale")
+// and the periodo (e.g. "23/09/2024 - 20/12/2024") of an event. Both can
+// contain the days of the period, in the numeric or in the long Italian
+// format ("18 settembre 2023 - 20 dicembre 2023").
+func ParsePeriod(calendarInterval string, interval string) Period {
+	p := Period{Label: strings.TrimSpace(calendarInterval)}
+	if match := cycleRegex.FindStringSubmatch(p.Label); match != nil {
+		p.Cycle, _ = strconv.Atoi(match[1])
+	}
+
+	for _, s := range []string{calendarInterval, interval} {
+		rawStart, rawEnd, found := strings.Cut(s, " - ")
+		if !found {
+			continue
+		}
+		start, okStart := parseItalianDate(rawStart)
+		end, okEnd := parseItalianDate(rawEnd)
+		if okStart && okEnd && !end.Before(start) {
+			p.Start, p.End = start, end
+			break
+		}
+	}
+	return p
+}
+
+var italianMonths = map[string]time.Month{
+	"gennaio": time.January, "febbraio": time.February, "marzo": time.March, "aprile": time.April,
+	"maggio": time.May, "giugno": time.June, "luglio": time.July, "agosto": time.August,
+	"settembre": time.September, "ottobre": time.October, "novembre": time.November, "dicembre": time.December,
+}
+
+// parseItalianDate parses a date like "18 settembre 2023" or "18/09/2023".
+func parseItalianDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("02/01/2006", s); err == nil {
+		return t, true
+	}
+
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) != 3 {
+		return time.Time{}, false
+	}
+	day, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	month, found := italianMonths[fields[1]]
+	if !found {
+		return time.Time{}, false
+	}
+	year, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+}
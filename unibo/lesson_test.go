@@ -0,0 +1,72 @@
+package unibo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_ParseTeachers(t *testing.T) {
+	assert.Equal(t, []string(nil), ParseTeachers(""))
+	assert.Equal(t, []string{"Mario Rossi"}, ParseTeachers(" Mario  Rossi "))
+	assert.Equal(t, []string{"Mario Rossi", "Anna Bianchi"}, ParseTeachers("Mario Rossi, Anna Bianchi,"))
+}
+
+func Test_ParseTeachingCode(t *testing.T) {
+	assert.Equal(t, TeachingCode{Subject: "28004", Module: "1"}, ParseTeachingCode("28004_1"))
+	assert.Equal(t, TeachingCode{Subject: "00819"}, ParseTeachingCode("00819"))
+	assert.Equal(t, "28004_1", ParseTeachingCode("28004_1").String())
+}
+
+func Test_ParseTeachingPage(t *testing.T) {
+	page, found := ParseTeachingPage("2023-000-356354--I")
+	assert.Equal(t, true, found)
+	assert.Equal(t, TeachingPage{Year: 2023, Id: "356354"}, page)
+	assert.Equal(t, "https://www.unibo.it/it/didattica/insegnamenti/insegnamento/2023/356354", page.Url())
+
+	_, found = ParseTeachingPage("")
+	assert.Equal(t, false, found)
+}
+
+func Test_ParsePeriod(t *testing.T) {
+	p := ParsePeriod("1° Ciclo Semestrale", "23/09/2024 - 20/12/2024")
+	assert.Equal(t, "1° Ciclo Semestrale", p.Label)
+	assert.Equal(t, 1, p.Cycle)
+	assert.Equal(t, true, p.Valid())
+	assert.Equal(t, time.Date(2024, time.September, 23, 0, 0, 0, 0, time.UTC), p.Start)
+	assert.Equal(t, time.Date(2024, time.December, 20, 0, 0, 0, 0, time.UTC), p.End)
+
+	p = ParsePeriod("19 Febbraio 2024 - 31 maggio 2024", "")
+	assert.Equal(t, 0, p.Cycle)
+	assert.Equal(t, time.February, p.Start.Month())
+
+	assert.Equal(t, false, ParsePeriod("boh", "31/12/2024 - 01/01/2024").Valid())
+}
+
+func Test_NewLesson(t *testing.T) {
+	var c timetable.Classroom
+	c.ResourceDesc = "AULA E1"
+	c.Raw.Building.Description = "Plesso Ercolani"
+	c.Raw.Building.Via = "Via Filippo Re, 10"
+	c.Raw.Building.Comune = "Bologna"
+	c.Raw.Seats = 120
+
+	l := NewLesson(Event{
+		CodModulo:  "00819_2",
+		Teacher:    "Mario Rossi, Anna Bianchi",
+		Cfu:        12,
+		ExtCode:    "2024-000-400000--I",
+		Interval:   "23/09/2024 - 20/12/2024",
+		Classrooms: []timetable.Classroom{c},
+	})
+
+	assert.Equal(t, []string{"Mario Rossi", "Anna Bianchi"}, l.Teachers)
+	assert.Equal(t, "2", l.Teaching.Module)
+	assert.Equal(t, "400000", l.Page.Id)
+	assert.Equal(t, 12, l.Cfu)
+	assert.Equal(t, true, l.Period.Valid())
+	assert.Equal(t, []Room{{Name: "AULA E1", Building: "Plesso Ercolani", Address: "Via Filippo Re, 10 - Bologna",
+		City: "Bologna", Seats: 120}}, l.Rooms)
+}
@@ -1,191 +1,96 @@
 package unibo_integ
 
 import (
-	"bytes"
-	"fmt"
-	"io"
-	"regexp"
-	"strconv"
-	"strings"
-	"sync"
-
-	"github.com/csunibo/unibo-go/curriculum"
-	"github.com/csunibo/unibo-go/timetable"
-	"github.com/rs/zerolog/log"
-
-	"github.com/patrickmn/go-cache"
+	"slices"
+	"sync/atomic"
+
+	"github.com/VaiTon/unibocalendar/unibo"
 )
 
-type Course struct {
-	AnnoAccademico       string
-	Immatricolabile      string
-	Codice               int
-	Descrizione          string
-	Url                  string
-	Campus               string
-	Ambiti               string
-	Tipologia            string
-	DurataAnni           int
-	Internazionale       bool
-	InternazionaleTitolo string
-	InternazionaleLingua string
-	Lingue               string
-	Accesso              string
-	SedeDidattica        string
-}
+// Course is a degree programme of the open data, see [unibo.Course].
+type Course = unibo.Course
 
-type CourseId struct {
-	Tipologia string
-	Id        string
-}
+// CourseId identifies the website of a course, see [unibo.CourseId].
+type CourseId = unibo.CourseId
 
-var websiteIdCache = cache.New(cache.NoExpiration, cache.NoExpiration)
+// Unibo is the client fetching the curricula and the timetables of the
+// courses, with the [Client] of the server.
+var Unibo = unibo.NewClient(&Client)
 
-// GetCourseWebsiteId returns the [CourseWebsiteId] of the course.
-//
-// If the course website id is already set, it returns it,
-// otherwise it scrapes it from the course website.
-func (c Course) GetCourseWebsiteId() (CourseId, error) {
-	codeStr := strconv.Itoa(c.Codice)
-
-	// If the course website id is already in the cache, return it
-	websiteIdAny, found := websiteIdCache.Get(codeStr)
-	if found {
-		return websiteIdAny.(CourseId), nil
-	}
-
-	// Scrape the course website id and set it
-	websiteId, err := c.scrapeCourseWebsiteId()
-	if err != nil {
-		return CourseId{}, err
-	}
-
-	websiteIdCache.Set(codeStr, websiteId, cache.DefaultExpiration)
-	return websiteId, nil
+// CoursesMap is an immutable snapshot of the courses, indexed by code and by
+// slug. It must not be modified after being created with [NewCoursesMap], so
+// it can be read by many goroutines without locking.
+type CoursesMap struct {
+	// list contains the courses sorted from the newest to the oldest code
+	list   []Course
+	byCode map[int]*Course
+	bySlug map[string][]*Course
 }
 
-var reg = regexp.MustCompile(`<a .* href="https://corsi\.unibo\.it/(.+?)"`)
-
-func (c Course) scrapeCourseWebsiteId() (CourseId, error) {
-
-	resp, err := Client.Get(c.Url)
-	if err != nil {
-		return CourseId{}, fmt.Errorf("unable to get course website: %w", err)
-	}
-
-	log.Debug().Str("url", c.Url).Msg("scraping course website")
-
-	buf := new(bytes.Buffer)
-
-	// Read all body
-	_, err = io.Copy(buf, resp.Body)
-	if err != nil {
-		return CourseId{}, fmt.Errorf("unable to read course website: %w", err)
-	}
-
-	// Close body
-	err = resp.Body.Close()
-	if err != nil {
-		return CourseId{}, fmt.Errorf("unable to close course website: %w", err)
-	}
-
-	// Convert body to string
-	found := reg.FindStringSubmatch(buf.String())
-	if found == nil {
-		return CourseId{}, fmt.Errorf("unable to find course website")
-	} else if len(found) != 2 {
-		return CourseId{}, fmt.Errorf("unexpected number of matches: %d (the website has changed?)", len(found))
+// NewCoursesMap creates a snapshot of the given courses.
+func NewCoursesMap(courses []Course) *CoursesMap {
+	m := &CoursesMap{
+		list:   slices.Clone(courses),
+		byCode: make(map[int]*Course, len(courses)),
+		bySlug: make(map[string][]*Course, len(courses)),
 	}
+	slices.SortFunc(m.list, func(a, b Course) int { return b.Codice - a.Codice })
 
-	// full url -> laurea/IngegneriaInformatica
-	id := found[1]
+	for i := range m.list {
+		course := &m.list[i]
+		m.byCode[course.Codice] = course
 
-	// laurea/IngegneriaInformatica -> IngegneriaInformatica
-	split := strings.Split(id, "/")
-	if len(split) != 2 {
-		return CourseId{}, fmt.Errorf("unexpected number of splits: %d (the website has changed?)", len(split))
+		slug := unibo.CourseSlug(course.Descrizione)
+		m.bySlug[slug] = append(m.bySlug[slug], course)
 	}
-
-	return CourseId{split[0], split[1]}, nil
+	return m
 }
 
-func (c Course) GetCurricula(year int) (curriculum.Curricula, error) {
-	id, err := c.GetCourseWebsiteId()
-	if err != nil {
-		return nil, err
-	}
-
-	curricula, err := curriculum.FetchCurricula(id.Tipologia, id.Id, year)
-	if err != nil {
-		return nil, err
-	}
-
-	return curricula, nil
+// Len returns the number of courses.
+func (c *CoursesMap) Len() int {
+	return len(c.list)
 }
 
-func (c Course) GetAllCurricula() (map[int]curriculum.Curricula, error) {
-	id, err := c.GetCourseWebsiteId()
-	if err != nil {
-		return nil, fmt.Errorf("could not get course website id: %w", err)
-	}
-
-	errCh := make(chan error, c.DurataAnni)
-	var wg sync.WaitGroup
-
-	var mapMutex sync.Mutex
-	curriculaMap := make(map[int]curriculum.Curricula, c.DurataAnni)
-
-	for year := 1; year <= c.DurataAnni; year++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			curricula, err := curriculum.FetchCurricula(id.Tipologia, id.Id, year)
-			if err != nil {
-				errCh <- err
-			} else {
-				mapMutex.Lock()
-				curriculaMap[year] = curricula
-				mapMutex.Unlock()
-			}
-		}()
-	}
-
-	wg.Wait()
-	select {
-	case e := <-errCh:
-		close(errCh)
-		return nil, e
-	default:
-		return curriculaMap, nil
-	}
+// ToList returns the courses, sorted from the newest to the oldest code.
+//
+// The slice is shared by every caller and must not be modified.
+func (c *CoursesMap) ToList() []Course {
+	return c.list
 }
 
-func (c Course) GetTimetable(year int, curriculum curriculum.Curriculum, period *timetable.Interval) (timetable.Timetable, error) {
-	id, err := c.GetCourseWebsiteId()
-	if err != nil {
-		return nil, err
-	}
+// FindById returns the course with the given code.
+func (c *CoursesMap) FindById(id int) (*Course, bool) {
+	course, found := c.byCode[id]
+	return course, found
+}
 
-	t, err := timetable.FetchTimetable(id.Tipologia, id.Id, curriculum.Value, year, period)
-	if err != nil {
-		return nil, err
-	}
+// FindBySlug returns the courses whose name has the given slug, see
+// [unibo.CourseSlug]. More courses can have the same name, e.g. in different
+// campuses.
+func (c *CoursesMap) FindBySlug(slug string) []*Course {
+	return c.bySlug[slug]
+}
 
-	return t, nil
+// Courses holds the current [CoursesMap], which can be swapped while the
+// server is running (e.g. when the open data is refreshed). Handlers should
+// load it once per request, so they work on a consistent snapshot.
+type Courses struct {
+	current atomic.Pointer[CoursesMap]
 }
 
-type CoursesMap map[int]Course
+// NewCourses creates a holder of the given snapshot.
+func NewCourses(m *CoursesMap) *Courses {
+	c := &Courses{}
+	c.current.Store(m)
+	return c
+}
 
-func (c CoursesMap) ToList() []Course {
-	courses := make([]Course, 0, len(c))
-	for _, course := range c {
-		courses = append(courses, course)
-	}
-	return courses
+// Load returns the current snapshot of the courses.
+func (c *Courses) Load() *CoursesMap {
+	return c.current.Load()
 }
 
-func (c CoursesMap) FindById(id int) (*Course, bool) {
-	course, found := c[id]
-	return &course, found
+// Swap replaces the current snapshot, returning the previous one.
+func (c *Courses) Swap(m *CoursesMap) *CoursesMap {
+	return c.current.Swap(m)
 }
@@ -0,0 +1,35 @@
+package unibo_integ
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/VaiTon/unibocalendar/unibo"
+)
+
+func Test_CoursesMap(t *testing.T) {
+	m := NewCoursesMap([]Course{
+		{Codice: 8009, Descrizione: "INFORMATICA", Campus: "Bologna"},
+		{Codice: 9254, Descrizione: "INGEGNERIA INFORMATICA"},
+		{Codice: 8014, Descrizione: "INFORMATICA", Campus: "Cesena"},
+	})
+
+	assert.Equal(t, 3, m.Len())
+	assert.Equal(t, 9254, m.ToList()[0].Codice)
+	assert.Equal(t, 8009, m.ToList()[2].Codice)
+
+	course, found := m.FindById(8014)
+	assert.Equal(t, true, found)
+	assert.Equal(t, "Cesena", course.Campus)
+	_, found = m.FindById(1)
+	assert.Equal(t, false, found)
+
+	assert.Equal(t, 2, len(m.FindBySlug("informatica")))
+	assert.Equal(t, 9254, m.FindBySlug(unibo.CourseSlug("Ingegneria Informatica"))[0].Codice)
+
+	courses := NewCourses(m)
+	previous := courses.Swap(NewCoursesMap(nil))
+	assert.Equal(t, m, previous)
+	assert.Equal(t, 0, courses.Load().Len())
+}
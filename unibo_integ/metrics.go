@@ -0,0 +1,156 @@
+package unibo_integ
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the
+// request duration histograms.
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// endpointMetrics are the metrics of the requests to an upstream endpoint.
+type endpointMetrics struct {
+	// buckets counts the requests by duration, see durationBuckets. The last
+	// one is +Inf.
+	buckets []uint64
+	sum     float64
+	count   uint64
+	// codes counts the responses by status code, "error" for the requests
+	// which got no response.
+	codes map[string]uint64
+}
+
+type upstreamMetrics struct {
+	mutex     sync.Mutex
+	endpoints map[string]*endpointMetrics
+}
+
+var metrics = &upstreamMetrics{endpoints: make(map[string]*endpointMetrics)}
+
+// observe records a request to the endpoint.
+func (m *upstreamMetrics) observe(endpoint string, d time.Duration, code string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e, found := m.endpoints[endpoint]
+	if !found {
+		e = &endpointMetrics{
+			buckets: make([]uint64, len(durationBuckets)+1),
+			codes:   make(map[string]uint64),
+		}
+		m.endpoints[endpoint] = e
+	}
+
+	seconds := d.Seconds()
+	i, _ := slices.BinarySearch(durationBuckets, seconds)
+	e.buckets[i]++
+	e.sum += seconds
+	e.count++
+	e.codes[code]++
+}
+
+// endpointName returns the name of the upstream endpoint of the request,
+// used as label of the metrics, or an empty string for the requests not
+// directed to Unibo.
+func endpointName(req *http.Request) string {
+	host := req.URL.Hostname()
+	switch {
+	case host == "dati.unibo.it":
+		return "opendata"
+	case host == "corsi.unibo.it" && strings.Contains(req.URL.Path, "@@orario_reale_json"):
+		return "timetable"
+	case host == "corsi.unibo.it" && strings.Contains(req.URL.Path, "@@available_curricula"):
+		return "curricula"
+	case host == "corsi.unibo.it":
+		return "course_website"
+	case host == "unibo.it" || strings.HasSuffix(host, ".unibo.it"):
+		return host
+	}
+	return ""
+}
+
+// metricsTransport records the duration and the status code of the requests
+// to Unibo.
+type metricsTransport struct {
+	http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointName(req)
+	if endpoint == "" {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	start := time.Now()
+	res, err := t.RoundTripper.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	metrics.observe(endpoint, time.Since(start), code)
+	return res, err
+}
+
+// InstrumentDefaultClient records the metrics of the requests made to Unibo
+// with [http.DefaultClient], which is used by unibo-go.
+func InstrumentDefaultClient() {
+	transport := http.DefaultClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	http.DefaultClient.Transport = &metricsTransport{transport}
+}
+
+// WriteMetrics writes the metrics of the requests to Unibo in the
+// Prometheus text format.
+func WriteMetrics(w io.Writer) error {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	names := make([]string, 0, len(metrics.endpoints))
+	for name := range metrics.endpoints {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	b := strings.Builder{}
+	b.WriteString("# HELP unibo_request_duration_seconds Duration of the requests to Unibo.\n")
+	b.WriteString("# TYPE unibo_request_duration_seconds histogram\n")
+	for _, name := range names {
+		e := metrics.endpoints[name]
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			cumulative += e.buckets[i]
+			b.WriteString(fmt.Sprintf("unibo_request_duration_seconds_bucket{endpoint=%q,le=%q} %d\n",
+				name, strconv.FormatFloat(le, 'f', -1, 64), cumulative))
+		}
+		b.WriteString(fmt.Sprintf("unibo_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", name, e.count))
+		b.WriteString(fmt.Sprintf("unibo_request_duration_seconds_sum{endpoint=%q} %g\n", name, e.sum))
+		b.WriteString(fmt.Sprintf("unibo_request_duration_seconds_count{endpoint=%q} %d\n", name, e.count))
+	}
+
+	b.WriteString("# HELP unibo_responses_total Responses of Unibo by status code, \"error\" for failed requests.\n")
+	b.WriteString("# TYPE unibo_responses_total counter\n")
+	for _, name := range names {
+		e := metrics.endpoints[name]
+		codes := make([]string, 0, len(e.codes))
+		for code := range e.codes {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			b.WriteString(fmt.Sprintf("unibo_responses_total{endpoint=%q,code=%q} %d\n", name, code, e.codes[code]))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,41 @@
+package unibo_integ
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_endpointName(t *testing.T) {
+	for u, name := range map[string]string{
+		"https://corsi.unibo.it/laurea/informatica/orario-lezioni/@@orario_reale_json?anno=1":  "timetable",
+		"https://corsi.unibo.it/2cycle/ComputerScience/timetable/@@available_curricula?anno=1": "curricula",
+		"https://corsi.unibo.it/laurea/informatica":                                            "course_website",
+		"https://dati.unibo.it/api/3/action/package_show?id=degree-programmes":                 "opendata",
+		"https://www.unibo.it/it/didattica/corsi-di-studio/corso/2024/8009":                    "www.unibo.it",
+		"https://matrix.org/_matrix/client/v3/sync":                                            "",
+	} {
+		req, _ := http.NewRequest("GET", u, nil)
+		assert.Equal(t, name, endpointName(req))
+	}
+}
+
+func Test_WriteMetrics(t *testing.T) {
+	metrics.observe("test", 300*time.Millisecond, "200")
+	metrics.observe("test", 40*time.Second, "error")
+
+	b := strings.Builder{}
+	err := WriteMetrics(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := b.String()
+	assert.Equal(t, true, strings.Contains(out, `unibo_request_duration_seconds_bucket{endpoint="test",le="0.25"} 0`))
+	assert.Equal(t, true, strings.Contains(out, `unibo_request_duration_seconds_bucket{endpoint="test",le="0.5"} 1`))
+	assert.Equal(t, true, strings.Contains(out, `unibo_request_duration_seconds_bucket{endpoint="test",le="+Inf"} 2`))
+	assert.Equal(t, true, strings.Contains(out, `unibo_responses_total{endpoint="test",code="error"} 1`))
+}
@@ -1,55 +1,124 @@
 package unibo_integ
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/csunibo/unibo-go/opendata"
 )
 
-func DownloadResource(resource *opendata.Resource) ([]Course, error) {
+// Download is a downloaded open data resource.
+type Download struct {
+	Courses []Course
+	// Size is the size of the file, in bytes
+	Size int
+	// Checksum is the hex encoded SHA-256 of the file
+	Checksum string
+}
+
+// DownloadResource downloads the courses of the resource, verifying that
+// the whole file was received.
+func DownloadResource(resource *opendata.Resource) (*Download, error) {
 	// Get the resource
 	res, err := Client.Get(resource.Url)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
-	// Parse the body
-	var courses []Course
-	if strings.HasSuffix(resource.Url, ".csv") {
-		courses, err = downloadCSV(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download resource: status %s", res.Status)
 	}
+
+	data, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
+	if res.ContentLength >= 0 && int64(len(data)) != res.ContentLength {
+		return nil, fmt.Errorf("resource is truncated: got %d bytes of %d", len(data), res.ContentLength)
+	}
 
-	// Close the body
-	err = res.Body.Close()
+	courses, err := parseCourses(data)
 	if err != nil {
 		return nil, err
 	}
 
-	if courses == nil {
-		return nil, fmt.Errorf("resource is not a csv file")
+	sum := sha256.Sum256(data)
+	return &Download{
+		Courses:  courses,
+		Size:     len(data),
+		Checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// AddEnglishNames sets the English name and URL of the courses, taken from
+// the courses of the English open data with the same code.
+func AddEnglishNames(courses []Course, english []Course) {
+	byCode := make(map[int]Course, len(english))
+	for _, c := range english {
+		byCode[c.Codice] = c
 	}
 
-	return courses, nil
+	for i := range courses {
+		en, found := byCode[courses[i].Codice]
+		if !found {
+			continue
+		}
+		courses[i].DescrizioneEn = strings.TrimSpace(en.Descrizione)
+		courses[i].UrlEn = strings.TrimSpace(en.Url)
+	}
 }
 
+// parseCourses parses the courses from a CSV or JSON file, detecting the
+// format from the content, since the URL and content type of the resources
+// are not reliable.
+func parseCourses(data []byte) ([]Course, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("resource is empty")
+	}
+
+	switch trimmed[0] {
+	case '[', '{':
+		return parseJSON(trimmed)
+	default:
+		return downloadCSV(bytes.NewReader(trimmed))
+	}
+}
+
+// csvColumns is the number of columns of the CSV file.
+const csvColumns = 15
+
+// classColumns are the names of the column of the degree class.
+var classColumns = []string{"classe", "classe_codice", "corso_classe"}
+
 func downloadCSV(body io.Reader) ([]Course, error) {
 	courses := make([]Course, 0, 100)
 
 	reader := csv.NewReader(body)
 
-	// Skip first line
-	_, err := reader.Read()
+	header, err := reader.Read()
 	if err != nil {
 		return nil, err
 	}
 
+	// The degree class is not in every version of the file
+	classColumn := -1
+	for i, name := range header {
+		if slices.Contains(classColumns, strings.ToLower(strings.TrimSpace(name))) {
+			classColumn = i
+		}
+	}
+
 	for {
 		row, err := reader.Read()
 		if err != nil {
@@ -60,6 +129,10 @@ func downloadCSV(body io.Reader) ([]Course, error) {
 			}
 		}
 
+		if len(row) < csvColumns {
+			return nil, fmt.Errorf("invalid csv row: %d columns, expected %d", len(row), csvColumns)
+		}
+
 		code, err := strconv.ParseInt(row[2], 10, 32)
 		if err != nil {
 			return nil, err
@@ -92,6 +165,108 @@ func downloadCSV(body io.Reader) ([]Course, error) {
 			Lingue:               row[13],
 			Accesso:              row[14],
 		})
+		if classColumn >= 0 && classColumn < len(row) {
+			courses[len(courses)-1].Classe = strings.TrimSpace(row[classColumn])
+		}
+	}
+	return courses, nil
+}
+
+// jsonCourse is a course in the JSON format, which uses the same names of
+// the CSV columns. Values can be either strings or numbers.
+type jsonCourse struct {
+	AnnoAccademico       jsonValue `json:"annoaccademico"`
+	Immatricolabile      jsonValue `json:"immatricolabile"`
+	Codice               jsonValue `json:"corso_codice"`
+	Descrizione          jsonValue `json:"corso_descrizione"`
+	Url                  jsonValue `json:"url"`
+	Campus               jsonValue `json:"campus"`
+	SedeDidattica        jsonValue `json:"sededidattica"`
+	Ambiti               jsonValue `json:"ambiti"`
+	Tipologia            jsonValue `json:"tipologia"`
+	DurataAnni           jsonValue `json:"durata"`
+	Internazionale       jsonValue `json:"internazionale"`
+	InternazionaleTitolo jsonValue `json:"internazionale_titolo"`
+	InternazionaleLingua jsonValue `json:"internazionale_lingua"`
+	Lingue               jsonValue `json:"lingue"`
+	Accesso              jsonValue `json:"accesso"`
+	Classe               jsonValue `json:"classe"`
+}
+
+// jsonValue is a JSON string, number or boolean, as a string.
+type jsonValue string
+
+func (v *jsonValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = jsonValue(s)
+		return nil
+	}
+	if string(b) == "null" {
+		*v = ""
+		return nil
+	}
+	*v = jsonValue(b)
+	return nil
+}
+
+// parseJSON parses a JSON array of courses, or a CKAN datastore response
+// containing them.
+func parseJSON(data []byte) ([]Course, error) {
+	var rows []jsonCourse
+	if data[0] == '[' {
+		err := json.Unmarshal(data, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json resource: %w", err)
+		}
+	} else {
+		var wrapper struct {
+			Result struct {
+				Records []jsonCourse `json:"records"`
+			} `json:"result"`
+		}
+		err := json.Unmarshal(data, &wrapper)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json resource: %w", err)
+		}
+		rows = wrapper.Result.Records
+	}
+
+	courses := make([]Course, 0, len(rows))
+	for _, row := range rows {
+		code, err := strconv.ParseInt(string(row.Codice), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		years, err := strconv.ParseInt(string(row.DurataAnni), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		international, err := strconv.ParseBool(string(row.Internazionale))
+		if err != nil {
+			return nil, err
+		}
+
+		courses = append(courses, Course{
+			AnnoAccademico:       string(row.AnnoAccademico),
+			Immatricolabile:      string(row.Immatricolabile),
+			Codice:               int(code),
+			Descrizione:          string(row.Descrizione),
+			Url:                  string(row.Url),
+			Campus:               string(row.Campus),
+			SedeDidattica:        string(row.SedeDidattica),
+			Ambiti:               string(row.Ambiti),
+			Tipologia:            string(row.Tipologia),
+			DurataAnni:           int(years),
+			Internazionale:       international,
+			InternazionaleTitolo: string(row.InternazionaleTitolo),
+			InternazionaleLingua: string(row.InternazionaleLingua),
+			Lingue:               string(row.Lingue),
+			Accesso:              string(row.Accesso),
+			Classe:               strings.TrimSpace(string(row.Classe)),
+		})
 	}
 	return courses, nil
 }
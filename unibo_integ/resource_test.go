@@ -0,0 +1,61 @@
+package unibo_integ
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseCourses(t *testing.T) {
+	csv := "annoaccademico,immatricolabile,corso_codice,corso_descrizione,url,campus,sededidattica,ambiti,tipologia,durata,internazionale,internazionale_titolo,internazionale_lingua,lingue,accesso\n" +
+		"2024/2025,SI,8009,INFORMATICA,https://corsi.unibo.it/laurea/informatica,Bologna,Bologna,Scienze,Laurea,3,false,,,italiano,libero\n"
+
+	json := `[{"annoaccademico":"2024/2025","immatricolabile":"SI","corso_codice":8009,"corso_descrizione":"INFORMATICA",
+		"url":"https://corsi.unibo.it/laurea/informatica","campus":"Bologna","sededidattica":"Bologna","ambiti":"Scienze",
+		"tipologia":"Laurea","durata":"3","internazionale":false,"internazionale_titolo":null,"internazionale_lingua":"",
+		"lingue":"italiano","accesso":"libero"}]`
+
+	ckan := `{"result": {"records": ` + json + `}}`
+
+	for _, data := range []string{csv, "\ufeff" + csv, json, ckan} {
+		courses, err := parseCourses([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, len(courses))
+		assert.Equal(t, 8009, courses[0].Codice)
+		assert.Equal(t, 3, courses[0].DurataAnni)
+		assert.Equal(t, "Bologna", courses[0].Campus)
+		assert.Equal(t, "italiano", courses[0].Lingue)
+	}
+
+	_, err := parseCourses([]byte("a,b\n1,2\n"))
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_parseCoursesClass(t *testing.T) {
+	csv := "annoaccademico,immatricolabile,corso_codice,corso_descrizione,url,campus,sededidattica,ambiti,tipologia,durata,internazionale,internazionale_titolo,internazionale_lingua,lingue,accesso,classe\n" +
+		"2024/2025,SI,8028,INFORMATICA,https://corsi.unibo.it/magistrale/informatica,Bologna,Bologna,Scienze,Laurea Magistrale,2,false,,,italiano,libero,LM-18\n"
+
+	courses, err := parseCourses([]byte(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "LM-18", courses[0].Classe)
+}
+
+func Test_AddEnglishNames(t *testing.T) {
+	courses := []Course{
+		{Codice: 8009, Descrizione: "INFORMATICA", Url: "https://www.unibo.it/it/8009"},
+		{Codice: 9254, Descrizione: "INGEGNERIA INFORMATICA"},
+	}
+	AddEnglishNames(courses, []Course{
+		{Codice: 8009, Descrizione: "COMPUTER SCIENCE ", Url: "https://www.unibo.it/en/8009"},
+	})
+
+	assert.Equal(t, "COMPUTER SCIENCE", courses[0].DescrizioneEn)
+	assert.Equal(t, "COMPUTER SCIENCE", courses[0].Name("en"))
+	assert.Equal(t, "INFORMATICA", courses[0].Name(""))
+	assert.Equal(t, "https://www.unibo.it/en/8009", courses[0].Website("en"))
+	assert.Equal(t, "INGEGNERIA INFORMATICA", courses[1].Name("en"))
+}
@@ -2,6 +2,7 @@ package unibo_integ
 
 import (
 	"net/http"
+	"time"
 )
 
 type transport struct {
@@ -14,9 +15,12 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 }
 
 // Client is the http client used to make requests.
-// It is used to set a custom User-Agent.
+// It is used to set a custom User-Agent and to record the metrics of the
+// requests, see [WriteMetrics]. The timeout also covers the downloads of
+// the open data, which are the largest responses.
 var Client = http.Client{
+	Timeout: time.Minute * 2,
 	Transport: &transport{
-		http.DefaultTransport,
+		&metricsTransport{http.DefaultTransport},
 	},
 }
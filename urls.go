@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trustedProxy reports whether the remote address (host:port) is one of
+// [Config.TrustedProxies].
+func trustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range config.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstForwarded returns the first value of a X-Forwarded-* header, the one
+// set by the proxy closest to the client.
+func firstForwarded(c *gin.Context, name string) string {
+	value, _, _ := strings.Cut(c.GetHeader(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// requestOrigin returns the scheme and the host the client reached the
+// server at: the ones of [Config.BaseUrl] if set, otherwise the ones in the
+// X-Forwarded-Proto and X-Forwarded-Host headers of a trusted proxy, or of
+// the request itself.
+func requestOrigin(c *gin.Context) (scheme string, host string) {
+	if config.BaseUrl != "" {
+		if u, err := url.Parse(config.BaseUrl); err == nil && u.Host != "" {
+			return u.Scheme, u.Host
+		}
+	}
+
+	scheme, host = "http", c.Request.Host
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if trustedProxy(c.Request.RemoteAddr) {
+		if proto := strings.ToLower(firstForwarded(c, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := firstForwarded(c, "X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme, host
+}
+
+// absoluteUrl returns the public URL of the path p of the app (see appUrl).
+func absoluteUrl(c *gin.Context, p string) string {
+	scheme, host := requestOrigin(c)
+	return scheme + "://" + host + appUrl(p)
+}
+
+// webcalUrl returns the webcal:// URL of the calendar at the path p, which
+// opens it in the calendar apps.
+func webcalUrl(c *gin.Context, p string) string {
+	_, rest, _ := strings.Cut(absoluteUrl(c, p), "://")
+	return "webcal://" + rest
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func testUrlContext(remoteAddr string, headers map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "http://internal:8080/builder", nil)
+	c.Request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func Test_absoluteUrl(t *testing.T) {
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "unibocalendar.it, internal"}
+
+	c := testUrlContext("192.0.2.1:1234", nil)
+	assert.Equal(t, "http://internal:8080/cal/8009/1", absoluteUrl(c, "/cal/8009/1"))
+
+	// Only the trusted proxies can set the origin
+	c = testUrlContext("192.0.2.1:1234", forwarded)
+	assert.Equal(t, "http://internal:8080/cal/8009/1", absoluteUrl(c, "/cal/8009/1"))
+	c = testUrlContext("127.0.0.1:1234", forwarded)
+	assert.Equal(t, "https://unibocalendar.it/cal/8009/1", absoluteUrl(c, "/cal/8009/1"))
+	assert.Equal(t, "webcal://unibocalendar.it/cal/8009/1", webcalUrl(c, "/cal/8009/1"))
+
+	proxies := config.TrustedProxies
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	defer func() { config.TrustedProxies = proxies }()
+	c = testUrlContext("10.1.2.3:1234", forwarded)
+	assert.Equal(t, "https://unibocalendar.it/cal/8009/1", absoluteUrl(c, "/cal/8009/1"))
+
+	baseUrl := config.BaseUrl
+	config.BaseUrl = "https://example.org"
+	defer func() { config.BaseUrl = baseUrl }()
+	c = testUrlContext("10.1.2.3:1234", forwarded)
+	assert.Equal(t, "https://example.org/cal/8009/1", absoluteUrl(c, "/cal/8009/1"))
+}
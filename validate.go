@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// icsProblem is something in a calendar that does not follow RFC 5545.
+type icsProblem struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// maxIcsProblems is the number of problems after which validation stops.
+const maxIcsProblems = 100
+
+// maxIcsLineLength is the maximum length of a line, in octets, excluding CRLF
+// (RFC 5545, section 3.1).
+const maxIcsLineLength = 75
+
+var icsDateTime = regexp.MustCompile(`^\d{8}(T\d{6}Z?)?$`)
+
+// icsLine is an unfolded content line.
+type icsLine struct {
+	number int
+	name   string
+	params string
+	value  string
+}
+
+type icsComponent struct {
+	name       string
+	line       int
+	properties map[string]int
+}
+
+// validateICS checks the calendar against the main rules of RFC 5545:
+// line endings and folding, component nesting and the required properties
+// of calendars and events.
+func validateICS(data []byte) []icsProblem {
+	var problems []icsProblem
+	report := func(line int, format string, args ...any) {
+		if len(problems) < maxIcsProblems {
+			problems = append(problems, icsProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	lines := unfoldICS(data, report)
+	if len(lines) == 0 {
+		report(0, "empty calendar")
+		return problems
+	}
+	if lines[0].name != "BEGIN" || lines[0].value != "VCALENDAR" {
+		report(lines[0].number, "calendar must start with BEGIN:VCALENDAR")
+	}
+
+	var stack []*icsComponent
+	// tzids are the first line using every TZID, timezones the TZIDs with a
+	// VTIMEZONE
+	tzids := make(map[string]int)
+	timezones := make(map[string]bool)
+	for _, l := range lines {
+		switch l.name {
+		case "BEGIN":
+			stack = append(stack, &icsComponent{name: l.value, line: l.number, properties: make(map[string]int)})
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != l.value {
+				report(l.number, "unexpected END:%s", l.value)
+				continue
+			}
+			checkICSComponent(stack[len(stack)-1], report)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 && l.number != lines[len(lines)-1].number {
+				report(l.number, "content after END:VCALENDAR")
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			report(l.number, "property %s outside of any component", l.name)
+			continue
+		}
+		stack[len(stack)-1].properties[l.name]++
+
+		if l.name == "TZID" && stack[len(stack)-1].name == "VTIMEZONE" {
+			timezones[l.value] = true
+		} else if tzid, found := icsParam(l.params, "TZID"); found && tzids[tzid] == 0 {
+			tzids[tzid] = l.number
+		}
+
+		switch l.name {
+		case "DTSTART", "DTEND", "DTSTAMP", "LAST-MODIFIED", "CREATED":
+			if !icsDateTime.MatchString(l.value) {
+				report(l.number, "invalid date-time for %s: %q", l.name, l.value)
+			}
+		}
+	}
+
+	for _, c := range stack {
+		report(c.line, "BEGIN:%s is never closed", c.name)
+	}
+	for tzid, line := range tzids {
+		if !timezones[tzid] {
+			report(line, "TZID %s has no VTIMEZONE", tzid)
+		}
+	}
+
+	return problems
+}
+
+// icsParam returns the value of the parameter of a content line.
+func icsParam(params string, name string) (string, bool) {
+	for _, param := range strings.Split(params, ";") {
+		key, value, found := strings.Cut(param, "=")
+		if found && strings.EqualFold(key, name) {
+			return strings.Trim(value, `"`), true
+		}
+	}
+	return "", false
+}
+
+// checkICSComponent checks the required properties of a component.
+func checkICSComponent(c *icsComponent, report func(line int, format string, args ...any)) {
+	var required, unique []string
+	switch c.name {
+	case "VCALENDAR":
+		required = []string{"PRODID", "VERSION"}
+		unique = []string{"PRODID", "VERSION", "METHOD", "CALSCALE"}
+	case "VEVENT":
+		required = []string{"UID", "DTSTAMP", "DTSTART"}
+		unique = []string{"UID", "DTSTAMP", "DTSTART", "DTEND", "SUMMARY", "DESCRIPTION", "LOCATION", "URL"}
+		if c.properties["DTEND"] > 0 && c.properties["DURATION"] > 0 {
+			report(c.line, "VEVENT must not have both DTEND and DURATION")
+		}
+	default:
+		return
+	}
+
+	for _, p := range required {
+		if c.properties[p] == 0 {
+			report(c.line, "%s is missing the required property %s", c.name, p)
+		}
+	}
+	for _, p := range unique {
+		if c.properties[p] > 1 {
+			report(c.line, "%s has %d %s properties, at most one is allowed", c.name, c.properties[p], p)
+		}
+	}
+}
+
+// unfoldICS splits the calendar in content lines, joining the folded ones, and
+// reports the problems with line endings and lengths.
+func unfoldICS(data []byte, report func(line int, format string, args ...any)) []icsLine {
+	var lines []icsLine
+	bareLf := false
+
+	physical := bytes.SplitAfter(data, []byte("\n"))
+	for i, raw := range physical {
+		number := i + 1
+		if len(raw) == 0 {
+			continue
+		}
+
+		content, hasLf := bytes.CutSuffix(raw, []byte("\n"))
+		content, hasCr := bytes.CutSuffix(content, []byte("\r"))
+		if hasLf && !hasCr && !bareLf {
+			report(number, "lines must end with CRLF, not LF")
+			bareLf = true
+		}
+		if len(content) > maxIcsLineLength {
+			report(number, "line is %d octets long, lines must be folded at %d", len(content), maxIcsLineLength)
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		// A line starting with a space or a tab continues the previous one
+		if content[0] == ' ' || content[0] == '\t' {
+			if len(lines) == 0 {
+				report(number, "folded line without a previous line")
+				continue
+			}
+			lines[len(lines)-1].value += string(content[1:])
+			continue
+		}
+
+		name, value, found := strings.Cut(string(content), ":")
+		if !found {
+			report(number, "content line without ':'")
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		lines = append(lines, icsLine{number: number, name: strings.ToUpper(name), params: params, value: value})
+	}
+
+	return lines
+}
+
+// validateClient is used to fetch the calendars to validate. It refuses to
+// connect to private addresses, so it can't be used to probe the internal
+// network of the server, and it doesn't use the proxy of the environment,
+// which would connect in its place.
+var validateClient = http.Client{
+	Timeout: time.Second * 15,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: time.Second * 5,
+			Control: refusePrivateAddresses,
+		}).DialContext,
+	},
+}
+
+// refusedPrefixes are the ranges that can reach the internal network but
+// aren't private for netip: the shared address space of carrier-grade NAT
+// and the NAT64 prefixes, which embed an IPv4 address.
+var refusedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+func refusePrivateAddresses(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	// An IPv4-mapped address (::ffff:10.0.0.1) is the IPv4 one
+	ip := addrPort.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() ||
+		slices.ContainsFunc(refusedPrefixes, func(p netip.Prefix) bool { return p.Contains(ip) }) {
+		return fmt.Errorf("refusing to connect to %s", ip)
+	}
+	return nil
+}
+
+// maxValidateSize is the maximum size of a calendar to validate.
+const maxValidateSize = 10 * 1024 * 1024
+
+// validateCalendar validates the calendar at the "url" query parameter or,
+// for a self-check, the calendar served by this instance at the "path" query
+// parameter (e.g. /cal/8009/1).
+func validateCalendar(r *gin.Engine) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		var data []byte
+		var err error
+
+		if rawUrl := ctx.Query("url"); rawUrl != "" {
+			data, err = fetchCalendar(rawUrl)
+		} else if p := ctx.Query("path"); p != "" {
+			data, err = selfRequest(r, p)
+		} else {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Missing url or path parameter")
+			return
+		}
+
+		if err != nil {
+			writeProblem(ctx, http.StatusBadGateway, codeUpstreamUnavailable, err.Error())
+			return
+		}
+
+		problems := validateICS(data)
+		if problems == nil {
+			problems = []icsProblem{}
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"valid":    len(problems) == 0,
+			"size":     len(data),
+			"problems": problems,
+		})
+	}
+}
+
+// fetchCalendar downloads an external calendar.
+func fetchCalendar(rawUrl string) ([]byte, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "webcal") {
+		return nil, fmt.Errorf("invalid calendar url")
+	}
+	if u.Scheme == "webcal" {
+		u.Scheme = "https"
+	}
+
+	res, err := validateClient.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch calendar: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch calendar: status %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxValidateSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read calendar: %w", err)
+	}
+	if len(data) > maxValidateSize {
+		return nil, fmt.Errorf("calendar is larger than %d bytes", maxValidateSize)
+	}
+	return data, nil
+}
+
+// selfRequest serves a GET request for the given path with the router,
+// without going through the network.
+func selfRequest(r *gin.Engine, p string) ([]byte, error) {
+	w, err := serveSelf(r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("calendar returned status %d: %s", w.Code, strings.TrimSpace(w.Body.String()))
+	}
+	return w.Body.Bytes(), nil
+}
+
+// selfRequestKey marks the requests made by serveSelf in their context.
+type selfRequestKey struct{}
+
+// isSelfRequest reports whether the request was made by the server itself,
+// so it's neither rate limited nor counted in the stats.
+func isSelfRequest(c *gin.Context) bool {
+	self, _ := c.Request.Context().Value(selfRequestKey{}).(bool)
+	return self
+}
+
+// serveSelf is like selfRequest, but returns the whole response.
+func serveSelf(r *gin.Engine, p string) (*httptest.ResponseRecorder, error) {
+	if !strings.HasPrefix(p, "/cal/") && !strings.HasPrefix(p, "/api/v1/cal/") {
+		return nil, fmt.Errorf("only calendar paths can be validated")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, p, nil)
+	req = req.WithContext(context.WithValue(req.Context(), selfRequestKey{}, true))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, nil
+}
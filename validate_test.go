@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/go-playground/assert/v2"
+)
+
+// testTimetable returns a small timetable that does not need the upstream.
+func testTimetable() timetable.Timetable {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	at := func(day, hour int) timetable.CalendarTime {
+		return timetable.CalendarTime{Time: time.Date(2024, time.October, day, hour, 0, 0, 0, rome)}
+	}
+
+	return timetable.Timetable{
+		{CodModulo: "00001_1", ExtCode: "2024-000-412345--I", Title: "ALGEBRA", Teacher: "Mario Rossi", Cfu: 6, Start: at(1, 9), End: at(1, 11),
+			Classrooms: []timetable.Classroom{{ResourceDesc: "AULA 1"}}},
+		{CodModulo: "00002_1", Title: "ANALISI MATEMATICA", Teacher: "Anna Bianchi", Cfu: 9, Start: at(1, 11), End: at(1, 13)},
+	}
+}
+
+func Test_validateICS(t *testing.T) {
+	course := testCourse(8009)
+	cal, err := createCal(testTimetable(), &course, 1, calOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := cal.Serialize()
+	problems := validateICS([]byte(data))
+	assert.Equal(t, 0, len(problems))
+	assert.Equal(t, true, strings.Contains(data, "METHOD:PUBLISH"))
+	assert.Equal(t, true, strings.Contains(data, "PRODID:"+config.IcsProdId))
+	assert.Equal(t, true, strings.Contains(data, "CALSCALE:GREGORIAN"))
+	assert.Equal(t, true, strings.Contains(data, "CATEGORIES:00001\r\n"))
+	assert.Equal(t, true, strings.Contains(data, "CATEGORIES:6 CFU\r\n"))
+
+	broken := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART:yesterday",
+		"END:VEVENT",
+	}, "\n")
+
+	problems = validateICS([]byte(broken))
+	messages := make([]string, 0, len(problems))
+	for _, p := range problems {
+		messages = append(messages, p.Message)
+	}
+	all := strings.Join(messages, "\n")
+
+	assert.Equal(t, true, strings.Contains(all, "CRLF"))
+	assert.Equal(t, true, strings.Contains(all, "invalid date-time for DTSTART"))
+	assert.Equal(t, true, strings.Contains(all, "missing the required property UID"))
+	assert.Equal(t, true, strings.Contains(all, "BEGIN:VCALENDAR is never closed"))
+}
+
+func Test_strictCalendar(t *testing.T) {
+	course := testCourse(8009)
+	tt := testTimetable()
+	tt[0].Title = "ALGEBRA\r\x00 LINEARE"
+
+	cal, err := createCal(tt, &course, 1, calOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := cal.Serialize()
+	assert.Equal(t, 0, len(validateICS([]byte(data))))
+	assert.Equal(t, true, strings.Contains(data, "METHOD:PUBLISH"))
+	assert.Equal(t, false, strings.Contains(data, "ORGANIZER"))
+	assert.Equal(t, true, strings.Contains(data, "SUMMARY:ALGEBRA LINEARE"))
+}
+
+func Test_refusePrivateAddresses(t *testing.T) {
+	for _, address := range []string{
+		"127.0.0.1:80", "10.1.2.3:443", "192.168.1.1:80", "169.254.169.254:80", "0.0.0.0:80",
+		"100.64.0.1:80", "[::1]:80", "[::ffff:10.0.0.1]:80", "[::ffff:127.0.0.1]:80",
+		"[64:ff9b::a00:1]:80", "[fe80::1%eth0]:80", "[fd00::1]:80", "224.0.0.1:80",
+	} {
+		assert.NotEqual(t, nil, refusePrivateAddresses("tcp", address, nil))
+	}
+
+	for _, address := range []string{"137.204.24.1:443", "[2001:760:2e00::1]:443", "[::ffff:137.204.24.1]:443"} {
+		assert.Equal(t, nil, refusePrivateAddresses("tcp", address, nil))
+	}
+}
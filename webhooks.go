@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csunibo/unibo-go/timetable"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// chatWebhook is a webhook of a chat service, receiving the schedule
+// changes of a course year and optionally its daily schedules.
+type chatWebhook struct {
+	// Id is secret, it's needed to delete the webhook
+	Id   string `json:"id"`
+	Kind string `json:"kind"`
+	Url  string `json:"url"`
+
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum,omitempty"`
+	// Daily enables the post with the lessons of the next day
+	Daily bool `json:"daily"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (w *chatWebhook) feed() feedRef {
+	return feedRef{Course: w.Course, Year: w.Year, Curriculum: w.Curriculum}
+}
+
+// webhookKind is a chat service supported by the webhooks.
+type webhookKind struct {
+	// validUrl reports whether the URL is a webhook of the service, so that
+	// the server doesn't post to arbitrary URLs
+	validUrl func(u *url.URL) bool
+	// changes and daily return the payloads of the messages
+	changes func(course *unibo_integ.Course, year int, changes []scheduleChange) any
+	daily   func(course *unibo_integ.Course, year int, day time.Time, lessons timetable.Timetable) any
+}
+
+var webhookKinds = map[string]webhookKind{
+	"discord": {validUrl: discordWebhookUrl, changes: discordChanges, daily: discordDaily},
+	"slack":   {validUrl: slackWebhookUrl, changes: slackChanges, daily: slackDaily},
+}
+
+// chatWebhooks are the registered webhooks, by id, saved as JSON.
+type chatWebhooks struct {
+	mu    sync.Mutex
+	file  string
+	hooks map[string]*chatWebhook
+}
+
+var webhooks = &chatWebhooks{file: config.WebhooksFile}
+
+func (c *chatWebhooks) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks = map[string]*chatWebhook{}
+	return loadJsonFile(c.file, &c.hooks)
+}
+
+// update calls fn with the webhooks and saves them if fn doesn't fail.
+func (c *chatWebhooks) update(fn func(hooks map[string]*chatWebhook) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The API can be called before Run loads the file
+	if c.hooks == nil {
+		c.hooks = map[string]*chatWebhook{}
+		err := loadJsonFile(c.file, &c.hooks)
+		if err != nil {
+			return err
+		}
+	}
+	err := fn(c.hooks)
+	if err != nil {
+		return err
+	}
+	// The URLs of the webhooks are secrets
+	return saveJsonFile(c.file, c.hooks, 0o600)
+}
+
+// matching returns the webhooks for which match is true.
+func (c *chatWebhooks) matching(match func(w *chatWebhook) bool) []chatWebhook {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var hooks []chatWebhook
+	for _, w := range c.hooks {
+		if match(w) {
+			hooks = append(hooks, *w)
+		}
+	}
+	return hooks
+}
+
+// secretLogId returns a short hash of a secret id, which identifies it in the
+// logs without allowing to use it.
+func secretLogId(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// webhookClient posts to the webhooks. The posts are made one after the
+// other, so a slow chat service must not stall the others.
+var webhookClient = &http.Client{Timeout: time.Second * 10}
+
+// post sends the payload to the webhook. Webhooks deleted on the chat
+// service are removed.
+func (c *chatWebhooks) post(w chatWebhook, payload any) {
+	logger := log.With().Str("webhook", secretLogId(w.Id)).Str("kind", w.Kind).Int("course-code", w.Course).Logger()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to encode webhook payload")
+		return
+	}
+
+	res, err := webhookClient.Post(w.Url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		logger.Warn().Err(err).Msg("unable to post to webhook")
+		return
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		logger.Info().Msg("webhook deleted, removing it")
+		err = c.update(func(hooks map[string]*chatWebhook) error {
+			delete(hooks, w.Id)
+			return nil
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("unable to save webhooks")
+		}
+	case res.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		logger.Warn().Str("status", res.Status).Str("response", strings.TrimSpace(string(msg))).Msg("webhook post failed")
+	}
+}
+
+// Run posts the schedule changes and the daily schedules to the webhooks.
+func (c *chatWebhooks) Run(courses *unibo_integ.Courses) {
+	err := c.load()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load webhooks")
+		return
+	}
+
+	scheduleChanges.onChange(func(feed feedRef, changes []scheduleChange) {
+		course, found := courses.Load().FindById(feed.Course)
+		if !found {
+			return
+		}
+		for _, w := range c.matching(func(w *chatWebhook) bool { return w.feed() == feed }) {
+			c.post(w, webhookKinds[w.Kind].changes(course, feed.Year, changes))
+		}
+	})
+
+	runDaily(func(tomorrow time.Time) {
+		for _, w := range c.matching(func(w *chatWebhook) bool { return w.Daily }) {
+			course, t, err := feedTimetable(courses, w.feed(), nil)
+			if err != nil {
+				log.Warn().Err(err).Int("course-code", w.Course).Msg("unable to retrieve timetable for webhook")
+				continue
+			}
+			if lessons := lessonsOn(t, tomorrow); len(lessons) > 0 {
+				c.post(w, webhookKinds[w.Kind].daily(course, w.Year, tomorrow, lessons))
+			}
+		}
+	})
+}
+
+// apiWebhookRequest is the body of POST /api/v1/webhooks.
+type apiWebhookRequest struct {
+	Kind       string `json:"kind"`
+	Url        string `json:"url"`
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum"`
+	Daily      bool   `json:"daily"`
+}
+
+type apiWebhook struct {
+	Id         string `json:"id"`
+	Kind       string `json:"kind"`
+	Course     int    `json:"course"`
+	Year       int    `json:"year"`
+	Curriculum string `json:"curriculum,omitempty"`
+	Daily      bool   `json:"daily"`
+}
+
+// apiCreateWebhook registers a webhook. The returned id is needed to delete
+// it.
+func apiCreateWebhook(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		var req apiWebhookRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Invalid body")
+			return
+		}
+
+		kind, found := webhookKinds[req.Kind]
+		if !found {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Unknown webhook kind")
+			return
+		}
+		u, err := url.Parse(req.Url)
+		if err != nil || !kind.validUrl(u) {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Invalid webhook url")
+			return
+		}
+		course, perr := findCourse(courses.Load(), "course", req.Course)
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+		if perr := checkYear(course, "year", req.Year); perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+		if perr := checkCurriculum(course, "curriculum", req.Year, req.Curriculum); perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		w := &chatWebhook{
+			Id:         randomId(),
+			Kind:       req.Kind,
+			Url:        req.Url,
+			Course:     course.Codice,
+			Year:       req.Year,
+			Curriculum: req.Curriculum,
+			Daily:      req.Daily,
+			CreatedAt:  time.Now(),
+		}
+		err = webhooks.update(func(hooks map[string]*chatWebhook) error {
+			hooks[w.Id] = w
+			return nil
+		})
+		if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to save the webhook")
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, apiWebhook{
+			Id:         w.Id,
+			Kind:       w.Kind,
+			Course:     w.Course,
+			Year:       w.Year,
+			Curriculum: w.Curriculum,
+			Daily:      w.Daily,
+		})
+	}
+}
+
+var errWebhookNotFound = fmt.Errorf("webhook not found")
+
+func apiDeleteWebhook(ctx *gin.Context) {
+	err := webhooks.update(func(hooks map[string]*chatWebhook) error {
+		if _, found := hooks[ctx.Param("id")]; !found {
+			return errWebhookNotFound
+		}
+		delete(hooks, ctx.Param("id"))
+		return nil
+	})
+	if err == errWebhookNotFound {
+		writeProblem(ctx, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return
+	} else if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to save the webhooks")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// Discord
+
+// discordWebhookUrl reports whether the URL is a Discord webhook.
+func discordWebhookUrl(u *url.URL) bool {
+	return u.Scheme == "https" &&
+		(u.Host == "discord.com" || u.Host == "discordapp.com") &&
+		strings.HasPrefix(u.Path, "/api/webhooks/")
+}
+
+// Limits of the Discord embeds
+const (
+	discordMaxFields = 25
+	discordColor     = 0xbb2e29
+)
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Url         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// discordLessonField describes a lesson as a field of an embed.
+func discordLessonField(name string, e timetable.Event) discordField {
+	var details []string
+	if room := eventRoom(e); room != "" {
+		details = append(details, "Aula: "+room)
+	}
+	if e.Teacher != "" {
+		details = append(details, "Docente: "+e.Teacher)
+	}
+	if len(details) == 0 {
+		details = append(details, "-")
+	}
+	return discordField{Name: name, Value: strings.Join(details, "\n")}
+}
+
+func discordChanges(course *unibo_integ.Course, year int, changes []scheduleChange) any {
+	embed := discordEmbed{
+		Title: fmt.Sprintf("Modifiche all'orario - %s, %d anno", course.Descrizione, year),
+		Color: discordColor,
+	}
+	for _, c := range changes {
+		if len(embed.Fields) == discordMaxFields {
+			embed.Description = fmt.Sprintf("Sono mostrate %d modifiche su %d.", discordMaxFields, len(changes))
+			break
+		}
+		field := discordLessonField(c.String(), c.Event)
+		if c.Kind == changeRoom && c.OldRoom != "" {
+			field.Value += "\nAula precedente: " + c.OldRoom
+		}
+		embed.Fields = append(embed.Fields, field)
+	}
+	return discordMessage{Embeds: []discordEmbed{embed}}
+}
+
+func discordDaily(course *unibo_integ.Course, year int, day time.Time, lessons timetable.Timetable) any {
+	embed := discordEmbed{
+		Title: fmt.Sprintf("Lezioni del %s - %s, %d anno", day.Format("02/01/2006"), course.Descrizione, year),
+		Color: discordColor,
+	}
+	for _, e := range lessons {
+		if len(embed.Fields) == discordMaxFields {
+			break
+		}
+		name := fmt.Sprintf("%s-%s %s", e.Start.Format("15:04"), e.End.Format("15:04"), e.Title)
+		embed.Fields = append(embed.Fields, discordLessonField(name, e))
+	}
+	return discordMessage{Embeds: []discordEmbed{embed}}
+}
+
+// Slack
+
+// slackWebhookUrl reports whether the URL is a Slack incoming webhook.
+func slackWebhookUrl(u *url.URL) bool {
+	return u.Scheme == "https" && u.Host == "hooks.slack.com" && strings.HasPrefix(u.Path, "/services/")
+}
+
+// slackMaxBlocks is the maximum number of blocks of a Slack message.
+const slackMaxBlocks = 50
+
+type slackMessage struct {
+	// Text is shown in the notifications
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackEscape escapes the control characters of the mrkdwn format.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackLessonText describes a lesson, with the title in bold.
+func slackLessonText(title string, e timetable.Event) string {
+	b := strings.Builder{}
+	b.WriteString("*" + slackEscape.Replace(title) + "*")
+	if room := eventRoom(e); room != "" {
+		b.WriteString("\nAula: " + slackEscape.Replace(room))
+	}
+	if e.Teacher != "" {
+		b.WriteString("\nDocente: " + slackEscape.Replace(e.Teacher))
+	}
+	return b.String()
+}
+
+func newSlackMessage(title string, sections []string) slackMessage {
+	msg := slackMessage{
+		Text:   title,
+		Blocks: []slackBlock{{Type: "header", Text: slackText{Type: "plain_text", Text: title}}},
+	}
+	for _, section := range sections {
+		if len(msg.Blocks) == slackMaxBlocks {
+			break
+		}
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "section", Text: slackText{Type: "mrkdwn", Text: section}})
+	}
+	return msg
+}
+
+func slackChanges(course *unibo_integ.Course, year int, changes []scheduleChange) any {
+	sections := make([]string, 0, len(changes))
+	for _, c := range changes {
+		text := slackLessonText(c.String(), c.Event)
+		if c.Kind == changeRoom && c.OldRoom != "" {
+			text += "\nAula precedente: " + slackEscape.Replace(c.OldRoom)
+		}
+		sections = append(sections, text)
+	}
+	return newSlackMessage(fmt.Sprintf("Modifiche all'orario - %s, %d anno", course.Descrizione, year), sections)
+}
+
+func slackDaily(course *unibo_integ.Course, year int, day time.Time, lessons timetable.Timetable) any {
+	sections := make([]string, 0, len(lessons))
+	for _, e := range lessons {
+		sections = append(sections, slackLessonText(fmt.Sprintf("%s-%s %s", e.Start.Format("15:04"), e.End.Format("15:04"), e.Title), e))
+	}
+	return newSlackMessage(fmt.Sprintf("Lezioni del %s - %s, %d anno", day.Format("02/01/2006"), course.Descrizione, year), sections)
+}
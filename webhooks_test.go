@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_discordWebhookUrl(t *testing.T) {
+	valid, _ := url.Parse("https://discord.com/api/webhooks/123/abc")
+	assert.Equal(t, true, discordWebhookUrl(valid))
+	other, _ := url.Parse("https://example.com/api/webhooks/123/abc")
+	assert.Equal(t, false, discordWebhookUrl(other))
+	plain, _ := url.Parse("http://discord.com/api/webhooks/123/abc")
+	assert.Equal(t, false, discordWebhookUrl(plain))
+}
+
+func Test_discordChanges(t *testing.T) {
+	course := testCourse(8009)
+	e := testTimetable()[0]
+	msg := discordChanges(&course, 1, []scheduleChange{{Kind: changeRoom, Event: e, OldRoom: "AULA 2"}}).(discordMessage)
+
+	assert.Equal(t, 1, len(msg.Embeds))
+	assert.Equal(t, "Modifiche all'orario - INFORMATICA, 1 anno", msg.Embeds[0].Title)
+	assert.Equal(t, "Cambio aula: ALGEBRA, 01/10 09:00, ora in AULA 1", msg.Embeds[0].Fields[0].Name)
+	assert.Equal(t, "Aula: AULA 1\nDocente: Mario Rossi\nAula precedente: AULA 2", msg.Embeds[0].Fields[0].Value)
+
+	daily := discordDaily(&course, 1, e.Start.Time, testTimetable()).(discordMessage)
+	assert.Equal(t, 2, len(daily.Embeds[0].Fields))
+	assert.Equal(t, "11:00-13:00 ANALISI MATEMATICA", daily.Embeds[0].Fields[1].Name)
+}
+
+func Test_slackChanges(t *testing.T) {
+	slack, _ := url.Parse("https://hooks.slack.com/services/T000/B000/XXX")
+	assert.Equal(t, true, slackWebhookUrl(slack))
+	assert.Equal(t, false, discordWebhookUrl(slack))
+
+	course := testCourse(8009)
+	e := testTimetable()[1]
+	e.Teacher = "Anna <Bianchi>"
+	msg := slackChanges(&course, 1, []scheduleChange{{Kind: changeCancelled, Event: e}}).(slackMessage)
+
+	assert.Equal(t, "Modifiche all'orario - INFORMATICA, 1 anno", msg.Text)
+	assert.Equal(t, 2, len(msg.Blocks))
+	assert.Equal(t, "header", msg.Blocks[0].Type)
+	assert.Equal(t, "*Lezione annullata: ANALISI MATEMATICA, 01/10 11:00*\nDocente: Anna &lt;Bianchi&gt;", msg.Blocks[1].Text.Text)
+}
+
+func Test_apiWebhooks(t *testing.T) {
+	webhooks.file = path.Join(t.TempDir(), "webhooks.json")
+	webhooks.hooks = nil
+	defer func() { webhooks.file = config.WebhooksFile }()
+	r := setupRouter(testCourses)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/webhooks", strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(`{"kind":"discord","url":"https://example.com/hook","course":8009,"year":1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = create(`{"kind":"discord","url":"https://discord.com/api/webhooks/1/a","course":8009,"year":1,"daily":true}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created apiWebhook
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, true, created.Daily)
+	assert.Equal(t, 1, len(webhooks.matching(func(w *chatWebhook) bool { return w.Daily })))
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/webhooks/"+created.Id, http.NoBody)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, len(webhooks.hooks))
+}
+
+func Test_secretLogId(t *testing.T) {
+	id := randomId()
+	assert.Equal(t, 12, len(secretLogId(id)))
+	assert.Equal(t, secretLogId(id), secretLogId(id))
+	assert.Equal(t, false, strings.Contains(id, secretLogId(id)))
+}
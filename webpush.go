@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// webPushEnabled reports whether the VAPID keys of the Web Push
+// notifications are configured.
+func webPushEnabled() bool {
+	return config.VapidPublicKey != "" && config.VapidPrivateKey != ""
+}
+
+// pushSubscription is a browser receiving the schedule changes of a course
+// year, as returned by PushManager.subscribe().
+type pushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+
+	Course     int       `json:"course"`
+	Year       int       `json:"year"`
+	Curriculum string    `json:"curriculum,omitempty"`
+	Subjects   []string  `json:"subjects,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (s *pushSubscription) feed() feedRef {
+	return feedRef{Course: s.Course, Year: s.Year, Curriculum: s.Curriculum}
+}
+
+// pushSubscriptions are the Web Push subscriptions, by endpoint, saved as
+// JSON.
+type pushSubscriptions struct {
+	mu   sync.Mutex
+	file string
+	subs map[string]*pushSubscription
+}
+
+var pushSubs = &pushSubscriptions{file: config.PushSubscriptionsFile}
+
+// load reads the subscriptions from their file. A missing file is an empty
+// list of subscriptions.
+func (p *pushSubscriptions) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.subs = map[string]*pushSubscription{}
+	data, err := os.ReadFile(p.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read push subscriptions: %w", err)
+	}
+
+	err = json.Unmarshal(data, &p.subs)
+	if err != nil {
+		return fmt.Errorf("unable to decode push subscriptions: %w", err)
+	}
+	return nil
+}
+
+func (p *pushSubscriptions) set(sub *pushSubscription) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.subs == nil {
+		p.subs = map[string]*pushSubscription{}
+	}
+	p.subs[sub.Endpoint] = sub
+	return saveJsonFile(p.file, p.subs, 0o600)
+}
+
+func (p *pushSubscriptions) remove(endpoint string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.subs, endpoint)
+	return saveJsonFile(p.file, p.subs, 0o600)
+}
+
+// following returns the subscriptions of the feed.
+func (p *pushSubscriptions) following(feed feedRef) []pushSubscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var subs []pushSubscription
+	for _, sub := range p.subs {
+		if sub.feed() == feed {
+			subs = append(subs, *sub)
+		}
+	}
+	return subs
+}
+
+// pushNotification is the payload of the notifications, shown by
+// static/push-sw.js.
+type pushNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Url   string `json:"url"`
+}
+
+// notify sends the changes of the feed to its subscribers. Expired
+// subscriptions are removed.
+func (p *pushSubscriptions) notify(courses *unibo_integ.Courses, feed feedRef, changes []scheduleChange) {
+	course, found := courses.Load().FindById(feed.Course)
+	if !found {
+		return
+	}
+
+	for _, sub := range p.following(feed) {
+		subChanges := filterChanges(changes, sub.Subjects)
+		if len(subChanges) == 0 {
+			continue
+		}
+
+		n := pushNotification{
+			Title: fmt.Sprintf("%s - %d anno", course.Descrizione, feed.Year),
+			Body:  subChanges[0].String(),
+			Url:   appUrl(fmt.Sprintf("/courses/%d", feed.Course)),
+		}
+		if len(subChanges) > 1 {
+			n.Body += fmt.Sprintf(" e altre %d modifiche", len(subChanges)-1)
+		}
+		payload, _ := json.Marshal(n)
+
+		status, err := sendWebPush(&sub, payload)
+		if status == http.StatusNotFound || status == http.StatusGone {
+			err = p.remove(sub.Endpoint)
+			if err != nil {
+				log.Error().Err(err).Msg("unable to save push subscriptions")
+			}
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Int("course-code", feed.Course).Msg("unable to send push notification")
+		}
+	}
+}
+
+// pushRecordSize is the record size of the encrypted payloads, which fit in a
+// single record.
+const pushRecordSize = 4096
+
+// encryptPushPayload encrypts the payload for the browser with the
+// "aes128gcm" content coding of RFC 8291, using the ephemeral key asKey.
+func encryptPushPayload(payload []byte, uaPublic []byte, authSecret []byte, asKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	secret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, secret, authSecret, keyInfo), ikm)
+	if err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, 16)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 is the padding delimiter of the last record
+	ciphertext := gcm.Seal(nil, nonce, append(payload, 0x02), nil)
+
+	header := bytes.Buffer{}
+	header.Write(salt)
+	_ = binary.Write(&header, binary.BigEndian, uint32(pushRecordSize))
+	header.WriteByte(byte(len(asPublic)))
+	header.Write(asPublic)
+	return append(header.Bytes(), ciphertext...), nil
+}
+
+// vapidKey returns the VAPID private key, from its base64url encoding.
+func vapidKey() (*ecdsa.PrivateKey, error) {
+	d, err := base64.RawURLEncoding.DecodeString(config.VapidPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+
+	// Uncompressed point: 0x04 || X || Y
+	public := key.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, nil
+}
+
+// vapidToken returns the JWT (RFC 8292) authorizing the requests to the
+// push service of the endpoint.
+func vapidToken(key *ecdsa.PrivateKey, endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid push endpoint: %w", err)
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": config.VapidSubject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// pushServices are the hosts of the push services of the browsers, or their
+// suffixes if they start with a dot. The subscriptions can only have an
+// endpoint there, so the server can't be made to post anywhere else.
+var pushServices = []string{
+	"fcm.googleapis.com",                // Chrome
+	"updates.push.services.mozilla.com", // Firefox
+	".push.apple.com",                   // Safari
+	".notify.windows.com",               // Edge
+}
+
+// isPushService reports whether the endpoint is an https URL of one of
+// pushServices.
+func isPushService(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || (u.Port() != "" && u.Port() != "443") || u.User != nil {
+		return false
+	}
+	host := u.Hostname()
+	return slices.ContainsFunc(pushServices, func(s string) bool {
+		return host == s || (strings.HasPrefix(s, ".") && strings.HasSuffix(host, s))
+	})
+}
+
+// sendWebPush sends the encrypted payload to the push service of the
+// subscription, returning the status code of the response.
+func sendWebPush(sub *pushSubscription, payload []byte) (int, error) {
+	if !isPushService(sub.Endpoint) {
+		return 0, fmt.Errorf("endpoint is not a known push service")
+	}
+
+	uaPublic, err := base64.RawURLEncoding.DecodeString(sub.Keys.P256dh)
+	if err != nil {
+		return 0, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(sub.Keys.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return 0, err
+	}
+	salt := make([]byte, 16)
+	_, _ = rand.Read(salt)
+
+	body, err := encryptPushPayload(payload, uaPublic, authSecret, asKey, salt)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := vapidKey()
+	if err != nil {
+		return 0, err
+	}
+	token, err := vapidToken(key, sub.Endpoint, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int((24 * time.Hour).Seconds())))
+	req.Header.Set("Authorization", "vapid t="+token+", k="+config.VapidPublicKey)
+
+	res, err := validateClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to reach push service: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("push service returned %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// pushSubscribeRequest is the body of /push/subscribe.
+type pushSubscribeRequest struct {
+	Subscription pushSubscription `json:"subscription"`
+	Course       int              `json:"course"`
+	Year         int              `json:"year"`
+	Curriculum   string           `json:"curriculum"`
+	Subjects     []string         `json:"subjects"`
+}
+
+func pushSubscribe(courses *unibo_integ.Courses) func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		var req pushSubscribeRequest
+		err := ctx.ShouldBindJSON(&req)
+		if err != nil {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Invalid subscription")
+			return
+		}
+
+		if !isPushService(req.Subscription.Endpoint) || req.Subscription.Keys.P256dh == "" || req.Subscription.Keys.Auth == "" {
+			writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Invalid subscription")
+			return
+		}
+		course, perr := findCourse(courses.Load(), "course", req.Course)
+		if perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+		if perr := checkYear(course, "year", req.Year); perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+		if perr := checkCurriculum(course, "curriculum", req.Year, req.Curriculum); perr != nil {
+			writeParamError(ctx, perr)
+			return
+		}
+
+		sub := req.Subscription
+		sub.Course = course.Codice
+		sub.Year = req.Year
+		sub.Curriculum = req.Curriculum
+		sub.Subjects = req.Subjects
+		sub.CreatedAt = time.Now()
+
+		err = pushSubs.set(&sub)
+		if err != nil {
+			_ = ctx.Error(err)
+			writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to save the subscription")
+			return
+		}
+		ctx.Status(http.StatusCreated)
+	}
+}
+
+func pushUnsubscribe(ctx *gin.Context) {
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Endpoint == "" {
+		writeProblem(ctx, http.StatusBadRequest, codeInvalidParameter, "Missing endpoint")
+		return
+	}
+
+	err := pushSubs.remove(req.Endpoint)
+	if err != nil {
+		_ = ctx.Error(err)
+		writeProblem(ctx, http.StatusInternalServerError, codeInternal, "Unable to save the subscription")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// setupWebPush registers the endpoints of the Web Push notifications and
+// sends the schedule changes to the subscribers.
+func setupWebPush(r *gin.Engine, courses *unibo_integ.Courses, limiter gin.HandlerFunc) {
+	err := pushSubs.load()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load push subscriptions")
+	}
+	scheduleChanges.onChange(func(feed feedRef, changes []scheduleChange) {
+		pushSubs.notify(courses, feed, changes)
+	})
+
+	r.GET("/push/key", func(c *gin.Context) {
+		c.String(http.StatusOK, config.VapidPublicKey)
+	})
+	r.POST("/push/subscribe", limiter, pushSubscribe(courses))
+	r.POST("/push/unsubscribe", pushUnsubscribe)
+}
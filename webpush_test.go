@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// decryptPushPayload is the browser side of encryptPushPayload.
+func decryptPushPayload(t *testing.T, body []byte, uaKey *ecdh.PrivateKey, authSecret []byte) []byte {
+	salt := body[:16]
+	assert.Equal(t, uint32(pushRecordSize), binary.BigEndian.Uint32(body[16:20]))
+	idLen := int(body[20])
+	asPublic := body[21 : 21+idLen]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := uaKey.ECDH(asKey)
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaKey.PublicKey().Bytes()...), asPublic...)
+	ikm := make([]byte, 32)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, secret, authSecret, keyInfo), ikm)
+	cek := make([]byte, 16)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek)
+	nonce := make([]byte, 12)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce)
+
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, byte(0x02), plain[len(plain)-1])
+	return plain[:len(plain)-1]
+}
+
+func Test_encryptPushPayload(t *testing.T) {
+	uaKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	asKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	salt := make([]byte, 16)
+	_, _ = rand.Read(authSecret)
+	_, _ = rand.Read(salt)
+
+	payload := []byte(`{"title":"Informatica - 1 anno"}`)
+	body, err := encryptPushPayload(payload, uaKey.PublicKey().Bytes(), authSecret, asKey, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, payload, decryptPushPayload(t, body, uaKey, authSecret))
+}
+
+func Test_vapidToken(t *testing.T) {
+	key, _ := ecdh.P256().GenerateKey(rand.Reader)
+	config.VapidPrivateKey = base64.RawURLEncoding.EncodeToString(key.Bytes())
+	defer func() { config.VapidPrivateKey = "" }()
+
+	vapid, err := vapidKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := vapidToken(vapid, "https://fcm.googleapis.com/fcm/send/abc", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	assert.Equal(t, 3, len(parts))
+
+	claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	_ = json.Unmarshal(claimsJson, &claims)
+	assert.Equal(t, "https://fcm.googleapis.com", claims["aud"])
+	assert.Equal(t, float64(now.Add(12*time.Hour).Unix()), claims["exp"])
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	publicKey := vapid.Public().(*ecdsa.PublicKey)
+	assert.Equal(t, true, ecdsa.Verify(publicKey, hash[:], r, s))
+	// The public key matches the one derived by ecdh
+	assert.Equal(t, key.PublicKey().Bytes()[1:33], publicKey.X.FillBytes(make([]byte, 32)))
+}
+
+func Test_isPushService(t *testing.T) {
+	assert.Equal(t, true, isPushService("https://fcm.googleapis.com/fcm/send/abc"))
+	assert.Equal(t, true, isPushService("https://web.push.apple.com/QGuQyavXutnMH"))
+	assert.Equal(t, true, isPushService("https://wns2-par02p.notify.windows.com/w/?token=abc"))
+
+	assert.Equal(t, false, isPushService("http://fcm.googleapis.com/fcm/send/abc"))
+	assert.Equal(t, false, isPushService("https://fcm.googleapis.com:8443/fcm/send/abc"))
+	assert.Equal(t, false, isPushService("https://evilpush.apple.com.example.org/"))
+	assert.Equal(t, false, isPushService("https://push.apple.com@169.254.169.254/"))
+	assert.Equal(t, false, isPushService("https://localhost/push"))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/VaiTon/unibocalendar/unibo_integ"
+)
+
+// workerPool runs jobs with a bounded number of goroutines, so background
+// jobs can't saturate the upstream or the process.
+type workerPool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+}
+
+// newWorkerPool starts a pool of the given number of workers. Every worker
+// waits for delay after each job.
+func newWorkerPool(workers int, delay time.Duration) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &workerPool{jobs: make(chan func())}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+				time.Sleep(delay)
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues a job, blocking until a worker is free.
+func (p *workerPool) Submit(job func()) {
+	p.jobs <- job
+}
+
+// Wait stops accepting jobs and waits for the queued ones to finish.
+func (p *workerPool) Wait() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// prefetchCourses runs job for every course, using a pool of
+// [Config.PrefetchWorkers] workers pausing for delay between jobs.
+//
+// The courses are the ones of the snapshot current when the prefetch starts.
+func prefetchCourses(courses *unibo_integ.Courses, delay time.Duration, job func(course *unibo_integ.Course)) {
+	pool := newWorkerPool(config.PrefetchWorkers, delay)
+	for _, course := range courses.Load().ToList() {
+		pool.Submit(func() { job(&course) })
+	}
+	pool.Wait()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_workerPool(t *testing.T) {
+	pool := newWorkerPool(2, 0)
+
+	var running, maxRunning, done atomic.Int32
+	for i := 0; i < 10; i++ {
+		pool.Submit(func() {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 5)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	pool.Wait()
+
+	assert.Equal(t, int32(10), done.Load())
+	assert.Equal(t, true, maxRunning.Load() <= 2)
+}